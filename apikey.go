@@ -0,0 +1,157 @@
+package sqltplainkv
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	apiKeyBuckt string = `--apikey--`
+)
+
+var (
+	ErrUnauthorized error = errors.New(`invalid api key`)
+	ErrForbidden    error = errors.New(`api key not allowed`)
+)
+
+// APIKeyScope describes what an API key may do.
+// An empty bucket list allows every bucket
+type APIKeyScope struct {
+	Read    bool     `json:"read"`
+	Write   bool     `json:"write"`
+	Buckets []string `json:"buckets,omitempty"`
+}
+
+// APIKey is an API key granting access to the store
+// through the built-in servers
+type APIKey struct {
+	ID        string      `json:"id"`
+	Name      string      `json:"name"`
+	Scope     APIKeyScope `json:"scope"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+type apiKeyRecord struct {
+	APIKey
+	Hash string `json:"hash"`
+}
+
+// Allows reports whether the key may access the bucket
+func (s APIKeyScope) Allows(bucket string, write bool) bool {
+	if write && !s.Write || !write && !s.Read {
+		return false
+	}
+	if len(s.Buckets) == 0 {
+		return true
+	}
+	for _, b := range s.Buckets {
+		if b == bucket {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateAPIKey creates an API key with the scope and returns it along
+// with its token. Only a hash of the token is stored, so the token
+// cannot be retrieved again
+func (p *SQLtPlainKV) CreateAPIKey(name string, scope APIKeyScope) (string, APIKey, error) {
+	id := make([]byte, 8)
+	secret := make([]byte, 24)
+	if _, err := rand.Read(id); err != nil {
+		return "", APIKey{}, err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return "", APIKey{}, err
+	}
+	rec := apiKeyRecord{
+		APIKey: APIKey{
+			ID:        hex.EncodeToString(id),
+			Name:      name,
+			Scope:     scope,
+			CreatedAt: time.Now().UTC(),
+		},
+	}
+	token := rec.ID + "." + hex.EncodeToString(secret)
+	rec.Hash = hashAPIToken(token)
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return "", APIKey{}, err
+	}
+	if err = p.set(apiKeyBuckt, rec.ID, b); err != nil {
+		return "", APIKey{}, err
+	}
+	return token, rec.APIKey, nil
+}
+
+// AuthorizeAPIKey checks a token against the stored API keys. It returns
+// ErrUnauthorized if the token is not valid and ErrForbidden if the key
+// does not allow reading, or writing when write is set, the bucket
+func (p *SQLtPlainKV) AuthorizeAPIKey(token, bucket string, write bool) (APIKey, error) {
+	if bucket == "" {
+		bucket = "default"
+	}
+	id, _, ok := strings.Cut(token, ".")
+	if !ok || id == "" {
+		return APIKey{}, ErrUnauthorized
+	}
+	rec, err := p.getAPIKey(id)
+	if err != nil {
+		return APIKey{}, err
+	}
+	if subtle.ConstantTimeCompare([]byte(rec.Hash), []byte(hashAPIToken(token))) != 1 {
+		return APIKey{}, ErrUnauthorized
+	}
+	if !rec.Scope.Allows(bucket, write) {
+		return rec.APIKey, ErrForbidden
+	}
+	return rec.APIKey, nil
+}
+
+// ListAPIKeys lists all API keys
+func (p *SQLtPlainKV) ListAPIKeys() ([]APIKey, error) {
+	keys := make([]APIKey, 0)
+	ids, err := p.listKeys(apiKeyBuckt, "")
+	if err != nil {
+		return keys, err
+	}
+	for _, id := range ids {
+		rec, err := p.getAPIKey(id)
+		if err != nil {
+			return keys, err
+		}
+		keys = append(keys, rec.APIKey)
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey deletes the API key with the id
+func (p *SQLtPlainKV) RevokeAPIKey(id string) error {
+	return p.del(apiKeyBuckt, id)
+}
+
+func (p *SQLtPlainKV) getAPIKey(id string) (apiKeyRecord, error) {
+	var rec apiKeyRecord
+	b, err := p.get(apiKeyBuckt, id)
+	if err != nil {
+		return rec, err
+	}
+	if len(b) == 0 {
+		return rec, ErrUnauthorized
+	}
+	if err = json.Unmarshal(b, &rec); err != nil {
+		return rec, err
+	}
+	return rec, nil
+}
+
+func hashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,47 @@
+package sqltplainkv
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAPIKeys(t *testing.T) {
+	pkv := newTestKV(t)
+
+	token, key, err := pkv.CreateAPIKey(`reader`, APIKeyScope{Read: true, Buckets: []string{`public`}})
+	if err != nil {
+		t.Fatalf(`%s`, err)
+	}
+	t.Logf(`Created key %s with token %s`, key.ID, token)
+
+	if _, err = pkv.AuthorizeAPIKey(token, `public`, false); err != nil {
+		t.Logf(`Expected read access to public, got %v`, err)
+		t.Fail()
+	}
+	if _, err = pkv.AuthorizeAPIKey(token, `public`, true); !errors.Is(err, ErrForbidden) {
+		t.Logf(`Expected ErrForbidden on write, got %v`, err)
+		t.Fail()
+	}
+	if _, err = pkv.AuthorizeAPIKey(token, `private`, false); !errors.Is(err, ErrForbidden) {
+		t.Logf(`Expected ErrForbidden on other bucket, got %v`, err)
+		t.Fail()
+	}
+	if _, err = pkv.AuthorizeAPIKey(key.ID+`.wrong`, `public`, false); !errors.Is(err, ErrUnauthorized) {
+		t.Logf(`Expected ErrUnauthorized, got %v`, err)
+		t.Fail()
+	}
+
+	keys, err := pkv.ListAPIKeys()
+	if err != nil || len(keys) != 1 || keys[0].Name != `reader` {
+		t.Logf(`Expected one key, got %+v (%v)`, keys, err)
+		t.Fail()
+	}
+
+	if err = pkv.RevokeAPIKey(key.ID); err != nil {
+		t.Fatalf(`%s`, err)
+	}
+	if _, err = pkv.AuthorizeAPIKey(token, `public`, false); !errors.Is(err, ErrUnauthorized) {
+		t.Logf(`Expected ErrUnauthorized after revoking, got %v`, err)
+		t.Fail()
+	}
+}
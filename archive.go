@@ -0,0 +1,142 @@
+package sqltplainkv
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"errors"
+	"io"
+	"mime"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ArchiveFormat is the file format of an archive export
+type ArchiveFormat int
+
+const (
+	Tar ArchiveFormat = iota
+	Zip
+)
+
+var (
+	ErrUnknownArchiveFormat error = errors.New(`unknown archive format`)
+
+	// preferred extensions of common types, where the system
+	// table lists several or none
+	mimeExtensions = map[string]string{
+		`text/html`:              `.html`,
+		`text/plain`:             `.txt`,
+		`text/css`:               `.css`,
+		`text/javascript`:        `.js`,
+		`application/javascript`: `.js`,
+		`application/json`:       `.json`,
+		`application/xml`:        `.xml`,
+		`image/jpeg`:             `.jpg`,
+		`image/png`:              `.png`,
+		`image/gif`:              `.gif`,
+		`image/svg+xml`:          `.svg`,
+		`image/webp`:             `.webp`,
+		`application/pdf`:        `.pdf`,
+	}
+)
+
+// ExportArchive writes every record of a bucket as a file of a tar or
+// zip archive, turning the bucket back into a file tree. Keys are used
+// as paths, and keys without an extension get one matching their mime
+func (p *SQLtPlainKV) ExportArchive(bucket string, w io.Writer, format ArchiveFormat) error {
+	var (
+		add   func(name string, data []byte) error
+		close func() error
+	)
+	now := time.Now()
+	switch format {
+	case Tar:
+		tw := tar.NewWriter(w)
+		add = func(name string, data []byte) error {
+			hdr := &tar.Header{
+				Name:    name,
+				Mode:    0o644,
+				Size:    int64(len(data)),
+				ModTime: now,
+			}
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			_, err := tw.Write(data)
+			return err
+		}
+		close = tw.Close
+	case Zip:
+		zw := zip.NewWriter(w)
+		add = func(name string, data []byte) error {
+			fw, err := zw.CreateHeader(&zip.FileHeader{
+				Name:     name,
+				Method:   zip.Deflate,
+				Modified: now,
+			})
+			if err != nil {
+				return err
+			}
+			_, err = fw.Write(data)
+			return err
+		}
+		close = zw.Close
+	default:
+		return ErrUnknownArchiveFormat
+	}
+
+	names := make(map[string]bool)
+	err := p.exportRecords(bucket, func(rec Record) error {
+		name := archiveName(rec.Key, rec.Mime)
+		// keys differing only in characters dropped from paths
+		for i := 2; names[name]; i++ {
+			ext := path.Ext(name)
+			name = strings.TrimSuffix(name, ext) + `-` + strconv.Itoa(i) + ext
+		}
+		names[name] = true
+		return add(name, rec.Value)
+	})
+	if err != nil {
+		return err
+	}
+	return close()
+}
+
+// archiveName turns a key into a relative file path that cannot
+// escape the archive root, adding an extension for its mime
+func archiveName(key, mimeType string) string {
+	parts := strings.Split(key, `/`)
+	clean := parts[:0]
+	for _, part := range parts {
+		if part == `` || part == `.` || part == `..` {
+			continue
+		}
+		clean = append(clean, part)
+	}
+	name := strings.Join(clean, `/`)
+	if name == `` || strings.HasSuffix(key, `/`) {
+		name = path.Join(name, `index`)
+	}
+	if path.Ext(name) == `` && mimeType != `` {
+		name += extensionFor(mimeType)
+	}
+	return name
+}
+
+// extensionFor returns the file extension of a mime type,
+// or an empty string if it has none
+func extensionFor(mimeType string) string {
+	mt, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		return ``
+	}
+	if ext, ok := mimeExtensions[mt]; ok {
+		return ext
+	}
+	if exts, err := mime.ExtensionsByType(mt); err == nil && len(exts) > 0 {
+		return exts[0]
+	}
+	return ``
+}
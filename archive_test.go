@@ -0,0 +1,64 @@
+package sqltplainkv
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestExportArchive(t *testing.T) {
+	pkv := newTestKV(t)
+	pkv.SetBucket(`site`)
+	pkv.Set(`/`, []byte(`<h1>home</h1>`))
+	pkv.SetMime(`/`, `text/html; charset=utf-8`)
+	pkv.Set(`css/site.css`, []byte(`body{}`))
+	pkv.Set(`api/status`, []byte(`{"ok":true}`))
+	pkv.SetMime(`api/status`, `application/json`)
+	pkv.Set(`../escape`, []byte(`kept inside`))
+
+	want := map[string]string{
+		`index.html`:      `<h1>home</h1>`,
+		`css/site.css`:    `body{}`,
+		`api/status.json`: `{"ok":true}`,
+		`escape`:          `kept inside`,
+	}
+
+	var buf bytes.Buffer
+	if err := pkv.ExportArchive(`site`, &buf, Tar); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	got := make(map[string]string)
+	tr := tar.NewReader(&buf)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf(`%v`, err)
+		}
+		data, _ := io.ReadAll(tr)
+		got[hdr.Name] = string(data)
+	}
+	for name, data := range want {
+		if got[name] != data {
+			t.Logf(`Expected tar entry %s to be %q, got %q`, name, data, got[name])
+			t.Fail()
+		}
+	}
+
+	buf.Reset()
+	if err := pkv.ExportArchive(`site`, &buf, Zip); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if len(zr.File) != len(want) {
+		t.Logf(`Expected %d zip entries, got %d`, len(want), len(zr.File))
+		t.Fail()
+	}
+}
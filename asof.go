@@ -0,0 +1,111 @@
+package sqltplainkv
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotVersioned error = errors.New(`bucket is not versioned`)
+)
+
+// GetAsOf returns the value a key of the current bucket held at a past
+// time, from its current value and its versions. It returns
+// ErrKeyNotFound if the key did not exist then, and ErrNotVersioned
+// unless versioning of the bucket is on. Values written before
+// versioning was turned on are only known from the time they were
+// replaced
+func (p *SQLtPlainKV) GetAsOf(key string, at time.Time) ([]byte, error) {
+	var err error
+	if p.currBuckt == "" {
+		p.currBuckt = "default"
+	}
+	if err = p.open(); err != nil {
+		return nil, err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	bucket := p.currBuckt
+	if !p.versioned(bucket, key) {
+		return nil, ErrNotVersioned
+	}
+	meta, err := p.getMeta(bucket, key)
+	if err != nil && !errors.Is(err, ErrKeyNotFound) {
+		return nil, err
+	}
+	if err == nil && !meta.UpdatedAt.After(at) && (meta.ExpiresAt.IsZero() || meta.ExpiresAt.After(at)) {
+		return p.get(bucket, key)
+	}
+	versions, err := p.versions(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range versions {
+		if !v.WrittenAt.After(at) && v.SavedAt.After(at) {
+			return p.get(bucket, versionKeyID(key, v.N))
+		}
+	}
+	return nil, ErrKeyNotFound
+}
+
+// ListKeysAsOf lists the keys of the current bucket starting with the
+// pattern that existed at a past time, in order, as GetAsOf sees them.
+// It returns ErrNotVersioned unless versioning of the bucket is on
+func (p *SQLtPlainKV) ListKeysAsOf(pattern string, at time.Time) ([]string, error) {
+	var err error
+	if p.currBuckt == "" {
+		p.currBuckt = "default"
+	}
+	keys := make([]string, 0)
+	if err = p.open(); err != nil {
+		return keys, err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	bucket := p.currBuckt
+	if opts, ok := p.bucketOpts[bucket]; !ok || !opts.Versioned || isInternalBucket(bucket) {
+		return keys, ErrNotVersioned
+	}
+	// version keys are the prefix, the key length, a colon, the key,
+	// another colon and the version number in 20 digits
+	base := versionKey[:strings.Index(versionKey, `%`)]
+	rest := `substr(KeyID, ` + strconv.Itoa(len(base)+1) + ` + instr(substr(KeyID, ` + strconv.Itoa(len(base)+1) + `), ':'))`
+	ts := at.UnixNano()
+	rows, err := p.conn().Query(p.rebind(`
+	WITH v AS (
+		SELECT substr(`+rest+`, 1, length(`+rest+`) - 21) AS Key, CreatedAt, UpdatedAt FROM `+p.defTableName+`
+		WHERE Bucket=?
+			AND substr(KeyID, 1, ?)=?
+	)
+	SELECT KeyID FROM `+p.defTableName+`
+	WHERE Bucket=?
+		AND KeyID LIKE ?
+		AND KeyID NOT LIKE ? ESCAPE '\'
+		AND (UpdatedAt IS NULL OR UpdatedAt <= ?)
+		AND (ExpiresAt IS NULL OR ExpiresAt > ?) -- live then
+		AND (ExpiresAt IS NULL OR ExpiresAt > ?) -- and still stored
+	UNION
+	SELECT Key FROM v
+	WHERE Key LIKE ?
+		AND (CreatedAt IS NULL OR CreatedAt <= ?)
+		AND UpdatedAt > ?
+	ORDER BY 1;`), bucket, len(base), base,
+		bucket, pattern+"%", likePrefix(internalKeyPrefix), ts, ts, time.Now().UnixNano(),
+		pattern+"%", ts, ts)
+	if err != nil {
+		return keys, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var k string
+		if err = rows.Scan(&k); err != nil {
+			return keys, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
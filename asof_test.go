@@ -0,0 +1,92 @@
+package sqltplainkv
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetAsOf(t *testing.T) {
+	pkv := newTestKV(t)
+	if err := pkv.SetBucketVersioning(`config`, true); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	pkv.SetBucket(`config`)
+	mark := func() time.Time {
+		time.Sleep(time.Millisecond)
+		defer time.Sleep(time.Millisecond)
+		return time.Now()
+	}
+
+	before := mark()
+	pkv.Set(`app`, []byte(`v1`))
+	pkv.Set(`app:extra`, []byte(`extra`))
+	atV1 := mark()
+	pkv.Set(`app`, []byte(`v2`))
+	atV2 := mark()
+	pkv.Del(`app`)
+	deleted := mark()
+	pkv.Set(`app`, []byte(`v3`))
+
+	for at, want := range map[time.Time]string{atV1: `v1`, atV2: `v2`, time.Now(): `v3`} {
+		if v, err := pkv.GetAsOf(`app`, at); err != nil || string(v) != want {
+			t.Logf(`Expected %s, got %q (%v)`, want, v, err)
+			t.Fail()
+		}
+	}
+	for _, at := range []time.Time{before, deleted} {
+		if _, err := pkv.GetAsOf(`app`, at); !errors.Is(err, ErrKeyNotFound) {
+			t.Logf(`Expected ErrKeyNotFound, got %v`, err)
+			t.Fail()
+		}
+	}
+
+	for at, want := range map[time.Time]string{before: ``, atV1: `app app:extra`, deleted: `app:extra`} {
+		keys, err := pkv.ListKeysAsOf(`app`, at)
+		if err != nil || strings.Join(keys, ` `) != want {
+			t.Logf(`Expected keys %q, got %v (%v)`, want, keys, err)
+			t.Fail()
+		}
+	}
+
+	pkv.SetBucket(`default`)
+	if _, err := pkv.GetAsOf(`app`, atV1); !errors.Is(err, ErrNotVersioned) {
+		t.Logf(`Expected ErrNotVersioned, got %v`, err)
+		t.Fail()
+	}
+	if _, err := pkv.ListKeysAsOf(``, atV1); !errors.Is(err, ErrNotVersioned) {
+		t.Logf(`Expected ErrNotVersioned, got %v`, err)
+		t.Fail()
+	}
+}
+
+func TestGetAsOfAfterTouch(t *testing.T) {
+	pkv := newTestKV(t)
+	if err := pkv.SetBucketVersioning(`config`, true); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	pkv.SetBucket(`config`)
+	if err := pkv.SetWithTTL(`app`, []byte(`v1`), time.Hour); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	time.Sleep(time.Millisecond)
+	at := time.Now()
+	time.Sleep(time.Millisecond)
+	if err := pkv.Touch(`app`); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+
+	if v, err := pkv.GetAsOf(`app`, at); err != nil || string(v) != `v1` {
+		t.Logf(`Expected v1, got %q (%v)`, v, err)
+		t.Fail()
+	}
+	if keys, err := pkv.ListKeysAsOf(`app`, at); err != nil || strings.Join(keys, ` `) != `app` {
+		t.Logf(`Expected keys app, got %v (%v)`, keys, err)
+		t.Fail()
+	}
+	if ttl, err := pkv.TTL(`app`); err != nil || ttl < 59*time.Minute || ttl > time.Hour {
+		t.Logf(`Expected a TTL of about an hour, got %v (%v)`, ttl, err)
+		t.Fail()
+	}
+}
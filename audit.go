@@ -0,0 +1,220 @@
+package sqltplainkv
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrAuditOff error = errors.New(`audit log not enabled`)
+)
+
+// auditBatch is the most entries returned by AuditLog at once
+const auditBatch int = 1000
+
+// AuditEntry is an entry of the audit log
+type AuditEntry struct {
+	Seq       int64
+	Principal string // empty if the write had none
+	Type      ChangeType
+	Bucket    string
+	Key       string
+	Size      int64  // size of the value written, zero for deletes
+	Hash      string // hex SHA-256 of the value written, empty for deletes and streamed values
+	At        time.Time
+}
+
+// AuditFilter selects entries of the audit log. Zero fields match
+// every entry
+type AuditFilter struct {
+	Principal string
+	Bucket    string
+	Key       string    // keys starting with it
+	Since     time.Time // entries at or after
+	Until     time.Time // entries before
+	AfterSeq  int64     // entries after the one with this Seq
+	Limit     int       // at most 1000
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a context carrying the principal, such as a
+// user or service name, the audit log attributes writes to
+func WithPrincipal(ctx context.Context, principal string) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFrom returns the principal carried by a context,
+// empty if it carries none
+func PrincipalFrom(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	principal, _ := ctx.Value(principalKey{}).(string)
+	return principal
+}
+
+// SetContext creates or updates the record by the value in the
+// current bucket, attributed in the audit log to the principal the
+// context carries, see WithPrincipal
+func (p *SQLtPlainKV) SetContext(ctx context.Context, key string, value []byte) error {
+	if p.currBuckt == "" {
+		p.currBuckt = "default"
+	}
+	return p.store(p.currBuckt, key, value, time.Time{}, storeOptions{principal: PrincipalFrom(ctx)})
+}
+
+// DelContext deletes a record with the provided key from the current
+// bucket, attributed in the audit log to the principal the context
+// carries, see WithPrincipal
+func (p *SQLtPlainKV) DelContext(ctx context.Context, key string) error {
+	if p.currBuckt == "" {
+		p.currBuckt = "default"
+	}
+	return p.delAs(PrincipalFrom(ctx), p.currBuckt, key)
+}
+
+// EnableAudit records every write and delete of the keys of the store
+// in an append-only table named after the key-value table with an
+// _audit suffix, along with a hash of the value written. Writes made
+// with SetContext and DelContext, and through the REST server with an
+// API key, are attributed to their principal, others to none. Entries
+// are written in the transaction of the change, and a change whose
+// entry cannot be written fails. Internal buckets and hidden keys are
+// not recorded
+func (p *SQLtPlainKV) EnableAudit() error {
+	p.audit = true
+	if p.db == nil || p.schemaReady != p.defTableName {
+		return nil // created along with the schema
+	}
+	return p.ensureAudit()
+}
+
+// WithAudit records the writes of the keys of the store along with
+// who made them, see EnableAudit
+func WithAudit() Option {
+	return func(p *SQLtPlainKV) error {
+		p.audit = true
+		return nil
+	}
+}
+
+func (p *SQLtPlainKV) auditTable() string {
+	return p.defTableName + `_audit`
+}
+
+// ensureAudit creates the audit log table
+func (p *SQLtPlainKV) ensureAudit() error {
+	_, err := p.db.Exec(`CREATE TABLE IF NOT EXISTS ` + p.auditTable() + ` (
+			Seq INTEGER PRIMARY KEY AUTOINCREMENT,
+			Principal VARCHAR(255),
+			Op VARCHAR(10),
+			Bucket VARCHAR(` + strconv.Itoa(p.limits.MaxBucketLen) + `),
+			KeyID VARCHAR(` + strconv.Itoa(p.limits.MaxKeyLen) + `),
+			Size BIGINT,
+			Hash VARCHAR(64),
+			ChangedAt BIGINT
+		);`)
+	return err
+}
+
+// audited reports whether writes of the key are recorded
+func (p *SQLtPlainKV) audited(bucket, key string) bool {
+	return p.audit && !isInternalBucket(bucket) && !strings.HasPrefix(key, internalKeyPrefix)
+}
+
+// recordAudit adds a write of a key to the audit log in the
+// transaction of the write. Streamed values are passed as nil and
+// recorded without a hash
+func (p *SQLtPlainKV) recordAudit(tx *sql.Tx, principal string, typ ChangeType, bucket, key string, value []byte) error {
+	if !p.audited(bucket, key) {
+		return nil
+	}
+	var hash sql.NullString
+	if typ != ChangeDelete && value != nil {
+		sum := sha256.Sum256(value)
+		hash = sql.NullString{String: hex.EncodeToString(sum[:]), Valid: true}
+	}
+	_, err := p.on(tx).Exec(p.rebind(`
+	INSERT INTO `+p.auditTable()+` (Principal, Op, Bucket, KeyID, Size, Hash, ChangedAt)
+	VALUES (?, ?, ?, ?, ?, ?, ?);`), principal, typ.String(), bucket, key, len(value), hash, time.Now().UnixNano())
+	return err
+}
+
+// AuditLog returns the entries of the audit log matching the filter,
+// in order. Pass the Seq of the last entry returned as AfterSeq to
+// read on. It returns ErrAuditOff if no process enabled the audit log
+func (p *SQLtPlainKV) AuditLog(filter AuditFilter) ([]AuditEntry, error) {
+	var err error
+	if err = p.open(); err != nil {
+		return nil, err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	var n int
+	if err = p.conn().QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?;`, p.auditTable()).Scan(&n); err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, ErrAuditOff
+	}
+	where := []string{`Seq > ?`}
+	args := []any{filter.AfterSeq}
+	if filter.Principal != "" {
+		where = append(where, `Principal = ?`)
+		args = append(args, filter.Principal)
+	}
+	if filter.Bucket != "" {
+		where = append(where, `Bucket = ?`)
+		args = append(args, filter.Bucket)
+	}
+	if filter.Key != "" {
+		where = append(where, `KeyID LIKE ? ESCAPE '\'`)
+		args = append(args, likePrefix(filter.Key))
+	}
+	if !filter.Since.IsZero() {
+		where = append(where, `ChangedAt >= ?`)
+		args = append(args, filter.Since.UnixNano())
+	}
+	if !filter.Until.IsZero() {
+		where = append(where, `ChangedAt < ?`)
+		args = append(args, filter.Until.UnixNano())
+	}
+	limit := filter.Limit
+	if limit <= 0 || limit > auditBatch {
+		limit = auditBatch
+	}
+	rows, err := p.conn().Query(p.rebind(`
+	SELECT Seq, Principal, Op, Bucket, KeyID, Size, Hash, ChangedAt FROM `+p.auditTable()+`
+	WHERE `+strings.Join(where, ` AND `)+`
+	ORDER BY Seq
+	LIMIT ?;`), append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	entries := make([]AuditEntry, 0)
+	for rows.Next() {
+		var (
+			e    AuditEntry
+			op   string
+			hash sql.NullString
+			at   int64
+		)
+		if err = rows.Scan(&e.Seq, &e.Principal, &op, &e.Bucket, &e.Key, &e.Size, &hash, &at); err != nil {
+			return nil, err
+		}
+		e.Type = parseChangeType(op)
+		e.Hash = hash.String
+		e.At = time.Unix(0, at)
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,129 @@
+package sqltplainkv
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuditLog(t *testing.T) {
+	pkv := newTestKV(t)
+	if _, err := pkv.AuditLog(AuditFilter{}); !errors.Is(err, ErrAuditOff) {
+		t.Logf(`Expected ErrAuditOff, got %v`, err)
+		t.Fail()
+	}
+	if err := pkv.EnableAudit(); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	start := time.Now()
+
+	alice := WithPrincipal(context.Background(), `alice`)
+	pkv.SetContext(alice, `config`, []byte(`v1`))
+	pkv.SetContext(alice, `config`, []byte(`v2`))
+	pkv.Tally(`visits`, 0)
+	pkv.DelContext(WithPrincipal(context.Background(), `bob`), `config`)
+	pkv.Set(`anonymous`, []byte(`x`))
+
+	entries, err := pkv.AuditLog(AuditFilter{})
+	if err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	want := []struct {
+		principal, key string
+		typ            ChangeType
+	}{
+		{`alice`, `config`, ChangeCreate},
+		{`alice`, `config`, ChangeUpdate},
+		{`bob`, `config`, ChangeDelete},
+		{``, `anonymous`, ChangeCreate},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf(`Expected %d entries, got %+v`, len(want), entries)
+	}
+	for i, w := range want {
+		e := entries[i]
+		if e.Principal != w.principal || e.Key != w.key || e.Type != w.typ || e.Bucket != `default` || e.At.Before(start) {
+			t.Logf(`Expected %+v, got %+v`, w, e)
+			t.Fail()
+		}
+	}
+	if entries[0].Size != 2 || len(entries[0].Hash) != 64 || entries[2].Hash != `` {
+		t.Logf(`Expected sizes and hashes of the values written, got %+v`, entries)
+		t.Fail()
+	}
+
+	if entries, _ = pkv.AuditLog(AuditFilter{Principal: `alice`, AfterSeq: entries[0].Seq}); len(entries) != 1 || entries[0].Type != ChangeUpdate {
+		t.Logf(`Expected the second write of alice, got %+v`, entries)
+		t.Fail()
+	}
+	if entries, _ = pkv.AuditLog(AuditFilter{Key: `anon`, Until: start}); len(entries) != 0 {
+		t.Logf(`Expected no entries before the start, got %+v`, entries)
+		t.Fail()
+	}
+}
+
+func TestWithAudit(t *testing.T) {
+	pkv, err := New(filepath.Join(t.TempDir(), `audit.dat`), WithAudit())
+	if err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	defer pkv.Close()
+	pkv.Set(`k`, []byte(`v`))
+	if entries, err := pkv.AuditLog(AuditFilter{}); err != nil || len(entries) != 1 {
+		t.Logf(`Expected 1 entry, got %+v (%v)`, entries, err)
+		t.Fail()
+	}
+}
+
+func TestAuditRESTPrincipal(t *testing.T) {
+	pkv := newTestKV(t)
+	if err := pkv.EnableAudit(); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	h, err := pkv.RESTHandler()
+	if err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	token, _, err := pkv.CreateAPIKey(`deployer`, APIKeyScope{Read: true, Write: true})
+	if err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	for _, method := range []string{http.MethodPut, http.MethodDelete} {
+		req := httptest.NewRequest(method, `/buckets/default/keys/release`, strings.NewReader(`v1`))
+		req.Header.Set(`Authorization`, `Bearer `+token)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf(`%s: expected 204, got %d %s`, method, rec.Code, rec.Body.String())
+		}
+	}
+	entries, err := pkv.AuditLog(AuditFilter{Principal: `deployer`})
+	if err != nil || len(entries) != 2 || entries[1].Type != ChangeDelete {
+		t.Logf(`Expected the write and delete of the API key, got %+v (%v)`, entries, err)
+		t.Fail()
+	}
+}
+
+func TestAuditFailureFailsTheChange(t *testing.T) {
+	pkv := newTestKV(t)
+	if err := pkv.EnableAudit(); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	pkv.Set(`k`, []byte(`v1`))
+	if _, err := pkv.db.Exec(`DROP TABLE ` + pkv.auditTable() + `;`); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if err := pkv.Set(`k`, []byte(`v2`)); err == nil {
+		t.Logf(`Expected the write to fail without its audit entry`)
+		t.Fail()
+	}
+	if v, _ := pkv.Get(`k`); string(v) != `v1` {
+		t.Logf(`Expected the write to be rolled back, got %q`, v)
+		t.Fail()
+	}
+}
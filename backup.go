@@ -0,0 +1,22 @@
+package sqltplainkv
+
+// BackupTo writes a consistent copy of the database to a new file at
+// path using VACUUM INTO. Writes can continue while the copy is made,
+// and the copy reflects the database as of the moment it started. The
+// file at path must not exist. Values spilled to files are not part
+// of the database and must be copied separately. It cannot be called
+// inside a transaction
+func (p *SQLtPlainKV) BackupTo(path string) error {
+	var err error
+	if p.inTransaction {
+		return ErrInTransaction
+	}
+	if err = p.open(); err != nil {
+		return err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	_, err = p.db.Exec(`VACUUM INTO ?;`, path)
+	return err
+}
@@ -0,0 +1,29 @@
+package sqltplainkv
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestBackupTo(t *testing.T) {
+	pkv := newTestKV(t)
+	pkv.Set(`sample_key`, []byte(`Sample value`))
+
+	path := filepath.Join(t.TempDir(), `backup.db`)
+	if err := pkv.BackupTo(path); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	pkv.Set(`sample_key`, []byte(`Changed after the backup`))
+
+	bak := NewSQLtPlainKV(path, false)
+	defer bak.Close()
+	if val, err := bak.Get(`sample_key`); err != nil || string(val) != `Sample value` {
+		t.Logf(`Expected the value as of the backup, got %s (%v)`, val, err)
+		t.Fail()
+	}
+
+	if err := pkv.BackupTo(path); err == nil {
+		t.Logf(`Expected an error when the backup file exists`)
+		t.Fail()
+	}
+}
@@ -0,0 +1,38 @@
+package sqltplainkv
+
+import (
+	"errors"
+)
+
+var (
+	ErrBarrierBusy error = errors.New(`barrier could not complete, database is busy`)
+)
+
+// Barrier returns once every write acknowledged before the call
+// that touches the bucket is durably stored in the database file.
+//
+// All writes currently go through SQLite synchronously, so the only
+// acknowledged data that may not yet be durable is what sits in the
+// write-ahead log. Barrier therefore runs a full WAL checkpoint, which
+// covers the bucket along with every other one. Writes made inside a
+// transaction that is not yet committed are not covered.
+func (p *SQLtPlainKV) Barrier(bucket string) error {
+	var (
+		err                 error
+		busy, log, chkpoint int
+	)
+	if err = p.open(); err != nil {
+		return err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	err = p.db.QueryRow(`PRAGMA wal_checkpoint(FULL);`).Scan(&busy, &log, &chkpoint)
+	if err != nil {
+		return err
+	}
+	if busy != 0 {
+		return ErrBarrierBusy
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package sqltplainkv
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestBarrier(t *testing.T) {
+	pkv := NewSQLtPlainKV(filepath.Join(t.TempDir(), "test.dat")+"?_pragma=journal_mode(WAL)", false)
+	if err := pkv.Open(); err != nil {
+		t.Fatalf(`%s`, err)
+	}
+	defer pkv.Close()
+
+	pkv.SetBucket(`checkpoints`)
+	if err := pkv.Set(`sample_key`, []byte(`Sample value`)); err != nil {
+		t.Fatalf(`%s`, err)
+	}
+
+	if err := pkv.Barrier(`checkpoints`); err != nil {
+		t.Logf(`%s`, err)
+		t.Fail()
+	}
+}
@@ -0,0 +1,90 @@
+// Package boltimport copies bbolt databases into SQLtPlainKV
+package boltimport
+
+import (
+	"io"
+	"time"
+
+	sqltplainkv "github.com/narsilworks/sqlt-plainkv"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Options controls how a bbolt database is imported
+type Options struct {
+	// Separator joins the names of nested buckets into a single
+	// bucket name. Defaults to "/"
+	Separator string
+	// Conflict decides what happens to keys that already exist
+	Conflict sqltplainkv.ConflictMode
+	// BatchSize is the number of keys written per transaction
+	BatchSize int
+}
+
+// ImportBolt copies every key of the bbolt database at path into the
+// store with the default options and returns the number of keys written
+func ImportBolt(kv *sqltplainkv.SQLtPlainKV, path string) (int, error) {
+	return Import(kv, path, Options{})
+}
+
+// Import copies every key of the bbolt database at path into the
+// store and returns the number of keys written. Each bbolt bucket
+// becomes a bucket of the same name, nested buckets are named after
+// their path joined by the separator. The database is opened read-only
+func Import(kv *sqltplainkv.SQLtPlainKV, path string, opts Options) (int, error) {
+	if opts.Separator == "" {
+		opts.Separator = "/"
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{ReadOnly: true, Timeout: 5 * time.Second})
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	total := 0
+	err = db.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			n, err := importBucket(kv, string(name), b, opts)
+			total += n
+			return err
+		})
+	})
+	return total, err
+}
+
+// importBucket imports the keys of a bucket, then its nested buckets
+func importBucket(kv *sqltplainkv.SQLtPlainKV, name string, b *bolt.Bucket, opts Options) (int, error) {
+	var nested []string
+	c := b.Cursor()
+	k, v := c.First()
+	total, err := kv.ImportRecords(sqltplainkv.ImportOptions{
+		Bucket:    name,
+		Conflict:  opts.Conflict,
+		BatchSize: opts.BatchSize,
+	}, func() (sqltplainkv.Record, error) {
+		for ; k != nil; k, v = c.Next() {
+			if v == nil {
+				nested = append(nested, string(k))
+				continue
+			}
+			// bbolt memory is only valid inside the transaction
+			rec := sqltplainkv.Record{
+				Key:   string(k),
+				Value: append([]byte(nil), v...),
+			}
+			k, v = c.Next()
+			return rec, nil
+		}
+		return sqltplainkv.Record{}, io.EOF
+	})
+	if err != nil {
+		return total, err
+	}
+	for _, sub := range nested {
+		n, err := importBucket(kv, name+opts.Separator+sub, b.Bucket([]byte(sub)), opts)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,51 @@
+package boltimport
+
+import (
+	"path/filepath"
+	"testing"
+
+	sqltplainkv "github.com/narsilworks/sqlt-plainkv"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestImportBolt(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, `source.bolt`)
+	db, err := bolt.Open(path, 0o600, nil)
+	if err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		users, _ := tx.CreateBucket([]byte(`users`))
+		users.Put([]byte(`alice`), []byte(`admin`))
+		users.Put([]byte(`bob`), []byte(`editor`))
+		prefs, _ := users.CreateBucket([]byte(`prefs`))
+		prefs.Put([]byte(`alice`), []byte(`dark`))
+		cfg, _ := tx.CreateBucket([]byte(`config`))
+		cfg.Put([]byte(`version`), []byte(`3`))
+		return nil
+	})
+	db.Close()
+	if err != nil {
+		t.Fatalf(`%v`, err)
+	}
+
+	kv := sqltplainkv.NewSQLtPlainKV(filepath.Join(dir, `target.db`), false)
+	defer kv.Close()
+	n, err := ImportBolt(kv, path)
+	if err != nil || n != 4 {
+		t.Fatalf(`Expected 4 keys imported, got %d (%v)`, n, err)
+	}
+	for _, c := range []struct{ bucket, key, want string }{
+		{`users`, `alice`, `admin`},
+		{`users`, `bob`, `editor`},
+		{`users/prefs`, `alice`, `dark`},
+		{`config`, `version`, `3`},
+	} {
+		kv.SetBucket(c.bucket)
+		if val, err := kv.Get(c.key); err != nil || string(val) != c.want {
+			t.Logf(`Expected %s/%s to be %s, got %s (%v)`, c.bucket, c.key, c.want, val, err)
+			t.Fail()
+		}
+	}
+}
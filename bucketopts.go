@@ -0,0 +1,122 @@
+package sqltplainkv
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	bucketMetaBuckt string = `--bucket--`
+)
+
+// BucketOptions are settings kept per bucket.
+// They are persisted in the database and apply
+// to every instance opening it
+type BucketOptions struct {
+	// Compression and CompressionThreshold replace the settings
+	// of SetCompression for the bucket
+	Compression          Compression `json:"compression"`
+	CompressionThreshold int         `json:"compression_threshold"`
+	// MaxKeys bounds the number of keys of the bucket. Writes beyond
+	// it evict the least recently used keys, see Evict
+	MaxKeys int `json:"max_keys,omitempty"`
+	// MaxBytes and QuotaPolicy set the quota of the bucket,
+	// see SetBucketQuota
+	MaxBytes    int64       `json:"max_bytes,omitempty"`
+	QuotaPolicy QuotaPolicy `json:"quota_policy,omitempty"`
+	// DefaultMime is returned by GetMime for keys of the bucket
+	// without a mime, see SetBucketDefaultMime
+	DefaultMime string `json:"default_mime,omitempty"`
+	// Dedup stores identical values of the bucket once,
+	// see SetBucketDedup
+	Dedup bool `json:"dedup,omitempty"`
+	// Versioned keeps the values replaced by writes as versions,
+	// see SetBucketVersioning
+	Versioned bool `json:"versioned,omitempty"`
+	// TTL is the time to live of the keys of the bucket written
+	// without one, see SetBucketTTL
+	TTL time.Duration `json:"ttl,omitempty"`
+	// Search indexes the text values of the bucket,
+	// see EnableSearch
+	Search bool `json:"search,omitempty"`
+}
+
+// SetBucketOptions stores the options of a bucket
+func (p *SQLtPlainKV) SetBucketOptions(bucket string, opts BucketOptions) error {
+	if bucket == "" {
+		bucket = "default"
+	}
+	b, err := json.Marshal(opts)
+	if err != nil {
+		return err
+	}
+	if err = p.set(bucketMetaBuckt, bucket, b); err != nil {
+		return err
+	}
+	if p.bucketOpts == nil {
+		p.bucketOpts = make(map[string]BucketOptions)
+	}
+	p.bucketOpts[bucket] = opts
+	return nil
+}
+
+// GetBucketOptions retrieves the options of a bucket. The second
+// return value reports whether options were set for the bucket
+func (p *SQLtPlainKV) GetBucketOptions(bucket string) (BucketOptions, bool, error) {
+	if bucket == "" {
+		bucket = "default"
+	}
+	if err := p.open(); err != nil {
+		return BucketOptions{}, false, err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	opts, ok := p.bucketOpts[bucket]
+	return opts, ok, nil
+}
+
+// ClearBucketOptions removes the options of a bucket
+func (p *SQLtPlainKV) ClearBucketOptions(bucket string) error {
+	if bucket == "" {
+		bucket = "default"
+	}
+	if err := p.del(bucketMetaBuckt, bucket); err != nil {
+		return err
+	}
+	delete(p.bucketOpts, bucket)
+	return nil
+}
+
+// loadBucketOptions reads the options of all buckets once.
+// It is called by Open with the database already open
+func (p *SQLtPlainKV) loadBucketOptions() error {
+	if p.bucketOpts != nil {
+		return nil
+	}
+	rows, err := p.db.Query(p.rebind(`SELECT KeyID, Value FROM `+p.defTableName+` WHERE Bucket=?;`), bucketMetaBuckt)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	opts := make(map[string]BucketOptions)
+	for rows.Next() {
+		var (
+			bucket string
+			val    []byte
+			bo     BucketOptions
+		)
+		if err = rows.Scan(&bucket, &val); err != nil {
+			return err
+		}
+		if err = json.Unmarshal(val, &bo); err != nil {
+			return err
+		}
+		opts[bucket] = bo
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	p.bucketOpts = opts
+	return nil
+}
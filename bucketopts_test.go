@@ -0,0 +1,42 @@
+package sqltplainkv
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBucketOptions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.dat")
+	pkv := NewSQLtPlainKV(path, false)
+	defer pkv.Close()
+
+	if err := pkv.SetBucketOptions(`pages`, BucketOptions{Compression: Gzip, CompressionThreshold: 512}); err != nil {
+		t.Fatalf(`%s`, err)
+	}
+
+	page := []byte(strings.Repeat(`<p>Sample paragraph</p>`, 100))
+	for _, bucket := range []string{`pages`, `small`} {
+		pkv.SetBucket(bucket)
+		pkv.Set(`page`, page)
+	}
+
+	pkv.SetBucket(`pages`)
+	if info, _ := pkv.InspectStorage(`page`); info.Compressed != Gzip {
+		t.Logf(`Expected pages to be compressed, got %+v`, info)
+		t.Fail()
+	}
+	pkv.SetBucket(`small`)
+	if info, _ := pkv.InspectStorage(`page`); info.Compressed != NoCompression {
+		t.Logf(`Expected small to be stored as is, got %+v`, info)
+		t.Fail()
+	}
+
+	// the options are persisted
+	other := NewSQLtPlainKV(path, true)
+	opts, ok, err := other.GetBucketOptions(`pages`)
+	if err != nil || !ok || opts.Compression != Gzip || opts.CompressionThreshold != 512 {
+		t.Logf(`Expected persisted options, got %+v %v (%v)`, opts, ok, err)
+		t.Fail()
+	}
+}
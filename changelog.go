@@ -0,0 +1,335 @@
+package sqltplainkv
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrChangeLogOff error = errors.New(`change log not enabled`)
+)
+
+// changesBatch is the most changes returned by ChangesSince at once
+const changesBatch int = 1000
+
+// Change is an entry of the change log
+type Change struct {
+	Seq    int64
+	Type   ChangeType
+	Bucket string
+	Key    string
+	At     time.Time
+}
+
+// EnableChangeLog records every create, update and delete of the
+// keys of the store in an append-only table named after the key-value
+// table with a _changes suffix. Entries are written by triggers, in
+// the same transaction as the change, so they are recorded for every
+// process writing to the database file once any of them enabled the
+// log. Internal buckets and reads are not recorded
+func (p *SQLtPlainKV) EnableChangeLog() error {
+	p.changeLog = true
+	if p.db == nil || p.schemaReady != p.defTableName {
+		return nil // created along with the schema
+	}
+	return p.ensureChangeLog()
+}
+
+// WithChangeLog records the changes of the keys of the store,
+// see EnableChangeLog
+func WithChangeLog() Option {
+	return func(p *SQLtPlainKV) error {
+		p.changeLog = true
+		return nil
+	}
+}
+
+func (p *SQLtPlainKV) changesTable() string {
+	return p.defTableName + `_changes`
+}
+
+// ensureChangeLog creates the change log table and the triggers
+// filling it
+func (p *SQLtPlainKV) ensureChangeLog() error {
+	for _, s := range p.changeLogSchema(p.defTableName) {
+		if _, err := p.db.Exec(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// changeLogSchema returns the statements creating the change log of
+// a key-value table and its triggers
+func (p *SQLtPlainKV) changeLogSchema(table string) []string {
+	changes := table + `_changes`
+	// UnixNano of the current time, SQLite keeps milliseconds
+	now := `CAST((julianday('now') - 2440587.5) * 86400000 AS INTEGER) * 1000000`
+	return []string{
+		`CREATE TABLE IF NOT EXISTS ` + changes + ` (
+			Seq INTEGER PRIMARY KEY AUTOINCREMENT,
+			Bucket VARCHAR(` + strconv.Itoa(p.limits.MaxBucketLen) + `),
+			KeyID VARCHAR(` + strconv.Itoa(p.limits.MaxKeyLen) + `),
+			Op VARCHAR(10),
+			ChangedAt BIGINT
+		);`,
+		`CREATE TRIGGER IF NOT EXISTS ` + table + `_log_insert AFTER INSERT ON ` + table + `
+		WHEN NOT (length(NEW.Bucket) > 4 AND NEW.Bucket LIKE '--%--')
+		BEGIN
+			INSERT INTO ` + changes + ` (Bucket, KeyID, Op, ChangedAt)
+			VALUES (NEW.Bucket, NEW.KeyID, 'create', ` + now + `);
+		END;`,
+		// reads only move AccessedAt, so they leave UpdatedAt alone
+		`CREATE TRIGGER IF NOT EXISTS ` + table + `_log_update AFTER UPDATE ON ` + table + `
+		WHEN NOT (length(NEW.Bucket) > 4 AND NEW.Bucket LIKE '--%--')
+			AND (OLD.Value IS NOT NEW.Value
+				OR OLD.ExpiresAt IS NOT NEW.ExpiresAt
+				OR OLD.UpdatedAt IS NOT NEW.UpdatedAt)
+		BEGIN
+			INSERT INTO ` + changes + ` (Bucket, KeyID, Op, ChangedAt)
+			VALUES (NEW.Bucket, NEW.KeyID, 'update', ` + now + `);
+		END;`,
+		`CREATE TRIGGER IF NOT EXISTS ` + table + `_log_delete AFTER DELETE ON ` + table + `
+		WHEN NOT (length(OLD.Bucket) > 4 AND OLD.Bucket LIKE '--%--')
+		BEGIN
+			INSERT INTO ` + changes + ` (Bucket, KeyID, Op, ChangedAt)
+			VALUES (OLD.Bucket, OLD.KeyID, 'delete', ` + now + `);
+		END;`,
+	}
+}
+
+// ChangesSince returns the entries of the change log after seq, in
+// order, at most 1000 at a time. Pass the Seq of the last entry
+// returned to read on, and zero to read from the start. It returns
+// ErrChangeLogOff if no process enabled the change log
+func (p *SQLtPlainKV) ChangesSince(seq int64) ([]Change, error) {
+	var err error
+	if err = p.open(); err != nil {
+		return nil, err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	if err = p.changeLogReady(); err != nil {
+		return nil, err
+	}
+	rows, err := p.conn().Query(p.rebind(`
+	SELECT Seq, Bucket, KeyID, Op, ChangedAt FROM `+p.changesTable()+`
+	WHERE Seq > ?
+	ORDER BY Seq
+	LIMIT ?;`), seq, changesBatch)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	changes := make([]Change, 0)
+	for rows.Next() {
+		var (
+			c  Change
+			op string
+			at int64
+		)
+		if err = rows.Scan(&c.Seq, &c.Bucket, &c.Key, &op, &at); err != nil {
+			return nil, err
+		}
+		c.Type = parseChangeType(op)
+		c.At = time.Unix(0, at)
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+// changeLogReady returns ErrChangeLogOff unless the change log table
+// exists
+func (p *SQLtPlainKV) changeLogReady() error {
+	var n int
+	if err := p.conn().QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?;`, p.changesTable()).Scan(&n); err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrChangeLogOff
+	}
+	return nil
+}
+
+// TrimChanges removes the entries of the change log up to and
+// including seq, and returns the number removed
+func (p *SQLtPlainKV) TrimChanges(seq int64) (int, error) {
+	var err error
+	if !p.changeLog {
+		return 0, ErrChangeLogOff
+	}
+	if err = p.open(); err != nil {
+		return 0, err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	res, err := p.conn().Exec(p.rebind(`DELETE FROM `+p.changesTable()+` WHERE Seq <= ?;`), seq)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// changesPoll is how often StreamChanges looks for new changes
+// once it has caught up with the change log
+const changesPoll = 250 * time.Millisecond
+
+// StreamChanges passes the changes logged after fromSeq to sink, in
+// order, and keeps polling for new ones until the context is done or
+// sink returns an error, which StreamChanges then returns. Events of
+// creates and updates carry the value of the key as it is when the
+// event is sent, which may be newer than the change, or nil if the key
+// has been deleted since. Use a Cursor to resume where a previous
+// stream stopped
+func (p *SQLtPlainKV) StreamChanges(ctx context.Context, fromSeq int64, sink func(ChangeEvent) error) error {
+	return p.streamChanges(ctx, fromSeq, nil, false, sink)
+}
+
+// streamChanges passes the changes logged after fromSeq that keep
+// selects, or all of them for a nil keep, to sink. With coalesce, only
+// the last change of a key among those read at once is passed on
+func (p *SQLtPlainKV) streamChanges(ctx context.Context, fromSeq int64, keep func(Change) bool, coalesce bool, sink func(ChangeEvent) error) error {
+	seq := fromSeq
+	for {
+		read, err := p.ChangesSince(seq)
+		if err != nil {
+			return err
+		}
+		changes := read
+		if keep != nil || coalesce {
+			changes = selectChanges(read, keep, coalesce)
+		}
+		for _, c := range changes {
+			if err = ctx.Err(); err != nil {
+				return err
+			}
+			ev := ChangeEvent{
+				Seq:    c.Seq,
+				Type:   c.Type,
+				Bucket: c.Bucket,
+				Key:    c.Key,
+			}
+			if c.Type != ChangeDelete {
+				if ev.Value, err = p.get(c.Bucket, c.Key); err != nil {
+					return err
+				}
+				if len(ev.Value) == 0 {
+					ev.Value = nil
+				}
+			}
+			if err = sink(ev); err != nil {
+				return err
+			}
+		}
+		if len(read) > 0 {
+			seq = read[len(read)-1].Seq
+		}
+		if len(read) == changesBatch {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(changesPoll):
+		}
+	}
+}
+
+// StreamChanges streams the changes logged after the position of the
+// cursor and advances it past every change sink accepts, so a new
+// stream resumes after the last change delivered. See
+// SQLtPlainKV.StreamChanges
+func (c *Cursor) StreamChanges(ctx context.Context, sink func(ChangeEvent) error) error {
+	_, seq, err := c.Position()
+	if err != nil {
+		return err
+	}
+	return c.p.StreamChanges(ctx, seq, func(ev ChangeEvent) error {
+		if err := sink(ev); err != nil {
+			return err
+		}
+		return c.Advance(ev.Key, ev.Seq)
+	})
+}
+
+// WatchOptions select the changes reported by WatchFrom
+type WatchOptions struct {
+	Bucket   string // report the keys of this bucket only, of every bucket if empty
+	Prefix   string // report the keys starting with the prefix only
+	Coalesce bool   // report only the last change of a key among those read at once
+}
+
+// matches reports whether the options select the change. Hidden keys
+// are never selected
+func (o WatchOptions) matches(c Change) bool {
+	return (o.Bucket == "" || c.Bucket == o.Bucket) &&
+		strings.HasPrefix(c.Key, o.Prefix) &&
+		!strings.HasPrefix(c.Key, internalKeyPrefix)
+}
+
+// selectChanges returns the changes keep selects, all of them for a
+// nil keep. With coalesce, only the last change of each key is
+// returned, in the order of those last changes
+func selectChanges(changes []Change, keep func(Change) bool, coalesce bool) []Change {
+	last := make(map[[2]string]int64)
+	if coalesce {
+		for _, c := range changes {
+			last[[2]string{c.Bucket, c.Key}] = c.Seq
+		}
+	}
+	selected := make([]Change, 0, len(changes))
+	for _, c := range changes {
+		if keep != nil && !keep(c) {
+			continue
+		}
+		if coalesce && last[[2]string{c.Bucket, c.Key}] != c.Seq {
+			continue
+		}
+		selected = append(selected, c)
+	}
+	return selected
+}
+
+// WatchFrom reports the changes logged after the revision rev, the Seq
+// of the last event received, or every change logged for zero. Unlike
+// Watch it sees the changes made by every process and keeps every
+// event for a receiver falling behind, so a watcher that went away
+// resumes where it stopped by passing the Seq of the last event it
+// handled. Events carry values as StreamChanges does, and hidden keys
+// are not reported. The channel is closed once the watch is cancelled
+// or reading the change log fails, failures are logged as warnings.
+// It returns ErrChangeLogOff if no process enabled the change log
+func (p *SQLtPlainKV) WatchFrom(rev int64, opts WatchOptions) (<-chan ChangeEvent, CancelFunc, error) {
+	if err := p.open(); err != nil {
+		return nil, nil, err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	if err := p.changeLogReady(); err != nil {
+		return nil, nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := make(chan ChangeEvent, watchBuffer)
+	go func() {
+		defer close(ch)
+		err := p.streamChanges(ctx, rev, opts.matches, opts.Coalesce, func(ev ChangeEvent) error {
+			select {
+			case ch <- ev:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		if err != nil && !errors.Is(err, context.Canceled) {
+			p.warn(`watching the change log failed`, `error`, err)
+		}
+	}()
+	return ch, CancelFunc(cancel), nil
+}
@@ -0,0 +1,196 @@
+package sqltplainkv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChangeLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), `test.dat`)
+	writer, err := New(path, WithChangeLog())
+	if err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	defer writer.Close()
+	reader := NewSQLtPlainKV(path, false)
+	defer reader.Close()
+
+	start := time.Now().Add(-time.Second)
+	writer.Set(`a`, []byte(`1`))
+	writer.SetMime(`a`, `text/plain`)
+	writer.Set(`a`, []byte(`2`))
+	writer.SetAccessTracking(true)
+	writer.Get(`a`)
+	writer.Begin()
+	writer.Set(`b`, []byte(`x`))
+	writer.Rollback()
+	writer.Del(`a`)
+
+	changes, err := reader.ChangesSince(0)
+	if err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	var got []string
+	for _, c := range changes {
+		got = append(got, fmt.Sprintf(`%d %s %s/%s`, c.Seq, c.Type, c.Bucket, c.Key))
+		if c.At.Before(start) {
+			t.Logf(`Unexpected change time %v`, c.At)
+			t.Fail()
+		}
+	}
+	want := `1 create default/a,2 update default/a,3 delete default/a`
+	if strings.Join(got, `,`) != want {
+		t.Logf(`Expected %s, got %q`, want, got)
+		t.Fail()
+	}
+
+	if changes, _ = reader.ChangesSince(2); len(changes) != 1 || changes[0].Seq != 3 {
+		t.Logf(`Expected the changes after 2, got %+v`, changes)
+		t.Fail()
+	}
+	if n, err := writer.TrimChanges(2); n != 2 || err != nil {
+		t.Logf(`Expected 2 trimmed changes, got %d, %v`, n, err)
+		t.Fail()
+	}
+	if changes, _ = reader.ChangesSince(0); len(changes) != 1 {
+		t.Logf(`Expected one change left, got %+v`, changes)
+		t.Fail()
+	}
+}
+
+func TestChangeLogOff(t *testing.T) {
+	pkv := newTestKV(t)
+	pkv.Set(`a`, []byte(`1`))
+	if _, err := pkv.ChangesSince(0); !errors.Is(err, ErrChangeLogOff) {
+		t.Logf(`Expected ErrChangeLogOff, got %v`, err)
+		t.Fail()
+	}
+	if err := pkv.EnableChangeLog(); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	pkv.Set(`b`, []byte(`1`))
+	if changes, err := pkv.ChangesSince(0); err != nil || len(changes) != 1 || changes[0].Key != `b` {
+		t.Logf(`Expected the change after enabling, got %+v, %v`, changes, err)
+		t.Fail()
+	}
+}
+
+func TestStreamChanges(t *testing.T) {
+	pkv := newTestKV(t)
+	if err := pkv.EnableChangeLog(); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	pkv.Set(`a`, []byte(`1`))
+	pkv.Set(`b`, []byte(`2`))
+	pkv.Del(`b`)
+
+	var got []string
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	cur := pkv.Cursor(`replica`)
+	errStop := errors.New(`stop`)
+	sink := func(ev ChangeEvent) error {
+		got = append(got, fmt.Sprintf(`%d %s %s=%s`, ev.Seq, ev.Type, ev.Key, ev.Value))
+		if len(got) == 4 {
+			return errStop
+		}
+		if len(got) == 3 {
+			go pkv.Set(`c`, []byte(`3`))
+		}
+		return nil
+	}
+	if err := cur.StreamChanges(ctx, sink); !errors.Is(err, errStop) {
+		t.Fatalf(`Expected the sink error, got %v`, err)
+	}
+	want := `1 create a=1,2 create b=,3 delete b=,4 create c=3`
+	if strings.Join(got, `,`) != want {
+		t.Logf(`Expected %s, got %q`, want, got)
+		t.Fail()
+	}
+	if _, seq, _ := cur.Position(); seq != 3 {
+		t.Logf(`Expected the cursor to stop before the failed change, got %d`, seq)
+		t.Fail()
+	}
+
+	got = nil
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err := cur.StreamChanges(ctx, func(ev ChangeEvent) error {
+		got = append(got, ev.Key)
+		return nil
+	})
+	if !errors.Is(err, context.DeadlineExceeded) || strings.Join(got, `,`) != `c` {
+		t.Logf(`Expected to resume at c until the deadline, got %q, %v`, got, err)
+		t.Fail()
+	}
+}
+
+func TestWatchFrom(t *testing.T) {
+	pkv, err := New(filepath.Join(t.TempDir(), `test.dat`), WithChangeLog())
+	if err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	defer pkv.Close()
+	pkv.SetBucket(`users`)
+	pkv.Set(`user:1`, []byte(`a`))
+	pkv.Set(`user:1`, []byte(`b`))
+	pkv.Set(`other`, []byte(`x`))
+	pkv.Tally(`user:hits`, 1)
+	pkv.SetBucket(`default`)
+	pkv.Set(`user:2`, []byte(`y`))
+	pkv.SetBucket(`users`)
+	pkv.Set(`user:3`, []byte(`c`))
+
+	recv := func(ch <-chan ChangeEvent, n int) []string {
+		var got []string
+		for len(got) < n {
+			select {
+			case ev := <-ch:
+				got = append(got, fmt.Sprintf(`%d %s %s=%s`, ev.Seq, ev.Type, ev.Key, ev.Value))
+			case <-time.After(5 * time.Second):
+				t.Fatalf(`Timed out after %q`, got)
+			}
+		}
+		return got
+	}
+
+	ch, cancel, err := pkv.WatchFrom(0, WatchOptions{Bucket: `users`, Prefix: `user:`, Coalesce: true})
+	if err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	want := `2 update user:1=b,6 create user:3=c`
+	if got := strings.Join(recv(ch, 2), `,`); got != want {
+		t.Logf(`Expected %s, got %s`, want, got)
+		t.Fail()
+	}
+	cancel()
+	if _, ok := <-ch; ok {
+		t.Logf(`Expected the channel to be closed once cancelled`)
+		t.Fail()
+	}
+
+	// resume after the last event received
+	ch, cancel, err = pkv.WatchFrom(2, WatchOptions{Bucket: `users`, Prefix: `user:`})
+	if err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	defer cancel()
+	pkv.Del(`user:1`)
+	want = `6 create user:3=c,7 delete user:1=`
+	if got := strings.Join(recv(ch, 2), `,`); got != want {
+		t.Logf(`Expected %s, got %s`, want, got)
+		t.Fail()
+	}
+
+	plain := NewSQLtPlainKV(filepath.Join(t.TempDir(), `plain.dat`), false)
+	defer plain.Close()
+	if _, _, err := plain.WatchFrom(0, WatchOptions{}); !errors.Is(err, ErrChangeLogOff) {
+		t.Logf(`Expected ErrChangeLogOff, got %v`, err)
+		t.Fail()
+	}
+}
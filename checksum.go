@@ -0,0 +1,117 @@
+package sqltplainkv
+
+import (
+	"database/sql"
+	"errors"
+	"hash/crc32"
+	"time"
+)
+
+var (
+	ErrChecksumMismatch error = errors.New(`checksum mismatch`)
+
+	crcTable = crc32.MakeTable(crc32.Castagnoli)
+)
+
+// Corruption reports a stored value that failed verification
+type Corruption struct {
+	Bucket string
+	Key    string
+	Err    error
+}
+
+// SetChecksums turns checksums on or off for values written from now
+// on. A CRC32 of every stored value is written along with it and
+// checked when it is read. Values written with a checksum are always
+// verified, whatever the current setting is
+func (p *SQLtPlainKV) SetChecksums(enabled bool) {
+	p.checksums = enabled
+}
+
+// WithChecksums writes a checksum along with every stored value
+func WithChecksums() Option {
+	return func(p *SQLtPlainKV) error {
+		p.SetChecksums(true)
+		return nil
+	}
+}
+
+// checksum returns the checksum to store along with a value,
+// or null if checksums are off
+func (p *SQLtPlainKV) checksum(value []byte) sql.NullInt64 {
+	if !p.checksums {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: int64(crc32.Checksum(value, crcTable)), Valid: true}
+}
+
+// verifyChecksum checks a value against the checksum stored with it
+func verifyChecksum(value []byte, sum sql.NullInt64) error {
+	if sum.Valid && int64(crc32.Checksum(value, crcTable)) != sum.Int64 {
+		return ErrChecksumMismatch
+	}
+	return nil
+}
+
+// getRow reads the stored value of a key in the transaction of a write,
+// or on the current connection for a nil transaction, and verifies its
+// checksum. It returns sql.ErrNoRows if the key does not exist or has
+// expired
+func (p *SQLtPlainKV) getRow(tx *sql.Tx, bucket, key string) ([]byte, error) {
+	var (
+		val []byte
+		sum sql.NullInt64
+	)
+	st, err := p.stmtOn(tx, stmtGet)
+	if err != nil {
+		return nil, err
+	}
+	if err = st.QueryRow(bucket, key, time.Now().UnixNano()).Scan(&val, &sum); err != nil {
+		return nil, err
+	}
+	if err = verifyChecksum(val, sum); err != nil {
+		return nil, err
+	}
+	return val, nil
+}
+
+// VerifyAll checks every stored value against its checksum, every
+// spilled value against the hash of its file and every deduplicated
+// value for its blob. It returns the values
+// that failed, an empty list if all are intact
+func (p *SQLtPlainKV) VerifyAll() ([]Corruption, error) {
+	var err error
+	bad := make([]Corruption, 0)
+	if err = p.open(); err != nil {
+		return bad, err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	rows, err := p.conn().Query(`SELECT Bucket, KeyID, Value, Checksum FROM ` + p.defTableName + `;`)
+	if err != nil {
+		return bad, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			c   Corruption
+			val []byte
+			sum sql.NullInt64
+		)
+		if err = rows.Scan(&c.Bucket, &c.Key, &val, &sum); err != nil {
+			return bad, err
+		}
+		c.Err = verifyChecksum(val, sum)
+		if c.Err == nil && isSpilled(val) {
+			_, c.Err = p.readSpilled(val[len(envMagic)+1:])
+		}
+		if c.Err == nil && isDeduped(val) {
+			_, c.Err = p.readBlob(val[len(envMagic)+1:])
+		}
+		if c.Err != nil {
+			bad = append(bad, c)
+		}
+	}
+	return bad, rows.Err()
+}
@@ -0,0 +1,49 @@
+package sqltplainkv
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestChecksums(t *testing.T) {
+	pkv := newTestKV(t)
+	pkv.Set(`unchecked`, []byte(`no checksum`))
+	pkv.SetChecksums(true)
+	pkv.Set(`a`, []byte(`first value`))
+	pkv.Set(`b`, []byte(`second value`))
+
+	if val, err := pkv.Get(`a`); err != nil || string(val) != `first value` {
+		t.Logf(`Expected the value back, got %s (%v)`, val, err)
+		t.Fail()
+	}
+
+	// flip a byte behind the store's back
+	pkv.db.Exec(`UPDATE KeyValueTBL SET Value = ? WHERE KeyID = ?`, []byte(`firxt value`), `a`)
+	pkv.db.Exec(`UPDATE KeyValueTBL SET Value = ? WHERE KeyID = ?`, []byte(`changed`), `unchecked`)
+	if _, err := pkv.Get(`a`); !errors.Is(err, ErrChecksumMismatch) {
+		t.Logf(`Expected ErrChecksumMismatch, got %v`, err)
+		t.Fail()
+	}
+
+	bad, err := pkv.VerifyAll()
+	if err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if len(bad) != 1 || bad[0].Key != `a` || !errors.Is(bad[0].Err, ErrChecksumMismatch) {
+		t.Logf(`Expected only a to be reported, got %v`, bad)
+		t.Fail()
+	}
+
+	// re-encrypted values keep a valid checksum
+	pkv.Del(`a`)
+	pkv.SetEncryptionKey(bytes.Repeat([]byte{1}, 32))
+	pkv.Set(`c`, []byte(`encrypted`))
+	if err = pkv.RotateEncryptionKey(bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 32)); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if bad, _ = pkv.VerifyAll(); len(bad) != 0 {
+		t.Logf(`Expected no corruption after rotation, got %v`, bad)
+		t.Fail()
+	}
+}
@@ -0,0 +1,123 @@
+package sqltplainkv
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"hash/crc32"
+)
+
+var (
+	ErrTargetNotEmpty error = errors.New(`target store is not empty`)
+)
+
+// CloneTo copies every row of the store to the empty database at dsn,
+// creating the table there with the same name and limits. The target
+// can be configured differently, for example with another page size or
+// journal mode set as DSN parameters. Rows are read from a single
+// snapshot and written in transactions of the throttle batch size.
+// They are copied as stored, so encrypted values need the same keys
+// to be read from the copy. Values spilled to files are read from
+// their files and stored in the rows of the copy. The change log, the
+// audit log, queues, search indexes and snapshots are not cloned, nor
+// are settings kept outside the database, such as codecs and keys.
+// It cannot be called inside a transaction
+func (p *SQLtPlainKV) CloneTo(dsn string) error {
+	var err error
+	if p.inTransaction {
+		return ErrInTransaction
+	}
+	if err = p.open(); err != nil {
+		return err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	dst := NewSQLtPlainKV(dsn, false)
+	dst.defTableName = p.defTableName
+	dst.limits = p.limits
+	if err = dst.Open(); err != nil {
+		return err
+	}
+	defer dst.Close()
+	var n int
+	if err = dst.db.QueryRow(`SELECT COUNT(*) FROM ` + dst.defTableName + `;`).Scan(&n); err != nil {
+		return err
+	}
+	if n > 0 {
+		return ErrTargetNotEmpty
+	}
+
+	src, err := p.db.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer src.Rollback()
+	var last int64
+	for {
+		copied, err := p.cloneBatch(src, dst.db, &last)
+		if err != nil {
+			return err
+		}
+		if copied == 0 {
+			return nil
+		}
+		p.pause()
+	}
+}
+
+// cloneBatch copies the next batch of rows after the rowid in last
+// in a single transaction on the target
+func (p *SQLtPlainKV) cloneBatch(src *sql.Tx, dst *sql.DB, last *int64) (int, error) {
+	rows, err := src.Query(`
+	SELECT rowid, Bucket, KeyID, Value, ExpiresAt, Checksum, CreatedAt, UpdatedAt, Hash, Mime, AccessedAt, DeletedAt, ExpirySetAt FROM `+p.defTableName+`
+	WHERE rowid > ?
+	ORDER BY rowid
+	LIMIT ?;`, *last, p.throttle.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	tx, err := dst.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.Prepare(insertColumnsSQL(p.dialect, p.defTableName, dumpColumns))
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+	copied := 0
+	for rows.Next() {
+		var (
+			bucket, key string
+			val         []byte
+			exp, sum    sql.NullInt64
+			crt, upd    sql.NullInt64
+			hash, mime  sql.NullString
+			acc, del    sql.NullInt64
+			ext         sql.NullInt64
+		)
+		if err = rows.Scan(last, &bucket, &key, &val, &exp, &sum, &crt, &upd, &hash, &mime, &acc, &del, &ext); err != nil {
+			return 0, err
+		}
+		// the copy does not share the spillover directory
+		if isSpilled(val) {
+			if val, err = p.readSpilled(val[len(envMagic)+1:]); err != nil {
+				return 0, err
+			}
+			if sum.Valid {
+				sum.Int64 = int64(crc32.Checksum(val, crcTable))
+			}
+		}
+		if _, err = stmt.Exec(bucket, key, val, exp, sum, crt, upd, hash, mime, acc, del, ext); err != nil {
+			return 0, err
+		}
+		copied++
+	}
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+	return copied, tx.Commit()
+}
@@ -0,0 +1,76 @@
+package sqltplainkv
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestCloneTo(t *testing.T) {
+	pkv := newTestKV(t)
+	pkv.SetThrottle(Throttle{BatchSize: 7})
+	pkv.Begin()
+	for i := 0; i < 50; i++ {
+		pkv.Set(fmt.Sprintf(`key%02d`, i), []byte(fmt.Sprintf(`value %d`, i)))
+	}
+	pkv.Commit()
+
+	path := filepath.Join(t.TempDir(), `clone.db`)
+	if err := pkv.CloneTo(path + `?_pragma=page_size(8192)`); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+
+	clone := NewSQLtPlainKV(path, false)
+	defer clone.Close()
+	keys, err := clone.ListKeys(`key`)
+	if err != nil || len(keys) != 50 {
+		t.Logf(`Expected 50 keys in the clone, got %d (%v)`, len(keys), err)
+		t.Fail()
+	}
+	if val, _ := clone.Get(`key42`); string(val) != `value 42` {
+		t.Logf(`Expected the value in the clone, got %s`, val)
+		t.Fail()
+	}
+	var pageSize int
+	clone.db.QueryRow(`PRAGMA page_size;`).Scan(&pageSize)
+	if pageSize != 8192 {
+		t.Logf(`Expected the clone to use its own page size, got %d`, pageSize)
+		t.Fail()
+	}
+
+	if err = pkv.CloneTo(path); !errors.Is(err, ErrTargetNotEmpty) {
+		t.Logf(`Expected ErrTargetNotEmpty, got %v`, err)
+		t.Fail()
+	}
+}
+
+func TestCloneToSpilled(t *testing.T) {
+	pkv := newTestKV(t)
+	pkv.SetChecksums(true)
+	if err := pkv.SetSpillover(filepath.Join(t.TempDir(), `blobs`), 64); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	big := bytes.Repeat([]byte(`spilled `), 32)
+	pkv.Set(`big`, big)
+
+	path := filepath.Join(t.TempDir(), `clone.db`)
+	if err := pkv.CloneTo(path); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	clone := NewSQLtPlainKV(path, false)
+	defer clone.Close()
+	if val, err := clone.Get(`big`); err != nil || !bytes.Equal(val, big) {
+		t.Logf(`Expected the spilled value in the clone, got %d bytes (%v)`, len(val), err)
+		t.Fail()
+	}
+	if info, _ := clone.InspectStorage(`big`); info.Spilled {
+		t.Logf(`Expected the value to be stored in the row of the clone`)
+		t.Fail()
+	}
+	if bad, err := clone.VerifyAll(); len(bad) != 0 || err != nil {
+		t.Logf(`Expected the checksums of the clone to hold, got %+v (%v)`, bad, err)
+		t.Fail()
+	}
+}
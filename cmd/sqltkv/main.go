@@ -0,0 +1,436 @@
+// Command sqltkv inspects and administers SQLtPlainKV data files.
+//
+// Usage:
+//
+//	sqltkv <command> [flags] [arguments]
+//
+// The commands are:
+//
+//	get <key>              write the value of a key to standard output
+//	set <key> [value]      store a value, read from standard input if omitted
+//	del <key>...           delete keys
+//	list [prefix]          list the keys of the bucket
+//	buckets                list the buckets
+//	export                 write the records of the bucket to standard output
+//	import [file]          import records from a file or standard input
+//	stats [bucket]         show the figures of the store or of a bucket
+//	vacuum                 rebuild the data file, returning free space
+//	repl                   run the commands interactively
+//
+// Every command takes --db, the data file (local.dat by default),
+// --bucket, the bucket to work on (default by default), --table, the
+// table of the store (KeyValueTBL by default), --key, the encryption
+// key of the values in hex, and --spill, the directory of values
+// spilled to files. Flags may follow the arguments. Only set and
+// import create the data file, the other commands fail if it does not
+// exist. The commands that only read the data file leave its schema
+// as it is.
+//
+// The repl command reads commands from a prompt with line editing,
+// history and tab completion of commands, buckets and keys. Besides
+// the commands above it takes use <bucket> to switch buckets, and hex
+// and string to switch how values are displayed.
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	sqltplainkv "github.com/narsilworks/sqlt-plainkv"
+)
+
+// options holds the flags of all commands
+type options struct {
+	db       string
+	bucket   string
+	table    string
+	key      string
+	spill    string
+	hex      bool
+	ttl      time.Duration
+	mime     string
+	format   string
+	conflict string
+}
+
+// command is a subcommand of the tool
+type command struct {
+	args  string
+	help  string
+	flags []string // flags besides the ones every command takes
+	run   func(c *cli, opts options, args []string) error
+}
+
+// cli holds the store and the streams the commands work with
+type cli struct {
+	kv     *sqltplainkv.SQLtPlainKV
+	in     io.Reader
+	out    io.Writer
+	errOut io.Writer
+	// interactive is set in the REPL, where values are displayed
+	// rather than written as they are
+	interactive bool
+	hex         bool
+}
+
+var errUsage = errors.New(`usage`)
+
+// readOnly are the commands that only read the data file
+var readOnly = map[string]bool{
+	`get`:     true,
+	`list`:    true,
+	`buckets`: true,
+	`export`:  true,
+	`stats`:   true,
+}
+
+var commands = map[string]command{
+	`get`:     {`<key>`, `write the value of a key to standard output`, []string{`hex`}, runGet},
+	`set`:     {`<key> [value]`, `store a value, read from standard input if omitted`, []string{`ttl`, `mime`}, runSet},
+	`del`:     {`<key>...`, `delete keys`, nil, runDel},
+	`list`:    {`[prefix]`, `list the keys of the bucket`, nil, runList},
+	`buckets`: {``, `list the buckets`, nil, runBuckets},
+	`export`:  {``, `write the records of the bucket to standard output`, []string{`format`}, runExport},
+	`import`:  {`[file]`, `import records from a file or standard input`, []string{`format`, `conflict`}, runImport},
+	`stats`:   {`[bucket]`, `show the figures of the store or of a bucket`, nil, runStats},
+	`vacuum`:  {``, `rebuild the data file, returning free space`, nil, runVacuum},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run runs the command line and returns the exit code
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == `help` || args[0] == `-h` || args[0] == `--help` {
+		usage(stderr)
+		return 2
+	}
+	name := args[0]
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "sqltkv: unknown command %q\n", name)
+		usage(stderr)
+		return 2
+	}
+	fs, opts := newFlagSet(name, cmd)
+	fs.SetOutput(stderr)
+	rest, err := parseArgs(fs, args[1:])
+	if err != nil {
+		return 2
+	}
+
+	// opening a missing data file would create an empty one
+	if name != `set` && name != `import` {
+		if _, err = os.Stat(opts.db); err != nil {
+			fmt.Fprintf(stderr, "sqltkv: %v\n", err)
+			return 1
+		}
+	}
+	so, err := storeOptions(name, opts)
+	if err != nil {
+		fmt.Fprintf(stderr, "sqltkv: %v\n", err)
+		return 2
+	}
+	kv, err := sqltplainkv.New(opts.db, so...)
+	if err != nil {
+		fmt.Fprintf(stderr, "sqltkv: %v\n", err)
+		return 1
+	}
+	defer kv.Close()
+	if err = kv.Open(); err != nil {
+		fmt.Fprintf(stderr, "sqltkv: %v\n", err)
+		return 1
+	}
+	c := &cli{kv: kv, in: stdin, out: stdout, errOut: stderr}
+	if err = cmd.run(c, *opts, rest); err != nil {
+		if errors.Is(err, errUsage) {
+			fmt.Fprintf(stderr, "usage: sqltkv %s %s\n", name, cmd.args)
+			fs.PrintDefaults()
+			return 2
+		}
+		fmt.Fprintf(stderr, "sqltkv %s: %v\n", name, err)
+		return 1
+	}
+	return 0
+}
+
+// storeOptions returns the options of the store the command works on
+func storeOptions(name string, opts *options) ([]sqltplainkv.Option, error) {
+	so := []sqltplainkv.Option{
+		sqltplainkv.WithBucket(opts.bucket),
+		sqltplainkv.WithTableName(opts.table),
+	}
+	if opts.key != "" {
+		key, err := hex.DecodeString(opts.key)
+		if err != nil {
+			return nil, fmt.Errorf(`invalid --key: %w`, err)
+		}
+		so = append(so, sqltplainkv.WithEncryptionKey(key))
+	}
+	if opts.spill != "" {
+		so = append(so, sqltplainkv.WithSpillover(opts.spill, 0))
+	}
+	if readOnly[name] {
+		so = append(so, sqltplainkv.WithoutMigration())
+	}
+	return so, nil
+}
+
+func usage(w io.Writer) {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintf(w, "usage: sqltkv <command> [flags] [arguments]\n\ncommands:\n")
+	for _, name := range names {
+		cmd := commands[name]
+		fmt.Fprintf(w, "  %-22s %s\n", strings.TrimSpace(name+` `+cmd.args), cmd.help)
+	}
+	fmt.Fprintf(w, "\nrun sqltkv <command> -h for the flags of a command\n")
+}
+
+// newFlagSet creates the flag set of a command
+func newFlagSet(name string, cmd command) (*flag.FlagSet, *options) {
+	opts := &options{}
+	fs := flag.NewFlagSet(`sqltkv `+name, flag.ContinueOnError)
+	fs.StringVar(&opts.db, `db`, `local.dat`, `data file`)
+	fs.StringVar(&opts.bucket, `bucket`, `default`, `bucket`)
+	fs.StringVar(&opts.table, `table`, `KeyValueTBL`, `table of the store`)
+	fs.StringVar(&opts.key, `key`, ``, `encryption key of the values, in hex`)
+	fs.StringVar(&opts.spill, `spill`, ``, `directory of values spilled to files`)
+	for _, f := range cmd.flags {
+		switch f {
+		case `hex`:
+			fs.BoolVar(&opts.hex, `hex`, false, `display the value as a hex dump`)
+		case `ttl`:
+			fs.DurationVar(&opts.ttl, `ttl`, 0, `expire the value after the duration`)
+		case `mime`:
+			fs.StringVar(&opts.mime, `mime`, ``, `MIME type of the value`)
+		case `format`:
+			fs.StringVar(&opts.format, `format`, `ndjson`, `record format, ndjson or csv`)
+		case `conflict`:
+			fs.StringVar(&opts.conflict, `conflict`, `overwrite`, `what to do with existing keys: overwrite, skip or fail`)
+		}
+	}
+	return fs, opts
+}
+
+// parseArgs parses the flags of a command wherever they appear
+// and returns the remaining arguments
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var rest []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return rest, nil
+		}
+		rest = append(rest, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+func runGet(c *cli, opts options, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	val, err := c.kv.Get(args[0])
+	if err != nil {
+		return err
+	}
+	if len(val) == 0 {
+		return sqltplainkv.ErrKeyNotFound
+	}
+	return c.writeValue(val, opts.hex)
+}
+
+// writeValue writes a value as it is, or as a hex dump when asked
+// to or in hex mode. Values displayed in the REPL end with a newline
+func (c *cli) writeValue(val []byte, asHex bool) error {
+	if asHex || c.hex {
+		_, err := io.WriteString(c.out, hex.Dump(val))
+		return err
+	}
+	if _, err := c.out.Write(val); err != nil {
+		return err
+	}
+	if c.interactive && val[len(val)-1] != '\n' {
+		_, err := io.WriteString(c.out, "\n")
+		return err
+	}
+	return nil
+}
+
+func runSet(c *cli, opts options, args []string) error {
+	var (
+		val []byte
+		err error
+	)
+	switch len(args) {
+	case 1:
+		if c.interactive {
+			return errUsage
+		}
+		if val, err = io.ReadAll(c.in); err != nil {
+			return err
+		}
+	case 2:
+		val = []byte(args[1])
+	default:
+		return errUsage
+	}
+	if err = c.kv.SetWithTTL(args[0], val, opts.ttl); err != nil {
+		return err
+	}
+	if opts.mime != "" {
+		return c.kv.SetMime(args[0], opts.mime)
+	}
+	return nil
+}
+
+func runDel(c *cli, opts options, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	for _, key := range args {
+		if err := c.kv.Del(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runList(c *cli, opts options, args []string) error {
+	if len(args) > 1 {
+		return errUsage
+	}
+	prefix := ``
+	if len(args) == 1 {
+		prefix = args[0]
+	}
+	keys, err := c.kv.ListKeys(prefix)
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		fmt.Fprintln(c.out, k)
+	}
+	return nil
+}
+
+func runBuckets(c *cli, opts options, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	buckets, err := c.kv.Buckets()
+	if err != nil {
+		return err
+	}
+	for _, b := range buckets {
+		fmt.Fprintln(c.out, b)
+	}
+	return nil
+}
+
+func runExport(c *cli, opts options, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	switch opts.format {
+	case `ndjson`:
+		return c.kv.ExportNDJSON(opts.bucket, c.out)
+	case `csv`:
+		return c.kv.ExportCSV(opts.bucket, c.out)
+	}
+	return fmt.Errorf(`unknown format %q`, opts.format)
+}
+
+func runImport(c *cli, opts options, args []string) error {
+	if len(args) > 1 {
+		return errUsage
+	}
+	in := c.in
+	if c.interactive && len(args) == 0 {
+		return errUsage
+	}
+	if len(args) == 1 && args[0] != `-` {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	iopts := sqltplainkv.ImportOptions{Bucket: opts.bucket}
+	switch opts.conflict {
+	case `overwrite`:
+		iopts.Conflict = sqltplainkv.ConflictOverwrite
+	case `skip`:
+		iopts.Conflict = sqltplainkv.ConflictSkip
+	case `fail`:
+		iopts.Conflict = sqltplainkv.ConflictFail
+	default:
+		return fmt.Errorf(`unknown conflict mode %q`, opts.conflict)
+	}
+	var (
+		n   int
+		err error
+	)
+	switch opts.format {
+	case `ndjson`:
+		n, err = c.kv.ImportNDJSON(in, iopts)
+	case `csv`:
+		n, err = c.kv.ImportCSV(in, iopts)
+	default:
+		return fmt.Errorf(`unknown format %q`, opts.format)
+	}
+	fmt.Fprintf(c.out, "imported %d records\n", n)
+	return err
+}
+
+func runStats(c *cli, opts options, args []string) error {
+	var (
+		st  sqltplainkv.StoreStats
+		err error
+	)
+	switch len(args) {
+	case 0:
+		st, err = c.kv.Stats()
+	case 1:
+		st, err = c.kv.BucketStats(args[0])
+	default:
+		return errUsage
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "buckets     %d\n", st.Buckets)
+	fmt.Fprintf(c.out, "keys        %d\n", st.Keys)
+	fmt.Fprintf(c.out, "value bytes %d\n", st.ValueBytes)
+	fmt.Fprintf(c.out, "avg value   %.1f\n", st.AvgValueSize)
+	fmt.Fprintf(c.out, "dedup saved %d\n", st.DedupSaved)
+	fmt.Fprintf(c.out, "file size   %d\n", st.FileSize)
+	return nil
+}
+
+func runVacuum(c *cli, opts options, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	st, err := c.kv.Compact()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "%d bytes before, %d bytes after\n", st.Before, st.After)
+	return nil
+}
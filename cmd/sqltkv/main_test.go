@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	sqltplainkv "github.com/narsilworks/sqlt-plainkv"
+)
+
+func sqltkv(t *testing.T, stdin string, args ...string) (string, int) {
+	var out, errOut bytes.Buffer
+	code := run(args, strings.NewReader(stdin), &out, &errOut)
+	if code != 0 {
+		t.Logf(`sqltkv %s: %s`, strings.Join(args, ` `), errOut.String())
+	}
+	return out.String(), code
+}
+
+func TestCommands(t *testing.T) {
+	db := filepath.Join(t.TempDir(), `test.dat`)
+
+	for _, tc := range []struct {
+		stdin string
+		args  []string
+		out   string
+		code  int
+	}{
+		{``, []string{`set`, `a`, `1`, `--db`, db}, ``, 0},
+		{`from stdin`, []string{`set`, `--db`, db, `--mime`, `text/plain`, `b`}, ``, 0},
+		{`{"key":"alice","value":"eA==","updated_at":"2026-01-02T03:04:05Z"}`, []string{`import`, `--db`, db, `--bucket`, `users`}, "imported 1 records\n", 0},
+		{``, []string{`get`, `b`, `--db`, db}, `from stdin`, 0},
+		{``, []string{`get`, `missing`, `--db`, db}, ``, 1},
+		{``, []string{`list`, `--db`, db}, "a\nb\n", 0},
+		{``, []string{`buckets`, `--db`, db}, "default\nusers\n", 0},
+		{``, []string{`export`, `--db`, db, `--bucket`, `users`}, `{"key":"alice","value":"eA==","updated_at":"2026-01-02T03:04:05Z"}` + "\n", 0},
+		{``, []string{`set`, `--db`, db, `--bucket`, `users`, `bob`, `y`}, ``, 0},
+		{``, []string{`list`, `--db`, db, `--bucket`, `users`}, "alice\nbob\n", 0},
+		{``, []string{`del`, `a`, `b`, `--db`, db}, ``, 0},
+		{``, []string{`list`, `--db`, db}, ``, 0},
+		{``, []string{`get`, `--db`, db}, ``, 2},
+		{``, []string{`frobnicate`}, ``, 2},
+	} {
+		out, code := sqltkv(t, tc.stdin, tc.args...)
+		if code != tc.code || out != tc.out {
+			t.Logf(`sqltkv %s: expected %d %q, got %d %q`, strings.Join(tc.args, ` `), tc.code, tc.out, code, out)
+			t.Fail()
+		}
+	}
+
+	missing := filepath.Join(t.TempDir(), `missing.dat`)
+	if _, code := sqltkv(t, ``, `list`, `--db`, missing); code != 1 {
+		t.Logf(`Expected list to fail on a missing data file, got %d`, code)
+		t.Fail()
+	}
+	if _, err := os.Stat(missing); !os.IsNotExist(err) {
+		t.Logf(`Expected list not to create the data file, got %v`, err)
+		t.Fail()
+	}
+
+	out, code := sqltkv(t, ``, `stats`, `--db`, db)
+	if code != 0 || !strings.Contains(out, "keys        2\n") {
+		t.Logf(`Unexpected stats %q`, out)
+		t.Fail()
+	}
+	if out, code = sqltkv(t, ``, `vacuum`, `--db`, db); code != 0 || !strings.Contains(out, `bytes after`) {
+		t.Logf(`Unexpected vacuum output %q`, out)
+		t.Fail()
+	}
+
+	// reading a table that does not exist must not create it
+	if _, code := sqltkv(t, ``, `list`, `--db`, db, `--table`, `OtherTBL`); code != 1 {
+		t.Logf(`Expected list to fail on a missing table, got %d`, code)
+		t.Fail()
+	}
+}
+
+func TestStoreFlags(t *testing.T) {
+	db := filepath.Join(t.TempDir(), `test.dat`)
+	spill := filepath.Join(t.TempDir(), `spill`)
+	key := strings.Repeat(`ab`, 32)
+	flags := []string{`--db`, db, `--table`, `SecretTBL`, `--key`, key, `--spill`, spill}
+
+	big := strings.Repeat(`x`, sqltplainkv.DefaultSpillThreshold)
+	if _, code := sqltkv(t, big, append([]string{`set`, `a`}, flags...)...); code != 0 {
+		t.Fatalf(`Expected set to succeed, got %d`, code)
+	}
+	if out, code := sqltkv(t, ``, append([]string{`get`, `a`}, flags...)...); code != 0 || out != big {
+		t.Logf(`Expected the value back, got %d (%d bytes)`, code, len(out))
+		t.Fail()
+	}
+	if files, _ := os.ReadDir(spill); len(files) == 0 {
+		t.Logf(`Expected the value to be spilled to %s`, spill)
+		t.Fail()
+	}
+	if out, code := sqltkv(t, ``, `list`, `--db`, db, `--table`, `SecretTBL`); code != 0 || out != "a\n" {
+		t.Logf(`Expected the key in the table, got %d %q`, code, out)
+		t.Fail()
+	}
+	if _, code := sqltkv(t, ``, `get`, `a`, `--db`, db, `--key`, `zz`); code != 2 {
+		t.Logf(`Expected an invalid key to be a usage error, got %d`, code)
+		t.Fail()
+	}
+}
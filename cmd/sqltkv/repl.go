@@ -0,0 +1,236 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/peterh/liner"
+)
+
+// the REPL runs the other commands, so it is registered once they are
+func init() {
+	commands[`repl`] = command{``, `run the commands interactively`, nil, runREPL}
+}
+
+// replCompletions is the most keys or buckets offered on tab
+const replCompletions = 100
+
+// prompter reads the lines of the REPL
+type prompter interface {
+	Prompt(prompt string) (string, error)
+	AppendHistory(item string)
+}
+
+// repl runs commands read from a prompt
+type repl struct {
+	c      *cli
+	bucket string
+}
+
+func runREPL(c *cli, opts options, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	r := &repl{c: c, bucket: opts.bucket}
+	c.interactive = true
+
+	line := liner.NewLiner()
+	defer line.Close()
+	line.SetCtrlCAborts(true)
+	line.SetWordCompleter(r.complete)
+	history := historyPath()
+	if f, err := os.Open(history); err == nil {
+		line.ReadHistory(f)
+		f.Close()
+	}
+	err := r.loop(line)
+	if f, ferr := os.Create(history); ferr == nil {
+		line.WriteHistory(f)
+		f.Close()
+	}
+	return err
+}
+
+// historyPath returns the file the REPL history is kept in
+func historyPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return `.sqltkv_history`
+	}
+	return filepath.Join(home, `.sqltkv_history`)
+}
+
+func (r *repl) prompt() string {
+	mode := ``
+	if r.c.hex {
+		mode = ` hex`
+	}
+	return `sqltkv:` + r.bucket + mode + `> `
+}
+
+// loop runs the lines read until the input ends or exit is entered
+func (r *repl) loop(p prompter) error {
+	for {
+		text, err := p.Prompt(r.prompt())
+		if errors.Is(err, liner.ErrPromptAborted) {
+			continue
+		}
+		if errors.Is(err, io.EOF) {
+			fmt.Fprintln(r.c.out)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if text = strings.TrimSpace(text); text == `` {
+			continue
+		}
+		p.AppendHistory(text)
+		if !r.exec(text) {
+			return nil
+		}
+	}
+}
+
+// exec runs a line and reports whether the REPL goes on
+func (r *repl) exec(text string) bool {
+	words, err := splitWords(text)
+	if err != nil {
+		fmt.Fprintf(r.c.errOut, "error: %v\n", err)
+		return true
+	}
+	switch name := words[0]; name {
+	case `exit`, `quit`:
+		return false
+	case `help`:
+		r.help()
+	case `use`:
+		if len(words) != 2 {
+			fmt.Fprintln(r.c.errOut, `usage: use <bucket>`)
+			break
+		}
+		r.bucket = words[1]
+	case `hex`, `string`:
+		r.c.hex = name == `hex`
+	case `repl`:
+		fmt.Fprintln(r.c.errOut, `already in the REPL`)
+	default:
+		cmd, ok := commands[name]
+		if !ok {
+			fmt.Fprintf(r.c.errOut, "unknown command %q, try help\n", name)
+			break
+		}
+		fs, opts := newFlagSet(name, cmd)
+		fs.SetOutput(r.c.errOut)
+		opts.bucket = r.bucket
+		args, err := parseArgs(fs, words[1:])
+		if err != nil {
+			break
+		}
+		r.c.kv.SetBucket(opts.bucket)
+		err = cmd.run(r.c, *opts, args)
+		r.c.kv.SetBucket(r.bucket)
+		switch {
+		case errors.Is(err, errUsage):
+			fmt.Fprintf(r.c.errOut, "usage: %s %s\n", name, cmd.args)
+		case err != nil:
+			fmt.Fprintf(r.c.errOut, "error: %v\n", err)
+		}
+	}
+	return true
+}
+
+func (r *repl) help() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		if name != `repl` {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		cmd := commands[name]
+		fmt.Fprintf(r.c.out, "  %-22s %s\n", strings.TrimSpace(name+` `+cmd.args), cmd.help)
+	}
+	fmt.Fprintf(r.c.out, "  %-22s %s\n", `use <bucket>`, `switch to another bucket`)
+	fmt.Fprintf(r.c.out, "  %-22s %s\n", `hex`, `display values as hex dumps`)
+	fmt.Fprintf(r.c.out, "  %-22s %s\n", `string`, `display values as they are`)
+	fmt.Fprintf(r.c.out, "  %-22s %s\n", `exit`, `leave the REPL`)
+}
+
+// complete completes the word before the cursor: commands first,
+// then buckets after use and keys after the other commands
+func (r *repl) complete(line string, pos int) (string, []string, string) {
+	head, tail := line[:pos], line[pos:]
+	start := strings.LastIndexAny(head, " \t") + 1
+	word := head[start:]
+	fields := strings.Fields(head[:start])
+
+	var candidates []string
+	switch {
+	case len(fields) == 0:
+		for name := range commands {
+			candidates = append(candidates, name)
+		}
+		candidates = append(candidates, `use`, `hex`, `string`, `help`, `exit`)
+	case fields[0] == `use` || fields[0] == `stats`:
+		candidates, _ = r.c.kv.Buckets()
+	case fields[0] == `get` || fields[0] == `set` || fields[0] == `del` || fields[0] == `list`:
+		r.c.kv.SetBucket(r.bucket)
+		candidates, _ = r.c.kv.ListKeys(word)
+	}
+
+	completions := make([]string, 0)
+	for _, cand := range candidates {
+		if strings.HasPrefix(cand, word) && !strings.ContainsAny(cand, " \t") {
+			completions = append(completions, cand+` `)
+		}
+	}
+	sort.Strings(completions)
+	if len(completions) > replCompletions {
+		completions = completions[:replCompletions]
+	}
+	return head[:start], completions, tail
+}
+
+// splitWords splits a line into words separated by white space.
+// Words may be quoted with single or double quotes
+func splitWords(s string) ([]string, error) {
+	var (
+		words []string
+		word  strings.Builder
+		quote rune
+		in    bool
+	)
+	for _, c := range s {
+		switch {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			word.WriteRune(c)
+		case c == '"' || c == '\'':
+			quote, in = c, true
+		case c == ' ' || c == '\t':
+			if in {
+				words = append(words, word.String())
+				word.Reset()
+				in = false
+			}
+		default:
+			word.WriteRune(c)
+			in = true
+		}
+	}
+	if quote != 0 {
+		return nil, errors.New(`unterminated quote`)
+	}
+	if in {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
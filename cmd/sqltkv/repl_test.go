@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	sqltplainkv "github.com/narsilworks/sqlt-plainkv"
+)
+
+// scriptPrompter returns the lines of a script, then io.EOF
+type scriptPrompter struct {
+	lines   []string
+	history []string
+}
+
+func (p *scriptPrompter) Prompt(string) (string, error) {
+	if len(p.lines) == 0 {
+		return ``, io.EOF
+	}
+	line := p.lines[0]
+	p.lines = p.lines[1:]
+	return line, nil
+}
+
+func (p *scriptPrompter) AppendHistory(item string) {
+	p.history = append(p.history, item)
+}
+
+func newTestREPL(t *testing.T) (*repl, *bytes.Buffer, *bytes.Buffer) {
+	kv := sqltplainkv.NewSQLtPlainKV(filepath.Join(t.TempDir(), `test.dat`), false)
+	if err := kv.Open(); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	t.Cleanup(func() { kv.Close() })
+	var out, errOut bytes.Buffer
+	c := &cli{kv: kv, out: &out, errOut: &errOut, interactive: true}
+	return &repl{c: c, bucket: `default`}, &out, &errOut
+}
+
+func TestREPL(t *testing.T) {
+	r, out, errOut := newTestREPL(t)
+	p := &scriptPrompter{lines: []string{
+		`set greeting "hello world"`,
+		`get greeting`,
+		`hex`,
+		`get greeting`,
+		`string`,
+		`use users`,
+		`set alice 1`,
+		`list`,
+		`buckets`,
+		`get missing`,
+		`bogus`,
+		``,
+		`exit`,
+		`get greeting`,
+	}}
+	if err := r.loop(p); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	want := "hello world\n" +
+		"00000000  68 65 6c 6c 6f 20 77 6f  72 6c 64                 |hello world|\n" +
+		"alice\n" +
+		"default\nusers\n"
+	if out.String() != want {
+		t.Logf(`Expected %q, got %q`, want, out.String())
+		t.Fail()
+	}
+	if !strings.Contains(errOut.String(), `key not found`) || !strings.Contains(errOut.String(), `unknown command "bogus"`) {
+		t.Logf(`Unexpected errors %q`, errOut.String())
+		t.Fail()
+	}
+	if r.bucket != `users` || len(p.history) != 12 {
+		t.Logf(`Unexpected state %q %v`, r.bucket, p.history)
+		t.Fail()
+	}
+}
+
+func TestREPLComplete(t *testing.T) {
+	r, _, _ := newTestREPL(t)
+	r.c.kv.Set(`apple`, []byte(`1`))
+	r.c.kv.Set(`apricot`, []byte(`2`))
+	r.c.kv.Set(`banana`, []byte(`3`))
+	r.c.kv.SetBucket(`fruits`)
+	r.c.kv.Set(`x`, []byte(`4`))
+
+	for _, tc := range []struct {
+		line string
+		head string
+		want string
+	}{
+		{`ge`, ``, `get `},
+		{`get ap`, `get `, `apple ,apricot `},
+		{`del banana ap`, `del banana `, `apple ,apricot `},
+		{`use f`, `use `, `fruits `},
+		{`get z`, `get `, ``},
+	} {
+		head, got, tail := r.complete(tc.line, len(tc.line))
+		if head != tc.head || strings.Join(got, `,`) != tc.want || tail != `` {
+			t.Logf(`%q: expected %q %q, got %q %q`, tc.line, tc.head, tc.want, head, got)
+			t.Fail()
+		}
+	}
+}
+
+func TestSplitWords(t *testing.T) {
+	words, err := splitWords(`set 'a key' "a value" plain`)
+	if err != nil || strings.Join(words, `|`) != `set|a key|a value|plain` {
+		t.Logf(`Unexpected words %q %v`, words, err)
+		t.Fail()
+	}
+	if _, err = splitWords(`set "open`); err == nil {
+		t.Logf(`Expected an unterminated quote to fail`)
+		t.Fail()
+	}
+}
@@ -0,0 +1,110 @@
+package sqltplainkv
+
+import (
+	"strings"
+)
+
+// Codec transforms values on their way to and from the database.
+// Encode is applied before a value is stored and Decode after it
+// is read, so layers such as compression or encryption can be
+// added without changing the call sites.
+type Codec interface {
+	Encode(value []byte) ([]byte, error)
+	Decode(value []byte) ([]byte, error)
+}
+
+// SetCodec sets the codec applied to values of all buckets
+// that do not have a codec of their own. A nil codec removes it
+func (p *SQLtPlainKV) SetCodec(c Codec) {
+	p.codec = c
+}
+
+// SetBucketCodec sets the codec applied to values of the bucket,
+// replacing the codec set by SetCodec for it. A nil codec removes it
+func (p *SQLtPlainKV) SetBucketCodec(bucket string, c Codec) {
+	if bucket == "" {
+		bucket = "default"
+	}
+	if c == nil {
+		delete(p.bucketCodecs, bucket)
+		return
+	}
+	if p.bucketCodecs == nil {
+		p.bucketCodecs = make(map[string]Codec)
+	}
+	p.bucketCodecs[bucket] = c
+}
+
+// ChainCodecs composes codecs into one. Values are encoded by the
+// codecs in the order given and decoded in the reverse order
+func ChainCodecs(codecs ...Codec) Codec {
+	return codecChain(codecs)
+}
+
+type codecChain []Codec
+
+func (cc codecChain) Encode(value []byte) ([]byte, error) {
+	var err error
+	for _, c := range cc {
+		if value, err = c.Encode(value); err != nil {
+			return nil, err
+		}
+	}
+	return value, nil
+}
+
+func (cc codecChain) Decode(value []byte) ([]byte, error) {
+	var err error
+	for i := len(cc) - 1; i >= 0; i-- {
+		if value, err = cc[i].Decode(value); err != nil {
+			return nil, err
+		}
+	}
+	return value, nil
+}
+
+// codecFor returns the codec of a bucket. Internal buckets
+// such as the mime bucket are never encoded
+func (p *SQLtPlainKV) codecFor(bucket string) Codec {
+	if isInternalBucket(bucket) {
+		return nil
+	}
+	if c, ok := p.bucketCodecs[bucket]; ok {
+		return c
+	}
+	return p.codec
+}
+
+// encodeValue prepares a value for storage by applying the codec
+// of the bucket followed by the built-in transforms of the store
+func (p *SQLtPlainKV) encodeValue(bucket string, value []byte, skip skipped) ([]byte, error) {
+	var err error
+	if isInternalBucket(bucket) || len(value) == 0 {
+		return value, nil
+	}
+	if c := p.codecFor(bucket); c != nil {
+		if value, err = c.Encode(value); err != nil {
+			return nil, err
+		}
+	}
+	return p.wrapValue(bucket, value, skip)
+}
+
+// decodeValue reverses encodeValue on a stored value
+func (p *SQLtPlainKV) decodeValue(bucket string, value []byte) ([]byte, error) {
+	var err error
+	if isInternalBucket(bucket) || len(value) == 0 {
+		return value, nil
+	}
+	if value, err = p.unwrapValue(value); err != nil {
+		return nil, err
+	}
+	if c := p.codecFor(bucket); c != nil {
+		return c.Decode(value)
+	}
+	return value, nil
+}
+
+func isInternalBucket(bucket string) bool {
+	return len(bucket) > 4 && strings.HasPrefix(bucket, "--") && strings.HasSuffix(bucket, "--")
+}
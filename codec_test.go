@@ -0,0 +1,71 @@
+package sqltplainkv
+
+import (
+	"bytes"
+	"testing"
+)
+
+// xorCodec flips the bits of every byte
+type xorCodec byte
+
+func (x xorCodec) Encode(value []byte) ([]byte, error) {
+	out := make([]byte, len(value))
+	for i, b := range value {
+		out[i] = b ^ byte(x)
+	}
+	return out, nil
+}
+
+func (x xorCodec) Decode(value []byte) ([]byte, error) {
+	return x.Encode(value)
+}
+
+// prefixCodec prepends a marker to every value
+type prefixCodec string
+
+func (pc prefixCodec) Encode(value []byte) ([]byte, error) {
+	return append([]byte(pc), value...), nil
+}
+
+func (pc prefixCodec) Decode(value []byte) ([]byte, error) {
+	return bytes.TrimPrefix(value, []byte(pc)), nil
+}
+
+func TestCodec(t *testing.T) {
+	pkv := newTestKV(t)
+
+	pkv.SetCodec(ChainCodecs(prefixCodec(`v1:`), xorCodec(0xFF)))
+	pkv.SetBucketCodec(`plain`, prefixCodec(`p:`))
+
+	if err := pkv.Set(`sample_key`, []byte(`Sample value`)); err != nil {
+		t.Fatalf(`%s`, err)
+	}
+	pkv.SetMime(`sample_key`, `text/plain`)
+
+	b, err := pkv.Get(`sample_key`)
+	if err != nil || string(b) != `Sample value` {
+		t.Logf(`Expected Sample value, got %s (%v)`, b, err)
+		t.Fail()
+	}
+
+	var raw []byte
+	pkv.db.QueryRow(`SELECT Value FROM KeyValueTBL WHERE Bucket='default' AND KeyID='sample_key'`).Scan(&raw)
+	if bytes.Contains(raw, []byte(`Sample`)) {
+		t.Logf(`Expected the stored value to be encoded, got %q`, raw)
+		t.Fail()
+	}
+
+	// the mime bucket is not encoded
+	if mime, _ := pkv.GetMime(`sample_key`); mime != `text/plain` {
+		t.Logf(`Expected text/plain, got %s`, mime)
+		t.Fail()
+	}
+
+	pkv.SetBucket(`plain`)
+	pkv.Set(`sample_key`, []byte(`Plain value`))
+	pkv.db.QueryRow(`SELECT Value FROM KeyValueTBL WHERE Bucket='plain' AND KeyID='sample_key'`).Scan(&raw)
+	if string(raw) != `p:Plain value` {
+		t.Logf(`Expected the bucket codec to be used, got %q`, raw)
+		t.Fail()
+	}
+}
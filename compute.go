@@ -0,0 +1,161 @@
+package sqltplainkv
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// GetOrCompute retrieves a record using a key. If the record does not
+// exist, fn is called to compute the value, which is then stored with
+// the ttl before it is returned. Concurrent callers for the same key
+// share a single call to fn.
+// A ttl of zero or less stores the value with the ttl of the bucket,
+// or without expiration
+func (p *SQLtPlainKV) GetOrCompute(key string, ttl time.Duration, fn func() ([]byte, error)) ([]byte, error) {
+	if p.currBuckt == "" {
+		p.currBuckt = "default"
+	}
+	bucket := p.currBuckt
+	val, err := p.get(bucket, key)
+	if err != nil || len(val) > 0 {
+		return val, err
+	}
+	v, err, _ := p.flight.Do(bucket+"\x00"+key, func() (interface{}, error) {
+		// another caller may have stored it in the meantime
+		val, err := p.get(bucket, key)
+		if err != nil || len(val) > 0 {
+			return val, err
+		}
+		if val, err = fn(); err != nil {
+			return nil, err
+		}
+		var expiry time.Time
+		if ttl > 0 {
+			expiry = time.Now().Add(ttl)
+		}
+		if err = p.setExpiring(bucket, key, val, expiry); err != nil {
+			return nil, err
+		}
+		return val, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// callers sharing the result must not share its backing array
+	return append([]byte(nil), v.([]byte)...), nil
+}
+
+// SetNX stores the value under the key in the current bucket only if
+// the key does not exist or has expired, and reports whether it was
+// stored. The check and the write are a single statement, so of
+// concurrent callers, in this or other processes, only one succeeds.
+// A ttl of zero or less stores the value with the ttl of the bucket,
+// or without expiration.
+// Values larger than the chunk size are not supported
+func (p *SQLtPlainKV) SetNX(key string, value []byte, ttl time.Duration) (bool, error) {
+	if p.currBuckt == "" {
+		p.currBuckt = "default"
+	}
+	var expiry time.Time
+	if ttl > 0 {
+		expiry = time.Now().Add(ttl)
+	}
+	return p.setNX(p.currBuckt, key, value, expiry)
+}
+
+func (p *SQLtPlainKV) setNX(bucket, key string, value []byte, expiry time.Time) (ok bool, err error) {
+	if p.observing() {
+		defer func(start time.Time) {
+			p.observe(opSet, bucket, key, start, err)
+		}(time.Now())
+	}
+	if err = p.open(); err != nil {
+		return false, err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	if len(bucket) > p.limits.MaxBucketLen {
+		return false, ErrBucketIdTooLong
+	}
+	if len(key) > p.limits.MaxKeyLen {
+		return false, ErrKeyTooLong
+	}
+	if len(value) > p.limits.ChunkSize {
+		return false, ErrValueTooLong
+	}
+	if err = p.beforeSet(bucket, key, value); err != nil {
+		return false, err
+	}
+	stored := value
+	if stored, err = p.encodeValue(bucket, stored, skipped{}); err != nil {
+		return false, err
+	}
+	var exp sql.NullInt64
+	if expiry = p.bucketExpiry(bucket, key, expiry); !expiry.IsZero() {
+		exp = sql.NullInt64{Int64: expiry.UnixNano(), Valid: true}
+	}
+	t := p.defTableName
+	// an expired record is replaced as if it did not exist
+	sqlstr := p.rebind(`
+	INSERT INTO ` + t + ` (Bucket, KeyID, Value, ExpiresAt, Checksum, CreatedAt, UpdatedAt, Hash)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT(Bucket, KeyID) DO UPDATE SET
+		Value = excluded.Value,
+		ExpiresAt = excluded.ExpiresAt,
+		Checksum = excluded.Checksum,
+		CreatedAt = excluded.CreatedAt,
+		UpdatedAt = excluded.UpdatedAt,
+		Hash = excluded.Hash,
+		AccessedAt = NULL,
+		Mime = NULL
+	WHERE ` + t + `.ExpiresAt IS NOT NULL AND ` + t + `.ExpiresAt <= excluded.UpdatedAt;`)
+	var evicted []string
+	hash := p.contentHash(bucket, value)
+	err = p.retry(func() error {
+		return p.withTx(func(tx *sql.Tx) error {
+			var err error
+			if evicted, err = p.checkQuota(tx, bucket, key, int64(len(stored))); err != nil {
+				return err
+			}
+			more, err := p.checkDatabaseSize(tx, bucket, key, int64(len(stored)))
+			if err != nil {
+				return err
+			}
+			evicted = append(evicted, more...)
+			now := time.Now().UnixNano()
+			res, err := p.on(tx).Exec(sqlstr, bucket, key, stored, exp, p.checksum(stored), now, now, hash)
+			if err != nil {
+				return err
+			}
+			if n, err := res.RowsAffected(); err != nil || n == 0 {
+				if err == nil {
+					err = ErrKeyExists // rolls back the evictions
+				}
+				return err
+			}
+			// drop the chunks of an expired value that was replaced
+			delChunks, err := p.stmtOn(tx, stmtDelChunks)
+			if err != nil {
+				return err
+			}
+			if _, err = delChunks.Exec(chunkRangeArgs(bucket, key)...); err != nil {
+				return err
+			}
+			return p.recordChange(tx, "", ChangeCreate, bucket, key, value)
+		})
+	})
+	if errors.Is(err, ErrKeyExists) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	p.warmDel(bucket, key)
+	for _, k := range evicted {
+		p.afterDelete(bucket, k)
+	}
+	p.afterSet(bucket, key, value, ChangeCreate)
+	return true, p.enforceMaxKeys(bucket)
+}
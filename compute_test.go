@@ -0,0 +1,63 @@
+package sqltplainkv
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetOrCompute(t *testing.T) {
+	pkv := newTestKV(t)
+
+	var (
+		calls int32
+		wg    sync.WaitGroup
+	)
+	fn := func() ([]byte, error) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(50 * time.Millisecond)
+		return []byte(`computed`), nil
+	}
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b, err := pkv.GetOrCompute(`cached_key`, time.Minute, fn)
+			if err != nil {
+				t.Logf(`%s`, err)
+				t.Fail()
+				return
+			}
+			if string(b) != `computed` {
+				t.Logf(`Expected computed, got %s`, b)
+				t.Fail()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Logf(`Expected fn to be called once, got %d`, n)
+		t.Fail()
+	}
+}
+
+func TestSetWithTTL(t *testing.T) {
+	pkv := newTestKV(t)
+
+	if err := pkv.SetWithTTL(`short_lived`, []byte(`value`), 10*time.Millisecond); err != nil {
+		t.Fatalf(`%s`, err)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	b, err := pkv.Get(`short_lived`)
+	if err != nil {
+		t.Fatalf(`%s`, err)
+	}
+	if len(b) != 0 {
+		t.Logf(`Expected expired value, got %s`, b)
+		t.Fail()
+	}
+}
@@ -0,0 +1,85 @@
+package sqltplainkv
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	ErrNotText    error = errors.New(`value is not valid UTF-8 text`)
+	ErrInvalidCSV error = errors.New(`invalid CSV`)
+
+	csvHeader = []string{`key`, `value`, `mime`, `expires_at`}
+)
+
+// ExportCSV writes the records of a bucket to the writer as CSV with
+// a header row of key, value, mime and expires_at, ordered by key.
+// Expiries are written in RFC 3339 format. It is meant for buckets of
+// text values and returns ErrNotText for a value that is not UTF-8
+func (p *SQLtPlainKV) ExportCSV(bucket string, w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	err := p.exportRecords(bucket, func(rec Record) error {
+		if !utf8.Valid(rec.Value) {
+			return ErrNotText
+		}
+		exp := ""
+		if rec.ExpiresAt != nil {
+			exp = rec.ExpiresAt.Format(time.RFC3339Nano)
+		}
+		return cw.Write([]string{rec.Key, string(rec.Value), rec.Mime, exp})
+	})
+	if err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ImportCSV imports records in the format written by ExportCSV and
+// returns the number of records written. The header row is required,
+// the mime and expires_at columns may be left empty or omitted. It
+// follows the same rules as ImportNDJSON
+func (p *SQLtPlainKV) ImportCSV(r io.Reader, opts ImportOptions) (int, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return 0, ErrInvalidCSV
+	}
+	if len(header) < 2 || len(header) > len(csvHeader) {
+		return 0, ErrInvalidCSV
+	}
+	for i, h := range header {
+		if h != csvHeader[i] {
+			return 0, ErrInvalidCSV
+		}
+	}
+	return p.ImportRecords(opts, func() (Record, error) {
+		var rec Record
+		fields, err := cr.Read()
+		if err != nil {
+			return rec, err
+		}
+		if len(fields) != len(header) {
+			return rec, ErrInvalidCSV
+		}
+		rec.Key, rec.Value = fields[0], []byte(fields[1])
+		if len(fields) > 2 {
+			rec.Mime = fields[2]
+		}
+		if len(fields) > 3 && fields[3] != "" {
+			exp, err := time.Parse(time.RFC3339Nano, fields[3])
+			if err != nil {
+				return rec, err
+			}
+			rec.ExpiresAt = &exp
+		}
+		return rec, nil
+	})
+}
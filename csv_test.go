@@ -0,0 +1,56 @@
+package sqltplainkv
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCSV(t *testing.T) {
+	src := newTestKV(t)
+	src.Set(`greeting`, []byte("Hello, \"world\"\nsecond line"))
+	src.SetMime(`greeting`, `text/plain`)
+	src.SetWithTTL(`temp`, []byte(`short lived`), time.Hour)
+
+	var buf bytes.Buffer
+	if err := src.ExportCSV(`default`, &buf); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if !strings.HasPrefix(buf.String(), "key,value,mime,expires_at\n") {
+		t.Logf(`Expected a header row, got %s`, buf.String())
+		t.Fail()
+	}
+
+	dst := newTestKV(t)
+	n, err := dst.ImportCSV(bytes.NewReader(buf.Bytes()), ImportOptions{})
+	if err != nil || n != 2 {
+		t.Fatalf(`Expected 2 records imported, got %d (%v)`, n, err)
+	}
+	if val, _ := dst.Get(`greeting`); string(val) != "Hello, \"world\"\nsecond line" {
+		t.Logf(`Expected the value to round-trip, got %q`, val)
+		t.Fail()
+	}
+	if info, _ := dst.InspectStorage(`temp`); info.ExpiresAt.IsZero() {
+		t.Logf(`Expected the expiry to round-trip`)
+		t.Fail()
+	}
+
+	// a hand-written sheet may leave out the optional columns
+	n, err = dst.ImportCSV(strings.NewReader("key,value\nedited,new text\n"), ImportOptions{Bucket: `sheet`})
+	if err != nil || n != 1 {
+		t.Logf(`Expected the short sheet to be imported, got %d (%v)`, n, err)
+		t.Fail()
+	}
+	if _, err = dst.ImportCSV(strings.NewReader("name,text\n"), ImportOptions{}); !errors.Is(err, ErrInvalidCSV) {
+		t.Logf(`Expected ErrInvalidCSV, got %v`, err)
+		t.Fail()
+	}
+
+	src.Set(`binary`, []byte{0xff, 0xfe})
+	if err = src.ExportCSV(`default`, &buf); !errors.Is(err, ErrNotText) {
+		t.Logf(`Expected ErrNotText, got %v`, err)
+		t.Fail()
+	}
+}
@@ -0,0 +1,70 @@
+package sqltplainkv
+
+import (
+	"encoding/json"
+)
+
+const (
+	cursorBuckt string = `--cursor--`
+)
+
+// Cursor is a persistent progress marker for a consumer.
+// It records the last processed key and sequence number so that
+// batch processors can resume where they left off after a crash.
+type Cursor struct {
+	p    *SQLtPlainKV
+	name string
+}
+
+type cursorPos struct {
+	Key string `json:"key"`
+	Seq int64  `json:"seq"`
+}
+
+// Cursor returns the named progress cursor.
+// The cursor is created on its first Advance
+func (p *SQLtPlainKV) Cursor(name string) *Cursor {
+	return &Cursor{
+		p:    p,
+		name: name,
+	}
+}
+
+// Name returns the name of the cursor
+func (c *Cursor) Name() string {
+	return c.name
+}
+
+// Position returns the last processed key and sequence.
+// A cursor that was never advanced returns an empty key and zero
+func (c *Cursor) Position() (string, int64, error) {
+	val, err := c.p.get(cursorBuckt, c.name)
+	if err != nil || len(val) == 0 {
+		return "", 0, err
+	}
+	var pos cursorPos
+	if err = json.Unmarshal(val, &pos); err != nil {
+		return "", 0, err
+	}
+	return pos.Key, pos.Seq, nil
+}
+
+// Advance records the key and sequence as the last processed position.
+// Both are written in a single statement, so a crash leaves either the
+// old or the new position. If a transaction is active the advance is
+// part of it, letting consumers commit their work and progress together
+func (c *Cursor) Advance(key string, seq int64) error {
+	val, err := json.Marshal(cursorPos{Key: key, Seq: seq})
+	if err != nil {
+		return err
+	}
+	if err = c.p.set(cursorBuckt, c.name, val); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Reset moves the cursor back to its initial position
+func (c *Cursor) Reset() error {
+	return c.Advance("", 0)
+}
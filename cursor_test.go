@@ -0,0 +1,44 @@
+package sqltplainkv
+
+import (
+	"testing"
+)
+
+func TestCursor(t *testing.T) {
+	pkv := newTestKV(t)
+
+	cur := pkv.Cursor(`consumer`)
+	key, seq, err := cur.Position()
+	if err != nil {
+		t.Fatalf(`%s`, err)
+	}
+	if key != `` || seq != 0 {
+		t.Logf(`Expected initial position, got %s (%d)`, key, seq)
+		t.Fail()
+	}
+
+	pkv.Begin()
+	if err = cur.Advance(`item_10`, 10); err != nil {
+		t.Logf(`%s`, err)
+		t.Fail()
+	}
+	pkv.Rollback()
+
+	if _, seq, _ = cur.Position(); seq != 0 {
+		t.Logf(`Expected rolled back position, got %d`, seq)
+		t.Fail()
+	}
+
+	if err = cur.Advance(`item_20`, 20); err != nil {
+		t.Fatalf(`%s`, err)
+	}
+
+	key, seq, err = pkv.Cursor(`consumer`).Position()
+	if err != nil {
+		t.Fatalf(`%s`, err)
+	}
+	if key != `item_20` || seq != 20 {
+		t.Logf(`Expected item_20 (20), got %s (%d)`, key, seq)
+		t.Fail()
+	}
+}
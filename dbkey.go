@@ -0,0 +1,19 @@
+package sqltplainkv
+
+import (
+	"errors"
+)
+
+var (
+	ErrDatabaseKeyUnsupported error = errors.New(`database keys require building with the sqlcipher tag`)
+)
+
+// SetDatabaseKey sets the key used to open an encrypted database file.
+// Encrypting the whole file requires an SQLCipher driver, selected by
+// building with the sqlcipher tag; without it Open fails with
+// ErrDatabaseKeyUnsupported while a key is set. A raw key can also be
+// given through the DSN, for example `local.dat?_pragma_key=secret`.
+// It takes effect on the next Open
+func (p *SQLtPlainKV) SetDatabaseKey(key []byte) {
+	p.dbKey = append([]byte(nil), key...)
+}
@@ -0,0 +1,20 @@
+//go:build !sqlcipher
+
+package sqltplainkv
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestDatabaseKeyUnsupported(t *testing.T) {
+	pkv := NewSQLtPlainKV(filepath.Join(t.TempDir(), "test.dat"), false)
+	pkv.SetDatabaseKey([]byte(`secret`))
+
+	if err := pkv.Open(); !errors.Is(err, ErrDatabaseKeyUnsupported) {
+		t.Logf(`Expected ErrDatabaseKeyUnsupported, got %v`, err)
+		t.Fail()
+		pkv.Close()
+	}
+}
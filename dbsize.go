@@ -0,0 +1,73 @@
+package sqltplainkv
+
+import (
+	"database/sql"
+	"errors"
+)
+
+var (
+	ErrDatabaseFull error = errors.New(`database size limit reached`)
+)
+
+// SetMaxDatabaseSize caps the size of the database file in bytes.
+// Writes that would need more space than the pages in use plus the
+// free pages SQLite can reuse fail with ErrDatabaseFull, unless the
+// bucket written to has the QuotaEvict policy, in which case its
+// least recently used keys are evicted to make room. Zero removes
+// the cap
+func (p *SQLtPlainKV) SetMaxDatabaseSize(maxBytes int64) {
+	if maxBytes < 0 {
+		maxBytes = 0
+	}
+	p.maxDBSize = maxBytes
+}
+
+// WithMaxDatabaseSize caps the size of the database file in bytes
+func WithMaxDatabaseSize(maxBytes int64) Option {
+	return func(p *SQLtPlainKV) error {
+		p.SetMaxDatabaseSize(maxBytes)
+		return nil
+	}
+}
+
+// checkDatabaseSize makes sure writing size bytes under a key keeps
+// the database within its cap, evicting keys in the transaction of the
+// write if the bucket allows. It returns the keys evicted
+func (p *SQLtPlainKV) checkDatabaseSize(tx *sql.Tx, bucket, key string, size int64) ([]string, error) {
+	if p.maxDBSize <= 0 || isInternalBucket(bucket) {
+		return nil, nil
+	}
+	used, err := p.usedPages(tx)
+	if err != nil {
+		return nil, err
+	}
+	over := used + size - p.maxDBSize
+	if over <= 0 {
+		return nil, nil
+	}
+	if opts, ok := p.bucketOpts[bucket]; ok && opts.QuotaPolicy == QuotaEvict {
+		evicted, err := p.evictBytes(tx, bucket, key, over)
+		if errors.Is(err, ErrQuotaExceeded) {
+			return nil, ErrDatabaseFull
+		}
+		return evicted, err
+	}
+	return nil, ErrDatabaseFull
+}
+
+// usedPages returns the bytes of the database pages in use,
+// leaving out the free pages that new writes reuse
+func (p *SQLtPlainKV) usedPages(tx *sql.Tx) (int64, error) {
+	var pages, free, size int64
+	q := p.on(tx)
+	if err := q.QueryRow(`PRAGMA page_count;`).Scan(&pages); err != nil {
+		return 0, err
+	}
+	if err := q.QueryRow(`PRAGMA freelist_count;`).Scan(&free); err != nil {
+		return 0, err
+	}
+	if err := q.QueryRow(`PRAGMA page_size;`).Scan(&size); err != nil {
+		return 0, err
+	}
+	return (pages - free) * size, nil
+}
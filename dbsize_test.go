@@ -0,0 +1,48 @@
+package sqltplainkv
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestMaxDatabaseSize(t *testing.T) {
+	pkv := newTestKV(t)
+	used, err := pkv.usedPages(nil)
+	if err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	pkv.SetMaxDatabaseSize(used + 64*1024)
+	value := bytes.Repeat([]byte(`x`), 8*1024)
+
+	written := 0
+	for i := 0; i < 20; i++ {
+		if err = pkv.Set(`k`+strconv.Itoa(i), value); err != nil {
+			break
+		}
+		written++
+	}
+	if !errors.Is(err, ErrDatabaseFull) || written == 0 || written > 8 {
+		t.Logf(`Expected ErrDatabaseFull after a few writes, got %v after %d`, err, written)
+		t.Fail()
+	}
+
+	// deleted keys free pages that later writes reuse
+	pkv.Del(`k0`)
+	pkv.Del(`k1`)
+	if err = pkv.Set(`again`, value); err != nil {
+		t.Logf(`Expected freed pages to be reused, got %v`, err)
+		t.Fail()
+	}
+
+	pkv.SetBucketOptions(`cache`, BucketOptions{QuotaPolicy: QuotaEvict})
+	pkv.SetBucket(`cache`)
+	for i := 0; i < 20; i++ {
+		if err = pkv.Set(`c`+strconv.Itoa(i), value[:2048]); err != nil {
+			t.Logf(`Expected the cache bucket to evict instead of failing, got %v at %d`, err, i)
+			t.Fail()
+			break
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package sqltplainkv
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+const (
+	blobBuckt string = `--blob--`
+)
+
+var (
+	ErrDedupBlobNotFound error = errors.New(`deduplicated value not found`)
+)
+
+// SetBucketDedup turns deduplication of the values of a bucket on or
+// off. Values written to a deduplicated bucket are stored once per
+// content in the blob bucket and the table keeps a pointer to them, so
+// keys holding identical values, such as thumbnails or templates,
+// share their storage. Values stored in chunks or spilled to files are
+// not deduplicated. The content is hashed after the codec of the
+// bucket is applied, so the store reveals which keys hold the same
+// value even when values are encrypted. Values already deduplicated
+// stay readable when it is turned off
+func (p *SQLtPlainKV) SetBucketDedup(bucket string, on bool) error {
+	if bucket == "" {
+		bucket = "default"
+	}
+	opts, _, err := p.GetBucketOptions(bucket)
+	if err != nil {
+		return err
+	}
+	opts.Dedup = on
+	return p.SetBucketOptions(bucket, opts)
+}
+
+// dedups reports whether the values of the bucket are deduplicated
+func (p *SQLtPlainKV) dedups(bucket string) bool {
+	opts, ok := p.bucketOpts[bucket]
+	return ok && opts.Dedup && !isInternalBucket(bucket)
+}
+
+// dedupValue encodes a value, stores it in the blob bucket unless a
+// value with the same content is already there and returns the
+// pointer envelope stored in its place, in the transaction of the write
+func (p *SQLtPlainKV) dedupValue(tx *sql.Tx, bucket string, value []byte) ([]byte, error) {
+	var err error
+	if c := p.codecFor(bucket); c != nil {
+		if value, err = c.Encode(value); err != nil {
+			return nil, err
+		}
+	}
+	sum := sha256.Sum256(value)
+	ptr := envelope(envDedup, sum[:])
+	blobKey := hex.EncodeToString(sum[:])
+	var one int
+	err = p.on(tx).QueryRow(p.rebind(`
+	SELECT 1 FROM `+p.defTableName+`
+	WHERE Bucket=?
+		AND KeyID=?;`), blobBuckt, blobKey).Scan(&one)
+	if err == nil {
+		return ptr, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if value, err = p.wrapValue(bucket, value, skipped{}); err != nil {
+		return nil, err
+	}
+	if len(value) > p.limits.MaxValueSize {
+		return nil, ErrValueTooLong
+	}
+	st, err := p.stmtOn(tx, stmtSet)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UnixNano()
+	if _, err = st.Exec(blobBuckt, blobKey, value, nil, p.checksum(value), now, now, nil); err != nil {
+		return nil, err
+	}
+	return ptr, nil
+}
+
+// readBlob reads the stored value a pointer envelope refers to.
+// The value is returned as stored, with its envelopes
+func (p *SQLtPlainKV) readBlob(body []byte) ([]byte, error) {
+	if len(body) != sha256.Size {
+		return nil, ErrCorruptValue
+	}
+	val, err := p.getRow(nil, blobBuckt, hex.EncodeToString(body))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrDedupBlobNotFound
+		}
+		return nil, err
+	}
+	return val, nil
+}
+
+// isDeduped reports whether a stored value points to a shared blob
+func isDeduped(value []byte) bool {
+	return len(value) > len(envMagic) &&
+		bytes.HasPrefix(value, []byte(envMagic)) &&
+		value[len(envMagic)] == envDedup
+}
+
+// dedupPointerSQL matches the rows of table t holding a pointer to a
+// blob, envMagic followed by envDedup, and dedupBlobKeySQL is the key
+// of the blob they point to
+const (
+	dedupPointerSQL = `substr(t.Value, 1, 5) = X'00534B5664'`
+	dedupBlobKeySQL = `lower(hex(substr(t.Value, 6)))`
+)
+
+// dedupStats returns the bytes of the blobs the live values of the
+// rows matching where point to, and the bytes deduplication saved
+// them, both as stored. The where clause refers to the table as t
+func (p *SQLtPlainKV) dedupStats(where string, args ...any) (blobs, saved int64, err error) {
+	t := p.defTableName
+	args = append(args, time.Now().UnixNano(), blobBuckt, blobBuckt)
+	var total int64
+	err = p.conn().QueryRow(p.rebind(`
+	WITH refs AS (
+		SELECT `+dedupBlobKeySQL+` AS BlobKey FROM `+t+` t
+		WHERE `+where+`
+			AND `+dedupPointerSQL+`
+			AND (t.ExpiresAt IS NULL OR t.ExpiresAt > ?)
+	)
+	SELECT
+		COALESCE((SELECT SUM(length(b.Value)) FROM refs r
+			JOIN `+t+` b ON b.Bucket=? AND b.KeyID=r.BlobKey), 0),
+		COALESCE((SELECT SUM(length(b.Value)) FROM `+t+` b
+			WHERE b.Bucket=? AND b.KeyID IN (SELECT BlobKey FROM refs)), 0);`), args...).Scan(&total, &blobs)
+	return blobs, total - blobs, err
+}
+
+// SweepDedup removes the blobs of deduplicated values that no stored
+// value points to anymore and returns their count. Blobs are shared
+// by values with the same content, so they are not removed when a
+// value is deleted or replaced. Versions, deleted keys kept in the
+// trash and records copied for snapshots keep their blobs. Blobs are
+// removed in batches of the throttle batch size, with the throttle
+// pause between batches
+func (p *SQLtPlainKV) SweepDedup() (int, error) {
+	var err error
+	if err = p.open(); err != nil {
+		return 0, err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	t := p.defTableName
+	snapped, err := p.hasSnapshotRows()
+	if err != nil {
+		return 0, err
+	}
+	snapRefs := ``
+	if snapped {
+		snapRefs = `
+			AND KeyID NOT IN (SELECT ` + dedupBlobKeySQL + ` FROM ` + p.snapshotRowsTable() + ` t
+				WHERE ` + dedupPointerSQL + `)`
+	}
+	sqlstr := p.rebind(`
+	DELETE FROM ` + t + `
+	WHERE Bucket=?
+		AND KeyID IN (SELECT KeyID FROM ` + t + `
+			WHERE Bucket=?
+				AND KeyID NOT IN (SELECT ` + dedupBlobKeySQL + ` FROM ` + t + ` t
+					WHERE t.Bucket<>? AND ` + dedupPointerSQL + `)` + snapRefs + `
+			LIMIT ?);`)
+	removed := 0
+	for {
+		var n int64
+		err = p.retry(func() error {
+			res, err := p.conn().Exec(sqlstr, blobBuckt, blobBuckt, blobBuckt, p.throttle.BatchSize)
+			if err != nil {
+				return err
+			}
+			n, err = res.RowsAffected()
+			return err
+		})
+		removed += int(n)
+		if err != nil || int(n) < p.throttle.BatchSize {
+			return removed, err
+		}
+		p.pause()
+	}
+}
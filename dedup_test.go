@@ -0,0 +1,144 @@
+package sqltplainkv
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDedup(t *testing.T) {
+	pkv := newTestKV(t)
+	if err := pkv.SetBucketOptions(`thumbs`, BucketOptions{Compression: Gzip}); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if err := pkv.SetBucketDedup(`thumbs`, true); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	thumb := bytes.Repeat([]byte(`thumbnail `), 50)
+	pkv.SetBucket(`thumbs`)
+	for _, k := range []string{`a`, `b`, `c`} {
+		if err := pkv.Set(k, thumb); err != nil {
+			t.Fatalf(`%v`, err)
+		}
+	}
+	pkv.Set(`other`, []byte(`a different value`))
+
+	for _, k := range []string{`a`, `b`, `c`} {
+		if v, err := pkv.Get(k); err != nil || !bytes.Equal(v, thumb) {
+			t.Logf(`Expected %s to read back, got %q (%v)`, k, v, err)
+			t.Fail()
+		}
+	}
+	if info, _ := pkv.InspectStorage(`a`); !info.Deduped || info.Compressed != Gzip {
+		t.Logf(`Expected a compressed deduplicated value, got %+v`, info)
+		t.Fail()
+	}
+	var blobs int
+	pkv.db.QueryRow(`SELECT COUNT(*) FROM KeyValueTBL WHERE Bucket=?`, blobBuckt).Scan(&blobs)
+	if blobs != 2 {
+		t.Logf(`Expected 2 blobs, got %d`, blobs)
+		t.Fail()
+	}
+
+	st, err := pkv.BucketStats(`thumbs`)
+	if err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	var blobSize int64
+	pkv.db.QueryRow(`SELECT length(Value) FROM KeyValueTBL WHERE Bucket=? ORDER BY length(Value) DESC LIMIT 1`, blobBuckt).Scan(&blobSize)
+	if st.DedupSaved != 2*blobSize {
+		t.Logf(`Expected %d bytes saved, got %+v`, 2*blobSize, st)
+		t.Fail()
+	}
+	if all, _ := pkv.Stats(); all.DedupSaved != st.DedupSaved {
+		t.Logf(`Expected the store to report the same savings, got %+v`, all)
+		t.Fail()
+	}
+
+	// blobs outlive their values until swept
+	pkv.Del(`other`)
+	pkv.Set(`a`, []byte(`replaced`))
+	if n, err := pkv.SweepDedup(); n != 1 || err != nil {
+		t.Logf(`Expected 1 blob swept, got %d (%v)`, n, err)
+		t.Fail()
+	}
+	if v, _ := pkv.Get(`b`); !bytes.Equal(v, thumb) {
+		t.Logf(`Expected the shared blob to be kept, got %q`, v)
+		t.Fail()
+	}
+	if bad, err := pkv.VerifyAll(); len(bad) != 0 || err != nil {
+		t.Logf(`Expected no corruption, got %+v (%v)`, bad, err)
+		t.Fail()
+	}
+
+	// other buckets are stored as before
+	pkv.SetBucket(`default`)
+	pkv.Set(`a`, thumb)
+	if info, _ := pkv.InspectStorage(`a`); info.Deduped {
+		t.Logf(`Expected the default bucket not to be deduplicated`)
+		t.Fail()
+	}
+}
+
+func TestSweepDedupKeepsReferences(t *testing.T) {
+	pkv := newTestKV(t)
+	if err := pkv.SetBucketDedup(`thumbs`, true); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if err := pkv.SetBucketVersioning(`thumbs`, true); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	pkv.SetBucket(`thumbs`)
+	pkv.Set(`versioned`, []byte(`first thumbnail`))
+	pkv.Set(`versioned`, []byte(`second thumbnail`))
+
+	// deleting a versioned key keeps a version, snapshots are checked
+	// in a bucket without versions
+	if err := pkv.SetBucketDedup(`avatars`, true); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	pkv.SetBucket(`avatars`)
+	pkv.Set(`snapped`, []byte(`snapshotted avatar`))
+	if err := pkv.Snapshot(`before`); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	pkv.Del(`snapped`)
+
+	if n, err := pkv.SweepDedup(); n != 0 || err != nil {
+		t.Logf(`Expected the blobs of versions and snapshots to be kept, swept %d (%v)`, n, err)
+		t.Fail()
+	}
+	pkv.SetBucket(`thumbs`)
+	if v, err := pkv.GetVersion(`versioned`, 1); err != nil || string(v) != `first thumbnail` {
+		t.Logf(`Expected the version to read back, got %q (%v)`, v, err)
+		t.Fail()
+	}
+
+	pkv.SetBucket(`avatars`)
+	if err := pkv.DelSnapshot(`before`); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if n, err := pkv.SweepDedup(); n != 1 || err != nil {
+		t.Logf(`Expected the blob of the removed snapshot to be swept, got %d (%v)`, n, err)
+		t.Fail()
+	}
+}
+
+func TestSweepDedupInBatches(t *testing.T) {
+	pkv := newTestKV(t)
+	pkv.SetThrottle(Throttle{BatchSize: 2})
+	if err := pkv.SetBucketDedup(`thumbs`, true); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	pkv.SetBucket(`thumbs`)
+	for i := 0; i < 5; i++ {
+		pkv.Set(`a`, bytes.Repeat([]byte(`thumbnail `), 50+i))
+	}
+	if n, err := pkv.SweepDedup(); n != 4 || err != nil {
+		t.Logf(`Expected 4 blobs swept, got %d (%v)`, n, err)
+		t.Fail()
+	}
+	if v, _ := pkv.Get(`a`); !bytes.Equal(v, bytes.Repeat([]byte(`thumbnail `), 54)) {
+		t.Logf(`Expected the blob in use to be kept, got %q`, v)
+		t.Fail()
+	}
+}
@@ -0,0 +1,122 @@
+package sqltplainkv
+
+import "strings"
+
+// Dialect holds the parts of the SQL syntax that differ between
+// database engines, so backends for other engines can share the
+// statements of the store. Maintenance features built on SQLite
+// pragmas, VACUUM or rowid stay specific to SQLite
+type Dialect interface {
+	// Placeholder returns the bind parameter at position n, counting from 1
+	Placeholder(n int) string
+	// BlobType returns the column type holding values of up to maxSize bytes
+	BlobType(maxSize int) string
+	// Upsert returns the statement inserting a row of the columns,
+	// setting the update columns instead when a row with the same
+	// key columns exists
+	Upsert(table string, cols, key, update []string) string
+}
+
+// SQLite is the dialect of SQLite, used unless SetDialect is called
+var SQLite Dialect = sqliteDialect{}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Placeholder(int) string {
+	return `?`
+}
+
+func (sqliteDialect) BlobType(maxSize int) string {
+	if maxSize > DefaultLimits.MaxValueSize {
+		return `LONGBLOB`
+	}
+	return `MEDIUMBLOB`
+}
+
+func (d sqliteDialect) Upsert(table string, cols, key, update []string) string {
+	sets := make([]string, len(update))
+	for i, c := range update {
+		sets[i] = c + `=excluded.` + c
+	}
+	return `
+	INSERT INTO ` + table + ` (` + strings.Join(cols, `, `) + `) VALUES (` + placeholders(d, len(cols)) + `)
+	ON CONFLICT(` + strings.Join(key, `,`) + `) DO UPDATE SET ` + strings.Join(sets, `, `) + `;`
+}
+
+// SetDialect sets the SQL dialect of the statements of the store.
+// It is meant for backends wrapping the store with a driver of
+// another engine and must be called before the store is opened
+func (p *SQLtPlainKV) SetDialect(d Dialect) {
+	if d == nil {
+		d = SQLite
+	}
+	p.dialect = d
+}
+
+// WithDialect sets the SQL dialect of the statements of the store
+func WithDialect(d Dialect) Option {
+	return func(p *SQLtPlainKV) error {
+		p.SetDialect(d)
+		return nil
+	}
+}
+
+// placeholders returns the bind parameters 1 to n separated by commas
+func placeholders(d Dialect, n int) string {
+	ps := make([]string, n)
+	for i := range ps {
+		ps[i] = d.Placeholder(i + 1)
+	}
+	return strings.Join(ps, `, `)
+}
+
+// rebind replaces the ? bind parameters of a query
+// with the placeholders of the dialect
+func rebind(d Dialect, query string) string {
+	if d.Placeholder(1) == `?` {
+		return query
+	}
+	var (
+		sb strings.Builder
+		n  int
+	)
+	for _, part := range strings.SplitAfter(query, `?`) {
+		if strings.HasSuffix(part, `?`) {
+			n++
+			sb.WriteString(part[:len(part)-1])
+			sb.WriteString(d.Placeholder(n))
+			continue
+		}
+		sb.WriteString(part)
+	}
+	return sb.String()
+}
+
+// rebind returns the query with the placeholders of the store dialect
+func (p *SQLtPlainKV) rebind(query string) string {
+	return rebind(p.dialect, query)
+}
+
+// valueColumns are the columns written for every record
+var valueColumns = []string{`Bucket`, `KeyID`, `Value`, `ExpiresAt`, `Checksum`, `CreatedAt`, `UpdatedAt`, `Hash`}
+
+// upsertSQL returns the statement writing a record of the table.
+// CreatedAt keeps the time the record was first written
+func upsertSQL(d Dialect, table string) string {
+	return d.Upsert(table, valueColumns, valueColumns[:2], []string{`Value`, `ExpiresAt`, `Checksum`, `UpdatedAt`, `Hash`})
+}
+
+// recordColumns are the columns copied with every record. The mime
+// is set apart from the value, so writing a value leaves it alone
+var recordColumns = append(valueColumns[:len(valueColumns):len(valueColumns)], `Mime`)
+
+// insertSQL returns the statement inserting a record into the table
+func insertSQL(d Dialect, table string) string {
+	return insertColumnsSQL(d, table, recordColumns)
+}
+
+// insertColumnsSQL returns the statement inserting the columns of a
+// record into the table
+func insertColumnsSQL(d Dialect, table string, cols []string) string {
+	return `INSERT INTO ` + table + ` (` + strings.Join(cols, `, `) + `) VALUES (` + placeholders(d, len(cols)) + `);`
+}
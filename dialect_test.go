@@ -0,0 +1,58 @@
+package sqltplainkv
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// numberedDialect is SQLite with numbered ?NNN bind parameters,
+// standing in for engines with numbered placeholders
+type numberedDialect struct{ sqliteDialect }
+
+func (numberedDialect) Placeholder(n int) string {
+	return `?` + strconv.Itoa(n)
+}
+
+func (d numberedDialect) Upsert(table string, cols, key, update []string) string {
+	return strings.Replace(d.sqliteDialect.Upsert(table, cols, key, update),
+		placeholders(d.sqliteDialect, len(cols)), placeholders(d, len(cols)), 1)
+}
+
+func TestRebind(t *testing.T) {
+	got := rebind(numberedDialect{}, `SELECT 1 WHERE a=? AND b IN (?, ?);`)
+	if want := `SELECT 1 WHERE a=?1 AND b IN (?2, ?3);`; got != want {
+		t.Logf(`Expected %s, got %s`, want, got)
+		t.Fail()
+	}
+	if q := `SELECT ?;`; rebind(SQLite, q) != q {
+		t.Logf(`Expected the SQLite dialect to keep the query`)
+		t.Fail()
+	}
+}
+
+func TestDialect(t *testing.T) {
+	kv, err := New(filepath.Join(t.TempDir(), `dialect.db`), WithDialect(numberedDialect{}))
+	if err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	defer kv.Close()
+	if err = kv.Set(`alpha`, []byte(`one`)); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	kv.Set(`alpha`, []byte(`two`))
+	kv.SetMime(`alpha`, `text/plain`)
+	if val, err := kv.Get(`alpha`); err != nil || string(val) != `two` {
+		t.Logf(`Expected two, got %s (%v)`, val, err)
+		t.Fail()
+	}
+	if keys, _ := kv.ListKeys(`al`); len(keys) != 1 {
+		t.Logf(`Expected 1 key, got %v`, keys)
+		t.Fail()
+	}
+	if n, _ := kv.DelWhere(`key like "al%"`); n != 1 {
+		t.Logf(`Expected 1 key deleted, got %d`, n)
+		t.Fail()
+	}
+}
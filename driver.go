@@ -0,0 +1,27 @@
+//go:build !sqlcipher && !sqlite_cgo
+
+package sqltplainkv
+
+import (
+	"net/url"
+
+	_ "github.com/glebarez/go-sqlite"
+)
+
+// driverName is the database/sql driver used to open databases.
+// Build with the sqlcipher tag to use an SQLCipher driver instead,
+// or with the sqlite_cgo tag to use mattn/go-sqlite3
+const driverName string = `sqlite`
+
+// dataSource returns the DSN passed to the driver,
+// carrying the pragmas set on the store
+func (p *SQLtPlainKV) dataSource() (string, error) {
+	if len(p.dbKey) > 0 {
+		return "", ErrDatabaseKeyUnsupported
+	}
+	params := make([]string, 0, len(p.pragmas))
+	for _, pr := range p.effectivePragmas() {
+		params = append(params, "_pragma="+url.QueryEscape(pr.name+"("+pr.value+")"))
+	}
+	return withDSNParams(p.DSN, params), nil
+}
@@ -0,0 +1,77 @@
+//go:build sqlite_cgo && !sqlcipher
+
+package sqltplainkv
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"net/url"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// driverName is the database/sql driver used to open databases.
+// It wraps mattn/go-sqlite3 to apply the _pragma parameters of the
+// DSN, so DSNs are written as for the default driver
+const driverName string = `sqltplainkv-sqlite3`
+
+func init() {
+	sql.Register(driverName, pragmaDriver{})
+}
+
+// pragmaDriver runs the _pragma parameters of the DSN
+// on every connection it opens
+type pragmaDriver struct{}
+
+func (pragmaDriver) Open(dsn string) (driver.Conn, error) {
+	dsn, pragmas := splitPragmas(dsn)
+	c, err := (&sqlite3.SQLiteDriver{}).Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	conn := c.(*sqlite3.SQLiteConn)
+	for _, pr := range pragmas {
+		if _, err = conn.Exec(`PRAGMA `+pr+`;`, nil); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// splitPragmas removes the _pragma parameters from a DSN
+// and returns them in order
+func splitPragmas(dsn string) (string, []string) {
+	i := strings.IndexByte(dsn, '?')
+	if i < 0 {
+		return dsn, nil
+	}
+	var (
+		pragmas []string
+		rest    []string
+	)
+	for _, param := range strings.Split(dsn[i+1:], "&") {
+		if strings.HasPrefix(param, "_pragma=") {
+			if v, err := url.QueryUnescape(param[len("_pragma="):]); err == nil {
+				pragmas = append(pragmas, v)
+				continue
+			}
+		}
+		rest = append(rest, param)
+	}
+	return withDSNParams(dsn[:i], rest), pragmas
+}
+
+// dataSource returns the DSN passed to the driver,
+// carrying the pragmas set on the store
+func (p *SQLtPlainKV) dataSource() (string, error) {
+	if len(p.dbKey) > 0 {
+		return "", ErrDatabaseKeyUnsupported
+	}
+	params := make([]string, 0, len(p.pragmas))
+	for _, pr := range p.effectivePragmas() {
+		params = append(params, "_pragma="+url.QueryEscape(pr.name+"("+pr.value+")"))
+	}
+	return withDSNParams(p.DSN, params), nil
+}
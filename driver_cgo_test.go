@@ -0,0 +1,24 @@
+//go:build sqlite_cgo && !sqlcipher
+
+package sqltplainkv
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitPragmas(t *testing.T) {
+	dsn, pragmas := splitPragmas(`file:test.db?_pragma=busy_timeout%285000%29&mode=rwc&_pragma=journal_mode%28WAL%29`)
+	if dsn != `file:test.db?mode=rwc` {
+		t.Logf(`Expected the pragmas to be removed, got %s`, dsn)
+		t.Fail()
+	}
+	if want := []string{`busy_timeout(5000)`, `journal_mode(WAL)`}; !reflect.DeepEqual(pragmas, want) {
+		t.Logf(`Expected %v, got %v`, want, pragmas)
+		t.Fail()
+	}
+	if dsn, pragmas = splitPragmas(`local.dat`); dsn != `local.dat` || pragmas != nil {
+		t.Logf(`Expected a DSN without parameters to be kept, got %s %v`, dsn, pragmas)
+		t.Fail()
+	}
+}
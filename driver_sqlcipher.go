@@ -0,0 +1,39 @@
+//go:build sqlcipher
+
+package sqltplainkv
+
+import (
+	"encoding/hex"
+	"net/url"
+
+	_ "github.com/mutecomm/go-sqlcipher/v4"
+)
+
+// driverName is the database/sql driver used to open databases
+const driverName string = `sqlite3`
+
+// pragmaParams maps pragmas to the DSN parameters of the driver
+var pragmaParams = map[string]string{
+	`journal_mode`: `_journal_mode`,
+	`synchronous`:  `_synchronous`,
+	`busy_timeout`: `_busy_timeout`,
+	`foreign_keys`: `_foreign_keys`,
+	`cache_size`:   `_cache_size`,
+}
+
+// dataSource returns the DSN passed to the driver,
+// carrying the pragmas and the database key set on the store
+func (p *SQLtPlainKV) dataSource() (string, error) {
+	params := make([]string, 0, len(p.pragmas)+1)
+	if len(p.dbKey) > 0 {
+		params = append(params, "_pragma_key=x'"+hex.EncodeToString(p.dbKey)+"'")
+	}
+	for _, pr := range p.effectivePragmas() {
+		name, ok := pragmaParams[pr.name]
+		if !ok {
+			return "", ErrPragmaUnsupported
+		}
+		params = append(params, name+"="+url.QueryEscape(pr.value))
+	}
+	return withDSNParams(p.DSN, params), nil
+}
@@ -0,0 +1,89 @@
+package sqltplainkv
+
+import (
+	"bytes"
+	"math/rand"
+)
+
+// Mismatch describes a dual read where the new value did
+// not match the value read from the old store
+type Mismatch struct {
+	Key string
+	Old []byte // value read from the old store
+	New []byte // value read from the new store or format
+	Err error  // set when reading or converting the new value failed
+}
+
+// DualReader serves reads from an old store while comparing a
+// percentage of them against a new store or a new value format.
+// It is meant to de-risk data migrations: the old store stays
+// authoritative and mismatches are reported through a callback.
+type DualReader struct {
+	Old        *SQLtPlainKV
+	New        *SQLtPlainKV   // if nil, the new value is read from Old
+	Percent    float64        // percentage of reads to compare, from 0 to 100
+	OnMismatch func(Mismatch) // called for every mismatch found
+
+	// Convert translates a value read from the new store into the
+	// format of the old one before comparing. It is optional and
+	// allows checking migrations that change the value format.
+	Convert func(key string, value []byte) ([]byte, error)
+
+	// Equal compares the old and new values. Defaults to bytes.Equal
+	Equal func(old, new []byte) bool
+}
+
+// NewDualReader creates a new DualReader
+func NewDualReader(old, new *SQLtPlainKV, percent float64, onMismatch func(Mismatch)) *DualReader {
+	return &DualReader{
+		Old:        old,
+		New:        new,
+		Percent:    percent,
+		OnMismatch: onMismatch,
+	}
+}
+
+// Get retrieves a record from the old store. A sample of the reads
+// is also fetched from the new store and compared
+func (d *DualReader) Get(key string) ([]byte, error) {
+	val, err := d.Old.Get(key)
+	if err != nil {
+		return val, err
+	}
+	if d.Percent <= 0 || rand.Float64()*100 >= d.Percent {
+		return val, nil
+	}
+	d.compare(key, val)
+	return val, nil
+}
+
+func (d *DualReader) compare(key string, old []byte) {
+	var (
+		nv  []byte
+		err error
+	)
+	src := d.New
+	if src == nil {
+		src = d.Old
+	}
+	if nv, err = src.Get(key); err == nil && d.Convert != nil {
+		nv, err = d.Convert(key, nv)
+	}
+	if err != nil {
+		d.report(Mismatch{Key: key, Old: old, New: nv, Err: err})
+		return
+	}
+	equal := bytes.Equal
+	if d.Equal != nil {
+		equal = d.Equal
+	}
+	if !equal(old, nv) {
+		d.report(Mismatch{Key: key, Old: old, New: nv})
+	}
+}
+
+func (d *DualReader) report(m Mismatch) {
+	if d.OnMismatch != nil {
+		d.OnMismatch(m)
+	}
+}
@@ -0,0 +1,33 @@
+package sqltplainkv
+
+import (
+	"testing"
+)
+
+func TestDualReader(t *testing.T) {
+	oldKV := newTestKV(t)
+	newKV := newTestKV(t)
+
+	oldKV.Set(`same`, []byte(`value`))
+	newKV.Set(`same`, []byte(`value`))
+	oldKV.Set(`differs`, []byte(`old value`))
+	newKV.Set(`differs`, []byte(`new value`))
+
+	var mismatches []Mismatch
+	dr := NewDualReader(oldKV, newKV, 100, func(m Mismatch) {
+		mismatches = append(mismatches, m)
+	})
+
+	for _, k := range []string{`same`, `differs`} {
+		b, err := dr.Get(k)
+		if err != nil {
+			t.Fatalf(`%s`, err)
+		}
+		t.Logf(`Retrieved from the old store: %s`, b)
+	}
+
+	if len(mismatches) != 1 || mismatches[0].Key != `differs` {
+		t.Logf(`Expected one mismatch on differs, got %+v`, mismatches)
+		t.Fail()
+	}
+}
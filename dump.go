@@ -0,0 +1,270 @@
+package sqltplainkv
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// A dump is a magic header followed by one record per row. Each record
+// starts with a marker byte and holds the bucket, key and value, each
+// prefixed by its length, then the expiry, the checksum and, from the
+// second version on, the creation and update times. The third version
+// adds the mime, prefixed by its length, and the fourth the last access
+// and soft deletion times. A final marker ends the dump, so truncated
+// dumps are detected
+const (
+	dumpMagic   string = "SKVDUMP4"
+	dumpMagicV3 string = "SKVDUMP3"
+	dumpMagicV2 string = "SKVDUMP2"
+	dumpMagicV1 string = "SKVDUMP1"
+
+	dumpRecord byte = 1
+	dumpEnd    byte = 0
+)
+
+var (
+	ErrInvalidDump error = errors.New(`invalid dump`)
+)
+
+// dumpColumns are the columns restored from a dump or cloned
+var dumpColumns = append(recordColumns[:len(recordColumns):len(recordColumns)], `AccessedAt`, `DeletedAt`, `ExpirySetAt`)
+
+// Dump writes a consistent copy of the whole store to the writer.
+// Rows are written as stored, so encrypted values stay encrypted.
+// Values spilled to files are not included
+func (p *SQLtPlainKV) Dump(w io.Writer) error {
+	var err error
+	if p.inTransaction {
+		return ErrInTransaction
+	}
+	if err = p.open(); err != nil {
+		return err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	// a read transaction sees a single snapshot of the database
+	tx, err := p.db.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	rows, err := tx.Query(`SELECT Bucket, KeyID, Value, ExpiresAt, Checksum, CreatedAt, UpdatedAt, Mime, AccessedAt, DeletedAt, ExpirySetAt FROM ` + p.defTableName + ` ORDER BY Bucket, KeyID;`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString(dumpMagic)
+	for rows.Next() {
+		var (
+			bucket, key string
+			val         []byte
+			exp, sum    sql.NullInt64
+			crt, upd    sql.NullInt64
+			mime        sql.NullString
+			acc, del    sql.NullInt64
+			ext         sql.NullInt64
+		)
+		if err = rows.Scan(&bucket, &key, &val, &exp, &sum, &crt, &upd, &mime, &acc, &del, &ext); err != nil {
+			return err
+		}
+		bw.WriteByte(dumpRecord)
+		writeDumpBytes(bw, []byte(bucket))
+		writeDumpBytes(bw, []byte(key))
+		writeDumpBytes(bw, val)
+		writeDumpNull(bw, exp)
+		writeDumpNull(bw, sum)
+		writeDumpNull(bw, crt)
+		writeDumpNull(bw, upd)
+		writeDumpBytes(bw, []byte(mime.String))
+		writeDumpNull(bw, acc)
+		writeDumpNull(bw, del)
+		writeDumpNull(bw, ext)
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	bw.WriteByte(dumpEnd)
+	return bw.Flush()
+}
+
+// Restore replaces the whole content of the store with a dump
+// written by Dump, in a single transaction. It returns
+// ErrInvalidDump if the dump is malformed or truncated. Records of
+// dumps written before timestamps were stored have none, mimes of dumps
+// written before the Mime column are moved to it. Keys of dumps written
+// before access and deletion times were dumped are restored as never
+// accessed and not deleted
+func (p *SQLtPlainKV) Restore(r io.Reader) error {
+	var err error
+	if p.inTransaction {
+		return ErrInTransaction
+	}
+	if err = p.open(); err != nil {
+		return err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(dumpMagic))
+	if _, err = io.ReadFull(br, magic); err != nil {
+		return ErrInvalidDump
+	}
+	var version int
+	switch string(magic) {
+	case dumpMagicV1:
+		version = 1
+	case dumpMagicV2:
+		version = 2
+	case dumpMagicV3:
+		version = 3
+	case dumpMagic:
+		version = 4
+	default:
+		return ErrInvalidDump
+	}
+
+	tx, err := p.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err = tx.Exec(`DELETE FROM ` + p.defTableName + `;`); err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(insertColumnsSQL(p.dialect, p.defTableName, dumpColumns))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for {
+		marker, err := br.ReadByte()
+		if err != nil {
+			return ErrInvalidDump
+		}
+		if marker == dumpEnd {
+			break
+		}
+		if marker != dumpRecord {
+			return ErrInvalidDump
+		}
+		bucket, err := readDumpBytes(br)
+		if err != nil {
+			return err
+		}
+		key, err := readDumpBytes(br)
+		if err != nil {
+			return err
+		}
+		val, err := readDumpBytes(br)
+		if err != nil {
+			return err
+		}
+		exp, err := readDumpNull(br)
+		if err != nil {
+			return err
+		}
+		sum, err := readDumpNull(br)
+		if err != nil {
+			return err
+		}
+		var crt, upd sql.NullInt64
+		if version >= 2 {
+			if crt, err = readDumpNull(br); err != nil {
+				return err
+			}
+			if upd, err = readDumpNull(br); err != nil {
+				return err
+			}
+		}
+		var mime sql.NullString
+		if version >= 3 {
+			b, err := readDumpBytes(br)
+			if err != nil {
+				return err
+			}
+			mime = sql.NullString{String: string(b), Valid: len(b) > 0}
+		}
+		var acc, del, ext sql.NullInt64
+		if version >= 4 {
+			if acc, err = readDumpNull(br); err != nil {
+				return err
+			}
+			if del, err = readDumpNull(br); err != nil {
+				return err
+			}
+			if ext, err = readDumpNull(br); err != nil {
+				return err
+			}
+		}
+		// content hashes are not dumped, they are computed again when needed
+		if _, err = stmt.Exec(string(bucket), string(key), val, exp, sum, crt, upd, sql.NullString{}, mime, acc, del, ext); err != nil {
+			return err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	if version < 3 {
+		if err = p.migrateMimes(); err != nil {
+			return err
+		}
+	}
+	// settings stored in the database may have changed
+	p.DropWarmCache()
+	p.bucketOpts = nil
+	return p.loadBucketOptions()
+}
+
+func writeDumpBytes(bw *bufio.Writer, b []byte) {
+	var n [binary.MaxVarintLen64]byte
+	bw.Write(n[:binary.PutUvarint(n[:], uint64(len(b)))])
+	bw.Write(b)
+}
+
+// writeDumpNull writes a nullable integer as a flag byte and the value
+func writeDumpNull(bw *bufio.Writer, v sql.NullInt64) {
+	if !v.Valid {
+		bw.WriteByte(0)
+		return
+	}
+	var n [binary.MaxVarintLen64]byte
+	bw.WriteByte(1)
+	bw.Write(n[:binary.PutVarint(n[:], v.Int64)])
+}
+
+func readDumpBytes(br *bufio.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, ErrInvalidDump
+	}
+	// grow as data arrives instead of trusting the length up front
+	var buf bytes.Buffer
+	if _, err = io.CopyN(&buf, br, int64(n)); err != nil {
+		return nil, ErrInvalidDump
+	}
+	return buf.Bytes(), nil
+}
+
+func readDumpNull(br *bufio.Reader) (sql.NullInt64, error) {
+	flag, err := br.ReadByte()
+	if err != nil || flag > 1 {
+		return sql.NullInt64{}, ErrInvalidDump
+	}
+	if flag == 0 {
+		return sql.NullInt64{}, nil
+	}
+	v, err := binary.ReadVarint(br)
+	if err != nil {
+		return sql.NullInt64{}, ErrInvalidDump
+	}
+	return sql.NullInt64{Int64: v, Valid: true}, nil
+}
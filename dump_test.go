@@ -0,0 +1,131 @@
+package sqltplainkv
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDumpRestore(t *testing.T) {
+	src := newTestKV(t)
+	src.SetChecksums(true)
+	src.Set(`a`, []byte(`first`))
+	src.SetWithTTL(`b`, []byte(`second`), time.Hour)
+	src.SetBucket(`other`)
+	src.Set(`c`, []byte(`third`))
+	src.SetMime(`c`, `text/plain`)
+
+	var buf bytes.Buffer
+	if err := src.Dump(&buf); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+
+	dst := newTestKV(t)
+	dst.Set(`stale`, []byte(`replaced by the restore`))
+	if err := dst.Restore(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	for _, c := range []struct{ bucket, key, want string }{
+		{`default`, `a`, `first`},
+		{`default`, `b`, `second`},
+		{`other`, `c`, `third`},
+		{`default`, `stale`, ``},
+	} {
+		dst.SetBucket(c.bucket)
+		if val, err := dst.Get(c.key); err != nil || string(val) != c.want {
+			t.Logf(`Expected %s/%s to be %q, got %q (%v)`, c.bucket, c.key, c.want, val, err)
+			t.Fail()
+		}
+	}
+	dst.SetBucket(`other`)
+	if m, _ := dst.GetMime(`c`); m != `text/plain` {
+		t.Logf(`Expected the mime to be restored, got %s`, m)
+		t.Fail()
+	}
+	dst.SetBucket(`default`)
+	if info, _ := dst.InspectStorage(`b`); info.ExpiresAt.IsZero() {
+		t.Logf(`Expected the expiry to be restored`)
+		t.Fail()
+	}
+
+	truncated := buf.Bytes()[:buf.Len()-1]
+	if err := dst.Restore(bytes.NewReader(truncated)); !errors.Is(err, ErrInvalidDump) {
+		t.Logf(`Expected ErrInvalidDump for a truncated dump, got %v`, err)
+		t.Fail()
+	}
+	if val, _ := dst.Get(`a`); string(val) != `first` {
+		t.Logf(`Expected a failed restore to leave the store untouched, got %q`, val)
+		t.Fail()
+	}
+}
+
+func TestRestoreV1(t *testing.T) {
+	var buf bytes.Buffer
+	bw := bufio.NewWriter(&buf)
+	bw.WriteString(dumpMagicV1)
+	bw.WriteByte(dumpRecord)
+	writeDumpBytes(bw, []byte(`default`))
+	writeDumpBytes(bw, []byte(`old`))
+	writeDumpBytes(bw, []byte(`value`))
+	writeDumpNull(bw, sql.NullInt64{})
+	writeDumpNull(bw, sql.NullInt64{})
+	bw.WriteByte(dumpEnd)
+	bw.Flush()
+
+	pkv := newTestKV(t)
+	if err := pkv.Restore(&buf); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	meta, err := pkv.GetMeta(`old`)
+	if err != nil || meta.Size != 5 || !meta.UpdatedAt.IsZero() {
+		t.Logf(`Expected the record without timestamps, got %+v (%v)`, meta, err)
+		t.Fail()
+	}
+}
+
+func TestDumpRestoreAccessAndDeletion(t *testing.T) {
+	src := newTestKV(t)
+	src.SetAccessTracking(true)
+	src.Set(`read`, []byte(`read once`))
+	src.Get(`read`)
+	src.Set(`trashed`, []byte(`soft deleted`))
+	if err := src.SoftDel(`trashed`); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+
+	var buf bytes.Buffer
+	if err := src.Dump(&buf); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	dst := newTestKV(t)
+	if err := dst.Restore(&buf); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+
+	times := func(pkv *SQLtPlainKV, q string) (acc, del sql.NullInt64) {
+		pkv.db.QueryRow(q).Scan(&acc, &del)
+		return acc, del
+	}
+	for _, q := range []string{
+		`SELECT AccessedAt, DeletedAt FROM KeyValueTBL WHERE KeyID='read'`,
+		`SELECT AccessedAt, DeletedAt FROM KeyValueTBL WHERE KeyID='trashed'`,
+	} {
+		wantAcc, wantDel := times(src, q)
+		acc, del := times(dst, q)
+		if acc != wantAcc || del != wantDel {
+			t.Logf(`Expected access and deletion times %v %v, got %v %v`, wantAcc, wantDel, acc, del)
+			t.Fail()
+		}
+	}
+	if acc, _ := times(dst, `SELECT AccessedAt, DeletedAt FROM KeyValueTBL WHERE KeyID='read'`); !acc.Valid {
+		t.Logf(`Expected the access time to be restored`)
+		t.Fail()
+	}
+	if n, err := dst.PurgeDeleted(0); n != 1 || err != nil {
+		t.Logf(`Expected the restored soft deleted key to be purged, got %d (%v)`, n, err)
+		t.Fail()
+	}
+}
@@ -0,0 +1,371 @@
+package sqltplainkv
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash/crc32"
+)
+
+const (
+	encKeyIDLen int = 4
+)
+
+var (
+	ErrInvalidEncryptionKey error = errors.New(`encryption key must be 16, 24 or 32 bytes`)
+	ErrUnknownEncryptionKey error = errors.New(`value is encrypted with an unknown key`)
+)
+
+// encryptionKey is an AES-GCM key identified by the
+// first bytes of the SHA-256 digest of the key
+type encryptionKey struct {
+	id   []byte
+	aead cipher.AEAD
+}
+
+func newEncryptionKey(key []byte) (*encryptionKey, error) {
+	switch len(key) {
+	case 16, 24, 32:
+	default:
+		return nil, ErrInvalidEncryptionKey
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(key)
+	return &encryptionKey{
+		id:   sum[:encKeyIDLen],
+		aead: aead,
+	}, nil
+}
+
+// ID returns the key id stored in front of values encrypted with the key
+func (k *encryptionKey) ID() string {
+	return hex.EncodeToString(k.id)
+}
+
+// encrypt seals the value into an encrypted envelope
+// holding the key id, the nonce and the ciphertext
+func (k *encryptionKey) encrypt(value []byte) ([]byte, error) {
+	nonce := make([]byte, k.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	body := make([]byte, 0, encKeyIDLen+len(nonce)+len(value)+k.aead.Overhead())
+	body = append(body, k.id...)
+	body = append(body, nonce...)
+	body = k.aead.Seal(body, nonce, value, nil)
+	return envelope(envEncrypted, body), nil
+}
+
+// SetEncryptionKey encrypts all values written from now on with AES-GCM
+// using the key, which must be 16, 24 or 32 bytes long. The key is also
+// used to read values it encrypted. A nil key stops encrypting new
+// values while keeping known keys available for reading
+func (p *SQLtPlainKV) SetEncryptionKey(key []byte) error {
+	if key == nil {
+		p.encActive = nil
+		return nil
+	}
+	ek, err := p.addEncryptionKey(key)
+	if err != nil {
+		return err
+	}
+	p.encActive = ek
+	return nil
+}
+
+// AddDecryptionKey makes a key available for reading values
+// encrypted with it, such as keys that were rotated out
+func (p *SQLtPlainKV) AddDecryptionKey(key []byte) error {
+	_, err := p.addEncryptionKey(key)
+	return err
+}
+
+func (p *SQLtPlainKV) addEncryptionKey(key []byte) (*encryptionKey, error) {
+	ek, err := newEncryptionKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if p.encKeys == nil {
+		p.encKeys = make(map[string]*encryptionKey)
+	}
+	if known, ok := p.encKeys[ek.ID()]; ok {
+		return known, nil
+	}
+	p.encKeys[ek.ID()] = ek
+	return ek, nil
+}
+
+// decrypt opens the body of an encrypted envelope
+func (p *SQLtPlainKV) decrypt(body []byte) ([]byte, error) {
+	if len(body) < encKeyIDLen {
+		return nil, ErrCorruptValue
+	}
+	ek, ok := p.encKeys[hex.EncodeToString(body[:encKeyIDLen])]
+	if !ok {
+		return nil, ErrUnknownEncryptionKey
+	}
+	body = body[encKeyIDLen:]
+	ns := ek.aead.NonceSize()
+	if len(body) < ns {
+		return nil, ErrCorruptValue
+	}
+	val, err := ek.aead.Open(nil, body[:ns], body[ns:], nil)
+	if err != nil {
+		return nil, ErrCorruptValue
+	}
+	return val, nil
+}
+
+// RotationProgress reports the progress of RotateEncryptionKey
+type RotationProgress struct {
+	Done  int // values re-encrypted so far
+	Total int // values encrypted with the old key when rotation started
+}
+
+// RotateEncryptionKey re-encrypts every value encrypted with the old key
+// using the new key, which becomes the active key. Rows are processed
+// in batches of the throttle batch size, each in its own transaction,
+// with the throttle pause between batches, so the store stays usable
+// while a long rotation runs. Spilled values are written to new files
+// named after their new content, and the old files are left for
+// SweepSpillover. It cannot be called inside a transaction
+func (p *SQLtPlainKV) RotateEncryptionKey(oldKey, newKey []byte) error {
+	return p.RotateEncryptionKeyFunc(oldKey, newKey, nil)
+}
+
+// RotateEncryptionKeyFunc is RotateEncryptionKey calling progress
+// after every batch
+func (p *SQLtPlainKV) RotateEncryptionKeyFunc(oldKey, newKey []byte, progress func(RotationProgress)) error {
+	var err error
+	if p.inTransaction {
+		return ErrInTransaction
+	}
+	oldEK, err := p.addEncryptionKey(oldKey)
+	if err != nil {
+		return err
+	}
+	newEK, err := p.addEncryptionKey(newKey)
+	if err != nil {
+		return err
+	}
+	if err = p.open(); err != nil {
+		return err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	p.encActive = newEK
+
+	prefix := append([]byte(envMagic+string(envEncrypted)), oldEK.id...)
+	var prog RotationProgress
+	err = p.db.QueryRow(`
+	SELECT COUNT(*) FROM `+p.defTableName+`
+	WHERE substr(Value, 1, ?) = ?;`, len(prefix), prefix).Scan(&prog.Total)
+	if err != nil {
+		return err
+	}
+
+	spilled, err := p.spilledWith(prefix)
+	if err != nil {
+		return err
+	}
+	prog.Total += len(spilled)
+
+	batchSize := p.throttle.BatchSize
+	var last int64
+	for {
+		n, err := p.rotateBatch(prefix, &last, batchSize)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			break
+		}
+		prog.Done += n
+		if progress != nil {
+			progress(prog)
+		}
+		p.pause()
+	}
+	// files shared by several values are rewritten once
+	rewritten := make(map[string][]byte)
+	for len(spilled) > 0 {
+		n := len(spilled)
+		if n > batchSize {
+			n = batchSize
+		}
+		if err = p.rotateSpilled(spilled[:n], rewritten); err != nil {
+			return err
+		}
+		spilled = spilled[n:]
+		prog.Done += n
+		if progress != nil {
+			progress(prog)
+		}
+		p.pause()
+	}
+	return nil
+}
+
+// rotateBatch re-encrypts the next batch of rows after the rowid
+// in last with the active key, in a single transaction
+func (p *SQLtPlainKV) rotateBatch(prefix []byte, last *int64, size int) (int, error) {
+	type row struct {
+		id  int64
+		val []byte
+	}
+	rows, err := p.db.Query(`
+	SELECT rowid, Value FROM `+p.defTableName+`
+	WHERE rowid > ?
+		AND substr(Value, 1, ?) = ?
+	ORDER BY rowid
+	LIMIT ?;`, *last, len(prefix), prefix, size)
+	if err != nil {
+		return 0, err
+	}
+	var batch []row
+	for rows.Next() {
+		var r row
+		if err = rows.Scan(&r.id, &r.val); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		batch = append(batch, r)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil || len(batch) == 0 {
+		return 0, err
+	}
+
+	tx, err := p.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	// a row written again since it was listed is left alone
+	stmt, err := tx.Prepare(`
+	UPDATE ` + p.defTableName + `
+	SET Value = ?, Checksum = CASE WHEN Checksum IS NULL THEN NULL ELSE ? END
+	WHERE rowid = ?
+		AND Value = ?;`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+	for _, r := range batch {
+		inner, err := p.decrypt(r.val[len(envMagic)+1:])
+		if err != nil {
+			return 0, err
+		}
+		val, err := p.encActive.encrypt(inner)
+		if err != nil {
+			return 0, err
+		}
+		if _, err = stmt.Exec(val, int64(crc32.Checksum(val, crcTable)), r.id, r.val); err != nil {
+			return 0, err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	*last = batch[len(batch)-1].id
+	return len(batch), nil
+}
+
+// spilledRow is a row whose value is spilled to a file
+type spilledRow struct {
+	id  int64
+	ptr []byte
+}
+
+// spilledWith lists the rows whose spilled files are encrypted with
+// the key of the prefix. Rows whose file is missing are left out
+func (p *SQLtPlainKV) spilledWith(prefix []byte) ([]spilledRow, error) {
+	spilled := make([]spilledRow, 0)
+	if p.spill.dir == "" {
+		return spilled, nil
+	}
+	ext := envelope(envExternal, nil)
+	rows, err := p.db.Query(`
+	SELECT rowid, Value FROM `+p.defTableName+`
+	WHERE substr(Value, 1, ?) = ?
+	ORDER BY rowid;`, len(ext), ext)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var r spilledRow
+		if err = rows.Scan(&r.id, &r.ptr); err != nil {
+			return nil, err
+		}
+		head, err := p.spillHead(r.ptr[len(ext):], len(prefix))
+		if errors.Is(err, ErrBlobNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Equal(head, prefix) {
+			spilled = append(spilled, r)
+		}
+	}
+	return spilled, rows.Err()
+}
+
+// rotateSpilled re-encrypts the files of a batch of spilled rows with
+// the active key and points the rows to the new files, in a single
+// transaction. rewritten maps the old pointers to the new ones
+func (p *SQLtPlainKV) rotateSpilled(batch []spilledRow, rewritten map[string][]byte) error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	// a row written again since it was listed is left alone
+	stmt, err := tx.Prepare(`
+	UPDATE ` + p.defTableName + `
+	SET Value = ?, Checksum = CASE WHEN Checksum IS NULL THEN NULL ELSE ? END
+	WHERE rowid = ?
+		AND Value = ?;`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, r := range batch {
+		ptr, ok := rewritten[string(r.ptr)]
+		if !ok {
+			val, err := p.readSpilled(r.ptr[len(envMagic)+1:])
+			if err != nil {
+				return err
+			}
+			inner, err := p.decrypt(val[len(envMagic)+1:])
+			if err != nil {
+				return err
+			}
+			if val, err = p.encActive.encrypt(inner); err != nil {
+				return err
+			}
+			if ptr, err = p.spillValue(val); err != nil {
+				return err
+			}
+			rewritten[string(r.ptr)] = ptr
+		}
+		if _, err = stmt.Exec(ptr, int64(crc32.Checksum(ptr, crcTable)), r.id, r.ptr); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,134 @@
+package sqltplainkv
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestEncryption(t *testing.T) {
+	pkv := newTestKV(t)
+
+	key := bytes.Repeat([]byte{0x42}, 32)
+	if err := pkv.SetEncryptionKey([]byte(`short`)); !errors.Is(err, ErrInvalidEncryptionKey) {
+		t.Logf(`Expected ErrInvalidEncryptionKey, got %v`, err)
+		t.Fail()
+	}
+	if err := pkv.SetEncryptionKey(key); err != nil {
+		t.Fatalf(`%s`, err)
+	}
+	pkv.SetCompression(Gzip, 64)
+
+	secret := []byte(strings.Repeat(`token-1234 `, 20))
+	if err := pkv.Set(`secret`, secret); err != nil {
+		t.Fatalf(`%s`, err)
+	}
+
+	var raw []byte
+	pkv.db.QueryRow(`SELECT Value FROM KeyValueTBL WHERE Bucket='default' AND KeyID='secret'`).Scan(&raw)
+	if bytes.Contains(raw, []byte(`token`)) {
+		t.Logf(`Expected the stored value to be encrypted`)
+		t.Fail()
+	}
+
+	info, _ := pkv.InspectStorage(`secret`)
+	if !info.Encrypted || info.Compressed != Gzip || info.KeyID == `` {
+		t.Logf(`Unexpected storage info: %+v`, info)
+		t.Fail()
+	}
+
+	if b, err := pkv.Get(`secret`); err != nil || !bytes.Equal(b, secret) {
+		t.Logf(`Expected the original value, got %q (%v)`, b, err)
+		t.Fail()
+	}
+
+	// a store without the key cannot read the value
+	pkv.encKeys = nil
+	pkv.encActive = nil
+	if _, err := pkv.Get(`secret`); !errors.Is(err, ErrUnknownEncryptionKey) {
+		t.Logf(`Expected ErrUnknownEncryptionKey, got %v`, err)
+		t.Fail()
+	}
+
+	// a rotated out key remains usable for reading
+	pkv.AddDecryptionKey(key)
+	pkv.SetEncryptionKey(bytes.Repeat([]byte{0x24}, 16))
+	if b, err := pkv.Get(`secret`); err != nil || !bytes.Equal(b, secret) {
+		t.Logf(`Expected the original value, got %q (%v)`, b, err)
+		t.Fail()
+	}
+}
+
+func TestRotateEncryptionKey(t *testing.T) {
+	pkv := newTestKV(t)
+
+	oldKey := bytes.Repeat([]byte{0x01}, 32)
+	newKey := bytes.Repeat([]byte{0x02}, 32)
+	pkv.SetEncryptionKey(oldKey)
+	for i := 0; i < 1200; i++ {
+		pkv.Set(`key_`+strconv.Itoa(i), []byte(`value `+strconv.Itoa(i)))
+	}
+
+	var last RotationProgress
+	err := pkv.RotateEncryptionKeyFunc(oldKey, newKey, func(rp RotationProgress) {
+		last = rp
+	})
+	if err != nil {
+		t.Fatalf(`%s`, err)
+	}
+	if last.Done != 1200 || last.Total != 1200 {
+		t.Logf(`Unexpected progress: %+v`, last)
+		t.Fail()
+	}
+
+	// only the new key is needed to read the values now
+	pkv.encKeys = nil
+	pkv.SetEncryptionKey(newKey)
+	for _, i := range []int{0, 599, 1199} {
+		b, err := pkv.Get(`key_` + strconv.Itoa(i))
+		if err != nil || string(b) != `value `+strconv.Itoa(i) {
+			t.Logf(`Expected value %d, got %s (%v)`, i, b, err)
+			t.Fail()
+		}
+	}
+}
+
+func TestRotateSpilledValues(t *testing.T) {
+	pkv := newTestKV(t)
+	dir := t.TempDir()
+	if err := pkv.SetSpillover(dir, 64); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	oldKey := bytes.Repeat([]byte{0x01}, 32)
+	newKey := bytes.Repeat([]byte{0x02}, 32)
+	pkv.SetEncryptionKey(oldKey)
+	large := bytes.Repeat([]byte(`spilled `), 32)
+	pkv.Set(`large`, large)
+	pkv.Set(`small`, []byte(`small`))
+
+	var last RotationProgress
+	err := pkv.RotateEncryptionKeyFunc(oldKey, newKey, func(rp RotationProgress) {
+		last = rp
+	})
+	if err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if last.Done != 2 || last.Total != 2 {
+		t.Logf(`Expected both values rotated, got %+v`, last)
+		t.Fail()
+	}
+	// the old file is no longer referenced
+	ageSpilled(t, dir)
+	if n, err := pkv.SweepSpillover(); err != nil || n != 1 {
+		t.Logf(`Expected the old file to be swept, got %d (%v)`, n, err)
+		t.Fail()
+	}
+	pkv.encKeys = nil
+	pkv.SetEncryptionKey(newKey)
+	if v, err := pkv.Get(`large`); err != nil || !bytes.Equal(v, large) {
+		t.Logf(`Expected the spilled value with the new key, got %d bytes (%v)`, len(v), err)
+		t.Fail()
+	}
+}
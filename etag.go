@@ -0,0 +1,98 @@
+package sqltplainkv
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// hashLen is the number of bytes of the SHA-256 of a value kept as
+// its content hash
+const hashLen int = 16
+
+// contentHash returns the content hash stored along with a value.
+// Values of internal buckets are not hashed
+func (p *SQLtPlainKV) contentHash(bucket string, value []byte) sql.NullString {
+	if isInternalBucket(bucket) {
+		return sql.NullString{}
+	}
+	sum := sha256.Sum256(value)
+	return sql.NullString{String: hex.EncodeToString(sum[:hashLen]), Valid: true}
+}
+
+// ContentHash returns the hash of the value of a key in the current
+// bucket, as hex. The hash is computed when the value is set, values
+// set before hashes were stored or restored from a dump are hashed
+// when asked for. It returns ErrKeyNotFound if the key does not exist
+func (p *SQLtPlainKV) ContentHash(key string) (string, error) {
+	var err error
+	if p.currBuckt == "" {
+		p.currBuckt = "default"
+	}
+	if err = p.open(); err != nil {
+		return "", err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	hash, _, err := p.hashOf(p.currBuckt, key, nil)
+	return hash, err
+}
+
+// hashOf returns the content hash and the last update of a key.
+// A missing hash is computed from value, or the stored value if nil
+func (p *SQLtPlainKV) hashOf(bucket, key string, value []byte) (string, time.Time, error) {
+	var (
+		hash sql.NullString
+		upd  sql.NullInt64
+	)
+	err := p.conn().QueryRow(p.rebind(`
+	SELECT Hash, UpdatedAt FROM `+p.defTableName+`
+	WHERE Bucket=?
+		AND KeyID=?
+		AND (ExpiresAt IS NULL OR ExpiresAt > ?);`), bucket, key, time.Now().UnixNano()).Scan(&hash, &upd)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", time.Time{}, ErrKeyNotFound
+		}
+		return "", time.Time{}, err
+	}
+	if !hash.Valid {
+		if value == nil {
+			if value, err = p.get(bucket, key); err != nil {
+				return "", time.Time{}, err
+			}
+		}
+		sum := sha256.Sum256(value)
+		hash.String = hex.EncodeToString(sum[:hashLen])
+	}
+	return hash.String, nanoTime(upd), nil
+}
+
+// notModified reports whether the conditional headers of the request
+// match the entity tag or the modification time. If-None-Match takes
+// precedence over If-Modified-Since
+func notModified(r *http.Request, etag string, modTime time.Time) bool {
+	if inm := r.Header.Get(`If-None-Match`); inm != "" {
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), `W/`)
+			if tag == etag || tag == `*` {
+				return true
+			}
+		}
+		return false
+	}
+	ims := r.Header.Get(`If-Modified-Since`)
+	if ims == "" || modTime.IsZero() {
+		return false
+	}
+	t, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	return !modTime.Truncate(time.Second).After(t)
+}
@@ -0,0 +1,91 @@
+package sqltplainkv
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestContentHash(t *testing.T) {
+	pkv := newTestKV(t)
+	pkv.Set(`a`, []byte(`same`))
+	pkv.Set(`b`, []byte(`same`))
+	pkv.SetLimits(Limits{ChunkSize: 4})
+	pkv.Set(`chunked`, []byte(`same`+`same`))
+	pkv.SetFrom(`streamed`, bytes.NewReader([]byte(`samesame`)))
+
+	ha, err := pkv.ContentHash(`a`)
+	if err != nil || len(ha) != 2*hashLen {
+		t.Fatalf(`Unexpected hash %q, %v`, ha, err)
+	}
+	if hb, _ := pkv.ContentHash(`b`); hb != ha {
+		t.Logf(`Expected equal values to hash alike`)
+		t.Fail()
+	}
+	hc, _ := pkv.ContentHash(`chunked`)
+	if hs, _ := pkv.ContentHash(`streamed`); hs != hc || hc == ha {
+		t.Logf(`Expected chunked and streamed values to hash alike, got %s and %s`, hc, hs)
+		t.Fail()
+	}
+	// values without a stored hash are hashed when asked for
+	pkv.db.Exec(`UPDATE KeyValueTBL SET Hash = NULL;`)
+	if h, _ := pkv.ContentHash(`a`); h != ha {
+		t.Logf(`Expected the computed hash to match, got %s`, h)
+		t.Fail()
+	}
+	if _, err = pkv.ContentHash(`missing`); err != ErrKeyNotFound {
+		t.Logf(`Expected ErrKeyNotFound, got %v`, err)
+		t.Fail()
+	}
+}
+
+func TestHandlerConditional(t *testing.T) {
+	pkv := newTestKV(t)
+	pkv.Set(`app.js`, []byte(`console.log(1)`))
+	h := pkv.Handler(map[string]string{`/`: `default`})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(`GET`, `/app.js`, nil))
+	etag, lastMod := rec.Header().Get(`ETag`), rec.Header().Get(`Last-Modified`)
+	if rec.Code != 200 || etag == `` || lastMod == `` {
+		t.Fatalf(`Expected ETag and Last-Modified, got %d %v`, rec.Code, rec.Header())
+	}
+
+	for _, tc := range []struct {
+		name   string
+		header string
+		value  string
+		status int
+	}{
+		{`matching etag`, `If-None-Match`, etag, 304},
+		{`etag list`, `If-None-Match`, `"other", W/` + etag, 304},
+		{`other etag`, `If-None-Match`, `"other"`, 200},
+		{`not modified since`, `If-Modified-Since`, lastMod, 304},
+		{`modified since`, `If-Modified-Since`, time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 200},
+	} {
+		req := httptest.NewRequest(`GET`, `/app.js`, nil)
+		req.Header.Set(tc.header, tc.value)
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tc.status {
+			t.Logf(`%s: expected %d, got %d`, tc.name, tc.status, rec.Code)
+			t.Fail()
+		}
+		if tc.status == 304 && (rec.Body.Len() != 0 || rec.Header().Get(`ETag`) != etag) {
+			t.Logf(`%s: unexpected 304 response %q %v`, tc.name, rec.Body.String(), rec.Header())
+			t.Fail()
+		}
+	}
+
+	pkv.Set(`app.js`, []byte(`console.log(2)`))
+	req := httptest.NewRequest(`GET`, `/app.js`, nil)
+	req.Header.Set(`If-None-Match`, etag)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != 200 || rec.Header().Get(`ETag`) == etag {
+		t.Logf(`Expected a changed value to be sent with a new ETag, got %d`, rec.Code)
+		t.Fail()
+	}
+}
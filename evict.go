@@ -0,0 +1,167 @@
+package sqltplainkv
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SetAccessTracking turns recording the time keys are read on or off.
+// Get and GetWithInfo then write the time of the read along with the
+// key, which Evict uses to find the least recently used keys. Without
+// it, keys are ranked by the time they were last updated or touched
+func (p *SQLtPlainKV) SetAccessTracking(enabled bool) {
+	p.trackAccess = enabled
+}
+
+// WithAccessTracking records the time keys are read for eviction
+func WithAccessTracking() Option {
+	return func(p *SQLtPlainKV) error {
+		p.SetAccessTracking(true)
+		return nil
+	}
+}
+
+// recordAccess stores the time a key was read, if tracking is on
+func (p *SQLtPlainKV) recordAccess(bucket, key string) error {
+	if !p.trackAccess {
+		return nil
+	}
+	if err := p.open(); err != nil {
+		return err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	sqlstr := p.rebind(`UPDATE ` + p.defTableName + ` SET AccessedAt = ? WHERE Bucket = ? AND KeyID = ?;`)
+	return p.retry(func() error {
+		_, err := p.conn().Exec(sqlstr, time.Now().UnixNano(), bucket, key)
+		return err
+	})
+}
+
+// Evict removes the least recently used keys of a bucket until it
+// holds at most maxKeys keys, and returns the number removed. Keys
+// are ranked by the time they were last read, with access tracking,
+// or else last updated or touched. Expired keys and hidden keys, such
+// as versions and locale variants, are not counted, and a key is
+// evicted along with its versions and locale variants
+func (p *SQLtPlainKV) Evict(bucket string, maxKeys int) (int, error) {
+	var err error
+	if bucket == "" {
+		bucket = "default"
+	}
+	if maxKeys < 0 {
+		maxKeys = 0
+	}
+	if err = p.open(); err != nil {
+		return 0, err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	return p.evict(bucket, maxKeys)
+}
+
+func (p *SQLtPlainKV) evict(bucket string, maxKeys int) (int, error) {
+	var count int
+	now := time.Now().UnixNano()
+	hidden := likePrefix(internalKeyPrefix)
+	err := p.conn().QueryRow(p.rebind(`
+	SELECT COUNT(*) FROM `+p.defTableName+`
+	WHERE Bucket=?
+		AND KeyID NOT LIKE ? ESCAPE '\'
+		AND (ExpiresAt IS NULL OR ExpiresAt > ?);`), bucket, hidden, now).Scan(&count)
+	if err != nil || count <= maxKeys {
+		return 0, err
+	}
+	rows, err := p.conn().Query(p.rebind(`
+	SELECT KeyID FROM `+p.defTableName+`
+	WHERE Bucket=?
+		AND KeyID NOT LIKE ? ESCAPE '\'
+		AND (ExpiresAt IS NULL OR ExpiresAt > ?)
+	ORDER BY COALESCE(AccessedAt, UpdatedAt, 0), KeyID
+	LIMIT ?;`), bucket, hidden, now, count-maxKeys)
+	if err != nil {
+		return 0, err
+	}
+	var keys []string
+	for rows.Next() {
+		var k string
+		if err = rows.Scan(&k); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		keys = append(keys, k)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return 0, err
+	}
+	// the versions of an evicted key go with it, so none is saved
+	err = p.retry(func() error {
+		return p.withTx(func(tx *sql.Tx) error {
+			all := keys
+			for _, k := range keys {
+				more, err := p.companionKeys(tx, bucket, k)
+				if err != nil {
+					return err
+				}
+				all = append(all[:len(all):len(all)], more...)
+			}
+			return p.dropKeys(tx, "", bucket, all)
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+	for _, k := range keys {
+		p.afterDelete(bucket, k)
+	}
+	return len(keys), nil
+}
+
+// companionKeys returns the hidden keys stored for a key of a bucket,
+// its versions and locale variants
+func (p *SQLtPlainKV) companionKeys(tx *sql.Tx, bucket, key string) ([]string, error) {
+	vp := versionPrefix(key)
+	lp := strings.TrimSuffix(fmt.Sprintf(localeKey, "", ""), ":")
+	suffix := ":" + key
+	rows, err := p.on(tx).Query(p.rebind(`
+	SELECT KeyID FROM `+p.defTableName+`
+	WHERE Bucket=?
+		AND (KeyID LIKE ? ESCAPE '\' OR KeyID LIKE ? ESCAPE '\');`),
+		bucket, likePrefix(vp), likePrefix(lp)+strings.TrimSuffix(likePrefix(suffix), "%"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	keys := make([]string, 0)
+	for rows.Next() {
+		var k string
+		if err = rows.Scan(&k); err != nil {
+			return nil, err
+		}
+		// LIKE ignores case, and a locale holds no colon
+		if strings.HasPrefix(k, vp) {
+			keys = append(keys, k)
+		} else if strings.HasPrefix(k, lp) && strings.HasSuffix(k, suffix) &&
+			!strings.Contains(k[len(lp):len(k)-len(suffix)], ":") {
+			keys = append(keys, k)
+		}
+	}
+	return keys, rows.Err()
+}
+
+// enforceMaxKeys evicts keys of a bucket written to
+// beyond the MaxKeys of its options
+func (p *SQLtPlainKV) enforceMaxKeys(bucket string) error {
+	opts, ok := p.bucketOpts[bucket]
+	if !ok || opts.MaxKeys <= 0 {
+		return nil
+	}
+	_, err := p.evict(bucket, opts.MaxKeys)
+	return err
+}
@@ -0,0 +1,94 @@
+package sqltplainkv
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestEvict(t *testing.T) {
+	pkv := newTestKV(t)
+	pkv.SetAccessTracking(true)
+	for i := 0; i < 5; i++ {
+		pkv.Set(`k`+strconv.Itoa(i), []byte(`v`))
+		pkv.SetMime(`k`+strconv.Itoa(i), `text/plain`)
+		time.Sleep(time.Millisecond)
+	}
+	// reading k0 makes k1 the least recently used
+	pkv.Get(`k0`)
+
+	n, err := pkv.Evict(`default`, 3)
+	if err != nil || n != 2 {
+		t.Fatalf(`Expected 2 keys evicted, got %d (%v)`, n, err)
+	}
+	keys, _ := pkv.ListKeys(`k`)
+	if len(keys) != 3 || keys[0] != `k0` || keys[1] != `k3` {
+		t.Logf(`Expected k0, k3 and k4 to remain, got %v`, keys)
+		t.Fail()
+	}
+	if mime, _ := pkv.GetMime(`k1`); mime != `text/html` {
+		t.Logf(`Expected the mime of evicted keys to be removed, got %s`, mime)
+		t.Fail()
+	}
+	if n, _ = pkv.Evict(`default`, 3); n != 0 {
+		t.Logf(`Expected nothing to evict, got %d`, n)
+		t.Fail()
+	}
+}
+
+func TestMaxKeysPolicy(t *testing.T) {
+	pkv := newTestKV(t)
+	pkv.SetBucketOptions(`cache`, BucketOptions{MaxKeys: 2})
+	pkv.SetBucket(`cache`)
+	for i := 0; i < 4; i++ {
+		pkv.Set(`k`+strconv.Itoa(i), []byte(`v`))
+		time.Sleep(time.Millisecond)
+	}
+	keys, _ := pkv.ListKeys(``)
+	if len(keys) != 2 || keys[0] != `k2` || keys[1] != `k3` {
+		t.Logf(`Expected the bucket to keep the 2 newest keys, got %v`, keys)
+		t.Fail()
+	}
+	pkv.Touch(`k2`)
+	pkv.Set(`k4`, []byte(`v`))
+	if keys, _ = pkv.ListKeys(``); len(keys) != 2 || keys[0] != `k2` {
+		t.Logf(`Expected the touched key to be kept, got %v`, keys)
+		t.Fail()
+	}
+}
+
+func TestEvictWithCompanions(t *testing.T) {
+	pkv := newTestKV(t)
+	if err := pkv.SetBucketVersioning(`default`, true); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	pkv.Set(`old`, []byte(`v1`))
+	pkv.Set(`old`, []byte(`v2`))
+	pkv.SetLocale(`old`, `de`, []byte(`alt`))
+	pkv.TallyIncr(`visits`)
+	time.Sleep(time.Millisecond)
+	pkv.Set(`new`, []byte(`v`))
+
+	// only old and new count, and hidden keys are never picked
+	n, err := pkv.Evict(`default`, 1)
+	if err != nil || n != 1 {
+		t.Fatalf(`Expected 1 key evicted, got %d (%v)`, n, err)
+	}
+	if v, _ := pkv.Get(`new`); string(v) != `v` {
+		t.Logf(`Expected new to remain, got %q`, v)
+		t.Fail()
+	}
+	if versions, _ := pkv.ListVersions(`old`); len(versions) != 0 {
+		t.Logf(`Expected the versions to be evicted with the key, got %+v`, versions)
+		t.Fail()
+	}
+	if v, locale, _ := pkv.GetLocale(`old`, `de`); len(v) != 0 || locale != `` {
+		t.Logf(`Expected the locale variant to be evicted with the key, got %q`, v)
+		t.Fail()
+	}
+	if ok, err := pkv.exists(nil, `default`, fmt.Sprintf(tallyKey, `visits`)); !ok {
+		t.Logf(`Expected the tally to remain, got %v`, err)
+		t.Fail()
+	}
+}
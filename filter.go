@@ -0,0 +1,457 @@
+package sqltplainkv
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var (
+	ErrInvalidFilter error = errors.New(`invalid filter`)
+)
+
+// filterFields maps the field names usable in filter expressions
+// to the SQL expression they stand for. Table t is the key-value
+// table. Times are expressed in Unix seconds
+var filterFields = map[string]func(p *SQLtPlainKV) string{
+	`key`: func(p *SQLtPlainKV) string {
+		return `t.KeyID`
+	},
+	`size`: func(p *SQLtPlainKV) string {
+		return `length(t.Value)`
+	},
+	`mime`: func(p *SQLtPlainKV) string {
+		return `t.Mime`
+	},
+	`expires_at`: func(p *SQLtPlainKV) string {
+		return `(t.ExpiresAt / 1000000000)`
+	},
+	`created_at`: func(p *SQLtPlainKV) string {
+		return `(t.CreatedAt / 1000000000)`
+	},
+	`updated_at`: func(p *SQLtPlainKV) string {
+		return `(t.UpdatedAt / 1000000000)`
+	},
+}
+
+// Filter is a compiled filter expression
+//
+// A filter compares the fields key, size, mime, expires_at, created_at
+// and updated_at against literals, for example:
+//
+//	size > 1024 && mime == "image/png"
+//	key like "user:%" || expires_at < now()+3600
+//	updated_at > now()-86400
+//
+// Supported operators are ==, !=, <, <=, >, >=, like, &&, ||, !, + and -.
+// The function now() returns the current time in Unix seconds.
+type Filter struct {
+	expr  string
+	where string
+	args  []any
+}
+
+// CompileFilter parses a filter expression
+func (p *SQLtPlainKV) CompileFilter(expr string) (*Filter, error) {
+	fp := &filterParser{p: p, src: expr}
+	if err := fp.tokenize(); err != nil {
+		return nil, err
+	}
+	where, err := fp.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if fp.pos < len(fp.toks) {
+		return nil, fp.errorf(`unexpected %q`, fp.toks[fp.pos].text)
+	}
+	return &Filter{
+		expr:  expr,
+		where: where,
+		args:  fp.args,
+	}, nil
+}
+
+// String returns the source expression of the filter
+func (f *Filter) String() string {
+	return f.expr
+}
+
+// ListWhere lists all keys in the current bucket matching the filter expression
+func (p *SQLtPlainKV) ListWhere(filter string) ([]string, error) {
+	var (
+		err error
+		val []string
+		k   string
+		sqr *sql.Rows
+	)
+
+	val = make([]string, 0)
+	f, err := p.CompileFilter(filter)
+	if err != nil {
+		return val, err
+	}
+	if err = p.open(); err != nil {
+		return val, err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	if p.currBuckt == "" {
+		p.currBuckt = "default"
+	}
+	sqlstr := p.rebind(`
+	SELECT t.KeyID FROM ` + p.defTableName + ` t
+	WHERE t.Bucket=?
+		AND (t.ExpiresAt IS NULL OR t.ExpiresAt > ?)
+		AND (` + f.where + `);`)
+	args := append([]any{p.currBuckt, time.Now().UnixNano()}, f.args...)
+	if sqr, err = p.conn().Query(sqlstr, args...); err != nil {
+		return val, err
+	}
+	defer sqr.Close()
+	for sqr.Next() {
+		if err = sqr.Scan(&k); err != nil {
+			return val, err
+		}
+		val = append(val, k)
+	}
+	if err = sqr.Err(); err != nil {
+		return val, err
+	}
+	return val, nil
+}
+
+// DelWhere deletes all records in the current bucket matching the
+// filter expression, along with their mime, and returns their count
+func (p *SQLtPlainKV) DelWhere(filter string) (int, error) {
+	var err error
+
+	if _, err = p.CompileFilter(filter); err != nil {
+		return 0, err
+	}
+	if err = p.open(); err != nil {
+		return 0, err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	keys, err := p.ListWhere(filter)
+	if err != nil {
+		return 0, err
+	}
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	if err = p.delKeys(p.currBuckt, keys); err != nil {
+		return 0, err
+	}
+	return len(keys), nil
+}
+
+// delKeys deletes keys of a bucket along with their mime
+// and chunks, in a single transaction
+func (p *SQLtPlainKV) delKeys(bucket string, keys []string) error {
+	err := p.retry(func() error {
+		return p.withTx(func(tx *sql.Tx) error {
+			for _, k := range keys {
+				if err := p.saveVersion(tx, bucket, k); err != nil {
+					return err
+				}
+			}
+			return p.dropKeys(tx, "", bucket, keys)
+		})
+	})
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		p.afterDelete(bucket, k)
+	}
+	return nil
+}
+
+// dropKeys deletes keys of a bucket along with their mime and chunks
+// in the transaction of a write on behalf of the principal, recording
+// the deletes without running the hooks
+func (p *SQLtPlainKV) dropKeys(tx *sql.Tx, principal, bucket string, keys []string) error {
+	stmt, err := p.stmtOn(tx, stmtDel)
+	if err != nil {
+		return err
+	}
+	delChunks, err := p.stmtOn(tx, stmtDelChunks)
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		p.warmDel(bucket, k)
+		if _, err = stmt.Exec(bucket, k); err != nil {
+			return err
+		}
+		if _, err = delChunks.Exec(chunkRangeArgs(bucket, k)...); err != nil {
+			return err
+		}
+		if err = p.recordChange(tx, principal, ChangeDelete, bucket, k, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type filterToken struct {
+	kind byte // i: identifier, n: number, s: string, o: operator
+	text string
+	pos  int
+}
+
+type filterParser struct {
+	p    *SQLtPlainKV
+	src  string
+	toks []filterToken
+	pos  int
+	args []any
+}
+
+func (fp *filterParser) errorf(format string, a ...any) error {
+	pos := len(fp.src)
+	if fp.pos < len(fp.toks) {
+		pos = fp.toks[fp.pos].pos
+	}
+	return fmt.Errorf(`%w at %d: %s`, ErrInvalidFilter, pos, fmt.Sprintf(format, a...))
+}
+
+func (fp *filterParser) tokenize() error {
+	src := fp.src
+	for i := 0; i < len(src); {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case unicode.IsLetter(c) || c == '_':
+			j := i
+			for j < len(src) && (unicode.IsLetter(rune(src[j])) || unicode.IsDigit(rune(src[j])) || src[j] == '_') {
+				j++
+			}
+			fp.toks = append(fp.toks, filterToken{kind: 'i', text: src[i:j], pos: i})
+			i = j
+		case unicode.IsDigit(c) || c == '.':
+			j := i
+			for j < len(src) && (unicode.IsDigit(rune(src[j])) || src[j] == '.') {
+				j++
+			}
+			fp.toks = append(fp.toks, filterToken{kind: 'n', text: src[i:j], pos: i})
+			i = j
+		case c == '"' || c == '\'':
+			j := i + 1
+			var sb strings.Builder
+			for ; j < len(src) && rune(src[j]) != c; j++ {
+				if src[j] == '\\' && j+1 < len(src) {
+					j++
+				}
+				sb.WriteByte(src[j])
+			}
+			if j >= len(src) {
+				return fmt.Errorf(`%w at %d: unterminated string`, ErrInvalidFilter, i)
+			}
+			fp.toks = append(fp.toks, filterToken{kind: 's', text: sb.String(), pos: i})
+			i = j + 1
+		default:
+			op := ""
+			for _, o := range []string{`&&`, `||`, `==`, `!=`, `<=`, `>=`, `<`, `>`, `!`, `+`, `-`, `(`, `)`} {
+				if strings.HasPrefix(src[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return fmt.Errorf(`%w at %d: unexpected %q`, ErrInvalidFilter, i, c)
+			}
+			fp.toks = append(fp.toks, filterToken{kind: 'o', text: op, pos: i})
+			i += len(op)
+		}
+	}
+	return nil
+}
+
+func (fp *filterParser) peek(kind byte, text string) bool {
+	if fp.pos >= len(fp.toks) {
+		return false
+	}
+	t := fp.toks[fp.pos]
+	return t.kind == kind && strings.EqualFold(t.text, text)
+}
+
+func (fp *filterParser) parseOr() (string, error) {
+	left, err := fp.parseAnd()
+	if err != nil {
+		return "", err
+	}
+	for fp.peek('o', `||`) {
+		fp.pos++
+		right, err := fp.parseAnd()
+		if err != nil {
+			return "", err
+		}
+		left = `(` + left + ` OR ` + right + `)`
+	}
+	return left, nil
+}
+
+func (fp *filterParser) parseAnd() (string, error) {
+	left, err := fp.parseNot()
+	if err != nil {
+		return "", err
+	}
+	for fp.peek('o', `&&`) {
+		fp.pos++
+		right, err := fp.parseNot()
+		if err != nil {
+			return "", err
+		}
+		left = `(` + left + ` AND ` + right + `)`
+	}
+	return left, nil
+}
+
+func (fp *filterParser) parseNot() (string, error) {
+	if fp.peek('o', `!`) {
+		fp.pos++
+		expr, err := fp.parseNot()
+		if err != nil {
+			return "", err
+		}
+		return `(NOT ` + expr + `)`, nil
+	}
+	return fp.parseCompare()
+}
+
+func (fp *filterParser) parseCompare() (string, error) {
+	left, err := fp.parseSum()
+	if err != nil {
+		return "", err
+	}
+	ops := map[string]string{
+		`==`: `=`, `!=`: `<>`, `<`: `<`, `<=`: `<=`, `>`: `>`, `>=`: `>=`,
+	}
+	if fp.pos < len(fp.toks) {
+		t := fp.toks[fp.pos]
+		op, ok := ops[t.text]
+		if t.kind == 'o' && ok {
+			fp.pos++
+		} else if t.kind == 'i' && strings.EqualFold(t.text, `like`) {
+			op = `LIKE`
+			fp.pos++
+		} else {
+			return left, nil
+		}
+		right, err := fp.parseSum()
+		if err != nil {
+			return "", err
+		}
+		return `(` + left + ` ` + op + ` ` + right + `)`, nil
+	}
+	return left, nil
+}
+
+func (fp *filterParser) parseSum() (string, error) {
+	left, err := fp.parseUnary()
+	if err != nil {
+		return "", err
+	}
+	for fp.peek('o', `+`) || fp.peek('o', `-`) {
+		op := fp.toks[fp.pos].text
+		fp.pos++
+		right, err := fp.parseUnary()
+		if err != nil {
+			return "", err
+		}
+		left = `(` + left + ` ` + op + ` ` + right + `)`
+	}
+	return left, nil
+}
+
+func (fp *filterParser) parseUnary() (string, error) {
+	if fp.peek('o', `-`) {
+		fp.pos++
+		expr, err := fp.parseUnary()
+		if err != nil {
+			return "", err
+		}
+		return `(-` + expr + `)`, nil
+	}
+	return fp.parsePrimary()
+}
+
+func (fp *filterParser) parsePrimary() (string, error) {
+	if fp.pos >= len(fp.toks) {
+		return "", fp.errorf(`unexpected end of filter`)
+	}
+	t := fp.toks[fp.pos]
+	switch t.kind {
+	case 'n':
+		fp.pos++
+		if n, err := strconv.ParseInt(t.text, 10, 64); err == nil {
+			fp.args = append(fp.args, n)
+			return `?`, nil
+		}
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			fp.pos--
+			return "", fp.errorf(`invalid number %q`, t.text)
+		}
+		fp.args = append(fp.args, f)
+		return `?`, nil
+	case 's':
+		fp.pos++
+		fp.args = append(fp.args, t.text)
+		return `?`, nil
+	case 'i':
+		name := strings.ToLower(t.text)
+		if fp.pos+1 < len(fp.toks) && fp.toks[fp.pos+1].text == `(` {
+			if name != `now` {
+				return "", fp.errorf(`unknown function %q`, t.text)
+			}
+			fp.pos += 2
+			if !fp.peek('o', `)`) {
+				return "", fp.errorf(`expected )`)
+			}
+			fp.pos++
+			fp.args = append(fp.args, time.Now().Unix())
+			return `?`, nil
+		}
+		switch name {
+		case `true`:
+			fp.pos++
+			return `1`, nil
+		case `false`:
+			fp.pos++
+			return `0`, nil
+		case `null`:
+			fp.pos++
+			return `NULL`, nil
+		}
+		field, ok := filterFields[name]
+		if !ok {
+			return "", fp.errorf(`unknown field %q`, t.text)
+		}
+		fp.pos++
+		return field(fp.p), nil
+	case 'o':
+		if t.text == `(` {
+			fp.pos++
+			expr, err := fp.parseOr()
+			if err != nil {
+				return "", err
+			}
+			if !fp.peek('o', `)`) {
+				return "", fp.errorf(`expected )`)
+			}
+			fp.pos++
+			return `(` + expr + `)`, nil
+		}
+	}
+	return "", fp.errorf(`unexpected %q`, t.text)
+}
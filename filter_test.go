@@ -0,0 +1,53 @@
+package sqltplainkv
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	pkv := newTestKV(t)
+
+	pkv.Set(`small.txt`, []byte(`tiny`))
+	pkv.Set(`large.png`, []byte(strings.Repeat(`x`, 2048)))
+	pkv.SetMime(`large.png`, `image/png`)
+	pkv.Set(`large.txt`, []byte(strings.Repeat(`y`, 2048)))
+
+	keys, err := pkv.ListWhere(`size > 1024 && mime == "image/png"`)
+	if err != nil {
+		t.Fatalf(`%s`, err)
+	}
+	if len(keys) != 1 || keys[0] != `large.png` {
+		t.Logf(`Expected [large.png], got %v`, keys)
+		t.Fail()
+	}
+
+	keys, err = pkv.ListWhere(`key like "%.txt" && !(size > 1024)`)
+	if err != nil {
+		t.Fatalf(`%s`, err)
+	}
+	if len(keys) != 1 || keys[0] != `small.txt` {
+		t.Logf(`Expected [small.txt], got %v`, keys)
+		t.Fail()
+	}
+
+	n, err := pkv.DelWhere(`size >= 2048`)
+	if err != nil {
+		t.Fatalf(`%s`, err)
+	}
+	keys, _ = pkv.ListKeys(``)
+	sort.Strings(keys)
+	if n != 2 || len(keys) != 1 || keys[0] != `small.txt` {
+		t.Logf(`Expected 2 deletions leaving [small.txt], got %d %v`, n, keys)
+		t.Fail()
+	}
+
+	for _, bad := range []string{`size >`, `colour == "red"`, `size > 1 )`, `key == "open`} {
+		if _, err := pkv.CompileFilter(bad); !errors.Is(err, ErrInvalidFilter) {
+			t.Logf(`Expected ErrInvalidFilter for %q, got %v`, bad, err)
+			t.Fail()
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package sqltplainkv
+
+import (
+	"bytes"
+	"time"
+)
+
+// FindValues returns the keys of a bucket whose values contain the
+// bytes, in order, at most limit of them, or all of them for a limit
+// of zero or less. Values stored as they are, without a codec, are
+// matched by SQLite, the others are read and decoded to be matched, so
+// it scans the bucket and suits ad hoc investigations rather than
+// frequent lookups, for which see EnableSearch
+func (p *SQLtPlainKV) FindValues(bucket string, contains []byte, limit int) ([]string, error) {
+	var err error
+	if bucket == "" {
+		bucket = "default"
+	}
+	keys := make([]string, 0)
+	if err = p.open(); err != nil {
+		return keys, err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	// compressed, encrypted, chunked and other wrapped values start
+	// with envMagic and values of a codec cannot be matched as stored
+	coded := p.codecFor(bucket) != nil && !isInternalBucket(bucket)
+	magic := []byte(envMagic)
+	rows, err := p.conn().Query(p.rebind(`
+	SELECT KeyID, substr(Value, 1, ?) = ? FROM `+p.defTableName+`
+	WHERE Bucket=?
+		AND KeyID NOT LIKE ? ESCAPE '\'
+		AND (ExpiresAt IS NULL OR ExpiresAt > ?)
+		AND (? OR instr(Value, ?) > 0 OR substr(Value, 1, ?) = ?)
+	ORDER BY KeyID;`), len(magic), magic, bucket, likePrefix(internalKeyPrefix), time.Now().UnixNano(),
+		coded, contains, len(magic), magic)
+	if err != nil {
+		return keys, err
+	}
+	defer rows.Close()
+	type candidate struct {
+		key    string
+		decode bool
+	}
+	found := make([]candidate, 0)
+	for rows.Next() {
+		var (
+			c       candidate
+			wrapped bool
+		)
+		if err = rows.Scan(&c.key, &wrapped); err != nil {
+			return keys, err
+		}
+		c.decode = wrapped || coded
+		found = append(found, c)
+	}
+	if err = rows.Err(); err != nil {
+		return keys, err
+	}
+	rows.Close()
+	for _, c := range found {
+		if limit > 0 && len(keys) >= limit {
+			break
+		}
+		if c.decode {
+			val, err := p.get(bucket, c.key)
+			if err != nil {
+				return keys, err
+			}
+			if !bytes.Contains(val, contains) {
+				continue
+			}
+		}
+		keys = append(keys, c.key)
+	}
+	return keys, nil
+}
@@ -0,0 +1,50 @@
+package sqltplainkv
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFindValues(t *testing.T) {
+	pkv := newTestKV(t)
+	pkv.SetLimits(Limits{ChunkSize: 64})
+	pkv.SetBucket(`logs`)
+	pkv.Set(`a`, []byte(`request failed: timeout`))
+	pkv.Set(`b`, []byte(`request ok`))
+	pkv.Set(`c`, []byte(strings.Repeat(`padding `, 20)+`timeout`))
+	pkv.SetBucketOptions(`logs`, BucketOptions{Compression: Gzip})
+	pkv.Set(`d`, []byte(`compressed timeout entry`))
+	pkv.Set(`e`, []byte(`compressed entry`))
+	pkv.Tally(`timeout`, 0)
+	pkv.SetBucket(`other`)
+	pkv.Set(`x`, []byte(`timeout elsewhere`))
+
+	keys, err := pkv.FindValues(`logs`, []byte(`timeout`), 0)
+	if err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if strings.Join(keys, ` `) != `a c d` {
+		t.Logf(`Expected a c d, got %v`, keys)
+		t.Fail()
+	}
+	if keys, _ = pkv.FindValues(`logs`, []byte(`timeout`), 2); strings.Join(keys, ` `) != `a c` {
+		t.Logf(`Expected the first 2 keys, got %v`, keys)
+		t.Fail()
+	}
+	if keys, _ = pkv.FindValues(`logs`, []byte(`missing`), 0); len(keys) != 0 {
+		t.Logf(`Expected no keys, got %v`, keys)
+		t.Fail()
+	}
+}
+
+func TestFindValuesCodec(t *testing.T) {
+	pkv := newTestKV(t)
+	pkv.SetBucketCodec(`users`, xorCodec(0xFF))
+	pkv.SetBucket(`users`)
+	pkv.Set(`alice`, []byte(`{"name":"Alice","role":"admin"}`))
+	pkv.Set(`bob`, []byte(`{"name":"Bob","role":"user"}`))
+	if keys, err := pkv.FindValues(`users`, []byte(`admin`), 0); err != nil || strings.Join(keys, ` `) != `alice` {
+		t.Logf(`Expected alice, got %v (%v)`, keys, err)
+		t.Fail()
+	}
+}
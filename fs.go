@@ -0,0 +1,208 @@
+package sqltplainkv
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// internalKeyPrefix starts the keys of tallies, locale variants and
+// versions, which are hidden from file system listings
+const internalKeyPrefix string = `_______#`
+
+// kvFS is a read-only fs.FS over the keys of a bucket
+type kvFS struct {
+	p      *SQLtPlainKV
+	bucket string
+}
+
+// AsFS returns a read-only fs.FS over a bucket, taking keys as slash
+// separated file paths. Directories are not stored, they exist for as
+// long as keys start with their path. Files have the size of their
+// value and their last update as modification time. Keys that are not
+// valid paths, such as ones starting with a slash, cannot be opened.
+// The values of opened files are read into memory
+func (p *SQLtPlainKV) AsFS(bucket string) fs.FS {
+	if bucket == "" {
+		bucket = "default"
+	}
+	return &kvFS{p: p, bucket: bucket}
+}
+
+// Open opens the file or directory of the name
+func (f *kvFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: `open`, Path: name, Err: fs.ErrInvalid}
+	}
+	p := f.p
+	if err := p.open(); err != nil {
+		return nil, &fs.PathError{Op: `open`, Path: name, Err: err}
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	if name != "." {
+		meta, err := p.getMeta(f.bucket, name)
+		if err == nil {
+			val, err := p.get(f.bucket, name)
+			if err != nil {
+				return nil, &fs.PathError{Op: `open`, Path: name, Err: err}
+			}
+			return &kvFile{
+				info: kvFileInfo{
+					name:    path.Base(name),
+					size:    int64(len(val)),
+					modTime: meta.UpdatedAt,
+				},
+				r: bytes.NewReader(val),
+			}, nil
+		}
+		if !errors.Is(err, ErrKeyNotFound) {
+			return nil, &fs.PathError{Op: `open`, Path: name, Err: err}
+		}
+	}
+	entries, err := f.readDir(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: `open`, Path: name, Err: err}
+	}
+	if len(entries) == 0 && name != "." {
+		return nil, &fs.PathError{Op: `open`, Path: name, Err: fs.ErrNotExist}
+	}
+	return &kvDir{
+		info:    kvFileInfo{name: path.Base(name), dir: true},
+		entries: entries,
+	}, nil
+}
+
+// ReadFile reads the value of the key of the name
+func (f *kvFS) ReadFile(name string) ([]byte, error) {
+	file, err := f.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	kf, ok := file.(*kvFile)
+	if !ok {
+		return nil, &fs.PathError{Op: `read`, Path: name, Err: errors.New(`is a directory`)}
+	}
+	return io.ReadAll(kf.r)
+}
+
+// readDir lists the files and directories directly under a directory
+func (f *kvFS) readDir(dir string) ([]fs.DirEntry, error) {
+	prefix := ""
+	if dir != "." {
+		prefix = dir + "/"
+	}
+	// LIKE matches more than the prefix, so the keys are checked again
+	keys, err := f.p.listKeys(f.bucket, prefix)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	entries := make([]fs.DirEntry, 0)
+	for _, k := range keys {
+		if !strings.HasPrefix(k, prefix) || strings.HasPrefix(k, internalKeyPrefix) || !fs.ValidPath(k) {
+			continue
+		}
+		rest := k[len(prefix):]
+		name, _, isDir := strings.Cut(rest, "/")
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		info := kvFileInfo{name: name, dir: isDir}
+		if !isDir {
+			meta, err := f.p.getMeta(f.bucket, k)
+			if err != nil {
+				if errors.Is(err, ErrKeyNotFound) {
+					continue // expired since it was listed
+				}
+				return nil, err
+			}
+			info.size = meta.Size
+			info.modTime = meta.UpdatedAt
+		}
+		entries = append(entries, fs.FileInfoToDirEntry(info))
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries, nil
+}
+
+// kvFileInfo describes a key or a synthesized directory
+type kvFileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+func (i kvFileInfo) Name() string       { return i.name }
+func (i kvFileInfo) Size() int64        { return i.size }
+func (i kvFileInfo) ModTime() time.Time { return i.modTime }
+func (i kvFileInfo) IsDir() bool        { return i.dir }
+func (i kvFileInfo) Sys() any           { return nil }
+
+func (i kvFileInfo) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0o555
+	}
+	return 0o444
+}
+
+// kvFile is an opened key. It supports seeking for http.FileServer
+type kvFile struct {
+	info kvFileInfo
+	r    *bytes.Reader
+}
+
+func (f *kvFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *kvFile) Read(b []byte) (int, error) { return f.r.Read(b) }
+func (f *kvFile) Close() error               { return nil }
+
+func (f *kvFile) Seek(offset int64, whence int) (int64, error) {
+	return f.r.Seek(offset, whence)
+}
+
+func (f *kvFile) ReadAt(b []byte, off int64) (int, error) {
+	return f.r.ReadAt(b, off)
+}
+
+// kvDir is an opened directory
+type kvDir struct {
+	info    kvFileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *kvDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *kvDir) Close() error               { return nil }
+
+func (d *kvDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: `read`, Path: d.info.name, Err: errors.New(`is a directory`)}
+}
+
+// ReadDir returns the next n entries of the directory, or all the
+// remaining ones if n is zero or less
+func (d *kvDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return rest, nil
+	}
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(rest) {
+		n = len(rest)
+	}
+	d.offset += n
+	return rest[:n], nil
+}
@@ -0,0 +1,64 @@
+package sqltplainkv
+
+import (
+	"errors"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestAsFS(t *testing.T) {
+	pkv := newTestKV(t)
+	pkv.SetBucket(`assets`)
+	pkv.Set(`index.html`, []byte(`<h1>home</h1>`))
+	pkv.Set(`css/site.css`, []byte(`body{}`))
+	pkv.Set(`css/print/a4.css`, []byte(`@page{}`))
+	pkv.Set(`tmpl/hello.tmpl`, []byte(`Hello {{.}}`))
+	pkv.Set(`cssx`, []byte(`not in css`))
+	pkv.TallyIncr(`visits`)
+	pkv.SetLocale(`index.html`, `de`, []byte(`<h1>Start</h1>`))
+	pkv.Set(`/absolute`, []byte(`skipped`))
+
+	fsys := pkv.AsFS(`assets`)
+	if err := fstest.TestFS(fsys, `index.html`, `css/site.css`, `css/print/a4.css`, `tmpl/hello.tmpl`, `cssx`); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	entries, err := fs.ReadDir(fsys, `.`)
+	if err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if strings.Join(names, `,`) != `css,cssx,index.html,tmpl` {
+		t.Logf(`Unexpected root entries %v`, names)
+		t.Fail()
+	}
+	if _, err = fs.Stat(fsys, `missing.txt`); !errors.Is(err, fs.ErrNotExist) {
+		t.Logf(`Expected fs.ErrNotExist, got %v`, err)
+		t.Fail()
+	}
+
+	tmpl, err := template.ParseFS(fsys, `tmpl/*.tmpl`)
+	if err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	var sb strings.Builder
+	tmpl.Execute(&sb, `world`)
+	if sb.String() != `Hello world` {
+		t.Logf(`Unexpected template output %q`, sb.String())
+		t.Fail()
+	}
+
+	rec := httptest.NewRecorder()
+	http.FileServer(http.FS(fsys)).ServeHTTP(rec, httptest.NewRequest(`GET`, `/css/site.css`, nil))
+	if rec.Code != 200 || rec.Body.String() != `body{}` {
+		t.Logf(`Unexpected file server response %d %q`, rec.Code, rec.Body.String())
+		t.Fail()
+	}
+}
@@ -0,0 +1,20 @@
+package sqltplainkv
+
+const (
+	mimeGob string = `application/x-gob`
+)
+
+// SetGob encodes v using encoding/gob and stores it by the key.
+// The mime of the key is set to application/x-gob
+func (p *SQLtPlainKV) SetGob(key string, v any) error {
+	if p.currBuckt == "" {
+		p.currBuckt = "default"
+	}
+	return p.setObject(p.currBuckt, key, v, GobSerializer)
+}
+
+// GetGob retrieves a record using a key and decodes it into out.
+// It returns ErrKeyNotFound if the key does not exist
+func (p *SQLtPlainKV) GetGob(key string, out any) error {
+	return p.getObject(p.currBuckt, key, out, GobSerializer)
+}
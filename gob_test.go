@@ -0,0 +1,39 @@
+package sqltplainkv
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestGob(t *testing.T) {
+	pkv := newTestKV(t)
+
+	type sample struct {
+		Name string
+		Tags []string
+	}
+
+	if err := pkv.SetGob(`sample_gob`, sample{Name: `widget`, Tags: []string{`a`, `b`}}); err != nil {
+		t.Fatalf(`%s`, err)
+	}
+
+	var out sample
+	if err := pkv.GetGob(`sample_gob`, &out); err != nil {
+		t.Fatalf(`%s`, err)
+	}
+	if out.Name != `widget` || len(out.Tags) != 2 {
+		t.Logf(`Unexpected value: %+v`, out)
+		t.Fail()
+	}
+
+	mime, err := pkv.GetMime(`sample_gob`)
+	if err != nil || mime != `application/x-gob` {
+		t.Logf(`Expected application/x-gob, got %s (%v)`, mime, err)
+		t.Fail()
+	}
+
+	if err := pkv.GetGob(`missing_gob`, &out); !errors.Is(err, ErrKeyNotFound) {
+		t.Logf(`Expected ErrKeyNotFound, got %v`, err)
+		t.Fail()
+	}
+}
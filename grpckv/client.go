@@ -0,0 +1,253 @@
+package grpckv
+
+import (
+	"context"
+	"time"
+
+	sqltplainkv "github.com/narsilworks/sqlt-plainkv"
+	"google.golang.org/grpc"
+)
+
+// Client is a sqltplainkv.PlainKVer backed by a remote store served
+// by Server. Writes made between Begin and Commit are buffered and
+// sent in a single batch applied in one transaction on Commit, so
+// reads made in between do not see them. Tallies are not buffered.
+// A Client is not safe for concurrent use
+type Client struct {
+	kv      KVClient
+	cc      *grpc.ClientConn // closed by Close when set
+	bucket  string
+	batch   []*Write
+	inBatch bool
+
+	// Timeout bounds every call, zero leaves calls unbounded
+	Timeout time.Duration
+}
+
+var _ sqltplainkv.PlainKVer = (*Client)(nil)
+
+// Dial connects to a server and returns a client closing
+// the connection on Close
+func Dial(target string, opts ...grpc.DialOption) (*Client, error) {
+	cc, err := grpc.Dial(target, opts...)
+	if err != nil {
+		return nil, err
+	}
+	c := NewClient(cc)
+	c.cc = cc
+	return c, nil
+}
+
+// NewClient creates a client on a connection
+func NewClient(cc grpc.ClientConnInterface) *Client {
+	return &Client{
+		kv:     NewKVClient(cc),
+		bucket: "default",
+	}
+}
+
+func (c *Client) ctx() (context.Context, context.CancelFunc) {
+	if c.Timeout > 0 {
+		return context.WithTimeout(context.Background(), c.Timeout)
+	}
+	return context.WithCancel(context.Background())
+}
+
+// Open does nothing, connections are opened when dialing
+func (c *Client) Open() error {
+	return nil
+}
+
+// Close closes the connection if the client was created by Dial
+func (c *Client) Close() error {
+	if c.cc == nil {
+		return nil
+	}
+	return c.cc.Close()
+}
+
+// SetBucket sets the current bucket
+func (c *Client) SetBucket(bucket string) {
+	if bucket == "" {
+		bucket = "default"
+	}
+	c.bucket = bucket
+}
+
+// Get retrieves a record using a key
+func (c *Client) Get(key string) ([]byte, error) {
+	ctx, cancel := c.ctx()
+	defer cancel()
+	r, err := c.kv.Get(ctx, &KeyRequest{Bucket: c.bucket, Key: key})
+	if err != nil {
+		return make([]byte, 0), fromStatus(err)
+	}
+	if r.Value == nil {
+		return make([]byte, 0), nil
+	}
+	return r.Value, nil
+}
+
+// Set creates or updates the record by the value
+func (c *Client) Set(key string, value []byte) error {
+	return c.SetWithTTL(key, value, 0)
+}
+
+// SetWithTTL creates or updates the record by the value, expiring it
+// after the ttl. A ttl of zero or less stores the record without
+// expiration. The ttl is ignored between Begin and Commit
+func (c *Client) SetWithTTL(key string, value []byte, ttl time.Duration) error {
+	if c.inBatch {
+		c.batch = append(c.batch, &Write{Op: WriteOp_WRITE_SET, Bucket: c.bucket, Key: key, Value: value})
+		return nil
+	}
+	ctx, cancel := c.ctx()
+	defer cancel()
+	_, err := c.kv.Set(ctx, &SetRequest{Bucket: c.bucket, Key: key, Value: value, TtlMs: ttl.Milliseconds()})
+	return fromStatus(err)
+}
+
+// Del deletes a record with the provided key
+func (c *Client) Del(key string) error {
+	if c.inBatch {
+		c.batch = append(c.batch, &Write{Op: WriteOp_WRITE_DEL, Bucket: c.bucket, Key: key})
+		return nil
+	}
+	ctx, cancel := c.ctx()
+	defer cancel()
+	_, err := c.kv.Del(ctx, &KeyRequest{Bucket: c.bucket, Key: key})
+	return fromStatus(err)
+}
+
+// ListKeys lists all keys containing the current pattern
+func (c *Client) ListKeys(pattern string) ([]string, error) {
+	ctx, cancel := c.ctx()
+	defer cancel()
+	r, err := c.kv.List(ctx, &ListRequest{Bucket: c.bucket, Pattern: pattern})
+	if err != nil {
+		return make([]string, 0), fromStatus(err)
+	}
+	if r.Keys == nil {
+		return make([]string, 0), nil
+	}
+	return r.Keys, nil
+}
+
+// GetMime gets the mime of the value stored
+func (c *Client) GetMime(key string) (string, error) {
+	ctx, cancel := c.ctx()
+	defer cancel()
+	r, err := c.kv.GetMime(ctx, &KeyRequest{Bucket: c.bucket, Key: key})
+	if err != nil {
+		return "", fromStatus(err)
+	}
+	return r.Mime, nil
+}
+
+// SetMime sets the mime of the value stored
+func (c *Client) SetMime(key string, mime string) error {
+	if c.inBatch {
+		c.batch = append(c.batch, &Write{Op: WriteOp_WRITE_SET_MIME, Bucket: c.bucket, Key: key, Value: []byte(mime)})
+		return nil
+	}
+	ctx, cancel := c.ctx()
+	defer cancel()
+	_, err := c.kv.SetMime(ctx, &SetMimeRequest{Bucket: c.bucket, Key: key, Mime: mime})
+	return fromStatus(err)
+}
+
+func (c *Client) tally(key string, op TallyOp, offset int) (int, error) {
+	ctx, cancel := c.ctx()
+	defer cancel()
+	r, err := c.kv.Tally(ctx, &TallyRequest{Bucket: c.bucket, Key: key, Op: op, Offset: int64(offset)})
+	if err != nil {
+		return -1, fromStatus(err)
+	}
+	return int(r.Value), nil
+}
+
+// Tally gets the current tally of a key, starting at offset
+func (c *Client) Tally(key string, offset int) (int, error) {
+	return c.tally(key, TallyOp_TALLY_GET, offset)
+}
+
+// TallyIncr increments a tally
+func (c *Client) TallyIncr(key string) (int, error) {
+	return c.tally(key, TallyOp_TALLY_INCR, 0)
+}
+
+// TallyDecr decrements a tally
+func (c *Client) TallyDecr(key string) (int, error) {
+	return c.tally(key, TallyOp_TALLY_DECR, 0)
+}
+
+// TallyReset resets a tally
+func (c *Client) TallyReset(key string) error {
+	_, err := c.tally(key, TallyOp_TALLY_RESET, 0)
+	return err
+}
+
+// Begin starts buffering writes until Commit or Rollback
+func (c *Client) Begin() error {
+	c.inBatch = true
+	c.batch = nil
+	return nil
+}
+
+// Commit sends the buffered writes to be applied in one transaction
+func (c *Client) Commit() error {
+	if !c.inBatch {
+		return nil
+	}
+	batch := c.batch
+	c.inBatch = false
+	c.batch = nil
+	if len(batch) == 0 {
+		return nil
+	}
+	ctx, cancel := c.ctx()
+	defer cancel()
+	_, err := c.kv.Batch(ctx, &BatchRequest{Writes: batch})
+	return fromStatus(err)
+}
+
+// Rollback discards the buffered writes
+func (c *Client) Rollback() error {
+	c.inBatch = false
+	c.batch = nil
+	return nil
+}
+
+// Watch reports the changes of the keys of the current bucket
+// starting with pattern until the context is done or the stream
+// fails. The channel is closed when the watch ends
+func (c *Client) Watch(ctx context.Context, pattern string) (<-chan sqltplainkv.ChangeEvent, error) {
+	stream, err := c.kv.Watch(ctx, &WatchRequest{Bucket: c.bucket, Pattern: pattern})
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+	if _, err = stream.Header(); err != nil {
+		return nil, fromStatus(err)
+	}
+	ch := make(chan sqltplainkv.ChangeEvent)
+	go func() {
+		defer close(ch)
+		for {
+			ev, err := stream.Recv()
+			if err != nil {
+				return
+			}
+			select {
+			case ch <- sqltplainkv.ChangeEvent{
+				Type:   sqltplainkv.ChangeType(ev.Type),
+				Bucket: ev.Bucket,
+				Key:    ev.Key,
+				Value:  ev.Value,
+			}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
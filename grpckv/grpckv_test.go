@@ -0,0 +1,138 @@
+package grpckv
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	sqltplainkv "github.com/narsilworks/sqlt-plainkv"
+	"github.com/narsilworks/sqlt-plainkv/sqltplainkvtest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newTestClient(t *testing.T) (*Client, *sqltplainkv.SQLtPlainKV) {
+	kv := sqltplainkvtest.NewTempKV(t, sqltplainkv.WithMaxValueSize(1024))
+	lis := bufconn.Listen(1 << 20)
+	gs := grpc.NewServer()
+	NewServer(kv).Register(gs)
+	go gs.Serve(lis)
+	t.Cleanup(gs.Stop)
+
+	c, err := Dial(`bufnet`,
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf(`Dial: %v`, err)
+	}
+	c.Timeout = 5 * time.Second
+	t.Cleanup(func() { c.Close() })
+	return c, kv
+}
+
+func TestClient(t *testing.T) {
+	c, kv := newTestClient(t)
+
+	c.SetBucket(`users`)
+	if err := c.Set(`alice`, []byte(`1`)); err != nil {
+		t.Fatalf(`Set: %v`, err)
+	}
+	c.SetMime(`alice`, `application/json`)
+	kv.SetBucket(`users`)
+	if v, _ := kv.Get(`alice`); string(v) != `1` {
+		t.Logf(`Expected the value to reach the store, got %q`, v)
+		t.Fail()
+	}
+	if v, err := c.Get(`alice`); err != nil || string(v) != `1` {
+		t.Logf(`Get: %q %v`, v, err)
+		t.Fail()
+	}
+	if v, err := c.Get(`bob`); err != nil || len(v) != 0 {
+		t.Logf(`Expected an empty value for a missing key, got %q %v`, v, err)
+		t.Fail()
+	}
+	if m, _ := c.GetMime(`alice`); m != `application/json` {
+		t.Logf(`Unexpected mime %q`, m)
+		t.Fail()
+	}
+	if keys, _ := c.ListKeys(`a`); len(keys) != 1 || keys[0] != `alice` {
+		t.Logf(`Unexpected keys %v`, keys)
+		t.Fail()
+	}
+	c.Tally(`visits`, 10)
+	if n, err := c.TallyIncr(`visits`); err != nil || n != 11 {
+		t.Logf(`TallyIncr: %d %v`, n, err)
+		t.Fail()
+	}
+	if err := c.Del(`alice`); err != nil {
+		t.Logf(`Del: %v`, err)
+		t.Fail()
+	}
+
+	if err := c.Set(`big`, make([]byte, 1025)); !errors.Is(err, sqltplainkv.ErrValueTooLong) {
+		t.Logf(`Expected ErrValueTooLong, got %v`, err)
+		t.Fail()
+	}
+}
+
+func TestClientTransaction(t *testing.T) {
+	c, _ := newTestClient(t)
+
+	c.Begin()
+	c.Set(`a`, []byte(`1`))
+	c.Set(`b`, []byte(`2`))
+	if v, _ := c.Get(`a`); len(v) != 0 {
+		t.Logf(`Expected buffered writes to stay local, got %q`, v)
+		t.Fail()
+	}
+	if err := c.Commit(); err != nil {
+		t.Fatalf(`Commit: %v`, err)
+	}
+	if keys, _ := c.ListKeys(``); len(keys) != 2 {
+		t.Logf(`Expected both writes to be committed, got %v`, keys)
+		t.Fail()
+	}
+
+	c.Begin()
+	c.Del(`a`)
+	c.Rollback()
+	if v, _ := c.Get(`a`); string(v) != `1` {
+		t.Logf(`Expected the rolled back delete to be discarded, got %q`, v)
+		t.Fail()
+	}
+}
+
+func TestClientWatch(t *testing.T) {
+	c, kv := newTestClient(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := c.Watch(ctx, `user:`)
+	if err != nil {
+		t.Fatalf(`Watch: %v`, err)
+	}
+	kv.SetBucket(`default`)
+	kv.Set(`other`, []byte(`x`))
+	kv.Set(`user:1`, []byte(`alice`))
+	c.Del(`user:1`)
+
+	for _, want := range []sqltplainkv.ChangeType{sqltplainkv.ChangeCreate, sqltplainkv.ChangeDelete} {
+		select {
+		case ev := <-events:
+			if ev.Type != want || ev.Key != `user:1` {
+				t.Logf(`Expected %s of user:1, got %s of %s`, want, ev.Type, ev.Key)
+				t.Fail()
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf(`Timed out waiting for %s`, want)
+		}
+	}
+	cancel()
+	for range events {
+	}
+}
@@ -0,0 +1,263 @@
+// Package grpckv serves a SQLtPlainKV store over gRPC and provides a
+// client implementing sqltplainkv.PlainKVer against it, so the store
+// can run as a small networked daemon
+package grpckv
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative sqltkv.proto
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	sqltplainkv "github.com/narsilworks/sqlt-plainkv"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// knownErrors are the errors of the store sent to clients with their
+// status code. Clients turn them back into the same errors
+var knownErrors = []struct {
+	err  error
+	code codes.Code
+}{
+	{sqltplainkv.ErrKeyNotFound, codes.NotFound},
+	{sqltplainkv.ErrBucketIdTooLong, codes.InvalidArgument},
+	{sqltplainkv.ErrKeyTooLong, codes.InvalidArgument},
+	{sqltplainkv.ErrValueTooLong, codes.InvalidArgument},
+	{sqltplainkv.ErrQuotaExceeded, codes.ResourceExhausted},
+	{sqltplainkv.ErrDatabaseFull, codes.ResourceExhausted},
+	{sqltplainkv.ErrInTransaction, codes.FailedPrecondition},
+}
+
+// Server implements the KV service on a store. The store is used by
+// one request at a time, as its current bucket is shared
+type Server struct {
+	UnimplementedKVServer
+
+	mu sync.Mutex
+	kv *sqltplainkv.SQLtPlainKV
+}
+
+// NewServer creates a server for the store
+func NewServer(kv *sqltplainkv.SQLtPlainKV) *Server {
+	return &Server{kv: kv}
+}
+
+// Register registers the server on a gRPC server. Secure the gRPC
+// server with credentials built from sqltplainkv.NewTLSConfig when it
+// listens beyond the local host. gRPC limits messages to 4 MiB by
+// default, raise grpc.MaxRecvMsgSize on the server and
+// grpc.MaxCallRecvMsgSize on clients to move larger values
+func (s *Server) Register(gs grpc.ServiceRegistrar) {
+	RegisterKVServer(gs, s)
+}
+
+// do runs fn on the store with the bucket as current bucket
+func (s *Server) do(bucket string, fn func(kv *sqltplainkv.SQLtPlainKV) error) error {
+	if bucket == "" {
+		bucket = "default"
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.kv.SetBucket(bucket)
+	return toStatus(fn(s.kv))
+}
+
+func (s *Server) Get(_ context.Context, req *KeyRequest) (*ValueReply, error) {
+	var val []byte
+	err := s.do(req.Bucket, func(kv *sqltplainkv.SQLtPlainKV) (err error) {
+		val, err = kv.Get(req.Key)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &ValueReply{Value: val}, nil
+}
+
+func (s *Server) Set(_ context.Context, req *SetRequest) (*emptypb.Empty, error) {
+	err := s.do(req.Bucket, func(kv *sqltplainkv.SQLtPlainKV) error {
+		if req.TtlMs > 0 {
+			return kv.SetWithTTL(req.Key, req.Value, time.Duration(req.TtlMs)*time.Millisecond)
+		}
+		return kv.Set(req.Key, req.Value)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *Server) Del(_ context.Context, req *KeyRequest) (*emptypb.Empty, error) {
+	err := s.do(req.Bucket, func(kv *sqltplainkv.SQLtPlainKV) error {
+		return kv.Del(req.Key)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *Server) List(_ context.Context, req *ListRequest) (*ListReply, error) {
+	var keys []string
+	err := s.do(req.Bucket, func(kv *sqltplainkv.SQLtPlainKV) (err error) {
+		keys, err = kv.ListKeys(req.Pattern)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &ListReply{Keys: keys}, nil
+}
+
+func (s *Server) GetMime(_ context.Context, req *KeyRequest) (*MimeReply, error) {
+	var mime string
+	err := s.do(req.Bucket, func(kv *sqltplainkv.SQLtPlainKV) (err error) {
+		mime, err = kv.GetMime(req.Key)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &MimeReply{Mime: mime}, nil
+}
+
+func (s *Server) SetMime(_ context.Context, req *SetMimeRequest) (*emptypb.Empty, error) {
+	err := s.do(req.Bucket, func(kv *sqltplainkv.SQLtPlainKV) error {
+		return kv.SetMime(req.Key, req.Mime)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *Server) Tally(_ context.Context, req *TallyRequest) (*TallyReply, error) {
+	var n int
+	err := s.do(req.Bucket, func(kv *sqltplainkv.SQLtPlainKV) (err error) {
+		switch req.Op {
+		case TallyOp_TALLY_GET:
+			n, err = kv.Tally(req.Key, int(req.Offset))
+		case TallyOp_TALLY_INCR:
+			n, err = kv.TallyIncr(req.Key)
+		case TallyOp_TALLY_DECR:
+			n, err = kv.TallyDecr(req.Key)
+		case TallyOp_TALLY_RESET:
+			err = kv.TallyReset(req.Key)
+		default:
+			err = status.Errorf(codes.InvalidArgument, `unknown tally op %d`, req.Op)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &TallyReply{Value: int64(n)}, nil
+}
+
+func (s *Server) Batch(_ context.Context, req *BatchRequest) (*emptypb.Empty, error) {
+	err := s.do("", func(kv *sqltplainkv.SQLtPlainKV) error {
+		if err := kv.Begin(); err != nil {
+			return err
+		}
+		for _, w := range req.Writes {
+			if err := applyWrite(kv, w); err != nil {
+				kv.Rollback()
+				return err
+			}
+		}
+		return kv.Commit()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func applyWrite(kv *sqltplainkv.SQLtPlainKV, w *Write) error {
+	bucket := w.Bucket
+	if bucket == "" {
+		bucket = "default"
+	}
+	kv.SetBucket(bucket)
+	switch w.Op {
+	case WriteOp_WRITE_SET:
+		return kv.Set(w.Key, w.Value)
+	case WriteOp_WRITE_DEL:
+		return kv.Del(w.Key)
+	case WriteOp_WRITE_SET_MIME:
+		return kv.SetMime(w.Key, string(w.Value))
+	}
+	return status.Errorf(codes.InvalidArgument, `unknown write op %d`, w.Op)
+}
+
+func (s *Server) Watch(req *WatchRequest, stream KV_WatchServer) error {
+	var (
+		events <-chan sqltplainkv.ChangeEvent
+		cancel sqltplainkv.CancelFunc
+	)
+	s.do(req.Bucket, func(kv *sqltplainkv.SQLtPlainKV) error {
+		events, cancel = kv.Watch(req.Pattern)
+		return nil
+	})
+	defer cancel()
+	// the headers tell the client the watch is in place
+	if err := stream.SendHeader(nil); err != nil {
+		return err
+	}
+	ctx := stream.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev, ok := <-events:
+			if !ok {
+				return nil
+			}
+			err := stream.Send(&ChangeEvent{
+				Type:   ChangeType(ev.Type),
+				Bucket: ev.Bucket,
+				Key:    ev.Key,
+				Value:  ev.Value,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// toStatus turns the errors of the store into gRPC status errors
+func toStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	for _, k := range knownErrors {
+		if errors.Is(err, k.err) {
+			return status.Error(k.code, k.err.Error())
+		}
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// fromStatus turns the status errors of known errors of the store
+// back into those errors
+func fromStatus(err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	for _, k := range knownErrors {
+		if st.Code() == k.code && st.Message() == k.err.Error() {
+			return k.err
+		}
+	}
+	return err
+}
@@ -0,0 +1,176 @@
+package sqltplainkv
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// HandlerOptions configures the handler returned by HandlerWithOptions,
+// for hosting static sites
+type HandlerOptions struct {
+	// IndexDocument is the key served for paths naming a directory,
+	// so /docs/ serves docs/index.html with "index.html". A path
+	// naming a directory without its trailing slash is redirected
+	// to the path with it. Empty turns index documents off
+	IndexDocument string
+	// NotFoundKey is the key of the bucket served with status 404
+	// for missing keys, instead of a plain text error
+	NotFoundKey string
+	// CacheControl is sent as the Cache-Control header of found keys
+	CacheControl string
+}
+
+// kvHandler serves the values of buckets over HTTP
+type kvHandler struct {
+	p      *SQLtPlainKV
+	routes []route
+	opts   HandlerOptions
+}
+
+// route maps a URL path prefix to a bucket
+type route struct {
+	prefix string
+	bucket string
+}
+
+// Handler returns an http.Handler serving the values of the store.
+// The mapping maps URL path prefixes to buckets, the rest of the path
+// being the key: with {"/img/": "images"}, GET /img/logo.png serves the
+// key logo.png of the images bucket. The longest matching prefix wins.
+// Values are served with their MIME type as Content-Type. Locale
+// variants stored with SetLocale are served according to the
+// Accept-Language header of the request. Responses carry the content
+// hash of the value as ETag and its last update as Last-Modified, and
+// conditional requests matching them are answered with 304. Missing
+// keys are answered with 404, methods other than GET and HEAD with 405
+func (p *SQLtPlainKV) Handler(mapping map[string]string) http.Handler {
+	return p.HandlerWithOptions(mapping, HandlerOptions{})
+}
+
+// HandlerWithOptions returns a Handler configured by the options
+func (p *SQLtPlainKV) HandlerWithOptions(mapping map[string]string, opts HandlerOptions) http.Handler {
+	h := &kvHandler{p: p, opts: opts}
+	for prefix, bucket := range mapping {
+		if bucket == "" {
+			bucket = "default"
+		}
+		h.routes = append(h.routes, route{prefix: prefix, bucket: bucket})
+	}
+	sort.Slice(h.routes, func(i, j int) bool {
+		return len(h.routes[i].prefix) > len(h.routes[j].prefix)
+	})
+	return h
+}
+
+// resolve returns the bucket and key of a URL path
+func (h *kvHandler) resolve(path string) (string, string, bool) {
+	for _, r := range h.routes {
+		if strings.HasPrefix(path, r.prefix) {
+			return r.bucket, strings.TrimPrefix(path[len(r.prefix):], "/"), true
+		}
+	}
+	return "", "", false
+}
+
+func (h *kvHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set(`Allow`, `GET, HEAD`)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	bucket, key, ok := h.resolve(r.URL.Path)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if err := h.p.open(); err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if h.p.autoClose {
+		defer h.p.closeWhenIdle()
+	}
+	index := h.opts.IndexDocument
+	if index != "" && (key == "" || strings.HasSuffix(key, "/")) {
+		key += index
+	}
+	if key != "" {
+		found, err := h.serveKey(w, r, bucket, key, http.StatusOK)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+		if found || err != nil {
+			return
+		}
+		if index != "" && !strings.HasSuffix(key, index) {
+			val, err := h.p.get(bucket, key+"/"+index)
+			if err == nil && len(val) > 0 {
+				http.Redirect(w, r, r.URL.Path+"/", http.StatusMovedPermanently)
+				return
+			}
+		}
+	}
+	h.notFound(w, r, bucket)
+}
+
+// serveKey writes the value of a key with the status and reports
+// whether the key was found
+func (h *kvHandler) serveKey(w http.ResponseWriter, r *http.Request, bucket, key string, status int) (bool, error) {
+	val, locale, err := h.p.getLocale(bucket, key, ParseAcceptLanguage(r.Header.Get(`Accept-Language`)))
+	if err != nil || len(val) == 0 {
+		return false, err
+	}
+	mime, err := h.p.getMime(bucket, key)
+	if err != nil {
+		return false, err
+	}
+	if mime == "" {
+		mime = h.p.bucketDefaultMime(bucket)
+	}
+	served := key
+	if locale != "" {
+		served = fmt.Sprintf(localeKey, locale, key)
+	}
+	hash, modTime, err := h.p.hashOf(bucket, served, val)
+	if err != nil {
+		return false, err
+	}
+	hdr := w.Header()
+	hdr.Set(`Vary`, `Accept-Language`)
+	if status == http.StatusOK {
+		hdr.Set(`ETag`, `"`+hash+`"`)
+		if !modTime.IsZero() {
+			hdr.Set(`Last-Modified`, modTime.UTC().Format(http.TimeFormat))
+		}
+		if h.opts.CacheControl != "" {
+			hdr.Set(`Cache-Control`, h.opts.CacheControl)
+		}
+		if notModified(r, `"`+hash+`"`, modTime) {
+			w.WriteHeader(http.StatusNotModified)
+			return true, nil
+		}
+	}
+	hdr.Set(`Content-Type`, mime)
+	hdr.Set(`Content-Length`, strconv.Itoa(len(val)))
+	if locale != "" {
+		hdr.Set(`Content-Language`, locale)
+	}
+	w.WriteHeader(status)
+	if r.Method == http.MethodGet {
+		w.Write(val)
+	}
+	return true, nil
+}
+
+// notFound answers with the not found page of the bucket, if any
+func (h *kvHandler) notFound(w http.ResponseWriter, r *http.Request, bucket string) {
+	if h.opts.NotFoundKey != "" {
+		if found, _ := h.serveKey(w, r, bucket, h.opts.NotFoundKey, http.StatusNotFound); found {
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
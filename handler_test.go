@@ -0,0 +1,103 @@
+package sqltplainkv
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	pkv := newTestKV(t)
+	pkv.SetBucket(`images`)
+	pkv.Set(`logo.svg`, []byte(`<svg/>`))
+	pkv.SetMime(`logo.svg`, `image/svg+xml`)
+	pkv.SetBucket(`pages`)
+	pkv.Set(`about`, []byte(`about us`))
+	pkv.SetLocale(`about`, `de`, []byte(`über uns`))
+	pkv.SetBucket(`default`)
+	pkv.Set(`index`, []byte(`<p>home</p>`))
+
+	h := pkv.Handler(map[string]string{
+		`/`:       ``,
+		`/img/`:   `images`,
+		`/pages/`: `pages`,
+	})
+	for _, tc := range []struct {
+		method, path, lang string
+		status             int
+		body, mime, locale string
+	}{
+		{`GET`, `/img/logo.svg`, ``, 200, `<svg/>`, `image/svg+xml`, ``},
+		{`HEAD`, `/img/logo.svg`, ``, 200, ``, `image/svg+xml`, ``},
+		{`GET`, `/index`, ``, 200, `<p>home</p>`, `text/html`, ``},
+		{`GET`, `/pages/about`, `de-CH, en;q=0.5`, 200, `über uns`, `text/html`, `de`},
+		{`GET`, `/pages/about`, `fr`, 200, `about us`, `text/html`, ``},
+		{`GET`, `/img/missing.png`, ``, 404, ``, ``, ``},
+		{`GET`, `/img/`, ``, 404, ``, ``, ``},
+		{`POST`, `/index`, ``, 405, ``, ``, ``},
+	} {
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		if tc.lang != `` {
+			req.Header.Set(`Accept-Language`, tc.lang)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tc.status {
+			t.Logf(`%s %s: expected status %d, got %d`, tc.method, tc.path, tc.status, rec.Code)
+			t.Fail()
+			continue
+		}
+		if tc.status != 200 {
+			continue
+		}
+		hdr := rec.Header()
+		if rec.Body.String() != tc.body || hdr.Get(`Content-Type`) != tc.mime || hdr.Get(`Content-Language`) != tc.locale {
+			t.Logf(`%s %s: unexpected response %q %v`, tc.method, tc.path, rec.Body.String(), hdr)
+			t.Fail()
+		}
+		if hdr.Get(`Content-Length`) == `` {
+			t.Logf(`%s %s: expected a Content-Length`, tc.method, tc.path)
+			t.Fail()
+		}
+	}
+}
+
+func TestHandlerStaticSite(t *testing.T) {
+	pkv := newTestKV(t)
+	pkv.SetBucket(`site`)
+	pkv.Set(`index.html`, []byte(`home`))
+	pkv.Set(`docs/index.html`, []byte(`docs`))
+	pkv.Set(`docs/intro.html`, []byte(`intro`))
+	pkv.Set(`404.html`, []byte(`lost?`))
+
+	h := pkv.HandlerWithOptions(map[string]string{`/`: `site`}, HandlerOptions{
+		IndexDocument: `index.html`,
+		NotFoundKey:   `404.html`,
+		CacheControl:  `public, max-age=60`,
+	})
+	for _, tc := range []struct {
+		path     string
+		status   int
+		body     string
+		location string
+	}{
+		{`/`, 200, `home`, ``},
+		{`/docs/`, 200, `docs`, ``},
+		{`/docs/intro.html`, 200, `intro`, ``},
+		{`/docs`, 301, ``, `/docs/`},
+		{`/nope`, 404, `lost?`, ``},
+		{`/docs/nope/`, 404, `lost?`, ``},
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(`GET`, tc.path, nil))
+		if rec.Code != tc.status || (tc.body != `` && rec.Body.String() != tc.body) || rec.Header().Get(`Location`) != tc.location {
+			t.Logf(`%s: unexpected response %d %q %v`, tc.path, rec.Code, rec.Body.String(), rec.Header())
+			t.Fail()
+			continue
+		}
+		cc := rec.Header().Get(`Cache-Control`)
+		if (tc.status == 200) != (cc == `public, max-age=60`) {
+			t.Logf(`%s: unexpected Cache-Control %q`, tc.path, cc)
+			t.Fail()
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package sqltplainkv
+
+import "database/sql"
+
+// Hooks are called around the writes of a store. Any of them may be
+// nil. Hooks are not called for the internal buckets, such as mimes.
+// Inside a transaction they are called as the write is made, before
+// the transaction commits
+type Hooks struct {
+	// BeforeSet is called before a value is stored. Returning an
+	// error aborts the write and is returned by Set.
+	// Values streamed by SetFrom are passed as nil
+	BeforeSet func(bucket, key string, value []byte) error
+	// AfterSet is called once a value has been stored
+	AfterSet func(bucket, key string, value []byte)
+	// AfterDelete is called once a key has been deleted, by Del,
+	// DelWhere or eviction
+	AfterDelete func(bucket, key string)
+}
+
+// AddHooks registers hooks on the store. Hooks run in the order
+// they were added, the first BeforeSet error stops the write
+func (p *SQLtPlainKV) AddHooks(h Hooks) {
+	p.hooks = append(p.hooks, h)
+}
+
+// WithHooks registers hooks on the store, see AddHooks
+func WithHooks(h Hooks) Option {
+	return func(p *SQLtPlainKV) error {
+		p.AddHooks(h)
+		return nil
+	}
+}
+
+func (p *SQLtPlainKV) beforeSet(bucket, key string, value []byte) error {
+	if isInternalBucket(bucket) {
+		return nil
+	}
+	for _, h := range p.hooks {
+		if h.BeforeSet == nil {
+			continue
+		}
+		if err := h.BeforeSet(bucket, key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// afterSet runs the AfterSet hooks and tells the watchers
+// about a stored value
+func (p *SQLtPlainKV) afterSet(bucket, key string, value []byte, typ ChangeType) {
+	if isInternalBucket(bucket) {
+		return
+	}
+	p.notify(typ, bucket, key, value)
+	for _, h := range p.hooks {
+		if h.AfterSet != nil {
+			h.AfterSet(bucket, key, value)
+		}
+	}
+}
+
+// afterDelete runs the AfterDelete hooks and tells the watchers
+// about a deleted key
+func (p *SQLtPlainKV) afterDelete(bucket, key string) {
+	if isInternalBucket(bucket) {
+		return
+	}
+	p.notify(ChangeDelete, bucket, key, nil)
+	for _, h := range p.hooks {
+		if h.AfterDelete != nil {
+			h.AfterDelete(bucket, key)
+		}
+	}
+}
+
+// recordChange adds a written or deleted key to the audit log and
+// updates the search index, in the transaction of the change.
+// Streamed values are passed as nil
+func (p *SQLtPlainKV) recordChange(tx *sql.Tx, principal string, typ ChangeType, bucket, key string, value []byte) error {
+	if isInternalBucket(bucket) {
+		return nil
+	}
+	if err := p.recordAudit(tx, principal, typ, bucket, key, value); err != nil {
+		return err
+	}
+	return p.syncSearch(tx, typ, bucket, key, value)
+}
@@ -0,0 +1,60 @@
+package sqltplainkv
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestHooks(t *testing.T) {
+	pkv := newTestKV(t)
+	errReadOnly := errors.New(`read-only key`)
+	var events []string
+	pkv.AddHooks(Hooks{
+		BeforeSet: func(bucket, key string, value []byte) error {
+			if strings.HasPrefix(key, `ro-`) {
+				return errReadOnly
+			}
+			return nil
+		},
+		AfterSet: func(bucket, key string, value []byte) {
+			events = append(events, `set `+bucket+`/`+key+`=`+string(value))
+		},
+		AfterDelete: func(bucket, key string) {
+			events = append(events, `del `+bucket+`/`+key)
+		},
+	})
+	pkv.AddHooks(Hooks{
+		AfterSet: func(bucket, key string, value []byte) {
+			events = append(events, `second `+key)
+		},
+	})
+
+	if err := pkv.Set(`ro-a`, []byte(`1`)); !errors.Is(err, errReadOnly) {
+		t.Logf(`Expected the hook error, got %v`, err)
+		t.Fail()
+	}
+	if val, _ := pkv.Get(`ro-a`); len(val) != 0 {
+		t.Logf(`Expected the rejected value not to be stored`)
+		t.Fail()
+	}
+	pkv.Set(`a`, []byte(`1`))
+	pkv.SetMime(`a`, `text/plain`)
+	pkv.SetFrom(`s`, bytes.NewReader([]byte(`streamed`)))
+	pkv.Del(`a`)
+	pkv.Set(`b`, []byte(`2`))
+	pkv.DelWhere(`key == "b"`)
+
+	want := []string{
+		`set default/a=1`, `second a`,
+		`set default/s=`, `second s`,
+		`del default/a`,
+		`set default/b=2`, `second b`,
+		`del default/b`,
+	}
+	if strings.Join(events, `,`) != strings.Join(want, `,`) {
+		t.Logf(`Unexpected hook events %q`, events)
+		t.Fail()
+	}
+}
@@ -0,0 +1,95 @@
+package sqltplainkv
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultIdleTimeout is how long an auto-closing store keeps
+// the database open after its last operation
+const DefaultIdleTimeout = 30 * time.Second
+
+// idleCloser tracks the operations of an auto-closing store and
+// closes the database once none has run for the idle timeout
+type idleCloser struct {
+	mu      sync.Mutex
+	timeout time.Duration
+	busy    int
+	timer   *time.Timer
+}
+
+// SetIdleTimeout sets how long an auto-closing store keeps the
+// database open after its last operation. A timeout of zero or
+// less closes the database as soon as an operation completes
+func (p *SQLtPlainKV) SetIdleTimeout(d time.Duration) {
+	p.idle.mu.Lock()
+	defer p.idle.mu.Unlock()
+	p.idle.timeout = d
+}
+
+// WithIdleTimeout enables auto-close and sets how long the
+// database is kept open after the last operation
+func WithIdleTimeout(d time.Duration) Option {
+	return func(p *SQLtPlainKV) error {
+		p.autoClose = true
+		p.SetIdleTimeout(d)
+		return nil
+	}
+}
+
+// acquire marks an operation as running and cancels a pending close
+func (p *SQLtPlainKV) acquire() {
+	p.idle.mu.Lock()
+	defer p.idle.mu.Unlock()
+	p.idle.busy++
+	if p.idle.timer != nil {
+		p.idle.timer.Stop()
+		p.idle.timer = nil
+	}
+}
+
+// closeWhenIdle marks an operation as done. When no other operation
+// is running, the database is closed after the idle timeout
+func (p *SQLtPlainKV) closeWhenIdle() {
+	p.idle.mu.Lock()
+	defer p.idle.mu.Unlock()
+	if p.idle.busy > 0 {
+		p.idle.busy--
+	}
+	if p.idle.busy > 0 {
+		return
+	}
+	if p.idle.timeout <= 0 {
+		p.closeDB()
+		return
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(p.idle.timeout, func() {
+		p.idle.mu.Lock()
+		defer p.idle.mu.Unlock()
+		if p.idle.timer != timer || p.idle.busy > 0 {
+			return // an operation ran in the meantime
+		}
+		p.idle.timer = nil
+		p.closeDB()
+	})
+	p.idle.timer = timer
+}
+
+// stopIdle cancels a pending close and forgets running operations
+func (p *SQLtPlainKV) stopIdle() {
+	p.idle.mu.Lock()
+	defer p.idle.mu.Unlock()
+	p.idle.busy = 0
+	if p.idle.timer != nil {
+		p.idle.timer.Stop()
+		p.idle.timer = nil
+	}
+}
+
+// isOpen reports whether the database is currently open
+func (p *SQLtPlainKV) isOpen() bool {
+	p.idle.mu.Lock()
+	defer p.idle.mu.Unlock()
+	return p.db != nil
+}
@@ -0,0 +1,88 @@
+package sqltplainkv
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIdleTimeout(t *testing.T) {
+	pkv, err := New(filepath.Join(t.TempDir(), `idle.db`), WithIdleTimeout(50*time.Millisecond))
+	if err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	defer pkv.Close()
+
+	if err = pkv.Set(`a`, []byte(`1`)); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if !pkv.isOpen() {
+		t.Logf(`Expected the database to be kept open after an operation`)
+		t.Fail()
+	}
+	time.Sleep(200 * time.Millisecond)
+	if pkv.isOpen() {
+		t.Logf(`Expected the database to be closed after the idle timeout`)
+		t.Fail()
+	}
+	if val, err := pkv.Get(`a`); err != nil || string(val) != `1` {
+		t.Logf(`Expected the database to reopen, got %s (%v)`, val, err)
+		t.Fail()
+	}
+
+	if err = pkv.Begin(); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	pkv.Set(`b`, []byte(`2`))
+	time.Sleep(200 * time.Millisecond)
+	if !pkv.isOpen() {
+		t.Logf(`Expected the database to stay open during a transaction`)
+		t.Fail()
+	}
+	if err = pkv.Commit(); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+
+	pkv.SetIdleTimeout(0)
+	pkv.Set(`c`, []byte(`3`))
+	if pkv.isOpen() {
+		t.Logf(`Expected a zero timeout to close right after the operation`)
+		t.Fail()
+	}
+}
+
+func TestIdleTimeoutBalanced(t *testing.T) {
+	pkv, err := New(filepath.Join(t.TempDir(), `idle.db`), WithIdleTimeout(0))
+	if err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	defer pkv.Close()
+
+	// opening directly does not keep the store busy
+	if err = pkv.Open(); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	pkv.Set(`a`, []byte(`1`))
+	if pkv.isOpen() {
+		t.Logf(`Expected the database to close after an explicit Open`)
+		t.Fail()
+	}
+
+	// a failed commit still ends the transaction
+	if err = pkv.Begin(); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	pkv.Set(`b`, []byte(`2`))
+	pkv.tx.Rollback()
+	if err = pkv.Commit(); err == nil {
+		t.Fatalf(`Expected the commit to fail`)
+	}
+	if pkv.isOpen() {
+		t.Logf(`Expected the database to close after a failed commit`)
+		t.Fail()
+	}
+	if err = pkv.Set(`c`, []byte(`3`)); err != nil {
+		t.Logf(`Expected writes to work after a failed commit, got %v`, err)
+		t.Fail()
+	}
+}
@@ -0,0 +1,194 @@
+package sqltplainkv
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+)
+
+// ConflictMode decides what an import does with a key that exists
+type ConflictMode int
+
+const (
+	ConflictSkip      ConflictMode = iota // keep the existing value
+	ConflictOverwrite                     // replace the existing value
+	ConflictFail                          // stop the import with ErrKeyExists
+)
+
+// DefaultImportBatch is the number of records an import writes
+// per transaction when ImportOptions gives no batch size
+const DefaultImportBatch = 500
+
+var (
+	ErrKeyExists error = errors.New(`key already exists`)
+)
+
+// ImportOptions controls how records are imported
+type ImportOptions struct {
+	Bucket    string // bucket to import into, the current bucket if empty
+	Conflict  ConflictMode
+	BatchSize int // records per transaction
+}
+
+// ImportNDJSON imports records in the format written by ExportNDJSON,
+// either one JSON object per line or a JSON array of them, and returns
+// the number of records written. Records are written in transactions
+// of BatchSize records, so a failing import keeps the batches written
+// before the failure. Records that have already expired are skipped,
+// and records carrying the time they were last updated keep it.
+// Inside a transaction, all records are written to it
+func (p *SQLtPlainKV) ImportNDJSON(r io.Reader, opts ImportOptions) (int, error) {
+	br := bufio.NewReader(r)
+	dec := json.NewDecoder(br)
+	array := false
+	if b, err := peekNonSpace(br); err == nil && b == '[' {
+		if _, err = dec.Token(); err != nil {
+			return 0, err
+		}
+		array = true
+	}
+	return p.ImportRecords(opts, func() (Record, error) {
+		var rec Record
+		if array && !dec.More() {
+			return rec, io.EOF
+		}
+		err := dec.Decode(&rec)
+		return rec, err
+	})
+}
+
+// ImportRecords writes the records returned by next until it returns
+// io.EOF, following the import options as ImportNDJSON does. It lets
+// importers for other formats and stores share the same batching and
+// conflict handling
+func (p *SQLtPlainKV) ImportRecords(opts ImportOptions, next func() (Record, error)) (int, error) {
+	var err error
+	bucket := opts.Bucket
+	if bucket == "" {
+		bucket = p.currBuckt
+	}
+	if bucket == "" {
+		bucket = "default"
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultImportBatch
+	}
+	if err = p.open(); err != nil {
+		return 0, err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	batched := !p.inTransaction
+	defer func() {
+		if batched && p.inTransaction {
+			p.Rollback()
+		}
+	}()
+
+	written, pending := 0, 0
+	for {
+		rec, err := next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return written, err
+		}
+		var expiry time.Time
+		if rec.ExpiresAt != nil {
+			if !rec.ExpiresAt.After(time.Now()) {
+				continue
+			}
+			expiry = *rec.ExpiresAt
+		}
+		if batched && !p.inTransaction {
+			if err = p.Begin(); err != nil {
+				return written, err
+			}
+		}
+		if opts.Conflict != ConflictOverwrite {
+			found, err := p.exists(nil, bucket, rec.Key)
+			if err != nil {
+				return written, err
+			}
+			if found && opts.Conflict == ConflictFail {
+				return written, ErrKeyExists
+			}
+			if found {
+				continue
+			}
+		}
+		if err = p.setExpiring(bucket, rec.Key, rec.Value, expiry); err != nil {
+			return written, err
+		}
+		if rec.Mime != "" {
+			if err = p.setMime(bucket, rec.Key, rec.Mime); err != nil {
+				return written, err
+			}
+		}
+		if rec.UpdatedAt != nil {
+			if err = p.setUpdatedAt(bucket, rec.Key, *rec.UpdatedAt); err != nil {
+				return written, err
+			}
+		}
+		pending++
+		if batched && pending >= opts.BatchSize {
+			if err = p.Commit(); err != nil {
+				return written, err
+			}
+			written += pending
+			pending = 0
+		}
+	}
+	if batched && p.inTransaction {
+		if err = p.Commit(); err != nil {
+			return written, err
+		}
+	}
+	return written + pending, nil
+}
+
+// setUpdatedAt sets the time a record was last updated
+func (p *SQLtPlainKV) setUpdatedAt(bucket, key string, at time.Time) error {
+	_, err := p.conn().Exec(p.rebind(`
+	UPDATE `+p.defTableName+` SET UpdatedAt=?
+	WHERE Bucket=?
+		AND KeyID=?;`), at.UnixNano(), bucket, key)
+	return err
+}
+
+// exists reports whether a key exists in the bucket and has not expired.
+// A nil transaction looks it up on the current connection
+func (p *SQLtPlainKV) exists(tx *sql.Tx, bucket, key string) (bool, error) {
+	var one int
+	err := p.on(tx).QueryRow(p.rebind(`
+	SELECT 1 FROM `+p.defTableName+`
+	WHERE Bucket=?
+		AND KeyID=?
+		AND (ExpiresAt IS NULL OR ExpiresAt > ?);`), bucket, key, time.Now().UnixNano()).Scan(&one)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// peekNonSpace returns the first byte that is not white space
+// without consuming it
+func peekNonSpace(br *bufio.Reader) (byte, error) {
+	for {
+		b, err := br.Peek(1)
+		if err != nil {
+			return 0, err
+		}
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			br.ReadByte()
+		default:
+			return b[0], nil
+		}
+	}
+}
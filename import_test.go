@@ -0,0 +1,65 @@
+package sqltplainkv
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestImportNDJSON(t *testing.T) {
+	src := newTestKV(t)
+	src.Set(`a`, []byte(`first`))
+	src.SetMime(`a`, `text/plain`)
+	src.SetWithTTL(`b`, []byte(`second`), time.Hour)
+	src.Set(`c`, []byte(`third`))
+	var buf bytes.Buffer
+	if err := src.ExportNDJSON(`default`, &buf); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+
+	dst := newTestKV(t)
+	dst.SetBucket(`copy`)
+	dst.Set(`a`, []byte(`existing`))
+
+	n, err := dst.ImportNDJSON(bytes.NewReader(buf.Bytes()), ImportOptions{BatchSize: 2})
+	if err != nil || n != 2 {
+		t.Logf(`Expected 2 records written with skip, got %d (%v)`, n, err)
+		t.Fail()
+	}
+	if val, _ := dst.Get(`a`); string(val) != `existing` {
+		t.Logf(`Expected the existing value to be kept, got %s`, val)
+		t.Fail()
+	}
+	if info, _ := dst.InspectStorage(`b`); info.ExpiresAt.IsZero() {
+		t.Logf(`Expected the expiry to be imported`)
+		t.Fail()
+	}
+
+	if _, err = dst.ImportNDJSON(bytes.NewReader(buf.Bytes()), ImportOptions{Conflict: ConflictFail}); !errors.Is(err, ErrKeyExists) {
+		t.Logf(`Expected ErrKeyExists, got %v`, err)
+		t.Fail()
+	}
+
+	n, err = dst.ImportNDJSON(bytes.NewReader(buf.Bytes()), ImportOptions{Conflict: ConflictOverwrite})
+	if err != nil || n != 3 {
+		t.Logf(`Expected 3 records written with overwrite, got %d (%v)`, n, err)
+		t.Fail()
+	}
+	if val, _ := dst.Get(`a`); string(val) != `first` {
+		t.Logf(`Expected the value to be overwritten, got %s`, val)
+		t.Fail()
+	}
+	if mime, _ := dst.GetMime(`a`); mime != `text/plain` {
+		t.Logf(`Expected the mime to be imported, got %s`, mime)
+		t.Fail()
+	}
+
+	array := `[{"key":"x","value":"eA=="}, {"key":"y","value":"eQ=="}]`
+	n, err = dst.ImportNDJSON(strings.NewReader(array), ImportOptions{Bucket: `array`})
+	if err != nil || n != 2 {
+		t.Logf(`Expected a JSON array to be imported, got %d (%v)`, n, err)
+		t.Fail()
+	}
+}
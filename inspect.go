@@ -0,0 +1,82 @@
+package sqltplainkv
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// StorageInfo describes how a value is stored in the database
+type StorageInfo struct {
+	Bucket     string
+	Key        string
+	StoredSize int64     // size of the value as stored, after codecs
+	ExpiresAt  time.Time // zero if the value does not expire
+	Codec      bool      // a codec applies to the bucket of the value
+	Compressed Compression
+	Encrypted  bool
+	KeyID      string // id of the encryption key, if encrypted
+	Chunks     int    // number of chunks, if stored in chunks
+	Spilled    bool   // the value is stored in a spillover file
+	Deduped    bool   // the value is shared in the blob bucket
+}
+
+// InspectStorage reports how the value of a key in the current bucket
+// is stored. It returns ErrKeyNotFound if the key does not exist
+func (p *SQLtPlainKV) InspectStorage(key string) (StorageInfo, error) {
+	var (
+		err  error
+		val  []byte
+		size int64
+		exp  sql.NullInt64
+	)
+	if p.currBuckt == "" {
+		p.currBuckt = "default"
+	}
+	info := StorageInfo{
+		Bucket: p.currBuckt,
+		Key:    key,
+	}
+	if err = p.open(); err != nil {
+		return info, err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	sqlstr := p.rebind(`
+	SELECT Value, length(Value), ExpiresAt FROM ` + p.defTableName + `
+	WHERE Bucket=?
+		AND KeyID=?
+		AND (ExpiresAt IS NULL OR ExpiresAt > ?);`)
+	err = p.conn().QueryRow(sqlstr, info.Bucket, key, time.Now().UnixNano()).Scan(&val, &size, &exp)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return info, ErrKeyNotFound
+		}
+		return info, err
+	}
+	info.StoredSize = size
+	if exp.Valid {
+		info.ExpiresAt = time.Unix(0, exp.Int64)
+	}
+	info.Codec = p.codecFor(info.Bucket) != nil
+	if m, ok := parseManifest(val); ok {
+		info.Chunks = m.chunks
+	}
+	for _, env := range p.envelopes(val) {
+		switch env.kind {
+		case envGzip:
+			info.Compressed = Gzip
+		case envZstd:
+			info.Compressed = Zstd
+		case envEncrypted:
+			info.Encrypted = true
+			info.KeyID = env.keyID
+		case envExternal:
+			info.Spilled = true
+		case envDedup:
+			info.Deduped = true
+		}
+	}
+	return info, nil
+}
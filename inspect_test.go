@@ -0,0 +1,83 @@
+package sqltplainkv
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestInspectStorage(t *testing.T) {
+	pkv := newTestKV(t)
+
+	pkv.SetWithTTL(`sample_key`, []byte(`Sample value`), time.Hour)
+
+	info, err := pkv.InspectStorage(`sample_key`)
+	if err != nil {
+		t.Fatalf(`%s`, err)
+	}
+	if info.StoredSize != 12 || info.ExpiresAt.IsZero() || info.Codec {
+		t.Logf(`Unexpected storage info: %+v`, info)
+		t.Fail()
+	}
+
+	pkv.SetCodec(prefixCodec(`v1:`))
+	pkv.Set(`sample_key`, []byte(`Sample value`))
+	info, _ = pkv.InspectStorage(`sample_key`)
+	if info.StoredSize != 15 || !info.ExpiresAt.IsZero() || !info.Codec {
+		t.Logf(`Unexpected storage info: %+v`, info)
+		t.Fail()
+	}
+
+	if _, err = pkv.InspectStorage(`missing`); !errors.Is(err, ErrKeyNotFound) {
+		t.Logf(`Expected ErrKeyNotFound, got %v`, err)
+		t.Fail()
+	}
+}
+
+func TestSetWith(t *testing.T) {
+	pkv := newTestKV(t)
+	pkv.SetCompression(Gzip, 16)
+	if err := pkv.SetEncryptionKey(bytes.Repeat([]byte{7}, 32)); err != nil {
+		t.Fatalf(`%s`, err)
+	}
+	value := bytes.Repeat([]byte(`compressible `), 20)
+
+	pkv.Set(`both`, value)
+	if err := pkv.SetWith(`plain`, value, SetOptions{NoCompression: true, NoEncryption: true}); err != nil {
+		t.Fatalf(`%s`, err)
+	}
+	if err := pkv.SetWith(`encrypted`, value, SetOptions{NoCompression: true, TTL: time.Hour}); err != nil {
+		t.Fatalf(`%s`, err)
+	}
+
+	for key, want := range map[string]StorageInfo{
+		`both`:      {Compressed: Gzip, Encrypted: true},
+		`plain`:     {},
+		`encrypted`: {Encrypted: true},
+	} {
+		info, err := pkv.InspectStorage(key)
+		if err != nil {
+			t.Fatalf(`%s`, err)
+		}
+		if info.Compressed != want.Compressed || info.Encrypted != want.Encrypted {
+			t.Logf(`Expected %s to be stored with %+v, got %+v`, key, want, info)
+			t.Fail()
+		}
+		if v, err := pkv.Get(key); err != nil || !bytes.Equal(v, value) {
+			t.Logf(`Expected %s to read back, got %q (%v)`, key, v, err)
+			t.Fail()
+		}
+	}
+	if info, _ := pkv.InspectStorage(`encrypted`); info.ExpiresAt.IsZero() {
+		t.Logf(`Expected the ttl to apply`)
+		t.Fail()
+	}
+
+	// the settings apply to a single write
+	pkv.Set(`plain`, value)
+	if info, _ := pkv.InspectStorage(`plain`); info.Compressed != Gzip || !info.Encrypted {
+		t.Logf(`Expected the next write to be transformed, got %+v`, info)
+		t.Fail()
+	}
+}
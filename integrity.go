@@ -0,0 +1,41 @@
+package sqltplainkv
+
+// IntegrityCheck runs SQLite's integrity check on the database and
+// returns the problems it reports. An intact database returns an
+// empty list. The check reads the whole database and can take a
+// while on large files
+func (p *SQLtPlainKV) IntegrityCheck() ([]string, error) {
+	return p.checkPragma(`integrity_check`)
+}
+
+// QuickCheck is a faster IntegrityCheck that skips verifying that
+// indexes match their tables
+func (p *SQLtPlainKV) QuickCheck() ([]string, error) {
+	return p.checkPragma(`quick_check`)
+}
+
+func (p *SQLtPlainKV) checkPragma(name string) ([]string, error) {
+	var err error
+	problems := make([]string, 0)
+	if err = p.open(); err != nil {
+		return problems, err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	rows, err := p.conn().Query(`PRAGMA ` + name + `;`)
+	if err != nil {
+		return problems, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var msg string
+		if err = rows.Scan(&msg); err != nil {
+			return problems, err
+		}
+		if msg != `ok` {
+			problems = append(problems, msg)
+		}
+	}
+	return problems, rows.Err()
+}
@@ -0,0 +1,19 @@
+package sqltplainkv
+
+import "testing"
+
+func TestIntegrityCheck(t *testing.T) {
+	pkv := newTestKV(t)
+	pkv.Set(`sample_key`, []byte(`Sample value`))
+
+	problems, err := pkv.IntegrityCheck()
+	if err != nil || len(problems) != 0 {
+		t.Logf(`Expected an intact database, got %v (%v)`, problems, err)
+		t.Fail()
+	}
+	problems, err = pkv.QuickCheck()
+	if err != nil || len(problems) != 0 {
+		t.Logf(`Expected an intact database, got %v (%v)`, problems, err)
+		t.Fail()
+	}
+}
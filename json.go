@@ -0,0 +1,20 @@
+package sqltplainkv
+
+const (
+	mimeJSON string = `application/json`
+)
+
+// SetJSON marshals v to JSON and stores it by the key.
+// The mime of the key is set to application/json
+func (p *SQLtPlainKV) SetJSON(key string, v any) error {
+	if p.currBuckt == "" {
+		p.currBuckt = "default"
+	}
+	return p.setObject(p.currBuckt, key, v, JSONSerializer)
+}
+
+// GetJSON retrieves a record using a key and unmarshals it into out.
+// It returns ErrKeyNotFound if the key does not exist
+func (p *SQLtPlainKV) GetJSON(key string, out any) error {
+	return p.getObject(p.currBuckt, key, out, JSONSerializer)
+}
@@ -0,0 +1,39 @@
+package sqltplainkv
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestJSON(t *testing.T) {
+	pkv := newTestKV(t)
+
+	type sample struct {
+		Name  string
+		Count int
+	}
+
+	if err := pkv.SetJSON(`sample_json`, sample{Name: `widget`, Count: 3}); err != nil {
+		t.Fatalf(`%s`, err)
+	}
+
+	var out sample
+	if err := pkv.GetJSON(`sample_json`, &out); err != nil {
+		t.Fatalf(`%s`, err)
+	}
+	if out.Name != `widget` || out.Count != 3 {
+		t.Logf(`Unexpected value: %+v`, out)
+		t.Fail()
+	}
+
+	mime, err := pkv.GetMime(`sample_json`)
+	if err != nil || mime != `application/json` {
+		t.Logf(`Expected application/json, got %s (%v)`, mime, err)
+		t.Fail()
+	}
+
+	if err := pkv.GetJSON(`missing_json`, &out); !errors.Is(err, ErrKeyNotFound) {
+		t.Logf(`Expected ErrKeyNotFound, got %v`, err)
+		t.Fail()
+	}
+}
@@ -0,0 +1,41 @@
+package sqltplainkv
+
+// Limits are the size limits enforced on writes
+type Limits struct {
+	MaxBucketLen int // maximum length of a bucket name in bytes
+	MaxKeyLen    int // maximum length of a key in bytes
+	MaxValueSize int // maximum size of a stored value in bytes
+	ChunkSize    int // values larger than this are split across rows
+}
+
+// DefaultLimits are the limits of a new store
+var DefaultLimits = Limits{
+	MaxBucketLen: 50,
+	MaxKeyLen:    300,
+	MaxValueSize: 16777215,
+	ChunkSize:    16777215,
+}
+
+// SetLimits changes the size limits. Limits left at zero keep their
+// default. Tables are created with column sizes matching the limits,
+// so they should be set before the first Open
+func (p *SQLtPlainKV) SetLimits(l Limits) {
+	if l.MaxBucketLen <= 0 {
+		l.MaxBucketLen = DefaultLimits.MaxBucketLen
+	}
+	if l.MaxKeyLen <= 0 {
+		l.MaxKeyLen = DefaultLimits.MaxKeyLen
+	}
+	if l.MaxValueSize <= 0 {
+		l.MaxValueSize = DefaultLimits.MaxValueSize
+	}
+	if l.ChunkSize <= 0 {
+		l.ChunkSize = DefaultLimits.ChunkSize
+	}
+	p.limits = l
+}
+
+// GetLimits returns the size limits
+func (p *SQLtPlainKV) GetLimits() Limits {
+	return p.limits
+}
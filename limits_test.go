@@ -0,0 +1,37 @@
+package sqltplainkv
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLimits(t *testing.T) {
+	longKey := `https://example.com/` + strings.Repeat(`path/`, 100)
+
+	def := newTestKV(t)
+	if err := def.Set(longKey, []byte(`page`)); !errors.Is(err, ErrKeyTooLong) {
+		t.Logf(`Expected ErrKeyTooLong with the default limits, got %v`, err)
+		t.Fail()
+	}
+
+	pkv := NewSQLtPlainKV(filepath.Join(t.TempDir(), "test.dat"), false)
+	defer pkv.Close()
+	pkv.SetLimits(Limits{MaxKeyLen: 2048, MaxValueSize: 8})
+	if err := pkv.Set(longKey, []byte(`page`)); err != nil {
+		t.Logf(`Expected long key to be accepted, got %v`, err)
+		t.Fail()
+	}
+	if err := pkv.Set(`small`, []byte(`too large`)); !errors.Is(err, ErrValueTooLong) {
+		t.Logf(`Expected ErrValueTooLong, got %v`, err)
+		t.Fail()
+	}
+
+	var schema string
+	pkv.db.QueryRow(`SELECT sql FROM sqlite_master WHERE name='KeyValueTBL'`).Scan(&schema)
+	if !strings.Contains(schema, `VARCHAR(2048)`) {
+		t.Logf(`Expected the key column to follow the limit, got %s`, schema)
+		t.Fail()
+	}
+}
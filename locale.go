@@ -0,0 +1,127 @@
+package sqltplainkv
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	localeKey    string = `_______#locale-%s:%s`
+	localePrefix string = internalKeyPrefix + `locale-` // start of every locale variant
+)
+
+// SetLocale stores a locale variant of a key in the current bucket.
+// The locale is a language tag such as `de` or `de-CH`
+func (p *SQLtPlainKV) SetLocale(key, locale string, value []byte) error {
+	if p.currBuckt == "" {
+		p.currBuckt = "default"
+	}
+	lk := fmt.Sprintf(localeKey, normalizeLocale(locale), key)
+	if err := p.set(p.currBuckt, lk, value); err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetLocale retrieves the best matching locale variant of a key.
+// Locales are tried in order, each one falling back to its parent
+// tag (`de-CH` falls back to `de`). If no variant exists, the value
+// of the key itself is returned. The matched locale is returned
+// along with the value and is empty if the plain key was used.
+func (p *SQLtPlainKV) GetLocale(key string, locales ...string) ([]byte, string, error) {
+	return p.getLocale(p.currBuckt, key, locales)
+}
+
+func (p *SQLtPlainKV) getLocale(bucket, key string, locales []string) ([]byte, string, error) {
+	for _, l := range localeChain(locales) {
+		val, err := p.get(bucket, fmt.Sprintf(localeKey, l, key))
+		if err != nil {
+			return val, "", err
+		}
+		if len(val) > 0 {
+			return val, l, nil
+		}
+	}
+	val, err := p.get(bucket, key)
+	return val, "", err
+}
+
+// DelLocale deletes a locale variant of a key
+func (p *SQLtPlainKV) DelLocale(key, locale string) error {
+	if p.currBuckt == "" {
+		p.currBuckt = "default"
+	}
+	return p.del(p.currBuckt, fmt.Sprintf(localeKey, normalizeLocale(locale), key))
+}
+
+// ParseAcceptLanguage parses an Accept-Language header value and
+// returns the language tags ordered by preference. Tags with a
+// quality of zero and the wildcard are omitted.
+func ParseAcceptLanguage(header string) []string {
+	type langq struct {
+		tag string
+		q   float64
+	}
+	var langs []langq
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		tag, q := part, 1.0
+		if i := strings.Index(part, ";"); i >= 0 {
+			tag = strings.TrimSpace(part[:i])
+			for _, param := range strings.Split(part[i+1:], ";") {
+				param = strings.TrimSpace(param)
+				if !strings.HasPrefix(param, "q=") {
+					continue
+				}
+				v, err := strconv.ParseFloat(param[2:], 64)
+				if err != nil {
+					v = 0
+				}
+				q = v
+			}
+		}
+		if tag == "" || tag == "*" || q <= 0 {
+			continue
+		}
+		langs = append(langs, langq{tag: normalizeLocale(tag), q: q})
+	}
+	sort.SliceStable(langs, func(i, j int) bool {
+		return langs[i].q > langs[j].q
+	})
+	tags := make([]string, 0, len(langs))
+	for _, l := range langs {
+		tags = append(tags, l.tag)
+	}
+	return tags
+}
+
+// localeChain expands the locales into a fallback chain where each
+// tag is followed by its parents, without duplicates
+func localeChain(locales []string) []string {
+	chain := make([]string, 0, len(locales)*2)
+	seen := make(map[string]bool)
+	for _, l := range locales {
+		l = normalizeLocale(l)
+		for l != "" {
+			if !seen[l] {
+				seen[l] = true
+				chain = append(chain, l)
+			}
+			i := strings.LastIndex(l, "-")
+			if i < 0 {
+				break
+			}
+			l = l[:i]
+		}
+	}
+	return chain
+}
+
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+}
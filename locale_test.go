@@ -0,0 +1,68 @@
+package sqltplainkv
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLocale(t *testing.T) {
+	pkv := newTestKV(t)
+
+	if err := pkv.Set(`greeting`, []byte(`Hello`)); err != nil {
+		t.Fatalf(`%s`, err)
+	}
+	if err := pkv.SetLocale(`greeting`, `de`, []byte(`Hallo`)); err != nil {
+		t.Fatalf(`%s`, err)
+	}
+
+	b, loc, err := pkv.GetLocale(`greeting`, `de-CH`, `en`)
+	if err != nil {
+		t.Fatalf(`%s`, err)
+	}
+	if string(b) != `Hallo` || loc != `de` {
+		t.Logf(`Expected Hallo (de), got %s (%s)`, b, loc)
+		t.Fail()
+	}
+
+	b, loc, err = pkv.GetLocale(`greeting`, `fr`)
+	if err != nil {
+		t.Fatalf(`%s`, err)
+	}
+	if string(b) != `Hello` || loc != `` {
+		t.Logf(`Expected fallback to Hello, got %s (%s)`, b, loc)
+		t.Fail()
+	}
+}
+
+func TestDelLocale(t *testing.T) {
+	pkv := newTestKV(t)
+	pkv.SetLimits(Limits{ChunkSize: 16})
+	pkv.Set(`greeting`, []byte(`Hello`))
+	if err := pkv.SetLocale(`greeting`, `de`, []byte(strings.Repeat(`Hallo `, 10))); err != nil {
+		t.Fatalf(`%s`, err)
+	}
+
+	if err := pkv.DelLocale(`greeting`, `de`); err != nil {
+		t.Fatalf(`%s`, err)
+	}
+	if b, loc, err := pkv.GetLocale(`greeting`, `de`); err != nil || string(b) != `Hello` || loc != `` {
+		t.Logf(`Expected fallback to Hello, got %s (%s, %v)`, b, loc, err)
+		t.Fail()
+	}
+	var chunks int
+	pkv.db.QueryRow(`SELECT COUNT(*) FROM `+pkv.defTableName+` WHERE Bucket=?;`, chunkBuckt).Scan(&chunks)
+	if chunks != 0 {
+		t.Logf(`Expected the chunks to be deleted, got %d`, chunks)
+		t.Fail()
+	}
+}
+
+func TestParseAcceptLanguage(t *testing.T) {
+	tags := ParseAcceptLanguage(`fr;q=0.5, de-CH, en;q=0.8, *;q=0.1, it;q=0`)
+	want := []string{`de-ch`, `en`, `fr`}
+	if !reflect.DeepEqual(tags, want) {
+		t.Logf(`Expected %v, got %v`, want, tags)
+		t.Fail()
+	}
+}
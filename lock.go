@@ -0,0 +1,166 @@
+package sqltplainkv
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	lockBuckt string = `--lock--`
+)
+
+var (
+	ErrLockHeld   error = errors.New(`lock held by another owner`)
+	ErrLockLost   error = errors.New(`lock lost`)
+	ErrInvalidTTL error = errors.New(`ttl must be positive`)
+)
+
+// Lock is an exclusive lease on a name, shared by every process using
+// the database. The lease expires after its ttl unless renewed, which
+// the lock does in the background until it is released
+type Lock struct {
+	p     *SQLtPlainKV
+	name  string
+	token []byte
+	ttl   time.Duration
+	mu    sync.Mutex
+	stop  chan struct{}
+	lost  chan struct{}
+	done  bool
+}
+
+// AcquireLock takes the lock of a name for ttl, renewing it every
+// third of the ttl until Release is called. A lock whose owner stopped
+// renewing it, for example because its process died, is free again
+// once its ttl has elapsed. It returns ErrLockHeld if another owner
+// holds the lock. It cannot be called inside a transaction
+func (p *SQLtPlainKV) AcquireLock(name string, ttl time.Duration) (*Lock, error) {
+	if ttl <= 0 {
+		return nil, ErrInvalidTTL
+	}
+	if p.inTransaction {
+		return nil, ErrInTransaction
+	}
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+	token = []byte(hex.EncodeToString(token))
+	ok, err := p.setNX(lockBuckt, name, token, time.Now().Add(ttl))
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrLockHeld
+	}
+	l := &Lock{
+		p:     p,
+		name:  name,
+		token: token,
+		ttl:   ttl,
+		stop:  make(chan struct{}),
+		lost:  make(chan struct{}),
+	}
+	go l.heartbeat()
+	return l, nil
+}
+
+// Name returns the name of the lock
+func (l *Lock) Name() string {
+	return l.name
+}
+
+// Lost returns a channel closed when the lock could not be renewed
+// before another owner took it over, or it expired. Work guarded by
+// the lock should stop once it is closed
+func (l *Lock) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// heartbeat renews the lock until it is released or lost
+func (l *Lock) heartbeat() {
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			if err := l.Refresh(); errors.Is(err, ErrLockLost) {
+				return
+			}
+		}
+	}
+}
+
+// Refresh extends the lock by its ttl from now. The lock renews
+// itself, Refresh is only needed to extend it right away.
+// It returns ErrLockLost if the lock is no longer held
+func (l *Lock) Refresh() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.done {
+		return ErrLockLost
+	}
+	now := time.Now()
+	n, err := l.exec(`
+	UPDATE `+l.p.defTableName+` SET ExpiresAt = ?, UpdatedAt = ?
+	WHERE Bucket = ? AND KeyID = ? AND Value = ? AND ExpiresAt > ?;`,
+		now.Add(l.ttl).UnixNano(), now.UnixNano(), lockBuckt, l.name, l.token, now.UnixNano())
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		l.done = true
+		close(l.lost)
+		return ErrLockLost
+	}
+	return nil
+}
+
+// Release frees the lock and stops renewing it.
+// It returns ErrLockLost if the lock was lost before
+func (l *Lock) Release() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.done {
+		return ErrLockLost
+	}
+	l.done = true
+	close(l.stop)
+	n, err := l.exec(`DELETE FROM `+l.p.defTableName+` WHERE Bucket = ? AND KeyID = ? AND Value = ? AND ExpiresAt > ?;`,
+		lockBuckt, l.name, l.token, time.Now().UnixNano())
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		close(l.lost)
+		return ErrLockLost
+	}
+	return nil
+}
+
+// exec runs a statement on the lock record outside of any
+// transaction of the store and returns the rows it changed
+func (l *Lock) exec(query string, args ...any) (int64, error) {
+	p := l.p
+	if err := p.open(); err != nil {
+		return 0, err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	var n int64
+	err := p.retry(func() error {
+		res, err := p.db.Exec(p.rebind(query), args...)
+		if err != nil {
+			return err
+		}
+		n, err = res.RowsAffected()
+		return err
+	})
+	return n, err
+}
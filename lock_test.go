@@ -0,0 +1,91 @@
+package sqltplainkv
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSetNX(t *testing.T) {
+	pkv := newTestKV(t)
+	if ok, err := pkv.SetNX(`a`, []byte(`1`), 0); !ok || err != nil {
+		t.Fatalf(`Expected the first SetNX to store, got %v, %v`, ok, err)
+	}
+	if ok, _ := pkv.SetNX(`a`, []byte(`2`), 0); ok {
+		t.Logf(`Expected SetNX on an existing key to fail`)
+		t.Fail()
+	}
+	pkv.SetNX(`b`, []byte(`old`), time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if ok, _ := pkv.SetNX(`b`, []byte(`new`), 0); !ok {
+		t.Logf(`Expected SetNX to replace an expired key`)
+		t.Fail()
+	}
+	if val, _ := pkv.Get(`a`); string(val) != `1` {
+		t.Logf(`Expected a to keep 1, got %s`, val)
+		t.Fail()
+	}
+	if val, _ := pkv.Get(`b`); string(val) != `new` {
+		t.Logf(`Expected b to be new, got %s`, val)
+		t.Fail()
+	}
+}
+
+func TestLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), `test.dat`)
+	a := NewSQLtPlainKV(path, false)
+	defer a.Close()
+	b := NewSQLtPlainKV(path, false)
+	defer b.Close()
+
+	l, err := a.AcquireLock(`job`, 60*time.Millisecond)
+	if err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if _, err = b.AcquireLock(`job`, time.Second); !errors.Is(err, ErrLockHeld) {
+		t.Logf(`Expected ErrLockHeld, got %v`, err)
+		t.Fail()
+	}
+	// the heartbeat keeps the lock past its ttl
+	time.Sleep(150 * time.Millisecond)
+	if _, err = b.AcquireLock(`job`, time.Second); !errors.Is(err, ErrLockHeld) {
+		t.Logf(`Expected the lock to be renewed, got %v`, err)
+		t.Fail()
+	}
+	if err = l.Release(); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	lb, err := b.AcquireLock(`job`, time.Second)
+	if err != nil {
+		t.Fatalf(`Expected to take the released lock, got %v`, err)
+	}
+	defer lb.Release()
+	if err = l.Release(); !errors.Is(err, ErrLockLost) {
+		t.Logf(`Expected a second release to fail, got %v`, err)
+		t.Fail()
+	}
+	if _, err = a.AcquireLock(`other`, 0); !errors.Is(err, ErrInvalidTTL) {
+		t.Logf(`Expected ErrInvalidTTL, got %v`, err)
+		t.Fail()
+	}
+}
+
+func TestLockLost(t *testing.T) {
+	pkv := newTestKV(t)
+	l, err := pkv.AcquireLock(`job`, 30*time.Millisecond)
+	if err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	// another owner takes over the lock
+	pkv.db.Exec(`UPDATE KeyValueTBL SET Value = 'stolen' WHERE Bucket = ?;`, lockBuckt)
+	select {
+	case <-l.Lost():
+	case <-time.After(time.Second):
+		t.Fatalf(`Expected the lock to be lost`)
+	}
+	if err = l.Refresh(); !errors.Is(err, ErrLockLost) {
+		t.Logf(`Expected ErrLockLost, got %v`, err)
+		t.Fail()
+	}
+}
@@ -0,0 +1,22 @@
+package sqltplainkv
+
+// logger is the part of *slog.Logger the store logs through.
+// Builds older than Go 1.21 have no slog and log nothing
+type logger interface {
+	Debug(msg string, args ...any)
+	Warn(msg string, args ...any)
+}
+
+// debug logs a message at debug level if the store has a logger
+func (p *SQLtPlainKV) debug(msg string, args ...any) {
+	if p.log != nil {
+		p.log.Debug(msg, args...)
+	}
+}
+
+// warn logs a message at warn level if the store has a logger
+func (p *SQLtPlainKV) warn(msg string, args ...any) {
+	if p.log != nil {
+		p.log.Warn(msg, args...)
+	}
+}
@@ -0,0 +1,24 @@
+//go:build go1.21
+
+package sqltplainkv
+
+import "log/slog"
+
+// SetLogger logs the opening and closing of the database, schema
+// creation, retries on a busy database, transactions and failed
+// writes to the logger. A nil logger turns logging off
+func (p *SQLtPlainKV) SetLogger(l *slog.Logger) {
+	if l == nil {
+		p.log = nil
+		return
+	}
+	p.log = l
+}
+
+// WithLogger logs the activity of the store to the logger, see SetLogger
+func WithLogger(l *slog.Logger) Option {
+	return func(p *SQLtPlainKV) error {
+		p.SetLogger(l)
+		return nil
+	}
+}
@@ -0,0 +1,55 @@
+//go:build go1.21
+
+package sqltplainkv
+
+import (
+	"bytes"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	pkv, err := New(filepath.Join(t.TempDir(), `test.dat`), WithLogger(l))
+	if err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if err = pkv.Open(); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	pkv.Begin()
+	pkv.Set(`a`, []byte(`1`))
+	pkv.Commit()
+	pkv.Begin()
+	pkv.Rollback()
+	pkv.Set(`big`, make([]byte, pkv.limits.MaxValueSize+1))
+	pkv.Close()
+
+	out := buf.String()
+	for _, msg := range []string{
+		`opening database`,
+		`schema ready`,
+		`transaction begun`,
+		`transaction committed`,
+		`transaction rolled back`,
+		`level=WARN msg="set failed" bucket=default key=big`,
+		`database closed`,
+	} {
+		if !strings.Contains(out, msg) {
+			t.Logf(`Expected %q in the log:\n%s`, msg, out)
+			t.Fail()
+		}
+	}
+
+	buf.Reset()
+	pkv.SetLogger(nil)
+	pkv.Open()
+	pkv.Close()
+	if buf.Len() != 0 {
+		t.Logf(`Expected nothing logged without a logger, got %s`, buf.String())
+		t.Fail()
+	}
+}
@@ -0,0 +1,143 @@
+package sqltplainkv
+
+import (
+	"strconv"
+	"time"
+)
+
+// Throttle paces maintenance jobs so they do not hold the database
+// for long stretches. Jobs work in steps of BatchSize rows or pages
+// and pause between steps, letting other operations through
+type Throttle struct {
+	BatchSize int
+	Pause     time.Duration
+}
+
+// DefaultThrottle is the throttle of a new store
+var DefaultThrottle = Throttle{
+	BatchSize: 1000,
+	Pause:     10 * time.Millisecond,
+}
+
+// CompactStats reports the size of the database file before
+// and after it was compacted, in bytes
+type CompactStats struct {
+	Before int64
+	After  int64
+}
+
+// SetThrottle changes how maintenance jobs are paced.
+// A batch size of zero or less keeps the default
+func (p *SQLtPlainKV) SetThrottle(t Throttle) {
+	if t.BatchSize <= 0 {
+		t.BatchSize = DefaultThrottle.BatchSize
+	}
+	p.throttle = t
+}
+
+// WithThrottle changes how maintenance jobs are paced
+func WithThrottle(t Throttle) Option {
+	return func(p *SQLtPlainKV) error {
+		p.SetThrottle(t)
+		return nil
+	}
+}
+
+// Compact rebuilds the database file with VACUUM, returning the
+// space of deleted records to the file system. It rewrites the
+// whole file and blocks writers while it runs. It cannot be called
+// inside a transaction
+func (p *SQLtPlainKV) Compact() (CompactStats, error) {
+	var (
+		err   error
+		stats CompactStats
+	)
+	if p.inTransaction {
+		return stats, ErrInTransaction
+	}
+	if err = p.open(); err != nil {
+		return stats, err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	if stats.Before, err = p.databaseSize(); err != nil {
+		return stats, err
+	}
+	if _, err = p.db.Exec(`VACUUM;`); err != nil {
+		return stats, err
+	}
+	stats.After, err = p.databaseSize()
+	return stats, err
+}
+
+// CompactIncremental returns free pages to the file system in steps
+// of the throttle batch size, pausing between steps, so writers are
+// never blocked for long. It needs incremental auto-vacuum, which is
+// turned on with a full VACUUM the first time it is called on a
+// database. It cannot be called inside a transaction
+func (p *SQLtPlainKV) CompactIncremental() (CompactStats, error) {
+	var (
+		err   error
+		stats CompactStats
+		mode  int
+	)
+	if p.inTransaction {
+		return stats, ErrInTransaction
+	}
+	if err = p.open(); err != nil {
+		return stats, err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	if stats.Before, err = p.databaseSize(); err != nil {
+		return stats, err
+	}
+	if err = p.db.QueryRow(`PRAGMA auto_vacuum;`).Scan(&mode); err != nil {
+		return stats, err
+	}
+	if mode != 2 {
+		// switching to incremental takes effect with the next VACUUM
+		if _, err = p.db.Exec(`PRAGMA auto_vacuum = INCREMENTAL;`); err != nil {
+			return stats, err
+		}
+		if _, err = p.db.Exec(`VACUUM;`); err != nil {
+			return stats, err
+		}
+	}
+	for {
+		var free int
+		if err = p.db.QueryRow(`PRAGMA freelist_count;`).Scan(&free); err != nil {
+			return stats, err
+		}
+		if free == 0 {
+			break
+		}
+		if _, err = p.db.Exec(`PRAGMA incremental_vacuum(` + strconv.Itoa(p.throttle.BatchSize) + `);`); err != nil {
+			return stats, err
+		}
+		p.pause()
+	}
+	stats.After, err = p.databaseSize()
+	return stats, err
+}
+
+// pause waits between two steps of a maintenance job
+func (p *SQLtPlainKV) pause() {
+	if p.throttle.Pause > 0 {
+		time.Sleep(p.throttle.Pause)
+	}
+}
+
+// databaseSize returns the size of the database in bytes
+func (p *SQLtPlainKV) databaseSize() (int64, error) {
+	var pages, size int64
+	if err := p.db.QueryRow(`PRAGMA page_count;`).Scan(&pages); err != nil {
+		return 0, err
+	}
+	if err := p.db.QueryRow(`PRAGMA page_size;`).Scan(&size); err != nil {
+		return 0, err
+	}
+	return pages * size, nil
+}
@@ -0,0 +1,50 @@
+package sqltplainkv
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCompact(t *testing.T) {
+	fill := func(pkv *SQLtPlainKV) {
+		pkv.Begin()
+		for i := 0; i < 200; i++ {
+			pkv.Set(fmt.Sprintf(`key%d`, i), bytes.Repeat([]byte{'x'}, 4096))
+		}
+		pkv.Commit()
+		pkv.DelWhere(`key like "key%"`)
+	}
+
+	pkv := newTestKV(t)
+	fill(pkv)
+	stats, err := pkv.Compact()
+	if err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if stats.After >= stats.Before {
+		t.Logf(`Expected the database to shrink, got %d -> %d`, stats.Before, stats.After)
+		t.Fail()
+	}
+
+	pkv.SetThrottle(Throttle{BatchSize: 10, Pause: time.Millisecond})
+	fill(pkv)
+	if stats, err = pkv.CompactIncremental(); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if stats.After >= stats.Before {
+		t.Logf(`Expected the database to shrink when switching to incremental, got %d -> %d`, stats.Before, stats.After)
+		t.Fail()
+	}
+
+	// incremental auto-vacuum is on now
+	fill(pkv)
+	if stats, err = pkv.CompactIncremental(); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if stats.After >= stats.Before {
+		t.Logf(`Expected the database to shrink incrementally, got %d -> %d`, stats.Before, stats.After)
+		t.Fail()
+	}
+}
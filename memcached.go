@@ -0,0 +1,460 @@
+package sqltplainkv
+
+import (
+	"bufio"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// memcachedFlagsBuckt holds the client flags of the items stored
+	// through the memcached server, when they are not zero
+	memcachedFlagsBuckt string = `--mcflags--`
+	memcachedFlagsKey   string = `%d:%s:%s` // bucket length, bucket, key
+
+	memcachedMaxKeyLen  int    = 250
+	memcachedMaxLineLen int    = 2048
+	memcachedMaxRelExp  int64  = 60 * 60 * 24 * 30 // longer exptimes are Unix times
+	memcachedVersion    string = `1.6.0-sqltkv`
+)
+
+// MemcachedOption configures the memcached server
+type MemcachedOption func(s *memcachedServer) error
+
+// memcachedServer serves the memcached text protocol on a store
+type memcachedServer struct {
+	p      *SQLtPlainKV
+	bucket string
+	mu     sync.Mutex // serializes read-modify-write commands
+}
+
+// WithMemcachedBucket sets the bucket the memcached server stores
+// items in, the default bucket if not set
+func WithMemcachedBucket(bucket string) MemcachedOption {
+	return func(s *memcachedServer) error {
+		if len(bucket) > s.p.limits.MaxBucketLen {
+			return ErrBucketIdTooLong
+		}
+		if bucket != "" {
+			s.bucket = bucket
+		}
+		return nil
+	}
+}
+
+// ServeMemcached serves the memcached text protocol on the TCP address
+// until the listener fails. See ServeMemcachedListener for the commands
+func (p *SQLtPlainKV) ServeMemcached(addr string, opts ...MemcachedOption) error {
+	l, err := net.Listen(`tcp`, addr)
+	if err != nil {
+		return err
+	}
+	return p.ServeMemcachedListener(l, opts...)
+}
+
+// ServeMemcachedListener serves the memcached text protocol on the
+// connections accepted by the listener until it fails, so memcached
+// clients can use the store as a persistent cache. The get, set, add,
+// replace, delete, incr, decr, touch, version and quit commands are
+// supported, with exptime as in memcached: seconds from now up to 30
+// days, a Unix time beyond. incr and decr keep the expiry of the item.
+// Items hold their flags. Empty values are not stored, as in the rest
+// of the store, so they read back as misses. Hidden keys, such as
+// versions, are not served
+func (p *SQLtPlainKV) ServeMemcachedListener(l net.Listener, opts ...MemcachedOption) error {
+	s := &memcachedServer{p: p, bucket: `default`}
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			l.Close()
+			return err
+		}
+	}
+	defer l.Close()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go s.serveConn(conn)
+	}
+}
+
+func (s *memcachedServer) serveConn(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	for {
+		line, err := readMemcachedLine(r)
+		if err != nil {
+			if errors.Is(err, errMemcachedLineTooLong) {
+				w.WriteString("CLIENT_ERROR line too long\r\n")
+				w.Flush()
+			}
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			w.WriteString("ERROR\r\n")
+			w.Flush()
+			continue
+		}
+		if fields[0] == `quit` {
+			return
+		}
+		if err = s.handle(r, w, fields); err != nil {
+			return
+		}
+		if err = w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+var errMemcachedLineTooLong = errors.New(`line too long`)
+
+// readMemcachedLine reads a command line without its line ending
+func readMemcachedLine(r *bufio.Reader) (string, error) {
+	var sb strings.Builder
+	for {
+		chunk, isPrefix, err := r.ReadLine()
+		if err != nil {
+			return "", err
+		}
+		sb.Write(chunk)
+		if sb.Len() > memcachedMaxLineLen {
+			return "", errMemcachedLineTooLong
+		}
+		if !isPrefix {
+			return sb.String(), nil
+		}
+	}
+}
+
+// handle runs a command and writes its reply. It only returns an
+// error when the connection can no longer be used
+func (s *memcachedServer) handle(r *bufio.Reader, w *bufio.Writer, fields []string) error {
+	if err := s.p.open(); err != nil {
+		w.WriteString("SERVER_ERROR " + err.Error() + "\r\n")
+		return nil
+	}
+	if s.p.autoClose {
+		defer s.p.closeWhenIdle()
+	}
+	noreply := len(fields) > 1 && fields[len(fields)-1] == `noreply`
+	if noreply {
+		fields = fields[:len(fields)-1]
+	}
+	reply := func(msg string) {
+		if !noreply {
+			w.WriteString(msg + "\r\n")
+		}
+	}
+	fail := func(err error) {
+		reply("SERVER_ERROR " + err.Error())
+	}
+
+	switch cmd := fields[0]; cmd {
+	case `get`:
+		if len(fields) < 2 {
+			w.WriteString("ERROR\r\n")
+			return nil
+		}
+		for _, key := range fields[1:] {
+			val, flags, err := s.getItem(key)
+			if err != nil {
+				fail(err)
+				return nil
+			}
+			if len(val) == 0 {
+				continue
+			}
+			fmt.Fprintf(w, "VALUE %s %d %d\r\n", key, flags, len(val))
+			w.Write(val)
+			w.WriteString("\r\n")
+		}
+		w.WriteString("END\r\n")
+
+	case `set`, `add`, `replace`:
+		if len(fields) != 5 {
+			w.WriteString("ERROR\r\n")
+			return nil
+		}
+		flags, errF := strconv.ParseUint(fields[2], 10, 32)
+		exptime, errE := strconv.ParseInt(fields[3], 10, 64)
+		size, errS := strconv.Atoi(fields[4])
+		if errF != nil || errE != nil || errS != nil || size < 0 {
+			reply("CLIENT_ERROR bad command line format")
+			return nil
+		}
+		if size > s.p.limits.MaxValueSize {
+			// skip the data to keep the connection in sync
+			if _, err := io.CopyN(io.Discard, r, int64(size)+2); err != nil {
+				return err
+			}
+			reply("SERVER_ERROR object too large for cache")
+			return nil
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return err
+		}
+		if data[size] != '\r' || data[size+1] != '\n' {
+			reply("CLIENT_ERROR bad data chunk")
+			return errors.New(`bad data chunk`)
+		}
+		key := fields[1]
+		if len(key) > memcachedMaxKeyLen {
+			reply("CLIENT_ERROR key too long")
+			return nil
+		}
+		if hiddenKey(key) {
+			reply("CLIENT_ERROR reserved key")
+			return nil
+		}
+		stored, err := s.store(cmd, key, data[:size], uint32(flags), exptime)
+		switch {
+		case err != nil:
+			fail(err)
+		case stored:
+			reply("STORED")
+		default:
+			reply("NOT_STORED")
+		}
+
+	case `delete`:
+		if len(fields) != 2 {
+			w.WriteString("ERROR\r\n")
+			return nil
+		}
+		found, err := s.delete(fields[1])
+		switch {
+		case err != nil:
+			fail(err)
+		case found:
+			reply("DELETED")
+		default:
+			reply("NOT_FOUND")
+		}
+
+	case `incr`, `decr`:
+		if len(fields) != 3 {
+			w.WriteString("ERROR\r\n")
+			return nil
+		}
+		delta, err := strconv.ParseUint(fields[2], 10, 64)
+		if err != nil {
+			reply("CLIENT_ERROR invalid numeric delta argument")
+			return nil
+		}
+		n, found, err := s.incr(fields[1], delta, cmd == `decr`)
+		switch {
+		case errors.Is(err, strconv.ErrSyntax):
+			reply("CLIENT_ERROR cannot increment or decrement non-numeric value")
+		case err != nil:
+			fail(err)
+		case !found:
+			reply("NOT_FOUND")
+		default:
+			reply(strconv.FormatUint(n, 10))
+		}
+
+	case `touch`:
+		if len(fields) != 3 {
+			w.WriteString("ERROR\r\n")
+			return nil
+		}
+		exptime, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			reply("CLIENT_ERROR invalid exptime argument")
+			return nil
+		}
+		found, err := s.touch(fields[1], exptime)
+		switch {
+		case err != nil:
+			fail(err)
+		case found:
+			reply("TOUCHED")
+		default:
+			reply("NOT_FOUND")
+		}
+
+	case `version`:
+		w.WriteString("VERSION " + memcachedVersion + "\r\n")
+
+	default:
+		w.WriteString("ERROR\r\n")
+	}
+	return nil
+}
+
+// memcachedExpiry converts a memcached exptime to an expiry, zero for
+// items that do not expire. It reports false for items already expired
+func memcachedExpiry(exptime int64, now time.Time) (time.Time, bool) {
+	switch {
+	case exptime == 0:
+		return time.Time{}, true
+	case exptime < 0:
+		return time.Time{}, false
+	case exptime <= memcachedMaxRelExp:
+		return now.Add(time.Duration(exptime) * time.Second), true
+	}
+	exp := time.Unix(exptime, 0)
+	return exp, exp.After(now)
+}
+
+func (s *memcachedServer) flagsKey(key string) string {
+	return fmt.Sprintf(memcachedFlagsKey, len(s.bucket), s.bucket, key)
+}
+
+// hiddenKey reports whether a key is kept by the store for itself
+func hiddenKey(key string) bool {
+	return strings.HasPrefix(key, internalKeyPrefix)
+}
+
+// getItem returns the value and flags of an item,
+// an empty value if it does not exist
+func (s *memcachedServer) getItem(key string) ([]byte, uint32, error) {
+	if hiddenKey(key) {
+		return nil, 0, nil
+	}
+	val, err := s.p.get(s.bucket, key)
+	if err != nil || len(val) == 0 {
+		return val, 0, err
+	}
+	if err = s.p.recordAccess(s.bucket, key); err != nil {
+		return val, 0, err
+	}
+	fv, err := s.p.get(memcachedFlagsBuckt, s.flagsKey(key))
+	if err != nil || len(fv) == 0 {
+		return val, 0, err
+	}
+	flags, err := strconv.ParseUint(string(fv), 10, 32)
+	if err != nil {
+		return val, 0, ErrCorruptValue
+	}
+	return val, uint32(flags), nil
+}
+
+// store runs a set, add or replace and reports whether it stored the item
+func (s *memcachedServer) store(cmd, key string, val []byte, flags uint32, exptime int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expiry, live := memcachedExpiry(exptime, time.Now())
+	if cmd != `set` {
+		exists, err := s.p.exists(nil, s.bucket, key)
+		if err != nil {
+			return false, err
+		}
+		if exists != (cmd == `replace`) {
+			return false, nil
+		}
+	}
+	if !live || len(val) == 0 {
+		// stored and expired at once
+		_, err := s.delete(key)
+		return err == nil, err
+	}
+	if err := s.setItem(key, val, flags, expiry); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// setItem stores the value of an item along with its flags
+func (s *memcachedServer) setItem(key string, val []byte, flags uint32, expiry time.Time) error {
+	// the flags expire along with the item, even if the bucket has a ttl
+	expiry = s.p.bucketExpiry(s.bucket, key, expiry)
+	var exp sql.NullInt64
+	if !expiry.IsZero() {
+		exp = sql.NullInt64{Int64: expiry.UnixNano(), Valid: true}
+	}
+	return s.p.store(s.bucket, key, val, expiry, storeOptions{
+		also: func(tx *sql.Tx) error {
+			fk := s.flagsKey(key)
+			if flags == 0 {
+				return s.p.dropKeys(tx, "", memcachedFlagsBuckt, []string{fk})
+			}
+			_, err := s.p.writeValue(tx, memcachedFlagsBuckt, fk, []byte(strconv.FormatUint(uint64(flags), 10)), exp, skipped{})
+			return err
+		},
+	})
+}
+
+// delete deletes an item and reports whether it existed
+func (s *memcachedServer) delete(key string) (bool, error) {
+	if hiddenKey(key) {
+		return false, nil
+	}
+	exists, err := s.p.exists(nil, s.bucket, key)
+	if err != nil || !exists {
+		return false, err
+	}
+	if err = s.p.del(s.bucket, key); err != nil {
+		return false, err
+	}
+	return true, s.p.del(memcachedFlagsBuckt, s.flagsKey(key))
+}
+
+// incr adds the delta to a decimal item, or subtracts it down to zero
+// for decr, keeping its expiry. Increments wrap around at 64 bits
+func (s *memcachedServer) incr(key string, delta uint64, decr bool) (uint64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if hiddenKey(key) {
+		return 0, false, nil
+	}
+	val, err := s.p.get(s.bucket, key)
+	if err != nil || len(val) == 0 {
+		return 0, false, err
+	}
+	n, err := strconv.ParseUint(strings.TrimSpace(string(val)), 10, 64)
+	if err != nil {
+		return 0, true, strconv.ErrSyntax
+	}
+	switch {
+	case !decr:
+		n += delta
+	case delta > n:
+		n = 0
+	default:
+		n -= delta
+	}
+	return n, true, s.p.store(s.bucket, key, []byte(strconv.FormatUint(n, 10)), time.Time{}, storeOptions{keepTTL: true})
+}
+
+// touch sets the expiry of an item and its flags without rewriting
+// them, and reports whether it exists
+func (s *memcachedServer) touch(key string, exptime int64) (bool, error) {
+	if hiddenKey(key) {
+		return false, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expiry, live := memcachedExpiry(exptime, time.Now())
+	if !live {
+		return s.delete(key)
+	}
+	var touched bool
+	err := s.p.retry(func() error {
+		return s.p.withTx(func(tx *sql.Tx) error {
+			var err error
+			if touched, err = s.p.touch(tx, s.bucket, key, &expiry); err != nil || !touched {
+				return err
+			}
+			_, err = s.p.touch(tx, memcachedFlagsBuckt, s.flagsKey(key), &expiry)
+			return err
+		})
+	})
+	if err != nil {
+		return false, err
+	}
+	s.p.warmDel(s.bucket, key)
+	return touched, nil
+}
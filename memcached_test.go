@@ -0,0 +1,155 @@
+package sqltplainkv
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemcached(t *testing.T) {
+	pkv := newTestKV(t)
+	l, err := net.Listen(`tcp`, `127.0.0.1:0`)
+	if err != nil {
+		t.Fatalf(`Listen: %v`, err)
+	}
+	go pkv.ServeMemcachedListener(l, WithMemcachedBucket(`cache`))
+	t.Cleanup(func() { l.Close() })
+
+	conn, err := net.Dial(`tcp`, l.Addr().String())
+	if err != nil {
+		t.Fatalf(`Dial: %v`, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	r := bufio.NewReader(conn)
+	pkv.SetBucket(`cache`)
+	pkv.TallyIncr(`hits`)
+
+	for _, tc := range []struct {
+		send, want string
+	}{
+		{"set a 5 0 5\r\nhello\r\n", "STORED\r\n"},
+		{"get a b\r\n", "VALUE a 5 5\r\nhello\r\nEND\r\n"},
+		{"add a 0 0 1\r\nx\r\n", "NOT_STORED\r\n"},
+		{"replace b 0 0 1\r\nx\r\n", "NOT_STORED\r\n"},
+		{"add b 0 0 1\r\nx\r\n", "STORED\r\n"},
+		{"replace b 0 0 2\r\nyy\r\n", "STORED\r\n"},
+		{"get b\r\n", "VALUE b 0 2\r\nyy\r\nEND\r\n"},
+		{"set n 0 100 2\r\n10\r\n", "STORED\r\n"},
+		{"incr n 5\r\n", "15\r\n"},
+		{"decr n 20\r\n", "0\r\n"},
+		{"incr a 1\r\n", "CLIENT_ERROR cannot increment or decrement non-numeric value\r\n"},
+		{"incr missing 1\r\n", "NOT_FOUND\r\n"},
+		{"set gone 0 -1 1\r\nx\r\n", "STORED\r\n"},
+		{"get gone\r\n", "END\r\n"},
+		{"touch a 100\r\n", "TOUCHED\r\n"},
+		{"delete b noreply\r\ndelete b\r\n", "NOT_FOUND\r\n"},
+		{"get _______#tally-hits\r\n", "END\r\n"},
+		{"set _______#tally-hits 0 0 1\r\n9\r\n", "CLIENT_ERROR reserved key\r\n"},
+		{"delete _______#tally-hits\r\n", "NOT_FOUND\r\n"},
+		{"bogus\r\n", "ERROR\r\n"},
+	} {
+		if _, err = conn.Write([]byte(tc.send)); err != nil {
+			t.Fatalf(`Write: %v`, err)
+		}
+		got := make([]byte, len(tc.want))
+		if _, err = io.ReadFull(r, got); err != nil || string(got) != tc.want {
+			t.Logf(`%q: expected %q, got %q %v`, tc.send, tc.want, got, err)
+			t.Fail()
+		}
+	}
+
+	// the items are stored in the bucket, with their expiry
+	if n, _ := pkv.Tally(`hits`, 0); n != 1 {
+		t.Logf(`Expected the hidden tally to be left alone, got %d`, n)
+		t.Fail()
+	}
+	if v, _ := pkv.Get(`a`); string(v) != `hello` {
+		t.Logf(`Expected the item in the bucket, got %q`, v)
+		t.Fail()
+	}
+	meta, err := pkv.GetMeta(`n`)
+	if err != nil || meta.ExpiresAt.IsZero() {
+		t.Logf(`Expected incr and decr to keep the expiry, got %v %v`, meta.ExpiresAt, err)
+		t.Fail()
+	}
+	if keys, _ := pkv.ListKeys(``); strings.Join(keys, `,`) != `_______#tally-hits,a,n` {
+		t.Logf(`Unexpected keys %v`, keys)
+		t.Fail()
+	}
+
+	// a counter that does not expire does not pick up the bucket ttl
+	pkv.Set(`counter`, []byte(`1`))
+	if err = pkv.SetBucketTTL(`cache`, time.Hour); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if _, err = conn.Write([]byte("incr counter 1\r\n")); err != nil {
+		t.Fatalf(`Write: %v`, err)
+	}
+	if got, err := r.ReadString('\n'); err != nil || got != "2\r\n" {
+		t.Logf(`Expected 2, got %q %v`, got, err)
+		t.Fail()
+	}
+	if ttl, err := pkv.TTL(`counter`); err != nil || ttl != NoTTL {
+		t.Logf(`Expected incr to keep the counter without expiry, got %v %v`, ttl, err)
+		t.Fail()
+	}
+}
+
+func TestMemcachedExpiry(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	for _, tc := range []struct {
+		exptime int64
+		want    time.Time
+		live    bool
+	}{
+		{0, time.Time{}, true},
+		{-1, time.Time{}, false},
+		{60, now.Add(time.Minute), true},
+		{1800000000, time.Unix(1800000000, 0), true},
+		{1600000000, time.Unix(1600000000, 0), false},
+	} {
+		got, live := memcachedExpiry(tc.exptime, now)
+		if !got.Equal(tc.want) || live != tc.live {
+			t.Logf(`%d: expected %v %v, got %v %v`, tc.exptime, tc.want, tc.live, got, live)
+			t.Fail()
+		}
+	}
+}
+
+func TestMemcachedTouch(t *testing.T) {
+	pkv := newTestKV(t)
+	if err := pkv.SetBucketVersioning(`cache`, true); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	s := &memcachedServer{p: pkv, bucket: `cache`}
+	if _, err := s.store(`set`, `a`, []byte(`hello`), 5, 0); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if found, err := s.touch(`a`, 100); !found || err != nil {
+		t.Fatalf(`Expected the item to be touched, got %v %v`, found, err)
+	}
+
+	pkv.SetBucket(`cache`)
+	if versions, _ := pkv.ListVersions(`a`); len(versions) != 0 {
+		t.Logf(`Expected touch not to rewrite the value, got %d versions`, len(versions))
+		t.Fail()
+	}
+	if meta, _ := pkv.GetMeta(`a`); meta.ExpiresAt.IsZero() {
+		t.Logf(`Expected the item to expire`)
+		t.Fail()
+	}
+	if val, flags, err := s.getItem(`a`); string(val) != `hello` || flags != 5 || err != nil {
+		t.Logf(`Expected the item with its flags, got %q %d (%v)`, val, flags, err)
+		t.Fail()
+	}
+	var exp int64
+	pkv.db.QueryRow(`SELECT ExpiresAt FROM KeyValueTBL WHERE Bucket=?`, memcachedFlagsBuckt).Scan(&exp)
+	if meta, _ := pkv.GetMeta(`a`); exp != meta.ExpiresAt.UnixNano() {
+		t.Logf(`Expected the flags to expire along with the item`)
+		t.Fail()
+	}
+}
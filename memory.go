@@ -0,0 +1,35 @@
+package sqltplainkv
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+var memSeq int64
+
+// NewInMemory creates a SQLtPlainKV backed by a private in-memory
+// database, suited to tests and ephemeral caches. Connections share
+// one cache so they all see the same data, and they are never retired,
+// since the database is dropped once its last connection closes.
+// The data is lost when the store is closed; auto-close is not allowed
+func NewInMemory(opts ...Option) (*SQLtPlainKV, error) {
+	dsn := fmt.Sprintf("file:sqltplainkv-mem-%d?mode=memory&cache=shared", atomic.AddInt64(&memSeq, 1))
+	p, err := New(dsn, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if p.autoClose {
+		return nil, ErrAutoCloseInMemory
+	}
+	p.pool.maxLifetime = 0
+	if p.pool.maxIdle < p.pool.maxOpen {
+		p.pool.maxIdle = p.pool.maxOpen
+	}
+	if p.pool.maxIdle < 1 {
+		p.pool.maxIdle = 1
+	}
+	if err = p.open(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
@@ -0,0 +1,50 @@
+package sqltplainkv
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestNewInMemory(t *testing.T) {
+	a, err := NewInMemory()
+	if err != nil {
+		t.Fatalf(`%s`, err)
+	}
+	defer a.Close()
+	b, err := NewInMemory()
+	if err != nil {
+		t.Fatalf(`%s`, err)
+	}
+	defer b.Close()
+
+	if err = a.Set(`sample_key`, []byte(`Sample value`)); err != nil {
+		t.Fatalf(`%s`, err)
+	}
+
+	// every pooled connection sees the same data
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := a.Get(`sample_key`)
+			if err != nil || string(v) != `Sample value` {
+				t.Logf(`Expected Sample value, got %s (%v)`, v, err)
+				t.Fail()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// stores are independent of each other
+	if v, _ := b.Get(`sample_key`); len(v) != 0 {
+		t.Logf(`Expected an empty second store, got %s`, v)
+		t.Fail()
+	}
+
+	if _, err = NewInMemory(WithAutoClose(true)); !errors.Is(err, ErrAutoCloseInMemory) {
+		t.Logf(`Expected ErrAutoCloseInMemory, got %v`, err)
+		t.Fail()
+	}
+}
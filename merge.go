@@ -0,0 +1,152 @@
+package sqltplainkv
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"time"
+)
+
+// ConflictPolicy decides which value a merge keeps
+// when a key exists in both stores
+type ConflictPolicy int
+
+const (
+	TheirsWins ConflictPolicy = iota // the value of the other store
+	OursWins                         // the value of this store
+	NewestWins                       // the value written last
+)
+
+var (
+	ErrUnknownConflictPolicy error = errors.New(`unknown conflict policy`)
+	ErrMergeSelf             error = errors.New(`cannot merge a store into itself`)
+)
+
+// MergeFrom copies the records of every bucket of the other store
+// into this one, resolving keys present in both by the policy. Values
+// are decoded by the other store and encoded by this one, so the stores
+// may use different codecs and keys. Records are written in batched
+// transactions as by ImportNDJSON.
+//
+// NewestWins compares the times the records were last updated. Records
+// written before timestamps were stored count as the oldest. Merged
+// records keep the time they were last updated in the other store, so
+// merging back and forth keeps the newest value
+func (p *SQLtPlainKV) MergeFrom(other *SQLtPlainKV, policy ConflictPolicy) error {
+	var mode ConflictMode
+	switch policy {
+	case TheirsWins:
+		mode = ConflictOverwrite
+	case OursWins:
+		mode = ConflictSkip
+	case NewestWins:
+		mode = ConflictOverwrite
+	default:
+		return ErrUnknownConflictPolicy
+	}
+	if other == p {
+		return ErrMergeSelf
+	}
+	buckets, err := other.buckets()
+	if err != nil {
+		return err
+	}
+	for _, bucket := range buckets {
+		recs, err := other.listRecords(bucket)
+		if err != nil {
+			return err
+		}
+		i := 0
+		_, err = p.ImportRecords(ImportOptions{
+			Bucket:    bucket,
+			Conflict:  mode,
+			BatchSize: p.throttle.BatchSize,
+		}, func() (Record, error) {
+			for i < len(recs) {
+				rec := recs[i]
+				i++
+				if policy == NewestWins {
+					newer, err := p.newerThan(bucket, rec.Key, rec.UpdatedAt)
+					if err != nil {
+						return rec, err
+					}
+					if newer {
+						continue
+					}
+				}
+				return rec, other.fillRecord(bucket, &rec)
+			}
+			return Record{}, io.EOF
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buckets returns the names of the buckets holding records,
+// leaving out the internal ones
+func (p *SQLtPlainKV) buckets() ([]string, error) {
+	var err error
+	if err = p.open(); err != nil {
+		return nil, err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	rows, err := p.conn().Query(`SELECT DISTINCT Bucket FROM ` + p.defTableName + ` ORDER BY Bucket;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var buckets []string
+	for rows.Next() {
+		var b string
+		if err = rows.Scan(&b); err != nil {
+			return nil, err
+		}
+		if !isInternalBucket(b) {
+			buckets = append(buckets, b)
+		}
+	}
+	return buckets, rows.Err()
+}
+
+// newerThan reports whether this store holds a key updated no earlier
+// than theirs, the time the key was updated in the other store. A nil
+// time is the oldest
+func (p *SQLtPlainKV) newerThan(bucket, key string, theirs *time.Time) (bool, error) {
+	ours, ok, err := p.updatedAt(bucket, key)
+	if err != nil || !ok {
+		return false, err
+	}
+	if theirs == nil {
+		return true, nil
+	}
+	return ours >= theirs.UnixNano(), nil
+}
+
+// updatedAt returns the time a key was last updated in Unix
+// nanoseconds, zero if it is not known, and whether the key exists
+func (p *SQLtPlainKV) updatedAt(bucket, key string) (int64, bool, error) {
+	var upd sql.NullInt64
+	if err := p.open(); err != nil {
+		return 0, false, err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	err := p.conn().QueryRow(p.rebind(`
+	SELECT UpdatedAt FROM `+p.defTableName+`
+	WHERE Bucket=?
+		AND KeyID=?
+		AND (ExpiresAt IS NULL OR ExpiresAt > ?);`), bucket, key, time.Now().UnixNano()).Scan(&upd)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return upd.Int64, true, nil
+}
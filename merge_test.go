@@ -0,0 +1,102 @@
+package sqltplainkv
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMergeFrom(t *testing.T) {
+	device := newTestKV(t)
+	device.SetCodec(xorCodec(0x21))
+	device.Set(`shared`, []byte(`from device`))
+	device.Set(`only-device`, []byte(`device value`))
+	device.SetMime(`only-device`, `text/plain`)
+	device.SetBucket(`photos`)
+	device.Set(`p1`, []byte(`photo`))
+
+	central := newTestKV(t)
+	central.Set(`shared`, []byte(`from central`))
+
+	if err := central.MergeFrom(device, OursWins); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if val, _ := central.Get(`shared`); string(val) != `from central` {
+		t.Logf(`Expected our value to win, got %s`, val)
+		t.Fail()
+	}
+	if val, _ := central.Get(`only-device`); string(val) != `device value` {
+		t.Logf(`Expected the missing key to be merged, got %s`, val)
+		t.Fail()
+	}
+	if mime, _ := central.GetMime(`only-device`); mime != `text/plain` {
+		t.Logf(`Expected the mime to be merged, got %s`, mime)
+		t.Fail()
+	}
+	central.SetBucket(`photos`)
+	if val, _ := central.Get(`p1`); string(val) != `photo` {
+		t.Logf(`Expected other buckets to be merged, got %s`, val)
+		t.Fail()
+	}
+
+	central.SetBucket(`default`)
+	if err := central.MergeFrom(device, TheirsWins); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if val, _ := central.Get(`shared`); string(val) != `from device` {
+		t.Logf(`Expected their value to win, got %s`, val)
+		t.Fail()
+	}
+
+	central.Set(`shared`, []byte(`newer in central`))
+	device.SetBucket(`default`)
+	device.Set(`only-device`, []byte(`newer in device`))
+	if err := central.MergeFrom(device, NewestWins); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if val, _ := central.Get(`shared`); string(val) != `newer in central` {
+		t.Logf(`Expected the newer value of central to win, got %s`, val)
+		t.Fail()
+	}
+	if val, _ := central.Get(`only-device`); string(val) != `newer in device` {
+		t.Logf(`Expected the newer value of device to win, got %s`, val)
+		t.Fail()
+	}
+	if err := central.MergeFrom(device, ConflictPolicy(9)); !errors.Is(err, ErrUnknownConflictPolicy) {
+		t.Logf(`Expected ErrUnknownConflictPolicy, got %v`, err)
+		t.Fail()
+	}
+	if err := central.MergeFrom(central, TheirsWins); !errors.Is(err, ErrMergeSelf) {
+		t.Logf(`Expected ErrMergeSelf, got %v`, err)
+		t.Fail()
+	}
+}
+
+func TestMergeKeepsUpdateTimes(t *testing.T) {
+	a := newTestKV(t)
+	b := newTestKV(t)
+	a.Set(`k`, []byte(`old`))
+	time.Sleep(2 * time.Millisecond)
+	b.Set(`k`, []byte(`new`))
+	time.Sleep(2 * time.Millisecond)
+
+	// the old value merged into a third store must not look newer
+	c := newTestKV(t)
+	if err := c.MergeFrom(a, NewestWins); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if err := c.MergeFrom(b, NewestWins); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if val, _ := c.Get(`k`); string(val) != `new` {
+		t.Logf(`Expected the newest value, got %s`, val)
+		t.Fail()
+	}
+	if err := a.MergeFrom(c, NewestWins); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if val, _ := a.Get(`k`); string(val) != `new` {
+		t.Logf(`Expected the newest value merged back, got %s`, val)
+		t.Fail()
+	}
+}
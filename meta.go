@@ -0,0 +1,145 @@
+package sqltplainkv
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Meta describes a record without its value
+type Meta struct {
+	Size      int64     // size of the value as returned by Get
+	Mime      string    // empty if no mime is set
+	CreatedAt time.Time // zero for records written before timestamps were stored
+	UpdatedAt time.Time // zero for records written before timestamps were stored
+	ExpiresAt time.Time // zero if the record does not expire
+}
+
+// GetMeta returns the metadata of a key in the current bucket.
+// It returns ErrKeyNotFound if the key does not exist
+func (p *SQLtPlainKV) GetMeta(key string) (Meta, error) {
+	var err error
+	if p.currBuckt == "" {
+		p.currBuckt = "default"
+	}
+	if err = p.open(); err != nil {
+		return Meta{}, err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	return p.getMeta(p.currBuckt, key)
+}
+
+func (p *SQLtPlainKV) getMeta(bucket, key string) (Meta, error) {
+	var (
+		meta          Meta
+		val           []byte
+		mime          sql.NullString
+		crt, upd, exp sql.NullInt64
+	)
+	err := p.conn().QueryRow(p.rebind(`
+	SELECT Value, CreatedAt, UpdatedAt, ExpiresAt, Mime FROM `+p.defTableName+`
+	WHERE Bucket=?
+		AND KeyID=?
+		AND (ExpiresAt IS NULL OR ExpiresAt > ?);`), bucket, key, time.Now().UnixNano()).Scan(&val, &crt, &upd, &exp, &mime)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return meta, ErrKeyNotFound
+		}
+		return meta, err
+	}
+	meta.CreatedAt = nanoTime(crt)
+	meta.UpdatedAt = nanoTime(upd)
+	meta.ExpiresAt = nanoTime(exp)
+	meta.Mime = mime.String
+	if meta.Size, err = p.valueSize(bucket, key, val); err != nil {
+		return meta, err
+	}
+	return meta, nil
+}
+
+// valueSize returns the size of a stored value once decoded. Values
+// in chunks record their size unless they were encoded as a whole
+func (p *SQLtPlainKV) valueSize(bucket, key string, val []byte) (int64, error) {
+	if m, ok := parseManifest(val); ok {
+		if m.flags&manifestCodec == 0 || p.codecFor(bucket) == nil {
+			return m.size, nil
+		}
+		val, err := p.get(bucket, key)
+		return int64(len(val)), err
+	}
+	val, err := p.decodeValue(bucket, val)
+	return int64(len(val)), err
+}
+
+// nanoTime converts a nullable Unix time in nanoseconds,
+// returning the zero time for NULL
+func nanoTime(v sql.NullInt64) time.Time {
+	if !v.Valid {
+		return time.Time{}
+	}
+	return time.Unix(0, v.Int64)
+}
+
+// KeyInfo is the metadata returned along with a value by GetWithInfo
+type KeyInfo = Meta
+
+// GetWithInfo returns the value of a key in the current bucket along
+// with its metadata, read with a single query. It returns
+// ErrKeyNotFound if the key does not exist
+func (p *SQLtPlainKV) GetWithInfo(key string) ([]byte, KeyInfo, error) {
+	var (
+		err           error
+		info          KeyInfo
+		val           []byte
+		mime          sql.NullString
+		sum           sql.NullInt64
+		crt, upd, exp sql.NullInt64
+	)
+	if p.currBuckt == "" {
+		p.currBuckt = "default"
+	}
+	bucket := p.currBuckt
+	if err = p.open(); err != nil {
+		return nil, info, err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	err = p.conn().QueryRow(p.rebind(`
+	SELECT Value, Checksum, CreatedAt, UpdatedAt, ExpiresAt, Mime FROM `+p.defTableName+`
+	WHERE Bucket=?
+		AND KeyID=?
+		AND (ExpiresAt IS NULL OR ExpiresAt > ?);`),
+		bucket, key, time.Now().UnixNano()).Scan(&val, &sum, &crt, &upd, &exp, &mime)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, info, ErrKeyNotFound
+		}
+		return nil, info, err
+	}
+	if err = verifyChecksum(val, sum); err != nil {
+		return nil, info, err
+	}
+	if m, ok := parseManifest(val); ok {
+		var buf bytes.Buffer
+		buf.Grow(int(m.size))
+		if err = p.readChunks(nil, bucket, key, m, &buf); err != nil {
+			return nil, info, err
+		}
+		val, err = p.decodeChunked(bucket, m, buf.Bytes())
+	} else {
+		val, err = p.decodeValue(bucket, val)
+	}
+	if err != nil {
+		return nil, info, err
+	}
+	info.Size = int64(len(val))
+	info.Mime = mime.String
+	info.CreatedAt = nanoTime(crt)
+	info.UpdatedAt = nanoTime(upd)
+	info.ExpiresAt = nanoTime(exp)
+	return val, info, p.recordAccess(bucket, key)
+}
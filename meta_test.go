@@ -0,0 +1,82 @@
+package sqltplainkv
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetMeta(t *testing.T) {
+	pkv := newTestKV(t)
+	start := time.Now()
+	pkv.Set(`page`, []byte(`<p>hello</p>`))
+	pkv.SetMime(`page`, `text/html`)
+	first, err := pkv.GetMeta(`page`)
+	if err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if first.Size != 12 || first.Mime != `text/html` {
+		t.Logf(`Expected size 12 and text/html, got %d and %s`, first.Size, first.Mime)
+		t.Fail()
+	}
+	if first.CreatedAt.Before(start) || !first.UpdatedAt.Equal(first.CreatedAt) || !first.ExpiresAt.IsZero() {
+		t.Logf(`Unexpected timestamps %+v`, first)
+		t.Fail()
+	}
+
+	time.Sleep(2 * time.Millisecond)
+	pkv.Set(`page`, []byte(`<p>hello again</p>`))
+	second, _ := pkv.GetMeta(`page`)
+	if !second.CreatedAt.Equal(first.CreatedAt) || !second.UpdatedAt.After(first.UpdatedAt) {
+		t.Logf(`Expected only UpdatedAt to change, got %+v then %+v`, first, second)
+		t.Fail()
+	}
+
+	if _, err = pkv.GetMeta(`missing`); !errors.Is(err, ErrKeyNotFound) {
+		t.Logf(`Expected ErrKeyNotFound, got %v`, err)
+		t.Fail()
+	}
+}
+
+func TestGetMetaEncodedSize(t *testing.T) {
+	pkv := newTestKV(t)
+	pkv.SetCodec(prefixCodec(`enc:`))
+	pkv.SetLimits(Limits{ChunkSize: 16})
+	value := strings.Repeat(`x`, 100)
+	pkv.Set(`big`, []byte(value))
+	meta, err := pkv.GetMeta(`big`)
+	if err != nil || meta.Size != int64(len(value)) {
+		t.Logf(`Expected size %d, got %d (%v)`, len(value), meta.Size, err)
+		t.Fail()
+	}
+}
+
+func TestFilterTimestamps(t *testing.T) {
+	pkv := newTestKV(t)
+	pkv.Set(`fresh`, []byte(`1`))
+	keys, err := pkv.ListWhere(`updated_at > now()-60 && created_at <= now()`)
+	if err != nil || len(keys) != 1 {
+		t.Logf(`Expected the fresh key, got %v (%v)`, keys, err)
+		t.Fail()
+	}
+}
+
+func TestGetWithInfo(t *testing.T) {
+	pkv := newTestKV(t)
+	pkv.SetCodec(prefixCodec(`enc:`))
+	pkv.Set(`logo`, []byte(`PNG...`))
+	pkv.SetMime(`logo`, `image/png`)
+	val, info, err := pkv.GetWithInfo(`logo`)
+	if err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if string(val) != `PNG...` || info.Size != 6 || info.Mime != `image/png` || info.UpdatedAt.IsZero() {
+		t.Logf(`Unexpected value %s with info %+v`, val, info)
+		t.Fail()
+	}
+	if _, _, err = pkv.GetWithInfo(`missing`); !errors.Is(err, ErrKeyNotFound) {
+		t.Logf(`Expected ErrKeyNotFound, got %v`, err)
+		t.Fail()
+	}
+}
@@ -0,0 +1,131 @@
+package sqltplainkv
+
+import (
+	"expvar"
+	"sync/atomic"
+	"time"
+)
+
+// OpStats are the counters of one kind of operation
+type OpStats struct {
+	Count    int64         // operations run
+	Errors   int64         // operations that failed
+	Duration time.Duration // total time spent in the operations
+}
+
+// Metrics is a snapshot of the metrics of a store
+type Metrics struct {
+	Get             OpStats
+	Set             OpStats
+	Del             OpStats
+	List            OpStats
+	Expired         int64 // expired records deleted by SweepExpired
+	OpenConnections int   // zero while the database is closed
+	DatabaseSize    int64 // zero while the database is closed
+}
+
+type opKind int
+
+const (
+	opGet opKind = iota
+	opSet
+	opDel
+	opList
+	opKinds
+)
+
+func (op opKind) String() string {
+	switch op {
+	case opGet:
+		return `get`
+	case opSet:
+		return `set`
+	case opDel:
+		return `del`
+	case opList:
+		return `list`
+	}
+	return ``
+}
+
+// opCounters are updated atomically as operations complete
+type opCounters struct {
+	count  int64
+	errors int64
+	nanos  int64
+}
+
+// metrics holds the counters of a store with metrics enabled
+type metrics struct {
+	ops     [opKinds]opCounters
+	expired int64
+}
+
+// EnableMetrics starts counting the get, set, delete and list
+// operations of the store, along with their errors and the time they take. Reads
+// and writes of the internal buckets, such as mimes, are not counted
+func (p *SQLtPlainKV) EnableMetrics() {
+	if p.metrics == nil {
+		p.metrics = &metrics{}
+	}
+}
+
+// WithMetrics counts the operations of the store, see EnableMetrics
+func WithMetrics() Option {
+	return func(p *SQLtPlainKV) error {
+		p.EnableMetrics()
+		return nil
+	}
+}
+
+// observe records an operation that took d and returned err
+func (m *metrics) observe(op opKind, d time.Duration, err error) {
+	c := &m.ops[op]
+	atomic.AddInt64(&c.count, 1)
+	atomic.AddInt64(&c.nanos, int64(d))
+	if err != nil {
+		atomic.AddInt64(&c.errors, 1)
+	}
+}
+
+func (c *opCounters) snapshot() OpStats {
+	return OpStats{
+		Count:    atomic.LoadInt64(&c.count),
+		Errors:   atomic.LoadInt64(&c.errors),
+		Duration: time.Duration(atomic.LoadInt64(&c.nanos)),
+	}
+}
+
+// Metrics returns a snapshot of the metrics of the store. Operation
+// counters stay at zero unless metrics are enabled. It does not open
+// the database, and is safe to call while other goroutines use the store
+func (p *SQLtPlainKV) Metrics() (Metrics, error) {
+	var m Metrics
+	if p.metrics != nil {
+		m.Get = p.metrics.ops[opGet].snapshot()
+		m.Set = p.metrics.ops[opSet].snapshot()
+		m.Del = p.metrics.ops[opDel].snapshot()
+		m.List = p.metrics.ops[opList].snapshot()
+		m.Expired = atomic.LoadInt64(&p.metrics.expired)
+	}
+	p.idle.mu.Lock()
+	defer p.idle.mu.Unlock()
+	if p.db == nil {
+		return m, nil
+	}
+	m.OpenConnections = p.db.Stats().OpenConnections
+	var err error
+	m.DatabaseSize, err = p.databaseSize()
+	return m, err
+}
+
+// MetricsVar returns an expvar.Var publishing the metrics of the
+// store as JSON, for example
+//
+//	expvar.Publish("kv", kv.MetricsVar())
+func (p *SQLtPlainKV) MetricsVar() expvar.Var {
+	return expvar.Func(func() any {
+		m, _ := p.Metrics()
+		return m
+	})
+}
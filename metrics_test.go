@@ -0,0 +1,54 @@
+package sqltplainkv
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	pkv := newTestKV(t)
+	pkv.Set(`before`, []byte(`x`))
+	m, err := pkv.Metrics()
+	if err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if m.Set.Count != 0 || m.OpenConnections == 0 || m.DatabaseSize == 0 {
+		t.Logf(`Unexpected metrics with counting off %+v`, m)
+		t.Fail()
+	}
+
+	pkv.EnableMetrics()
+	pkv.Set(`a`, []byte(`1`))
+	pkv.SetMime(`a`, `text/plain`)
+	pkv.Get(`a`)
+	pkv.Get(`missing`)
+	pkv.Del(`a`)
+	pkv.Set(`big`, make([]byte, pkv.limits.MaxValueSize+1))
+
+	if m, err = pkv.Metrics(); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if m.Set.Count != 2 || m.Set.Errors != 1 || m.Get.Count != 2 || m.Del.Count != 1 {
+		t.Logf(`Expected the mime to be left out of the counts, got %+v`, m)
+		t.Fail()
+	}
+	if m.Get.Duration <= 0 {
+		t.Logf(`Expected get durations to be recorded`)
+		t.Fail()
+	}
+
+	var out Metrics
+	if err = json.Unmarshal([]byte(pkv.MetricsVar().String()), &out); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if out.Set.Count != 2 {
+		t.Logf(`Unexpected expvar output %+v`, out)
+		t.Fail()
+	}
+
+	pkv.Close()
+	if m, err = pkv.Metrics(); err != nil || m.OpenConnections != 0 || m.Get.Count != 2 {
+		t.Logf(`Unexpected metrics of a closed store %+v, %v`, m, err)
+		t.Fail()
+	}
+}
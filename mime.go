@@ -0,0 +1,78 @@
+package sqltplainkv
+
+import (
+	"mime"
+	"net/http"
+	"path"
+)
+
+// SetDetectMime stores the value under the key in the current bucket
+// along with its MIME type, in one transaction. The type comes from the
+// extension of the key when it has a known one, as content sniffing
+// cannot tell JSON, CSS or JavaScript from plain text, and from the
+// content of the value with http.DetectContentType otherwise
+func (p *SQLtPlainKV) SetDetectMime(key string, value []byte) error {
+	if p.currBuckt == "" {
+		p.currBuckt = "default"
+	}
+	return p.setTyped(p.currBuckt, key, value, DetectMime(key, value))
+}
+
+// GetMimeStrict returns the mime of the value stored under the key in
+// the current bucket and whether one is set. Unlike GetMime it does not
+// fall back to text/html, and it returns ErrKeyNotFound if the key does
+// not exist
+func (p *SQLtPlainKV) GetMimeStrict(key string) (string, bool, error) {
+	if p.currBuckt == "" {
+		p.currBuckt = "default"
+	}
+	mime, err := p.lookupMime(p.currBuckt, key)
+	if err != nil {
+		return "", false, err
+	}
+	return mime, mime != "", nil
+}
+
+// DelMime removes the mime of the value stored under the key in the
+// current bucket, leaving the value. It returns ErrKeyNotFound if the
+// key does not exist
+func (p *SQLtPlainKV) DelMime(key string) error {
+	if p.currBuckt == "" {
+		p.currBuckt = "default"
+	}
+	return p.setMime(p.currBuckt, key, "")
+}
+
+// SetBucketDefaultMime sets the mime GetMime returns for keys of the
+// bucket without one, in place of text/html. It is kept in the bucket
+// options. An empty mime restores text/html
+func (p *SQLtPlainKV) SetBucketDefaultMime(bucket, mime string) error {
+	if bucket == "" {
+		bucket = "default"
+	}
+	opts, _, err := p.GetBucketOptions(bucket)
+	if err != nil {
+		return err
+	}
+	opts.DefaultMime = mime
+	return p.SetBucketOptions(bucket, opts)
+}
+
+// bucketDefaultMime returns the mime of the keys of a bucket without one
+func (p *SQLtPlainKV) bucketDefaultMime(bucket string) string {
+	if opts, ok := p.bucketOpts[bucket]; ok && opts.DefaultMime != "" {
+		return opts.DefaultMime
+	}
+	return defaultMime
+}
+
+// DetectMime returns the MIME type of a value stored under the key,
+// as SetDetectMime stores it
+func DetectMime(key string, value []byte) string {
+	if ext := path.Ext(key); ext != "" {
+		if m := mime.TypeByExtension(ext); m != "" {
+			return m
+		}
+	}
+	return http.DetectContentType(value)
+}
@@ -0,0 +1,183 @@
+package sqltplainkv
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSetDetectMime(t *testing.T) {
+	pkv := newTestKV(t)
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	for _, tc := range []struct {
+		key   string
+		value []byte
+		mime  string
+	}{
+		{`config.json`, []byte(`{"a":1}`), `application/json`},
+		{`logo`, png, `image/png`},
+		{`page`, []byte(`<!DOCTYPE html><p>hi</p>`), `text/html; charset=utf-8`},
+		{`notes.unknownext`, []byte(`plain words`), `text/plain; charset=utf-8`},
+		{`blob`, []byte{0, 1, 2, 3}, `application/octet-stream`},
+	} {
+		if err := pkv.SetDetectMime(tc.key, tc.value); err != nil {
+			t.Fatalf(`SetDetectMime %s: %v`, tc.key, err)
+		}
+		if v, _ := pkv.Get(tc.key); string(v) != string(tc.value) {
+			t.Logf(`%s: expected the value to be stored, got %q`, tc.key, v)
+			t.Fail()
+		}
+		if m, _ := pkv.GetMime(tc.key); !strings.EqualFold(m, tc.mime) {
+			t.Logf(`%s: expected %q, got %q`, tc.key, tc.mime, m)
+			t.Fail()
+		}
+	}
+
+	// inside a transaction, both are rolled back together
+	pkv.Begin()
+	pkv.SetDetectMime(`style.css`, []byte(`p{}`))
+	pkv.Rollback()
+	if v, _ := pkv.Get(`style.css`); len(v) != 0 {
+		t.Logf(`Expected the value to be rolled back`)
+		t.Fail()
+	}
+	if m, _ := pkv.GetMime(`style.css`); m != defaultMime {
+		t.Logf(`Expected the mime to be rolled back, got %q`, m)
+		t.Fail()
+	}
+}
+
+func TestMimePerBucket(t *testing.T) {
+	pkv := newTestKV(t)
+	pkv.Set(`logo`, []byte(`<svg/>`))
+	pkv.SetMime(`logo`, `image/svg+xml`)
+	pkv.SetBucket(`images`)
+	pkv.Set(`logo`, []byte(`PNG...`))
+	if err := pkv.SetMime(`logo`, `image/png`); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if err := pkv.SetMime(`missing`, `image/png`); !errors.Is(err, ErrKeyNotFound) {
+		t.Logf(`Expected ErrKeyNotFound for a missing key, got %v`, err)
+		t.Fail()
+	}
+
+	// deleting in one bucket leaves the mime of the others alone
+	pkv.Del(`logo`)
+	pkv.Set(`logo`, []byte(`PNG...`))
+	if m, _ := pkv.GetMime(`logo`); m != defaultMime {
+		t.Logf(`Expected the mime to be deleted with the key, got %s`, m)
+		t.Fail()
+	}
+	pkv.SetBucket(`default`)
+	if m, _ := pkv.GetMime(`logo`); m != `image/svg+xml` {
+		t.Logf(`Expected the mime of the default bucket to be kept, got %s`, m)
+		t.Fail()
+	}
+
+	// writing the value keeps the mime
+	pkv.Set(`logo`, []byte(`<svg></svg>`))
+	if m, _ := pkv.GetMime(`logo`); m != `image/svg+xml` {
+		t.Logf(`Expected the mime to survive a write, got %s`, m)
+		t.Fail()
+	}
+}
+
+func TestGetMimeStrict(t *testing.T) {
+	pkv := newTestKV(t)
+	pkv.Set(`page`, []byte(`<p>hi</p>`))
+	if m, ok, err := pkv.GetMimeStrict(`page`); m != `` || ok || err != nil {
+		t.Logf(`Expected no mime, got %q, %v, %v`, m, ok, err)
+		t.Fail()
+	}
+	pkv.SetMime(`page`, defaultMime)
+	if m, ok, err := pkv.GetMimeStrict(`page`); m != defaultMime || !ok || err != nil {
+		t.Logf(`Expected %s to be set, got %q, %v, %v`, defaultMime, m, ok, err)
+		t.Fail()
+	}
+	if _, _, err := pkv.GetMimeStrict(`missing`); !errors.Is(err, ErrKeyNotFound) {
+		t.Logf(`Expected ErrKeyNotFound, got %v`, err)
+		t.Fail()
+	}
+
+	if err := pkv.DelMime(`page`); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if _, ok, _ := pkv.GetMimeStrict(`page`); ok {
+		t.Logf(`Expected the mime to be removed`)
+		t.Fail()
+	}
+	if v, _ := pkv.Get(`page`); string(v) != `<p>hi</p>` {
+		t.Logf(`Expected DelMime to keep the value, got %q`, v)
+		t.Fail()
+	}
+	if err := pkv.DelMime(`missing`); !errors.Is(err, ErrKeyNotFound) {
+		t.Logf(`Expected ErrKeyNotFound, got %v`, err)
+		t.Fail()
+	}
+}
+
+func TestBucketDefaultMime(t *testing.T) {
+	pkv := newTestKV(t)
+	if err := pkv.SetBucketDefaultMime(`api`, `application/json`); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	pkv.SetBucket(`api`)
+	pkv.Set(`status`, []byte(`{"ok":true}`))
+	pkv.Set(`readme`, []byte(`plain words`))
+	pkv.SetMime(`readme`, `text/plain`)
+	if m, _ := pkv.GetMime(`status`); m != `application/json` {
+		t.Logf(`Expected the default of the bucket, got %s`, m)
+		t.Fail()
+	}
+	if m, _ := pkv.GetMime(`readme`); m != `text/plain` {
+		t.Logf(`Expected the mime of the key, got %s`, m)
+		t.Fail()
+	}
+	if _, ok, _ := pkv.GetMimeStrict(`status`); ok {
+		t.Logf(`Expected GetMimeStrict to ignore the default of the bucket`)
+		t.Fail()
+	}
+
+	pkv.SetBucket(`default`)
+	pkv.Set(`status`, []byte(`{"ok":true}`))
+	if m, _ := pkv.GetMime(`status`); m != defaultMime {
+		t.Logf(`Expected %s in other buckets, got %s`, defaultMime, m)
+		t.Fail()
+	}
+
+	pkv.SetBucketDefaultMime(`api`, ``)
+	pkv.SetBucket(`api`)
+	if m, _ := pkv.GetMime(`status`); m != defaultMime {
+		t.Logf(`Expected %s once the default is cleared, got %s`, defaultMime, m)
+		t.Fail()
+	}
+}
+
+func TestSetDetectMimeConcurrent(t *testing.T) {
+	pkv := newTestKV(t)
+	var wg sync.WaitGroup
+	errs := make(chan error, 80)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				if err := pkv.SetDetectMime(fmt.Sprintf(`page-%d-%d.json`, i, j), []byte(`{}`)); err != nil {
+					errs <- err
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Logf(`Expected concurrent sets to succeed, got %v`, err)
+		t.Fail()
+	}
+	if mime, ok, err := pkv.GetMimeStrict(`page-7-9.json`); err != nil || !ok || mime != `application/json` {
+		t.Logf(`Expected application/json, got %q %v %v`, mime, ok, err)
+		t.Fail()
+	}
+}
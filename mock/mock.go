@@ -0,0 +1,273 @@
+// Package mock provides a map-backed implementation of
+// sqltplainkv.PlainKVer, so tests of code using the store need
+// neither a database file nor a driver
+package mock
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	sqltplainkv "github.com/narsilworks/sqlt-plainkv"
+)
+
+const (
+	defaultMime string = `text/html`
+	tallyKey    string = `_______#tally-%s`
+)
+
+// KV is an in-memory key-value store behaving like SQLtPlainKV.
+// Changes made after Begin are discarded by Rollback. It is safe
+// for concurrent use
+type KV struct {
+	mu     sync.Mutex
+	bucket string
+	data   state
+	saved  *state // state at Begin, nil outside a transaction
+}
+
+// state holds the records of every bucket and their mimes
+type state struct {
+	buckets map[string]map[string][]byte
+	mimes   map[string]string // by mimeKey
+}
+
+// mimeKey returns the key of the mime of a record in state.mimes
+func mimeKey(bucket, key string) string {
+	return bucket + "\x00" + key
+}
+
+var _ sqltplainkv.PlainKVer = (*KV)(nil)
+
+// NewKV creates an empty store using the default bucket
+func NewKV() *KV {
+	return &KV{
+		bucket: `default`,
+		data: state{
+			buckets: make(map[string]map[string][]byte),
+			mimes:   make(map[string]string),
+		},
+	}
+}
+
+func (s state) clone() state {
+	c := state{
+		buckets: make(map[string]map[string][]byte, len(s.buckets)),
+		mimes:   make(map[string]string, len(s.mimes)),
+	}
+	for b, recs := range s.buckets {
+		cr := make(map[string][]byte, len(recs))
+		for k, v := range recs {
+			cr[k] = v
+		}
+		c.buckets[b] = cr
+	}
+	for k, m := range s.mimes {
+		c.mimes[k] = m
+	}
+	return c
+}
+
+// Open does nothing, the store is always open
+func (m *KV) Open() error {
+	return nil
+}
+
+// Close discards the changes of a transaction still active
+func (m *KV) Close() error {
+	return m.Rollback()
+}
+
+// SetBucket sets the current bucket
+func (m *KV) SetBucket(bucket string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bucket = bucket
+}
+
+func (m *KV) currBucket() string {
+	if m.bucket == "" {
+		m.bucket = `default`
+	}
+	return m.bucket
+}
+
+// Get returns the value of a key in the current bucket,
+// or an empty value if the key does not exist
+func (m *KV) Get(key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.get(m.currBucket(), key), nil
+}
+
+func (m *KV) get(bucket, key string) []byte {
+	return append(make([]byte, 0), m.data.buckets[bucket][key]...)
+}
+
+// Set creates or updates a key in the current bucket
+func (m *KV) Set(key string, value []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.set(m.currBucket(), key, value)
+	return nil
+}
+
+func (m *KV) set(bucket, key string, value []byte) {
+	recs, ok := m.data.buckets[bucket]
+	if !ok {
+		recs = make(map[string][]byte)
+		m.data.buckets[bucket] = recs
+	}
+	recs[key] = append([]byte(nil), value...)
+}
+
+// Del deletes a key from the current bucket along with its mime
+func (m *KV) Del(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.data.buckets[m.currBucket()], key)
+	delete(m.data.mimes, mimeKey(m.currBucket(), key))
+	return nil
+}
+
+// ListKeys lists the keys of the current bucket starting with the
+// pattern, which may hold the % and _ wildcards of SQL LIKE
+func (m *KV) ListKeys(pattern string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	keys := make([]string, 0)
+	for k := range m.data.buckets[m.currBucket()] {
+		if like(k, pattern+"%") {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// GetMime returns the mime of a key, text/html if none is set
+func (m *KV) GetMime(key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if mime, ok := m.data.mimes[mimeKey(m.currBucket(), key)]; ok && mime != "" {
+		return mime, nil
+	}
+	return defaultMime, nil
+}
+
+// SetMime sets the mime of a key. It returns
+// sqltplainkv.ErrKeyNotFound if the key does not exist
+func (m *KV) SetMime(key string, mime string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.data.buckets[m.currBucket()][key]; !ok {
+		return sqltplainkv.ErrKeyNotFound
+	}
+	m.data.mimes[mimeKey(m.currBucket(), key)] = mime
+	return nil
+}
+
+// Tally gets the current tally of a key, creating it
+// with the offset if it does not exist
+func (m *KV) Tally(key string, offset int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.tally(key, offset), nil
+}
+
+func (m *KV) tally(key string, offset int) int {
+	tk := fmt.Sprintf(tallyKey, key)
+	tlly := m.get(m.currBucket(), tk)
+	if len(tlly) == 0 {
+		m.set(m.currBucket(), tk, []byte(strconv.Itoa(offset)))
+	}
+	tv, _ := strconv.Atoi(string(tlly))
+	return tv
+}
+
+func (m *KV) addTally(key string, delta int) int {
+	tv := m.tally(key, 0) + delta
+	m.set(m.currBucket(), fmt.Sprintf(tallyKey, key), []byte(strconv.Itoa(tv)))
+	return tv
+}
+
+// TallyIncr increments the tally
+func (m *KV) TallyIncr(key string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.addTally(key, 1), nil
+}
+
+// TallyDecr decrements the tally
+func (m *KV) TallyDecr(key string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.addTally(key, -1), nil
+}
+
+// TallyReset resets the tally to zero
+func (m *KV) TallyReset(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.set(m.currBucket(), fmt.Sprintf(tallyKey, key), []byte("0"))
+	return nil
+}
+
+// Begin starts a transaction. Changes made until Commit
+// are discarded by Rollback
+func (m *KV) Begin() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	saved := m.data.clone()
+	m.saved = &saved
+	return nil
+}
+
+// Commit keeps the changes of the transaction
+func (m *KV) Commit() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.saved = nil
+	return nil
+}
+
+// Rollback discards the changes of the transaction
+func (m *KV) Rollback() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.saved != nil {
+		m.data = *m.saved
+		m.saved = nil
+	}
+	return nil
+}
+
+// like reports whether s matches the SQL LIKE pattern,
+// ignoring case as SQLite does
+func like(s, pattern string) bool {
+	s, pattern = strings.ToLower(s), strings.ToLower(pattern)
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '%':
+			for i := 0; i <= len(s); i++ {
+				if like(s[i:], pattern[1:]) {
+					return true
+				}
+			}
+			return false
+		case '_':
+			if len(s) == 0 {
+				return false
+			}
+			s, pattern = s[1:], pattern[1:]
+		default:
+			if len(s) == 0 || s[0] != pattern[0] {
+				return false
+			}
+			s, pattern = s[1:], pattern[1:]
+		}
+	}
+	return len(s) == 0
+}
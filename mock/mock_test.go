@@ -0,0 +1,89 @@
+package mock
+
+import (
+	"reflect"
+	"testing"
+
+	sqltplainkv "github.com/narsilworks/sqlt-plainkv"
+)
+
+func TestKV(t *testing.T) {
+	var kv sqltplainkv.PlainKVer = NewKV()
+	kv.Set(`alpha`, []byte(`one`))
+	kv.Set(`alpine`, []byte(`two`))
+	kv.Set(`beta`, []byte(`three`))
+	kv.SetMime(`alpha`, `text/plain`)
+
+	if val, _ := kv.Get(`alpha`); string(val) != `one` {
+		t.Logf(`Expected one, got %s`, val)
+		t.Fail()
+	}
+	if val, err := kv.Get(`missing`); err != nil || val == nil || len(val) != 0 {
+		t.Logf(`Expected an empty value for a missing key, got %v (%v)`, val, err)
+		t.Fail()
+	}
+	if keys, _ := kv.ListKeys(`AL`); !reflect.DeepEqual(keys, []string{`alpha`, `alpine`}) {
+		t.Logf(`Expected the keys starting with al, got %v`, keys)
+		t.Fail()
+	}
+	if keys, _ := kv.ListKeys(`%ta`); !reflect.DeepEqual(keys, []string{`beta`}) {
+		t.Logf(`Expected wildcards to match, got %v`, keys)
+		t.Fail()
+	}
+	kv.Del(`alpha`)
+	if mime, _ := kv.GetMime(`alpha`); mime != `text/html` {
+		t.Logf(`Expected the mime to be deleted with the key, got %s`, mime)
+		t.Fail()
+	}
+
+	kv.SetBucket(`other`)
+	if val, _ := kv.Get(`beta`); len(val) != 0 {
+		t.Logf(`Expected buckets to be separate, got %s`, val)
+		t.Fail()
+	}
+}
+
+func TestTally(t *testing.T) {
+	kv := NewKV()
+	kv.Tally(`hits`, 10)
+	if n, _ := kv.TallyIncr(`hits`); n != 11 {
+		t.Logf(`Expected 11, got %d`, n)
+		t.Fail()
+	}
+	if n, _ := kv.TallyDecr(`hits`); n != 10 {
+		t.Logf(`Expected 10, got %d`, n)
+		t.Fail()
+	}
+	kv.TallyReset(`hits`)
+	if n, _ := kv.Tally(`hits`, 0); n != 0 {
+		t.Logf(`Expected 0, got %d`, n)
+		t.Fail()
+	}
+}
+
+func TestTransactions(t *testing.T) {
+	kv := NewKV()
+	kv.Set(`kept`, []byte(`before`))
+
+	kv.Begin()
+	kv.Set(`kept`, []byte(`during`))
+	kv.Set(`added`, []byte(`during`))
+	kv.Rollback()
+	if val, _ := kv.Get(`kept`); string(val) != `before` {
+		t.Logf(`Expected the rollback to restore the value, got %s`, val)
+		t.Fail()
+	}
+	if val, _ := kv.Get(`added`); len(val) != 0 {
+		t.Logf(`Expected the rollback to drop the added key, got %s`, val)
+		t.Fail()
+	}
+
+	kv.Begin()
+	kv.Set(`added`, []byte(`committed`))
+	kv.Commit()
+	kv.Rollback()
+	if val, _ := kv.Get(`added`); string(val) != `committed` {
+		t.Logf(`Expected the commit to keep the value, got %s`, val)
+		t.Fail()
+	}
+}
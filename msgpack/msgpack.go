@@ -0,0 +1,30 @@
+// Package msgpack provides a MessagePack serializer for SQLtPlainKV
+package msgpack
+
+import (
+	sqltplainkv "github.com/narsilworks/sqlt-plainkv"
+	vmsgpack "github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	// Mime is the mime recorded for MessagePack values
+	Mime string = `application/x-msgpack`
+)
+
+// Serializer stores values as MessagePack, which is considerably
+// more compact than JSON for small structs
+var Serializer sqltplainkv.Serializer = serializer{}
+
+type serializer struct{}
+
+func (serializer) Marshal(v any) ([]byte, error) {
+	return vmsgpack.Marshal(v)
+}
+
+func (serializer) Unmarshal(data []byte, v any) error {
+	return vmsgpack.Unmarshal(data, v)
+}
+
+func (serializer) Mime() string {
+	return Mime
+}
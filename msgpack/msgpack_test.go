@@ -0,0 +1,39 @@
+package msgpack
+
+import (
+	"path/filepath"
+	"testing"
+
+	sqltplainkv "github.com/narsilworks/sqlt-plainkv"
+)
+
+func TestSerializer(t *testing.T) {
+	pkv := sqltplainkv.NewSQLtPlainKV(filepath.Join(t.TempDir(), "test.dat"), false)
+	if err := pkv.Open(); err != nil {
+		t.Fatalf(`%s`, err)
+	}
+	defer pkv.Close()
+
+	type point struct {
+		X, Y int
+	}
+
+	if err := pkv.SetObject(`sample_point`, point{X: 1, Y: 2}, Serializer); err != nil {
+		t.Fatalf(`%s`, err)
+	}
+
+	var out point
+	if err := pkv.GetObject(`sample_point`, &out, Serializer); err != nil {
+		t.Fatalf(`%s`, err)
+	}
+	if out.X != 1 || out.Y != 2 {
+		t.Logf(`Unexpected value: %+v`, out)
+		t.Fail()
+	}
+
+	mime, _ := pkv.GetMime(`sample_point`)
+	if mime != Mime {
+		t.Logf(`Expected %s, got %s`, Mime, mime)
+		t.Fail()
+	}
+}
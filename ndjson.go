@@ -0,0 +1,114 @@
+package sqltplainkv
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// Record is a record of a bucket as exported and imported,
+// and a line of an NDJSON export. Values are base64 encoded
+// by encoding/json
+type Record struct {
+	Key       string     `json:"key"`
+	Value     []byte     `json:"value"`
+	Mime      string     `json:"mime,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// ExportNDJSON writes the records of a bucket to the writer as
+// newline-delimited JSON, one object per record ordered by key.
+// Values are decoded before they are written, so the export does
+// not depend on the codecs, compression or encryption of the store
+func (p *SQLtPlainKV) ExportNDJSON(bucket string, w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	err := p.exportRecords(bucket, func(rec Record) error {
+		return enc.Encode(rec)
+	})
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// exportRecords calls fn with every record of a bucket ordered by key
+func (p *SQLtPlainKV) exportRecords(bucket string, fn func(rec Record) error) error {
+	var err error
+	if bucket == "" {
+		bucket = "default"
+	}
+	if err = p.open(); err != nil {
+		return err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	recs, err := p.listRecords(bucket)
+	if err != nil {
+		return err
+	}
+	for _, rec := range recs {
+		if err = p.fillRecord(bucket, &rec); err != nil {
+			return err
+		}
+		if err = fn(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listRecords returns the records of a bucket ordered by key,
+// with their expiry and mime but without their value
+func (p *SQLtPlainKV) listRecords(bucket string) ([]Record, error) {
+	var err error
+	if err = p.open(); err != nil {
+		return nil, err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	rows, err := p.conn().Query(p.rebind(`
+	SELECT KeyID, ExpiresAt, UpdatedAt, Mime FROM `+p.defTableName+`
+	WHERE Bucket=?
+		AND (ExpiresAt IS NULL OR ExpiresAt > ?)
+	ORDER BY KeyID;`), bucket, time.Now().UnixNano())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var recs []Record
+	for rows.Next() {
+		var (
+			rec  Record
+			exp  sql.NullInt64
+			upd  sql.NullInt64
+			mime sql.NullString
+		)
+		if err = rows.Scan(&rec.Key, &exp, &upd, &mime); err != nil {
+			return nil, err
+		}
+		rec.Mime = mime.String
+		if exp.Valid {
+			t := time.Unix(0, exp.Int64).UTC()
+			rec.ExpiresAt = &t
+		}
+		if upd.Valid {
+			t := time.Unix(0, upd.Int64).UTC()
+			rec.UpdatedAt = &t
+		}
+		recs = append(recs, rec)
+	}
+	return recs, rows.Err()
+}
+
+// fillRecord reads the value of a listed record
+func (p *SQLtPlainKV) fillRecord(bucket string, rec *Record) error {
+	var err error
+	rec.Value, err = p.get(bucket, rec.Key)
+	return err
+}
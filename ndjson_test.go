@@ -0,0 +1,46 @@
+package sqltplainkv
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportNDJSON(t *testing.T) {
+	pkv := newTestKV(t)
+	pkv.SetCompression(Gzip, 0)
+	pkv.Set(`b`, []byte(`second`))
+	pkv.Set(`a`, []byte(`first`))
+	pkv.SetMime(`a`, `text/plain`)
+	pkv.SetWithTTL(`c`, []byte(`third`), time.Hour)
+	pkv.SetBucket(`other`)
+	pkv.Set(`x`, []byte(`not exported`))
+
+	var buf bytes.Buffer
+	if err := pkv.ExportNDJSON(`default`, &buf); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf(`Expected 3 lines, got %d: %s`, len(lines), buf.String())
+	}
+	var rec Record
+	if err := json.Unmarshal([]byte(lines[0]), &rec); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if rec.Key != `a` || string(rec.Value) != `first` || rec.Mime != `text/plain` || rec.ExpiresAt != nil {
+		t.Logf(`Unexpected first record %s`, lines[0])
+		t.Fail()
+	}
+	if !strings.Contains(lines[0], `"value":"Zmlyc3Q="`) {
+		t.Logf(`Expected the value to be base64 encoded, got %s`, lines[0])
+		t.Fail()
+	}
+	json.Unmarshal([]byte(lines[2]), &rec)
+	if rec.Key != `c` || rec.ExpiresAt == nil {
+		t.Logf(`Expected the expiry to be exported, got %s`, lines[2])
+		t.Fail()
+	}
+}
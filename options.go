@@ -0,0 +1,189 @@
+package sqltplainkv
+
+import (
+	"time"
+)
+
+// Option configures a SQLtPlainKV created by New
+type Option func(p *SQLtPlainKV) error
+
+// poolSettings are the connection pool settings applied on Open
+type poolSettings struct {
+	maxOpen     int
+	maxIdle     int
+	maxLifetime time.Duration
+}
+
+var defaultPool = poolSettings{
+	maxOpen:     10,
+	maxIdle:     10,
+	maxLifetime: time.Minute * 3,
+}
+
+// New creates a new SQLtPlainKV object configured by the options.
+// Without options it is the same as NewSQLtPlainKV(dsn, false)
+func New(dsn string, opts ...Option) (*SQLtPlainKV, error) {
+	p := NewSQLtPlainKV(dsn, false)
+	for _, opt := range opts {
+		if err := opt(p); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// WithBucket sets the bucket used when none is set with SetBucket
+func WithBucket(bucket string) Option {
+	return func(p *SQLtPlainKV) error {
+		p.SetBucket(bucket)
+		return nil
+	}
+}
+
+// WithTableName changes the default table name
+func WithTableName(tableName string) Option {
+	return func(p *SQLtPlainKV) error {
+		if !tableNameRe.MatchString(tableName) {
+			return ErrInvalidTableName
+		}
+		p.SetTableName(tableName)
+		return nil
+	}
+}
+
+// WithoutMigration opens the table as it is, without creating it,
+// adding missing columns or moving mimes, so that reading a data file
+// does not change it. EnsureSchema still migrates the table
+func WithoutMigration() Option {
+	return func(p *SQLtPlainKV) error {
+		p.noMigrate = true
+		return nil
+	}
+}
+
+// WithAutoClose closes the database once it has been idle
+// for the idle timeout
+func WithAutoClose(autoClose bool) Option {
+	return func(p *SQLtPlainKV) error {
+		p.autoClose = autoClose
+		return nil
+	}
+}
+
+// WithMaxOpenConns sets the maximum number of open connections
+func WithMaxOpenConns(n int) Option {
+	return func(p *SQLtPlainKV) error {
+		p.pool.maxOpen = n
+		return nil
+	}
+}
+
+// WithMaxIdleConns sets the maximum number of idle connections
+func WithMaxIdleConns(n int) Option {
+	return func(p *SQLtPlainKV) error {
+		p.pool.maxIdle = n
+		return nil
+	}
+}
+
+// WithConnMaxLifetime sets the maximum time a connection is reused
+func WithConnMaxLifetime(d time.Duration) Option {
+	return func(p *SQLtPlainKV) error {
+		p.pool.maxLifetime = d
+		return nil
+	}
+}
+
+// WithCodec sets the codec applied to values of all buckets
+func WithCodec(c Codec) Option {
+	return func(p *SQLtPlainKV) error {
+		p.SetCodec(c)
+		return nil
+	}
+}
+
+// WithBucketCodec sets the codec applied to values of the bucket
+func WithBucketCodec(bucket string, c Codec) Option {
+	return func(p *SQLtPlainKV) error {
+		p.SetBucketCodec(bucket, c)
+		return nil
+	}
+}
+
+// WithLimits sets the size limits
+func WithLimits(l Limits) Option {
+	return func(p *SQLtPlainKV) error {
+		p.SetLimits(l)
+		return nil
+	}
+}
+
+// WithMaxBucketLen sets the maximum length of a bucket name
+func WithMaxBucketLen(n int) Option {
+	return func(p *SQLtPlainKV) error {
+		l := p.limits
+		l.MaxBucketLen = n
+		p.SetLimits(l)
+		return nil
+	}
+}
+
+// WithMaxKeyLen sets the maximum length of a key
+func WithMaxKeyLen(n int) Option {
+	return func(p *SQLtPlainKV) error {
+		l := p.limits
+		l.MaxKeyLen = n
+		p.SetLimits(l)
+		return nil
+	}
+}
+
+// WithMaxValueSize sets the maximum size of a stored value
+func WithMaxValueSize(n int) Option {
+	return func(p *SQLtPlainKV) error {
+		l := p.limits
+		l.MaxValueSize = n
+		p.SetLimits(l)
+		return nil
+	}
+}
+
+// WithChunkSize sets the size above which values are split across rows
+func WithChunkSize(n int) Option {
+	return func(p *SQLtPlainKV) error {
+		l := p.limits
+		l.ChunkSize = n
+		p.SetLimits(l)
+		return nil
+	}
+}
+
+// WithCompression compresses values of at least threshold bytes
+func WithCompression(algo Compression, threshold int) Option {
+	return func(p *SQLtPlainKV) error {
+		p.SetCompression(algo, threshold)
+		return nil
+	}
+}
+
+// WithEncryptionKey encrypts values with AES-GCM using the key
+func WithEncryptionKey(key []byte) Option {
+	return func(p *SQLtPlainKV) error {
+		return p.SetEncryptionKey(key)
+	}
+}
+
+// WithDecryptionKey makes a rotated out key available for reading
+func WithDecryptionKey(key []byte) Option {
+	return func(p *SQLtPlainKV) error {
+		return p.AddDecryptionKey(key)
+	}
+}
+
+// WithDatabaseKey sets the key of an encrypted database file
+func WithDatabaseKey(key []byte) Option {
+	return func(p *SQLtPlainKV) error {
+		p.SetDatabaseKey(key)
+		return nil
+	}
+}
@@ -0,0 +1,47 @@
+package sqltplainkv
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewWithOptions(t *testing.T) {
+	pkv, err := New(filepath.Join(t.TempDir(), "test.dat"),
+		WithBucket(`pages`),
+		WithTableName(`PagesTBL`),
+		WithMaxKeyLen(1024),
+		WithMaxOpenConns(2),
+		WithCompression(Gzip, 16),
+		WithEncryptionKey(bytes.Repeat([]byte{0x01}, 16)),
+	)
+	if err != nil {
+		t.Fatalf(`%s`, err)
+	}
+	defer pkv.Close()
+
+	if err = pkv.Set(`sample_key`, []byte(`Sample value`)); err != nil {
+		t.Fatalf(`%s`, err)
+	}
+	if b, err := pkv.Get(`sample_key`); err != nil || string(b) != `Sample value` {
+		t.Logf(`Expected Sample value, got %s (%v)`, b, err)
+		t.Fail()
+	}
+
+	var n int
+	pkv.db.QueryRow(`SELECT COUNT(*) FROM PagesTBL WHERE Bucket='pages'`).Scan(&n)
+	if n != 1 {
+		t.Logf(`Expected the value in the pages bucket of PagesTBL`)
+		t.Fail()
+	}
+	if pkv.GetLimits().MaxKeyLen != 1024 || pkv.db.Stats().MaxOpenConnections != 2 {
+		t.Logf(`Expected the limits and pool settings to be applied`)
+		t.Fail()
+	}
+
+	if _, err = New(`unused.dat`, WithEncryptionKey([]byte(`short`))); !errors.Is(err, ErrInvalidEncryptionKey) {
+		t.Logf(`Expected ErrInvalidEncryptionKey, got %v`, err)
+		t.Fail()
+	}
+}
@@ -0,0 +1,29 @@
+package sqltplainkv
+
+// PlainKVer is the key-value API shared by the plainkv packages,
+// such as the MySQL-backed plainkv. Applications depending on it
+// rather than on SQLtPlainKV can swap backends or use a mock in tests
+type PlainKVer interface {
+	Open() error
+	Close() error
+
+	SetBucket(bucket string)
+	Get(key string) ([]byte, error)
+	Set(key string, value []byte) error
+	Del(key string) error
+	ListKeys(pattern string) ([]string, error)
+
+	GetMime(key string) (string, error)
+	SetMime(key string, mime string) error
+
+	Tally(key string, offset int) (int, error)
+	TallyIncr(key string) (int, error)
+	TallyDecr(key string) (int, error)
+	TallyReset(key string) error
+
+	Begin() error
+	Commit() error
+	Rollback() error
+}
+
+var _ PlainKVer = (*SQLtPlainKV)(nil)
@@ -0,0 +1,87 @@
+package sqltplainkv
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrPragmaUnsupported error = errors.New(`pragma not supported by the driver`)
+)
+
+// pragma is a PRAGMA statement applied to every connection
+type pragma struct {
+	name  string
+	value string
+}
+
+// SetPragma sets a PRAGMA applied to every connection opened by the
+// store, for example SetPragma("journal_mode", "WAL"). It takes effect
+// on the next Open
+func (p *SQLtPlainKV) SetPragma(name, value string) {
+	name = strings.ToLower(name)
+	for i := range p.pragmas {
+		if p.pragmas[i].name == name {
+			p.pragmas[i].value = value
+			return
+		}
+	}
+	p.pragmas = append(p.pragmas, pragma{name: name, value: value})
+}
+
+// withDSNParams appends query parameters to a DSN
+func withDSNParams(dsn string, params []string) string {
+	if len(params) == 0 {
+		return dsn
+	}
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	return dsn + sep + strings.Join(params, "&")
+}
+
+// WithPragma sets a PRAGMA applied to every connection
+func WithPragma(name, value string) Option {
+	return func(p *SQLtPlainKV) error {
+		p.SetPragma(name, value)
+		return nil
+	}
+}
+
+// WithJournalMode sets the journal mode, such as WAL or DELETE
+func WithJournalMode(mode string) Option {
+	return WithPragma(`journal_mode`, mode)
+}
+
+// WithSynchronous sets the synchronous level, such as NORMAL or FULL
+func WithSynchronous(level string) Option {
+	return WithPragma(`synchronous`, level)
+}
+
+// WithBusyTimeout sets how long a connection waits on a locked database
+func WithBusyTimeout(d time.Duration) Option {
+	return WithPragma(`busy_timeout`, strconv.FormatInt(d.Milliseconds(), 10))
+}
+
+// WithCacheSize sets the page cache size. Positive values are pages
+// and negative values are kibibytes, as in SQLite
+func WithCacheSize(n int) Option {
+	return WithPragma(`cache_size`, strconv.Itoa(n))
+}
+
+// WithForeignKeys turns foreign key enforcement on or off
+func WithForeignKeys(on bool) Option {
+	v := `0`
+	if on {
+		v = `1`
+	}
+	return WithPragma(`foreign_keys`, v)
+}
+
+// WithMmapSize sets the maximum number of bytes used for memory-mapped I/O
+func WithMmapSize(n int64) Option {
+	return WithPragma(`mmap_size`, strconv.FormatInt(n, 10))
+}
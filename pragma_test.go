@@ -0,0 +1,45 @@
+package sqltplainkv
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPragmas(t *testing.T) {
+	pkv, err := New(filepath.Join(t.TempDir(), "test.dat"),
+		WithJournalMode(`WAL`),
+		WithSynchronous(`NORMAL`),
+		WithBusyTimeout(5*time.Second),
+		WithCacheSize(-4096),
+		WithForeignKeys(true),
+		WithMmapSize(1<<20),
+	)
+	if err != nil {
+		t.Fatalf(`%s`, err)
+	}
+	defer pkv.Close()
+	if err = pkv.Open(); err != nil {
+		t.Fatalf(`%s`, err)
+	}
+
+	checks := map[string]string{
+		`journal_mode`: `wal`,
+		`synchronous`:  `1`,
+		`busy_timeout`: `5000`,
+		`cache_size`:   `-4096`,
+		`foreign_keys`: `1`,
+		`mmap_size`:    `1048576`,
+	}
+	for name, want := range checks {
+		var got string
+		if err = pkv.db.QueryRow(`PRAGMA ` + name).Scan(&got); err != nil {
+			t.Fatalf(`%s`, err)
+		}
+		if !strings.EqualFold(got, want) {
+			t.Logf(`Expected %s to be %s, got %s`, name, want, got)
+			t.Fail()
+		}
+	}
+}
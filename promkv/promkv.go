@@ -0,0 +1,76 @@
+// Package promkv exposes the metrics of SQLtPlainKV to Prometheus
+package promkv
+
+import (
+	sqltplainkv "github.com/narsilworks/sqlt-plainkv"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	opsDesc = prometheus.NewDesc(
+		`sqltkv_operations_total`,
+		`Number of key-value operations run.`,
+		[]string{`op`}, nil)
+	errorsDesc = prometheus.NewDesc(
+		`sqltkv_operation_errors_total`,
+		`Number of key-value operations that failed.`,
+		[]string{`op`}, nil)
+	secondsDesc = prometheus.NewDesc(
+		`sqltkv_operation_seconds_total`,
+		`Total time spent in key-value operations.`,
+		[]string{`op`}, nil)
+	connsDesc = prometheus.NewDesc(
+		`sqltkv_open_connections`,
+		`Number of open database connections.`,
+		nil, nil)
+	sizeDesc = prometheus.NewDesc(
+		`sqltkv_database_size_bytes`,
+		`Size of the database file.`,
+		nil, nil)
+)
+
+// Collector is a prometheus.Collector reading the metrics of a store
+// on every scrape
+type Collector struct {
+	kv *sqltplainkv.SQLtPlainKV
+}
+
+// NewCollector returns a collector for the store. Metrics must be
+// enabled on the store for the operation counters to move
+func NewCollector(kv *sqltplainkv.SQLtPlainKV) *Collector {
+	return &Collector{kv: kv}
+}
+
+// Describe sends the descriptors of the metrics of the collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- opsDesc
+	ch <- errorsDesc
+	ch <- secondsDesc
+	ch <- connsDesc
+	ch <- sizeDesc
+}
+
+// Collect sends the current metrics of the store
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	m, err := c.kv.Metrics()
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(sizeDesc, err)
+	}
+	for _, op := range []struct {
+		name  string
+		stats sqltplainkv.OpStats
+	}{
+		{`get`, m.Get},
+		{`set`, m.Set},
+		{`del`, m.Del},
+		{`list`, m.List},
+	} {
+		ch <- prometheus.MustNewConstMetric(opsDesc, prometheus.CounterValue, float64(op.stats.Count), op.name)
+		ch <- prometheus.MustNewConstMetric(errorsDesc, prometheus.CounterValue, float64(op.stats.Errors), op.name)
+		ch <- prometheus.MustNewConstMetric(secondsDesc, prometheus.CounterValue, op.stats.Duration.Seconds(), op.name)
+	}
+	ch <- prometheus.MustNewConstMetric(connsDesc, prometheus.GaugeValue, float64(m.OpenConnections))
+	if err == nil {
+		ch <- prometheus.MustNewConstMetric(sizeDesc, prometheus.GaugeValue, float64(m.DatabaseSize))
+	}
+}
@@ -0,0 +1,47 @@
+package promkv
+
+import (
+	"testing"
+
+	sqltplainkv "github.com/narsilworks/sqlt-plainkv"
+	"github.com/narsilworks/sqlt-plainkv/sqltplainkvtest"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestCollector(t *testing.T) {
+	kv := sqltplainkvtest.NewTempKV(t, sqltplainkv.WithMetrics())
+	kv.Set(`a`, []byte(`1`))
+	kv.Get(`a`)
+	kv.Get(`a`)
+
+	reg := prometheus.NewPedanticRegistry()
+	if err := reg.Register(NewCollector(kv)); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	got := map[string]float64{}
+	for _, f := range families {
+		for _, m := range f.GetMetric() {
+			name := f.GetName()
+			for _, l := range m.GetLabel() {
+				name += `{` + l.GetValue() + `}`
+			}
+			if c := m.GetCounter(); c != nil {
+				got[name] = c.GetValue()
+			} else {
+				got[name] = m.GetGauge().GetValue()
+			}
+		}
+	}
+	if got[`sqltkv_operations_total{get}`] != 2 || got[`sqltkv_operations_total{set}`] != 1 {
+		t.Logf(`Unexpected operation counts %v`, got)
+		t.Fail()
+	}
+	if got[`sqltkv_database_size_bytes`] == 0 || got[`sqltkv_open_connections`] == 0 {
+		t.Logf(`Expected the database gauges to be set, got %v`, got)
+		t.Fail()
+	}
+}
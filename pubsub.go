@@ -0,0 +1,203 @@
+package sqltplainkv
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Messages are stored in an internal bucket under the topic and
+// their sequence. The last sequence of a topic and the offsets of its
+// consumers are tallies of the same bucket
+const (
+	topicBuckt  string = `--topic--`
+	topicMsgKey string = `%s#%016d` // topic, sequence
+	topicOffset string = `%s@%s`    // topic, consumer
+	topicSeqLen int    = 17         // #, sequence
+
+	// topicPoll is how often Receive looks for new messages
+	topicPoll = 250 * time.Millisecond
+)
+
+// Message is a message published to a topic
+type Message struct {
+	Topic       string
+	Seq         int64
+	Payload     []byte
+	PublishedAt time.Time
+}
+
+// Subscription reads the messages of a topic for a named consumer.
+// The offset of the consumer is stored, so a subscription of the same
+// consumer resumes after the last message it acknowledged, from any
+// process sharing the database
+type Subscription struct {
+	p        *SQLtPlainKV
+	topic    string
+	consumer string
+}
+
+// Publish appends a message to a topic and returns its sequence.
+// Sequences of a topic start at 1 and have no gaps. Inside a
+// transaction the message is published when the transaction commits
+func (p *SQLtPlainKV) Publish(topic string, payload []byte) (int64, error) {
+	var (
+		err error
+		seq int64
+		mk  string
+	)
+	if err = p.open(); err != nil {
+		return 0, err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	tk := fmt.Sprintf(tallyKey, topic)
+	err = p.retry(func() error {
+		return p.withTx(func(tx *sql.Tx) error {
+			var err error
+			if seq, err = p.tallyOn(tx, topicBuckt, topic); err != nil {
+				return err
+			}
+			seq++
+			mk = fmt.Sprintf(topicMsgKey, topic, seq)
+			if _, err = p.writeValue(tx, topicBuckt, mk, payload, sql.NullInt64{}, skipped{}); err != nil {
+				return err
+			}
+			_, err = p.writeValue(tx, topicBuckt, tk, []byte(strconv.FormatInt(seq, 10)), sql.NullInt64{}, skipped{})
+			return err
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+	p.warmDel(topicBuckt, mk)
+	p.warmDel(topicBuckt, tk)
+	return seq, nil
+}
+
+// Subscribe returns the subscription of a consumer to a topic.
+// A new consumer starts before the first message of the topic
+func (p *SQLtPlainKV) Subscribe(topic, consumer string) *Subscription {
+	return &Subscription{
+		p:        p,
+		topic:    topic,
+		consumer: consumer,
+	}
+}
+
+// Offset returns the sequence of the last message acknowledged
+func (s *Subscription) Offset() (int64, error) {
+	return s.p.tally(topicBuckt, fmt.Sprintf(topicOffset, s.topic, s.consumer))
+}
+
+// Ack stores seq as the offset of the consumer, acknowledging the
+// messages up to and including it. If a transaction is active the
+// acknowledgement is part of it
+func (s *Subscription) Ack(seq int64) error {
+	return s.p.setTally(topicBuckt, fmt.Sprintf(topicOffset, s.topic, s.consumer), seq)
+}
+
+// Fetch returns up to max messages after the offset of the consumer,
+// in order. Fetching does not move the offset, call Ack once the
+// messages are processed
+func (s *Subscription) Fetch(max int) ([]Message, error) {
+	var err error
+	p := s.p
+	offset, err := s.Offset()
+	if err != nil {
+		return nil, err
+	}
+	if err = p.open(); err != nil {
+		return nil, err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	rows, err := p.conn().Query(p.rebind(`
+	SELECT KeyID, Value, CreatedAt FROM `+p.defTableName+`
+	WHERE Bucket = ?
+		AND KeyID > ?
+		AND KeyID <= ?
+		AND length(KeyID) = ?
+	ORDER BY KeyID
+	LIMIT ?;`),
+		topicBuckt,
+		fmt.Sprintf(topicMsgKey, s.topic, offset),
+		fmt.Sprintf(topicMsgKey, s.topic, int64(9999999999999999)),
+		len(s.topic)+topicSeqLen,
+		max)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	msgs := make([]Message, 0)
+	for rows.Next() {
+		var (
+			key string
+			m   Message
+			crt sql.NullInt64
+		)
+		if err = rows.Scan(&key, &m.Payload, &crt); err != nil {
+			return nil, err
+		}
+		m.Topic = s.topic
+		if m.Seq, err = strconv.ParseInt(key[len(s.topic)+1:], 10, 64); err != nil {
+			return nil, ErrCorruptValue
+		}
+		m.PublishedAt = nanoTime(crt)
+		msgs = append(msgs, m)
+	}
+	return msgs, rows.Err()
+}
+
+// Receive waits for the next message after the offset of the
+// consumer and returns it, until the context is done. The message is
+// returned again until it is acknowledged
+func (s *Subscription) Receive(ctx context.Context) (Message, error) {
+	for {
+		msgs, err := s.Fetch(1)
+		if err != nil {
+			return Message{}, err
+		}
+		if len(msgs) > 0 {
+			return msgs[0], nil
+		}
+		select {
+		case <-ctx.Done():
+			return Message{}, ctx.Err()
+		case <-time.After(topicPoll):
+		}
+	}
+}
+
+// tally returns the tally of a key in a bucket, zero if it is not set
+func (p *SQLtPlainKV) tally(bucket, key string) (int64, error) {
+	val, err := p.get(bucket, fmt.Sprintf(tallyKey, key))
+	if err != nil || len(val) == 0 {
+		return 0, err
+	}
+	return strconv.ParseInt(string(val), 10, 64)
+}
+
+// tallyOn returns the tally of a key in an internal bucket read in the
+// transaction of a write, zero if it is not set. Values of internal
+// buckets are stored as they are
+func (p *SQLtPlainKV) tallyOn(tx *sql.Tx, bucket, key string) (int64, error) {
+	val, err := p.getRow(tx, bucket, fmt.Sprintf(tallyKey, key))
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && len(val) == 0) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(string(val), 10, 64)
+}
+
+// setTally sets the tally of a key in a bucket
+func (p *SQLtPlainKV) setTally(bucket, key string, n int64) error {
+	return p.set(bucket, fmt.Sprintf(tallyKey, key), []byte(strconv.FormatInt(n, 10)))
+}
@@ -0,0 +1,99 @@
+package sqltplainkv
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPubSub(t *testing.T) {
+	path := filepath.Join(t.TempDir(), `test.dat`)
+	pub := NewSQLtPlainKV(path, false)
+	defer pub.Close()
+	for i := 1; i <= 3; i++ {
+		seq, err := pub.Publish(`orders`, []byte(fmt.Sprintf(`order %d`, i)))
+		if err != nil || seq != int64(i) {
+			t.Fatalf(`Expected sequence %d, got %d, %v`, i, seq, err)
+		}
+	}
+	pub.Publish(`orders#1`, []byte(`another topic`))
+	pub.Begin()
+	pub.Publish(`orders`, []byte(`rolled back`))
+	pub.Rollback()
+
+	sub := NewSQLtPlainKV(path, false)
+	defer sub.Close()
+	s := sub.Subscribe(`orders`, `billing`)
+	msgs, err := s.Fetch(10)
+	if err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if len(msgs) != 3 || msgs[0].Seq != 1 || string(msgs[2].Payload) != `order 3` || msgs[0].PublishedAt.IsZero() {
+		t.Fatalf(`Unexpected messages %+v`, msgs)
+	}
+	if err = s.Ack(2); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+
+	// the offset survives a new subscription
+	s = sub.Subscribe(`orders`, `billing`)
+	m, err := s.Receive(context.Background())
+	if err != nil || m.Seq != 3 {
+		t.Logf(`Expected message 3, got %+v, %v`, m, err)
+		t.Fail()
+	}
+	if msgs, _ = sub.Subscribe(`orders`, `shipping`).Fetch(1); len(msgs) != 1 || msgs[0].Seq != 1 {
+		t.Logf(`Expected another consumer to start at 1, got %+v`, msgs)
+		t.Fail()
+	}
+
+	s.Ack(3)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		pub.Publish(`orders`, []byte(`order 4`))
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if m, err = s.Receive(ctx); err != nil || string(m.Payload) != `order 4` || m.Seq != 4 {
+		t.Logf(`Expected to receive order 4, got %+v, %v`, m, err)
+		t.Fail()
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	s.Ack(4)
+	if _, err = s.Receive(ctx); err != context.DeadlineExceeded {
+		t.Logf(`Expected the deadline, got %v`, err)
+		t.Fail()
+	}
+}
+
+func TestPublishConcurrent(t *testing.T) {
+	pkv := newTestKV(t)
+	var wg sync.WaitGroup
+	errs := make(chan error, 80)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				if _, err := pkv.Publish(`events`, []byte(`event`)); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Logf(`Expected concurrent publishes to succeed, got %v`, err)
+		t.Fail()
+	}
+	msgs, err := pkv.Subscribe(`events`, `audit`).Fetch(100)
+	if err != nil || len(msgs) != 80 || msgs[79].Seq != 80 {
+		t.Logf(`Expected 80 messages without gaps, got %d (%v)`, len(msgs), err)
+		t.Fail()
+	}
+}
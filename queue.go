@@ -0,0 +1,258 @@
+package sqltplainkv
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrQueueEmpty  error = errors.New(`queue empty`)
+	ErrJobNotFound error = errors.New(`job not found`)
+)
+
+// Job is a message claimed from a queue
+type Job struct {
+	ID       int64
+	Queue    string
+	Payload  []byte
+	Priority int
+	Attempts int // claims of the job, including this one
+}
+
+// PushOptions schedules a message pushed with QueuePushWith
+type PushOptions struct {
+	// Priority orders the visible messages of a queue, higher first.
+	// Messages of the same priority are taken in the order pushed
+	Priority int
+	// NotBefore keeps the message hidden until the time
+	NotBefore time.Time
+	// Delay keeps the message hidden for the duration, if NotBefore
+	// is not set
+	Delay time.Duration
+}
+
+// queueTable returns the name of the table holding the queues
+func (p *SQLtPlainKV) queueTable() string {
+	return p.defTableName + `_queue`
+}
+
+// ensureQueue creates the queue table the first time a queue is used.
+// Inside a transaction the table is created as part of it, and checked
+// for again until a table is created outside of one
+func (p *SQLtPlainKV) ensureQueue() error {
+	if p.queueReady == p.defTableName {
+		return nil
+	}
+	_, err := p.conn().Exec(`CREATE TABLE IF NOT EXISTS ` + p.queueTable() + ` (
+			Seq INTEGER PRIMARY KEY AUTOINCREMENT,
+			Queue VARCHAR(` + strconv.Itoa(p.limits.MaxKeyLen) + `),
+			Payload ` + p.dialect.BlobType(p.limits.MaxValueSize) + `,
+			VisibleAt BIGINT,
+			Attempts INTEGER,
+			CreatedAt BIGINT,
+			Priority INTEGER DEFAULT 0
+		);`)
+	if err != nil {
+		return err
+	}
+	// queue tables created before priorities lack the column
+	var n int
+	if err = p.conn().QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = 'Priority';`, p.queueTable()).Scan(&n); err != nil {
+		return err
+	}
+	if n == 0 {
+		if _, err = p.conn().Exec(`ALTER TABLE ` + p.queueTable() + ` ADD COLUMN Priority INTEGER DEFAULT 0;`); err != nil {
+			return err
+		}
+	}
+	if _, err = p.conn().Exec(`CREATE INDEX IF NOT EXISTS ` + p.queueTable() + `_visible ON ` + p.queueTable() + ` (Queue, VisibleAt, Seq);`); err != nil {
+		return err
+	}
+	if !p.inTransaction {
+		p.queueReady = p.defTableName
+	}
+	return nil
+}
+
+// QueuePush appends a message to the end of a queue and returns its id
+func (p *SQLtPlainKV) QueuePush(queue string, payload []byte) (int64, error) {
+	return p.QueuePushWith(queue, payload, PushOptions{})
+}
+
+// QueuePushWith adds a message to a queue with a priority, optionally
+// hidden until a later time for delayed jobs, and returns its id
+func (p *SQLtPlainKV) QueuePushWith(queue string, payload []byte, opts PushOptions) (int64, error) {
+	var (
+		err error
+		id  int64
+	)
+	if len(queue) > p.limits.MaxKeyLen {
+		return 0, ErrKeyTooLong
+	}
+	if len(payload) > p.limits.MaxValueSize {
+		return 0, ErrValueTooLong
+	}
+	if err = p.open(); err != nil {
+		return 0, err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	if err = p.ensureQueue(); err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	visible := opts.NotBefore
+	if visible.IsZero() {
+		visible = now.Add(opts.Delay)
+	}
+	err = p.retry(func() error {
+		res, err := p.conn().Exec(p.rebind(`
+		INSERT INTO `+p.queueTable()+` (Queue, Payload, VisibleAt, Attempts, CreatedAt, Priority)
+		VALUES (?, ?, ?, 0, ?, ?);`), queue, payload, visible.UnixNano(), now.UnixNano(), opts.Priority)
+		if err != nil {
+			return err
+		}
+		id, err = res.LastInsertId()
+		return err
+	})
+	return id, err
+}
+
+// QueuePop removes the visible message of a queue with the highest
+// priority, the oldest among equals, and returns its payload. Messages
+// scheduled for later are skipped. The message is claimed and removed by a single
+// statement, so concurrent consumers, in this or other processes,
+// never receive the same message. A message lost by a consumer after
+// QueuePop is gone, use QueueClaim for at-least-once processing.
+// It returns ErrQueueEmpty if no message is visible
+func (p *SQLtPlainKV) QueuePop(queue string) ([]byte, error) {
+	var (
+		err     error
+		payload []byte
+	)
+	if err = p.open(); err != nil {
+		return nil, err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	if err = p.ensureQueue(); err != nil {
+		return nil, err
+	}
+	err = p.retry(func() error {
+		return p.conn().QueryRow(p.rebind(`
+		DELETE FROM `+p.queueTable()+`
+		WHERE Seq = (
+			SELECT Seq FROM `+p.queueTable()+`
+			WHERE Queue = ? AND VisibleAt <= ?
+			ORDER BY Priority DESC, Seq
+			LIMIT 1)
+		RETURNING Payload;`), queue, time.Now().UnixNano()).Scan(&payload)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrQueueEmpty
+	}
+	return payload, err
+}
+
+// QueueClaim claims the next visible message of a queue, in the
+// order of QueuePop, and hides it from other consumers for the visibility timeout. Acknowledge the
+// job with QueueAck once it is processed. Jobs that are not
+// acknowledged in time become visible again and are claimed anew, so
+// every message is processed at least once.
+// It returns ErrQueueEmpty if no message is visible
+func (p *SQLtPlainKV) QueueClaim(queue string, visibility time.Duration) (Job, error) {
+	var (
+		err error
+		job = Job{Queue: queue}
+	)
+	if err = p.open(); err != nil {
+		return job, err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	if err = p.ensureQueue(); err != nil {
+		return job, err
+	}
+	err = p.retry(func() error {
+		now := time.Now()
+		return p.conn().QueryRow(p.rebind(`
+		UPDATE `+p.queueTable()+`
+		SET VisibleAt = ?, Attempts = Attempts + 1
+		WHERE Seq = (
+			SELECT Seq FROM `+p.queueTable()+`
+			WHERE Queue = ? AND VisibleAt <= ?
+			ORDER BY Priority DESC, Seq
+			LIMIT 1)
+		RETURNING Seq, Payload, Priority, Attempts;`),
+			now.Add(visibility).UnixNano(), queue, now.UnixNano()).Scan(&job.ID, &job.Payload, &job.Priority, &job.Attempts)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return job, ErrQueueEmpty
+	}
+	return job, err
+}
+
+// QueueAck removes a claimed job from its queue.
+// It returns ErrJobNotFound if the job was already removed
+func (p *SQLtPlainKV) QueueAck(queue string, id int64) error {
+	return p.queueExec(`DELETE FROM `+p.queueTable()+` WHERE Queue = ? AND Seq = ?;`, queue, id)
+}
+
+// QueueRelease makes a claimed job visible again right away, for
+// consumers giving up on it before its visibility timeout.
+// It returns ErrJobNotFound if the job was already removed
+func (p *SQLtPlainKV) QueueRelease(queue string, id int64) error {
+	return p.queueExec(`UPDATE `+p.queueTable()+` SET VisibleAt = ? WHERE Queue = ? AND Seq = ?;`, time.Now().UnixNano(), queue, id)
+}
+
+// queueExec runs a statement on a single job of the queue table
+func (p *SQLtPlainKV) queueExec(query string, args ...any) error {
+	var err error
+	if err = p.open(); err != nil {
+		return err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	if err = p.ensureQueue(); err != nil {
+		return err
+	}
+	var n int64
+	err = p.retry(func() error {
+		res, err := p.conn().Exec(p.rebind(query), args...)
+		if err != nil {
+			return err
+		}
+		n, err = res.RowsAffected()
+		return err
+	})
+	if err == nil && n == 0 {
+		return ErrJobNotFound
+	}
+	return err
+}
+
+// QueueLen returns the number of messages in a queue,
+// including claimed and delayed ones
+func (p *SQLtPlainKV) QueueLen(queue string) (int, error) {
+	var (
+		err error
+		n   int
+	)
+	if err = p.open(); err != nil {
+		return 0, err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	if err = p.ensureQueue(); err != nil {
+		return 0, err
+	}
+	err = p.conn().QueryRow(p.rebind(`SELECT COUNT(*) FROM `+p.queueTable()+` WHERE Queue = ?;`), queue).Scan(&n)
+	return n, err
+}
@@ -0,0 +1,172 @@
+package sqltplainkv
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestQueue(t *testing.T) {
+	pkv := newTestKV(t)
+	if _, err := pkv.QueuePop(`jobs`); !errors.Is(err, ErrQueueEmpty) {
+		t.Logf(`Expected ErrQueueEmpty, got %v`, err)
+		t.Fail()
+	}
+	for i := 1; i <= 3; i++ {
+		pkv.QueuePush(`jobs`, []byte(fmt.Sprintf(`job %d`, i)))
+	}
+	pkv.QueuePush(`other`, []byte(`x`))
+
+	if val, err := pkv.QueuePop(`jobs`); err != nil || string(val) != `job 1` {
+		t.Logf(`Expected job 1, got %s, %v`, val, err)
+		t.Fail()
+	}
+	job, err := pkv.QueueClaim(`jobs`, 50*time.Millisecond)
+	if err != nil || string(job.Payload) != `job 2` || job.Attempts != 1 {
+		t.Fatalf(`Expected to claim job 2, got %+v, %v`, job, err)
+	}
+	next, _ := pkv.QueueClaim(`jobs`, time.Hour)
+	if string(next.Payload) != `job 3` {
+		t.Logf(`Expected the claimed job to be hidden, got %+v`, next)
+		t.Fail()
+	}
+	if _, err = pkv.QueueClaim(`jobs`, time.Hour); !errors.Is(err, ErrQueueEmpty) {
+		t.Logf(`Expected every job to be claimed, got %v`, err)
+		t.Fail()
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	again, err := pkv.QueueClaim(`jobs`, time.Hour)
+	if err != nil || again.ID != job.ID || again.Attempts != 2 {
+		t.Logf(`Expected job 2 to be claimed again, got %+v, %v`, again, err)
+		t.Fail()
+	}
+	if err = pkv.QueueAck(`jobs`, again.ID); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if err = pkv.QueueAck(`jobs`, again.ID); !errors.Is(err, ErrJobNotFound) {
+		t.Logf(`Expected ErrJobNotFound, got %v`, err)
+		t.Fail()
+	}
+	if err = pkv.QueueRelease(`jobs`, next.ID); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if val, _ := pkv.QueuePop(`jobs`); string(val) != `job 3` {
+		t.Logf(`Expected the released job, got %s`, val)
+		t.Fail()
+	}
+	if n, _ := pkv.QueueLen(`other`); n != 1 {
+		t.Logf(`Expected one job left in other, got %d`, n)
+		t.Fail()
+	}
+}
+
+func TestQueueConcurrentPop(t *testing.T) {
+	path := filepath.Join(t.TempDir(), `test.dat`)
+	const jobs = 100
+	producer := NewSQLtPlainKV(path, false)
+	defer producer.Close()
+	producer.Begin()
+	for i := 0; i < jobs; i++ {
+		producer.QueuePush(`jobs`, []byte(fmt.Sprint(i)))
+	}
+	producer.Commit()
+
+	var (
+		mu   sync.Mutex
+		seen = map[string]int{}
+		wg   sync.WaitGroup
+	)
+	for c := 0; c < 4; c++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			consumer := NewSQLtPlainKV(path, false)
+			defer consumer.Close()
+			for {
+				val, err := consumer.QueuePop(`jobs`)
+				if errors.Is(err, ErrQueueEmpty) {
+					return
+				}
+				if err != nil {
+					t.Logf(`%v`, err)
+					t.Fail()
+					return
+				}
+				mu.Lock()
+				seen[string(val)]++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(seen) != jobs {
+		t.Logf(`Expected %d jobs popped, got %d`, jobs, len(seen))
+		t.Fail()
+	}
+	for val, n := range seen {
+		if n != 1 {
+			t.Logf(`Job %s popped %d times`, val, n)
+			t.Fail()
+		}
+	}
+}
+
+func TestQueuePriorityAndDelay(t *testing.T) {
+	pkv := newTestKV(t)
+	pkv.QueuePush(`jobs`, []byte(`normal 1`))
+	pkv.QueuePushWith(`jobs`, []byte(`later`), PushOptions{Priority: 10, Delay: 50 * time.Millisecond})
+	pkv.QueuePushWith(`jobs`, []byte(`urgent`), PushOptions{Priority: 5})
+	pkv.QueuePush(`jobs`, []byte(`normal 2`))
+	pkv.QueuePushWith(`jobs`, []byte(`tomorrow`), PushOptions{NotBefore: time.Now().Add(24 * time.Hour)})
+
+	var got []string
+	for {
+		val, err := pkv.QueuePop(`jobs`)
+		if err != nil {
+			break
+		}
+		got = append(got, string(val))
+	}
+	if fmt.Sprint(got) != `[urgent normal 1 normal 2]` {
+		t.Logf(`Unexpected order %q`, got)
+		t.Fail()
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	job, err := pkv.QueueClaim(`jobs`, time.Minute)
+	if err != nil || string(job.Payload) != `later` || job.Priority != 10 {
+		t.Logf(`Expected the delayed job once due, got %+v, %v`, job, err)
+		t.Fail()
+	}
+	if n, _ := pkv.QueueLen(`jobs`); n != 2 {
+		t.Logf(`Expected the claimed and scheduled jobs to be counted, got %d`, n)
+		t.Fail()
+	}
+}
+
+func TestQueueAddsPriority(t *testing.T) {
+	pkv := newTestKV(t)
+	if _, err := pkv.db.Exec(`CREATE TABLE KeyValueTBL_queue (
+		Seq INTEGER PRIMARY KEY AUTOINCREMENT,
+		Queue VARCHAR(255),
+		Payload BLOB,
+		VisibleAt BIGINT,
+		Attempts INTEGER,
+		CreatedAt BIGINT);`); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	pkv.db.Exec(`INSERT INTO KeyValueTBL_queue (Queue, Payload, VisibleAt, Attempts, CreatedAt) VALUES ('jobs', 'old', 0, 0, 0);`)
+	pkv.QueuePushWith(`jobs`, []byte(`new`), PushOptions{Priority: 1})
+	if val, err := pkv.QueuePop(`jobs`); err != nil || string(val) != `new` {
+		t.Logf(`Expected the new job first, got %s, %v`, val, err)
+		t.Fail()
+	}
+	if val, _ := pkv.QueuePop(`jobs`); string(val) != `old` {
+		t.Logf(`Expected the old job with the default priority, got %s`, val)
+		t.Fail()
+	}
+}
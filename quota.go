@@ -0,0 +1,164 @@
+package sqltplainkv
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
+)
+
+// QuotaPolicy decides what happens to a write
+// that would take a bucket over its quota
+type QuotaPolicy int
+
+const (
+	QuotaReject QuotaPolicy = iota // the write fails with ErrQuotaExceeded
+	QuotaEvict                     // least recently used keys are evicted to make room
+)
+
+var (
+	ErrQuotaExceeded error = errors.New(`bucket quota exceeded`)
+)
+
+// SetBucketQuota limits the bytes stored in a bucket, counting values
+// as stored, after compression, along with their chunks. Values
+// spilled to files or deduplicated count only their pointer. Writes that would exceed
+// the quota fail with ErrQuotaExceeded unless the QuotaPolicy of the
+// bucket options is QuotaEvict. A maxBytes of zero removes the quota
+func (p *SQLtPlainKV) SetBucketQuota(bucket string, maxBytes int64) error {
+	if bucket == "" {
+		bucket = "default"
+	}
+	if maxBytes < 0 {
+		maxBytes = 0
+	}
+	opts, _, err := p.GetBucketOptions(bucket)
+	if err != nil {
+		return err
+	}
+	opts.MaxBytes = maxBytes
+	return p.SetBucketOptions(bucket, opts)
+}
+
+// checkQuota makes sure writing size bytes under a key keeps the
+// bucket within its quota, evicting other keys in the transaction of
+// the write if the policy allows. It returns the keys evicted
+func (p *SQLtPlainKV) checkQuota(tx *sql.Tx, bucket, key string, size int64) ([]string, error) {
+	opts, ok := p.bucketOpts[bucket]
+	if !ok || opts.MaxBytes <= 0 {
+		return nil, nil
+	}
+	if size > opts.MaxBytes {
+		return nil, ErrQuotaExceeded
+	}
+	used, err := p.bucketUsage(tx, bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	over := used + size - opts.MaxBytes
+	if over <= 0 {
+		return nil, nil
+	}
+	if opts.QuotaPolicy != QuotaEvict {
+		return nil, ErrQuotaExceeded
+	}
+	return p.evictBytes(tx, bucket, key, over)
+}
+
+// bucketUsage returns the bytes stored by the live keys of a bucket
+// and their chunks, leaving out the key being written
+func (p *SQLtPlainKV) bucketUsage(tx *sql.Tx, bucket, except string) (int64, error) {
+	var used int64
+	prefix := fmt.Sprintf(`%d:%s:`, len(bucket), bucket)
+	first, last, n := chunkRange(bucket, except, 0)
+	err := p.on(tx).QueryRow(p.rebind(`
+	SELECT COALESCE(SUM(length(Value)), 0) FROM `+p.defTableName+`
+	WHERE ((Bucket=? AND KeyID<>?)
+		OR (Bucket=? AND substr(KeyID, 1, ?)=? AND NOT (KeyID BETWEEN ? AND ? AND length(KeyID) = ?)))
+		AND (ExpiresAt IS NULL OR ExpiresAt > ?);`),
+		bucket, except,
+		chunkBuckt, utf8.RuneCountInString(prefix), prefix, first, last, n,
+		time.Now().UnixNano()).Scan(&used)
+	return used, err
+}
+
+// storedSize returns the bytes stored for a key and its chunks
+func (p *SQLtPlainKV) storedSize(tx *sql.Tx, bucket, key string) (int64, error) {
+	var size int64
+	first, last, n := chunkRange(bucket, key, 0)
+	err := p.on(tx).QueryRow(p.rebind(`
+	SELECT COALESCE(SUM(length(Value)), 0) FROM `+p.defTableName+`
+	WHERE (Bucket=? AND KeyID=?)
+		OR (Bucket=? AND KeyID BETWEEN ? AND ? AND length(KeyID) = ?);`),
+		bucket, key, chunkBuckt, first, last, n).Scan(&size)
+	return size, err
+}
+
+// evictBytes evicts the least recently used keys of a bucket other
+// than the key being written until at least need bytes are freed, and
+// returns them. Keys are evicted along with their versions and locale
+// variants, which are never picked on their own. The hooks of the keys
+// are left to the caller to run once the write is made
+func (p *SQLtPlainKV) evictBytes(tx *sql.Tx, bucket, except string, need int64) ([]string, error) {
+	rows, err := p.on(tx).Query(p.rebind(`
+	SELECT KeyID FROM `+p.defTableName+`
+	WHERE Bucket=?
+		AND KeyID<>?
+		AND KeyID NOT LIKE ? ESCAPE '\'
+		AND (ExpiresAt IS NULL OR ExpiresAt > ?)
+	ORDER BY COALESCE(AccessedAt, UpdatedAt, 0), KeyID;`), bucket, except, likePrefix(internalKeyPrefix), time.Now().UnixNano())
+	if err != nil {
+		return nil, err
+	}
+	var candidates []string
+	for rows.Next() {
+		var k string
+		if err = rows.Scan(&k); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		candidates = append(candidates, k)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+	var (
+		keys, all []string
+		freed     int64
+	)
+	for _, k := range candidates {
+		if freed >= need {
+			break
+		}
+		comps, err := p.companionKeys(tx, bucket, k)
+		if err != nil {
+			return nil, err
+		}
+		// the key being written may be a locale variant of the victim
+		more := comps[:0]
+		for _, ck := range comps {
+			if ck != except {
+				more = append(more, ck)
+			}
+		}
+		for _, ck := range append([]string{k}, more...) {
+			size, err := p.storedSize(tx, bucket, ck)
+			if err != nil {
+				return nil, err
+			}
+			freed += size
+		}
+		keys = append(keys, k)
+		all = append(append(all, k), more...)
+	}
+	if freed < need {
+		return nil, ErrQuotaExceeded
+	}
+	if err = p.dropKeys(tx, "", bucket, all); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
@@ -0,0 +1,65 @@
+package sqltplainkv
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBucketQuotaReject(t *testing.T) {
+	pkv := newTestKV(t)
+	if err := pkv.SetBucketQuota(`tenant`, 100); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	pkv.SetBucket(`tenant`)
+	if err := pkv.Set(`a`, bytes.Repeat([]byte(`a`), 60)); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if err := pkv.Set(`b`, bytes.Repeat([]byte(`b`), 60)); !errors.Is(err, ErrQuotaExceeded) {
+		t.Logf(`Expected ErrQuotaExceeded, got %v`, err)
+		t.Fail()
+	}
+	// replacing a value only counts the new one
+	if err := pkv.Set(`a`, bytes.Repeat([]byte(`a`), 90)); err != nil {
+		t.Logf(`Expected the replacement to fit, got %v`, err)
+		t.Fail()
+	}
+	pkv.SetLimits(Limits{ChunkSize: 16})
+	if err := pkv.SetFrom(`c`, strings.NewReader(strings.Repeat(`c`, 50))); !errors.Is(err, ErrQuotaExceeded) {
+		t.Logf(`Expected ErrQuotaExceeded for a streamed value, got %v`, err)
+		t.Fail()
+	}
+	if val, _ := pkv.Get(`c`); len(val) != 0 {
+		t.Logf(`Expected the rejected value not to be stored, got %d bytes`, len(val))
+		t.Fail()
+	}
+
+	pkv.SetBucket(`default`)
+	if err := pkv.Set(`free`, bytes.Repeat([]byte(`f`), 200)); err != nil {
+		t.Logf(`Expected other buckets to be unlimited, got %v`, err)
+		t.Fail()
+	}
+}
+
+func TestBucketQuotaEvict(t *testing.T) {
+	pkv := newTestKV(t)
+	pkv.SetBucketOptions(`cache`, BucketOptions{MaxBytes: 100, QuotaPolicy: QuotaEvict})
+	pkv.SetBucket(`cache`)
+	for _, k := range []string{`a`, `b`, `c`} {
+		if err := pkv.Set(k, bytes.Repeat([]byte(k), 40)); err != nil {
+			t.Fatalf(`%v`, err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	keys, _ := pkv.ListKeys(``)
+	if len(keys) != 2 || keys[0] != `b` || keys[1] != `c` {
+		t.Logf(`Expected the oldest key to be evicted, got %v`, keys)
+		t.Fail()
+	}
+	if err := pkv.Set(`huge`, bytes.Repeat([]byte(`h`), 200)); !errors.Is(err, ErrQuotaExceeded) {
+		t.Logf(`Expected ErrQuotaExceeded for a value larger than the quota, got %v`, err)
+		t.Fail()
+	}
+}
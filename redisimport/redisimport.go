@@ -0,0 +1,223 @@
+// Package redisimport copies the string keys of a Redis server
+// into SQLtPlainKV
+package redisimport
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	sqltplainkv "github.com/narsilworks/sqlt-plainkv"
+)
+
+// Options controls how a Redis server is imported
+type Options struct {
+	Addr     string // host:port of the server, defaults to localhost:6379
+	Password string
+	DB       int
+
+	Bucket    string // bucket to import into
+	Match     string // SCAN pattern of the keys to import, defaults to *
+	KeepTTL   bool   // carry the remaining time to live of keys over
+	Conflict  sqltplainkv.ConflictMode
+	BatchSize int
+}
+
+var (
+	ErrUnexpectedReply error = errors.New(`unexpected reply from redis`)
+)
+
+// Import copies the string keys of the server matching the pattern
+// into the bucket and returns the number of keys written. Keys of
+// other types are skipped. Keys are listed with SCAN, so the server
+// keeps serving other clients during the import
+func Import(kv *sqltplainkv.SQLtPlainKV, opts Options) (int, error) {
+	if opts.Addr == "" {
+		opts.Addr = `localhost:6379`
+	}
+	if opts.Match == "" {
+		opts.Match = `*`
+	}
+	c, err := dial(opts)
+	if err != nil {
+		return 0, err
+	}
+	defer c.Close()
+
+	var (
+		cursor = `0`
+		page   []string
+		done   bool
+	)
+	return kv.ImportRecords(sqltplainkv.ImportOptions{
+		Bucket:    opts.Bucket,
+		Conflict:  opts.Conflict,
+		BatchSize: opts.BatchSize,
+	}, func() (sqltplainkv.Record, error) {
+		for {
+			for len(page) > 0 {
+				key := page[0]
+				page = page[1:]
+				rec, ok, err := c.record(key, opts.KeepTTL)
+				if err != nil || ok {
+					return rec, err
+				}
+			}
+			if done {
+				return sqltplainkv.Record{}, io.EOF
+			}
+			if cursor, page, err = c.scan(cursor, opts.Match); err != nil {
+				return sqltplainkv.Record{}, err
+			}
+			done = cursor == `0`
+		}
+	})
+}
+
+// conn is a minimal RESP client
+type conn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func dial(opts Options) (*conn, error) {
+	nc, err := net.DialTimeout(`tcp`, opts.Addr, 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	c := &conn{Conn: nc, r: bufio.NewReader(nc)}
+	if opts.Password != "" {
+		if _, err = c.do(`AUTH`, opts.Password); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	if opts.DB != 0 {
+		if _, err = c.do(`SELECT`, strconv.Itoa(opts.DB)); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// scan returns the next cursor and the keys of a SCAN page
+func (c *conn) scan(cursor, match string) (string, []string, error) {
+	reply, err := c.do(`SCAN`, cursor, `MATCH`, match, `COUNT`, `500`)
+	if err != nil {
+		return ``, nil, err
+	}
+	arr, ok := reply.([]any)
+	if !ok || len(arr) != 2 {
+		return ``, nil, ErrUnexpectedReply
+	}
+	next, ok := arr[0].([]byte)
+	if !ok {
+		return ``, nil, ErrUnexpectedReply
+	}
+	items, _ := arr[1].([]any)
+	keys := make([]string, 0, len(items))
+	for _, it := range items {
+		if k, ok := it.([]byte); ok {
+			keys = append(keys, string(k))
+		}
+	}
+	return string(next), keys, nil
+}
+
+// record reads a key as a record. It reports false for keys that
+// are not strings or were removed since they were listed
+func (c *conn) record(key string, keepTTL bool) (sqltplainkv.Record, bool, error) {
+	rec := sqltplainkv.Record{Key: key}
+	typ, err := c.do(`TYPE`, key)
+	if err != nil || typ != `string` {
+		return rec, false, err
+	}
+	val, err := c.do(`GET`, key)
+	if err != nil {
+		return rec, false, err
+	}
+	b, ok := val.([]byte)
+	if !ok {
+		return rec, false, nil
+	}
+	rec.Value = b
+	if keepTTL {
+		ttl, err := c.do(`PTTL`, key)
+		if err != nil {
+			return rec, false, err
+		}
+		if ms, ok := ttl.(int64); ok && ms > 0 {
+			exp := time.Now().Add(time.Duration(ms) * time.Millisecond)
+			rec.ExpiresAt = &exp
+		}
+	}
+	return rec, true, nil
+}
+
+// do sends a command and reads its reply. Simple strings are returned
+// as string, bulk strings as []byte, integers as int64 and arrays as
+// []any. Nil replies are returned as nil and error replies as errors
+func (c *conn) do(args ...string) (any, error) {
+	w := bufio.NewWriter(c.Conn)
+	fmt.Fprintf(w, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(a), a)
+	}
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+	return c.readReply()
+}
+
+func (c *conn) readReply() (any, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 {
+		return nil, ErrUnexpectedReply
+	}
+	body := line[1 : len(line)-2]
+	switch line[0] {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, errors.New(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, ErrUnexpectedReply
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		b := make([]byte, n+2)
+		if _, err = io.ReadFull(c.r, b); err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, ErrUnexpectedReply
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		arr := make([]any, n)
+		for i := range arr {
+			if arr[i], err = c.readReply(); err != nil {
+				return nil, err
+			}
+		}
+		return arr, nil
+	}
+	return nil, ErrUnexpectedReply
+}
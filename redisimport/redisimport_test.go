@@ -0,0 +1,149 @@
+package redisimport
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	sqltplainkv "github.com/narsilworks/sqlt-plainkv"
+)
+
+// fakeRedis serves the commands used by the importer from a map
+func fakeRedis(t *testing.T, strs map[string]string, lists []string, ttls map[string]int64) string {
+	ln, err := net.Listen(`tcp`, `127.0.0.1:0`)
+	if err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	keys := []string{}
+	for k := range strs {
+		keys = append(keys, k)
+	}
+	keys = append(keys, lists...)
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		r := bufio.NewReader(c)
+		for {
+			args, err := readCommand(r)
+			if err != nil {
+				return
+			}
+			switch strings.ToUpper(args[0]) {
+			case `SCAN`:
+				// serve one key per page to exercise the cursor
+				i, _ := strconv.Atoi(args[1])
+				next := `0`
+				page := ``
+				if i < len(keys) {
+					page = fmt.Sprintf("$%d\r\n%s\r\n", len(keys[i]), keys[i])
+					if i+1 < len(keys) {
+						next = strconv.Itoa(i + 1)
+					}
+				}
+				n := 0
+				if page != `` {
+					n = 1
+				}
+				fmt.Fprintf(c, "*2\r\n$%d\r\n%s\r\n*%d\r\n%s", len(next), next, n, page)
+			case `TYPE`:
+				if _, ok := strs[args[1]]; ok {
+					io.WriteString(c, "+string\r\n")
+				} else {
+					io.WriteString(c, "+list\r\n")
+				}
+			case `GET`:
+				v := strs[args[1]]
+				fmt.Fprintf(c, "$%d\r\n%s\r\n", len(v), v)
+			case `PTTL`:
+				ms, ok := ttls[args[1]]
+				if !ok {
+					ms = -1
+				}
+				fmt.Fprintf(c, ":%d\r\n", ms)
+			default:
+				io.WriteString(c, "-ERR unknown command\r\n")
+			}
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+	args := make([]string, n)
+	for i := range args {
+		if _, err = r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		arg, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		args[i] = strings.TrimSuffix(arg, "\r\n")
+	}
+	return args, nil
+}
+
+func TestImport(t *testing.T) {
+	addr := fakeRedis(t,
+		map[string]string{`alice`: `admin`, `bob`: `editor`, `session`: `abc`},
+		[]string{`queue`},
+		map[string]int64{`session`: 60000})
+
+	kv := sqltplainkv.NewSQLtPlainKV(filepath.Join(t.TempDir(), `target.db`), false)
+	defer kv.Close()
+	n, err := Import(kv, Options{Addr: addr, Bucket: `redis`, KeepTTL: true})
+	if err != nil || n != 3 {
+		t.Fatalf(`Expected 3 keys imported, got %d (%v)`, n, err)
+	}
+	kv.SetBucket(`redis`)
+	for k, want := range map[string]string{`alice`: `admin`, `bob`: `editor`, `session`: `abc`} {
+		if val, err := kv.Get(k); err != nil || string(val) != want {
+			t.Logf(`Expected %s to be %s, got %s (%v)`, k, want, val, err)
+			t.Fail()
+		}
+	}
+	if val, _ := kv.Get(`queue`); len(val) != 0 {
+		t.Logf(`Expected list keys to be skipped`)
+		t.Fail()
+	}
+	var out bytes.Buffer
+	if err = kv.ExportNDJSON(`redis`, &out); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	dec := json.NewDecoder(&out)
+	for dec.More() {
+		var rec sqltplainkv.Record
+		if err = dec.Decode(&rec); err != nil {
+			t.Fatalf(`%v`, err)
+		}
+		switch rec.Key {
+		case `session`:
+			if rec.ExpiresAt == nil || time.Until(*rec.ExpiresAt) > time.Minute {
+				t.Logf(`Expected session to expire within a minute, got %v`, rec.ExpiresAt)
+				t.Fail()
+			}
+		default:
+			if rec.ExpiresAt != nil {
+				t.Logf(`Expected %s not to expire, got %v`, rec.Key, rec.ExpiresAt)
+				t.Fail()
+			}
+		}
+	}
+}
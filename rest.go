@@ -0,0 +1,414 @@
+package sqltplainkv
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultRESTPageSize is the number of keys listed per page
+	// when a list request sets no limit
+	DefaultRESTPageSize int = 100
+	// maxRESTPageSize is the most keys listed per page
+	maxRESTPageSize int = 1000
+)
+
+// RESTOption configures the REST API server
+type RESTOption func(s *restServer) error
+
+// restServer serves the REST API of a store
+type restServer struct {
+	p        *SQLtPlainKV
+	tokens   []string
+	tls      *TLSOptions
+	pageSize int
+}
+
+// WithRESTToken adds a bearer token with full access to the REST API,
+// in addition to the API keys created with CreateAPIKey
+func WithRESTToken(token string) RESTOption {
+	return func(s *restServer) error {
+		if token != "" {
+			s.tokens = append(s.tokens, token)
+		}
+		return nil
+	}
+}
+
+// WithRESTTLS serves the REST API over HTTPS
+func WithRESTTLS(opts TLSOptions) RESTOption {
+	return func(s *restServer) error {
+		s.tls = &opts
+		return nil
+	}
+}
+
+// WithRESTPageSize sets the number of keys listed per page
+// when a list request sets no limit
+func WithRESTPageSize(n int) RESTOption {
+	return func(s *restServer) error {
+		if n > 0 {
+			s.pageSize = n
+		}
+		return nil
+	}
+}
+
+// ServeREST serves the REST API of the store on the address until
+// the listener fails. See RESTHandler for the endpoints
+func (p *SQLtPlainKV) ServeREST(addr string, opts ...RESTOption) error {
+	s, err := p.newRESTServer(opts...)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	if s.tls == nil {
+		return srv.ListenAndServe()
+	}
+	if srv.TLSConfig, err = NewTLSConfig(*s.tls); err != nil {
+		return err
+	}
+	return srv.ListenAndServeTLS("", "")
+}
+
+// RESTHandler returns the http.Handler of the REST API, for serving
+// it with a server of its own. The endpoints are
+//
+//	GET    /buckets/{bucket}/keys/{key}  the value, with its MIME type as Content-Type
+//	PUT    /buckets/{bucket}/keys/{key}  stores the body, with the Content-Type as MIME
+//	                                     type and an optional ttl query parameter
+//	DELETE /buckets/{bucket}/keys/{key}  deletes the key
+//	GET    /buckets/{bucket}/keys        lists the keys, see below
+//	GET    /buckets/{bucket}/count       counts the keys
+//
+// Listing and counting take a prefix query parameter. Keys are listed
+// in order, a page at a time: limit sets the page size and after the
+// key to list from, the next field of the response holding the after
+// parameter of the next page, empty for the last one.
+// Requests authenticate with an Authorization: Bearer header holding
+// a token given with WithRESTToken or the token of an API key allowing
+// the bucket. Internal buckets are not served
+func (p *SQLtPlainKV) RESTHandler(opts ...RESTOption) (http.Handler, error) {
+	return p.newRESTServer(opts...)
+}
+
+func (p *SQLtPlainKV) newRESTServer(opts ...RESTOption) (*restServer, error) {
+	s := &restServer{p: p, pageSize: DefaultRESTPageSize}
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// keyPage is the response of a list request
+type keyPage struct {
+	Keys []string `json:"keys"`
+	Next string   `json:"next"`
+}
+
+func (s *restServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	bucket, resource, key, ok := parseRESTPath(r.URL.EscapedPath())
+	// hidden keys, such as versions, are reached through their key only
+	if !ok || isInternalBucket(bucket) || strings.HasPrefix(key, internalKeyPrefix) {
+		restError(w, http.StatusNotFound, ErrKeyNotFound)
+		return
+	}
+	write := r.Method == http.MethodPut || r.Method == http.MethodDelete
+	principal, status, err := s.authorize(r, bucket, write)
+	if err != nil {
+		if status == http.StatusUnauthorized {
+			w.Header().Set(`WWW-Authenticate`, `Bearer`)
+		}
+		restError(w, status, err)
+		return
+	}
+
+	switch {
+	case resource == `keys` && key != "":
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			s.getKey(w, bucket, key)
+		case http.MethodPut:
+			s.putKey(w, r, principal, bucket, key)
+		case http.MethodDelete:
+			if err := s.p.delAs(principal, bucket, key); err != nil {
+				restError(w, restStatus(err), err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			methodNotAllowed(w, `GET, HEAD, PUT, DELETE`)
+		}
+	case resource == `keys`:
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			methodNotAllowed(w, `GET, HEAD`)
+			return
+		}
+		s.listKeys(w, r, bucket)
+	case resource == `count`:
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			methodNotAllowed(w, `GET, HEAD`)
+			return
+		}
+		n, err := s.p.countKeys(bucket, r.URL.Query().Get(`prefix`))
+		if err != nil {
+			restError(w, restStatus(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]int{`count`: n})
+	default:
+		restError(w, http.StatusNotFound, ErrKeyNotFound)
+	}
+}
+
+// authorize checks the bearer token of a request and returns the
+// principal making it, the name of its API key, or the status to
+// answer with when it does not allow the request. Requests made with
+// a static token have no principal
+func (s *restServer) authorize(r *http.Request, bucket string, write bool) (string, int, error) {
+	token := r.Header.Get(`Authorization`)
+	if len(token) < 7 || !strings.EqualFold(token[:7], `Bearer `) {
+		return "", http.StatusUnauthorized, ErrUnauthorized
+	}
+	token = strings.TrimSpace(token[7:])
+	for _, t := range s.tokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			return "", 0, nil
+		}
+	}
+	key, err := s.p.AuthorizeAPIKey(token, bucket, write)
+	switch {
+	case err == nil:
+		return key.Name, 0, nil
+	case errors.Is(err, ErrUnauthorized):
+		return "", http.StatusUnauthorized, err
+	case errors.Is(err, ErrForbidden):
+		return "", http.StatusForbidden, err
+	}
+	return "", http.StatusInternalServerError, err
+}
+
+func (s *restServer) getKey(w http.ResponseWriter, bucket, key string) {
+	val, err := s.p.get(bucket, key)
+	if err == nil && len(val) == 0 {
+		err = ErrKeyNotFound
+	}
+	if err == nil {
+		err = s.p.recordAccess(bucket, key)
+	}
+	if err != nil {
+		restError(w, restStatus(err), err)
+		return
+	}
+	mime, err := s.p.getMime(bucket, key)
+	if err != nil {
+		restError(w, restStatus(err), err)
+		return
+	}
+	if mime == "" {
+		mime = s.p.bucketDefaultMime(bucket)
+	}
+	w.Header().Set(`Content-Type`, mime)
+	w.Header().Set(`Content-Length`, strconv.Itoa(len(val)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(val)
+}
+
+func (s *restServer) putKey(w http.ResponseWriter, r *http.Request, principal, bucket, key string) {
+	var expiry time.Time
+	if ttl := r.URL.Query().Get(`ttl`); ttl != "" {
+		d, err := time.ParseDuration(ttl)
+		if err != nil || d <= 0 {
+			restError(w, http.StatusBadRequest, ErrInvalidTTL)
+			return
+		}
+		expiry = time.Now().Add(d)
+	}
+	max := int64(s.p.limits.MaxValueSize)
+	val, err := io.ReadAll(io.LimitReader(r.Body, max+1))
+	if err != nil {
+		restError(w, http.StatusBadRequest, err)
+		return
+	}
+	if int64(len(val)) > max {
+		restError(w, http.StatusRequestEntityTooLarge, ErrValueTooLong)
+		return
+	}
+	// the mime is set along with the value, or kept if none is sent
+	mime := r.Header.Get(`Content-Type`)
+	if err = s.p.store(bucket, key, val, expiry, storeOptions{
+		principal: principal,
+		mime:      sql.NullString{String: mime, Valid: mime != ""},
+	}); err != nil {
+		restError(w, restStatus(err), err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *restServer) listKeys(w http.ResponseWriter, r *http.Request, bucket string) {
+	q := r.URL.Query()
+	limit := s.pageSize
+	if l := q.Get(`limit`); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 {
+			restError(w, http.StatusBadRequest, errors.New(`invalid limit`))
+			return
+		}
+		limit = n
+	}
+	if limit > maxRESTPageSize {
+		limit = maxRESTPageSize
+	}
+	keys, err := s.p.listPage(bucket, q.Get(`prefix`), q.Get(`after`), limit+1)
+	if err != nil {
+		restError(w, restStatus(err), err)
+		return
+	}
+	page := keyPage{Keys: keys}
+	if len(keys) > limit {
+		page.Keys = keys[:limit]
+		page.Next = keys[limit-1]
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// parseRESTPath splits an escaped path of the form
+// /buckets/{bucket}/{resource}[/{key}] into its parts.
+// Keys may contain slashes
+func parseRESTPath(path string) (bucket, resource, key string, ok bool) {
+	if !strings.HasPrefix(path, `/buckets/`) {
+		return "", "", "", false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(path, `/buckets/`), `/`, 3)
+	if len(parts) < 2 {
+		return "", "", "", false
+	}
+	var err error
+	if bucket, err = url.PathUnescape(parts[0]); err != nil || bucket == "" {
+		return "", "", "", false
+	}
+	resource = parts[1]
+	if len(parts) == 3 {
+		if key, err = url.PathUnescape(parts[2]); err != nil {
+			return "", "", "", false
+		}
+		if key == "" {
+			return "", "", "", false
+		}
+	}
+	return bucket, resource, key, true
+}
+
+// restStatus returns the HTTP status of an error of the store
+func restStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrKeyNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrBucketIdTooLong), errors.Is(err, ErrKeyTooLong):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrValueTooLong), errors.Is(err, ErrQuotaExceeded), errors.Is(err, ErrDatabaseFull):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, ErrKeyExists):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+func restError(w http.ResponseWriter, status int, err error) {
+	msg := http.StatusText(status)
+	if status != http.StatusInternalServerError {
+		msg = err.Error()
+	}
+	writeJSON(w, status, map[string]string{`error`: msg})
+}
+
+func methodNotAllowed(w http.ResponseWriter, allow string) {
+	w.Header().Set(`Allow`, allow)
+	writeJSON(w, http.StatusMethodNotAllowed, map[string]string{`error`: http.StatusText(http.StatusMethodNotAllowed)})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set(`Content-Type`, `application/json`)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// listPage lists at most limit keys of a bucket starting with the
+// prefix and sorting after the key after, in order. Tally and locale
+// keys are left out
+func (p *SQLtPlainKV) listPage(bucket, prefix, after string, limit int) (val []string, err error) {
+	val = make([]string, 0)
+	if p.observing() {
+		defer func(start time.Time) {
+			p.observe(opList, bucket, prefix, start, err)
+		}(time.Now())
+	}
+	if err = p.open(); err != nil {
+		return val, err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	rows, err := p.conn().Query(p.rebind(`
+	SELECT KeyID FROM `+p.defTableName+`
+	WHERE Bucket = ?
+		AND KeyID LIKE ? ESCAPE '\'
+		AND KeyID NOT LIKE ? ESCAPE '\'
+		AND KeyID > ?
+		AND (ExpiresAt IS NULL OR ExpiresAt > ?)
+	ORDER BY KeyID
+	LIMIT ?;`), bucket, likePrefix(prefix), likePrefix(internalKeyPrefix), after, time.Now().UnixNano(), limit)
+	if err != nil {
+		return val, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var k string
+		if err = rows.Scan(&k); err != nil {
+			return val, err
+		}
+		val = append(val, k)
+	}
+	return val, rows.Err()
+}
+
+// countKeys counts the keys of a bucket starting with the prefix.
+// Tally and locale keys are left out
+func (p *SQLtPlainKV) countKeys(bucket, prefix string) (int, error) {
+	var n int
+	if err := p.open(); err != nil {
+		return 0, err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	err := p.conn().QueryRow(p.rebind(`
+	SELECT COUNT(*) FROM `+p.defTableName+`
+	WHERE Bucket = ?
+		AND KeyID LIKE ? ESCAPE '\'
+		AND KeyID NOT LIKE ? ESCAPE '\'
+		AND (ExpiresAt IS NULL OR ExpiresAt > ?);`), bucket, likePrefix(prefix), likePrefix(internalKeyPrefix), time.Now().UnixNano()).Scan(&n)
+	return n, err
+}
+
+// likePrefix returns the LIKE pattern matching the strings starting
+// with the prefix, escaping the wildcards in it
+func likePrefix(prefix string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return r.Replace(prefix) + `%`
+}
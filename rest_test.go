@@ -0,0 +1,107 @@
+package sqltplainkv
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRESTHandler(t *testing.T) {
+	pkv := newTestKV(t)
+	h, err := pkv.RESTHandler(WithRESTToken(`secret`), WithRESTPageSize(2))
+	if err != nil {
+		t.Fatalf(`RESTHandler: %v`, err)
+	}
+	reader, _, err := pkv.CreateAPIKey(`reader`, APIKeyScope{Read: true, Buckets: []string{`users`}})
+	if err != nil {
+		t.Fatalf(`CreateAPIKey: %v`, err)
+	}
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != `` {
+			req.Header.Set(`Authorization`, `Bearer `+token)
+		}
+		if method == http.MethodPut {
+			req.Header.Set(`Content-Type`, `application/json`)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, k := range []string{`alice`, `bob`, `carol`, `a_b`, `dir/file`} {
+		if rec := do(`PUT`, `/buckets/users/keys/`+k, `secret`, `{"name":"`+k+`"}`); rec.Code != 204 {
+			t.Fatalf(`PUT %s: expected 204, got %d %s`, k, rec.Code, rec.Body.String())
+		}
+	}
+	pkv.SetBucket(`users`)
+	pkv.TallyIncr(`visits`)
+
+	for _, tc := range []struct {
+		method, path, token string
+		status              int
+		body                string
+	}{
+		{`GET`, `/buckets/users/keys/alice`, `secret`, 200, `{"name":"alice"}`},
+		{`GET`, `/buckets/users/keys/dir%2Ffile`, reader, 200, `{"name":"dir/file"}`},
+		{`GET`, `/buckets/users/keys/dave`, `secret`, 404, ``},
+		{`GET`, `/buckets/users/keys/alice`, ``, 401, ``},
+		{`GET`, `/buckets/users/keys/alice`, `wrong`, 401, ``},
+		{`PUT`, `/buckets/users/keys/alice`, reader, 403, ``},
+		{`GET`, `/buckets/other/keys/alice`, reader, 403, ``},
+		{`GET`, `/buckets/--mime--/keys/alice`, `secret`, 404, ``},
+		{`GET`, `/buckets/users/keys/_______%23tally-visits`, `secret`, 404, ``},
+		{`PUT`, `/buckets/users/keys/_______%23tally-visits`, `secret`, 404, ``},
+		{`DELETE`, `/buckets/users/keys/_______%23tally-visits`, `secret`, 404, ``},
+		{`POST`, `/buckets/users/keys/alice`, `secret`, 405, ``},
+		{`PUT`, `/buckets/users/keys/tmp?ttl=soon`, `secret`, 400, ``},
+		{`GET`, `/buckets/users/count?prefix=a_`, reader, 200, `{"count":1}` + "\n"},
+		{`GET`, `/buckets/users/count`, reader, 200, `{"count":5}` + "\n"},
+	} {
+		rec := do(tc.method, tc.path, tc.token, ``)
+		if rec.Code != tc.status || tc.body != `` && rec.Body.String() != tc.body {
+			t.Logf(`%s %s: expected %d %q, got %d %q`, tc.method, tc.path, tc.status, tc.body, rec.Code, rec.Body.String())
+			t.Fail()
+		}
+	}
+	if rec := do(`GET`, `/buckets/users/keys/alice`, `secret`, ``); rec.Header().Get(`Content-Type`) != `application/json` {
+		t.Logf(`Expected the stored MIME type, got %q`, rec.Header().Get(`Content-Type`))
+		t.Fail()
+	}
+	if n, _ := pkv.Tally(`visits`, 0); n != 1 {
+		t.Logf(`Expected the hidden tally to be left alone, got %d`, n)
+		t.Fail()
+	}
+
+	// page through the keys
+	var (
+		listed []string
+		after  string
+	)
+	for i := 0; i < 5; i++ {
+		rec := do(`GET`, `/buckets/users/keys?after=`+after, reader, ``)
+		var page keyPage
+		if err = json.Unmarshal(rec.Body.Bytes(), &page); err != nil || rec.Code != 200 {
+			t.Fatalf(`List: %d %v`, rec.Code, err)
+		}
+		listed = append(listed, page.Keys...)
+		if after = page.Next; after == `` {
+			break
+		}
+	}
+	if strings.Join(listed, `,`) != `a_b,alice,bob,carol,dir/file` {
+		t.Logf(`Unexpected listing %v`, listed)
+		t.Fail()
+	}
+
+	if rec := do(`DELETE`, `/buckets/users/keys/bob`, `secret`, ``); rec.Code != 204 {
+		t.Logf(`DELETE: expected 204, got %d`, rec.Code)
+		t.Fail()
+	}
+	if rec := do(`GET`, `/buckets/users/keys/bob`, `secret`, ``); rec.Code != 404 {
+		t.Logf(`Expected a deleted key to be gone, got %d`, rec.Code)
+		t.Fail()
+	}
+}
@@ -0,0 +1,82 @@
+package sqltplainkv
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	// defaultBusyTimeout is how long SQLite itself waits on a lock
+	// before the store starts retrying
+	defaultBusyTimeout string = `5000`
+)
+
+// RetryPolicy controls how writes are retried when the database is
+// locked by another connection or process. The delay starts at
+// InitialBackoff and doubles after every attempt up to MaxBackoff
+type RetryPolicy struct {
+	MaxAttempts    int // attempts including the first one, 1 disables retrying
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// DefaultRetryPolicy is the retry policy of a new store
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    5,
+	InitialBackoff: 10 * time.Millisecond,
+	MaxBackoff:     time.Second,
+}
+
+// SetRetryPolicy changes how set, Del and Begin are retried
+// when the database is busy
+func (p *SQLtPlainKV) SetRetryPolicy(rp RetryPolicy) {
+	p.retryPolicy = rp
+}
+
+// WithRetryPolicy changes how writes are retried when the database is busy
+func WithRetryPolicy(rp RetryPolicy) Option {
+	return func(p *SQLtPlainKV) error {
+		p.SetRetryPolicy(rp)
+		return nil
+	}
+}
+
+// retry calls fn until it succeeds, fails with an error other than
+// a busy database, or the attempts of the retry policy run out
+func (p *SQLtPlainKV) retry(fn func() error) error {
+	rp := p.retryPolicy
+	backoff := rp.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || !isBusy(err) || attempt >= rp.MaxAttempts {
+			return err
+		}
+		p.warn(`database busy, retrying`, `attempt`, attempt, `backoff`, backoff)
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > rp.MaxBackoff {
+			backoff = rp.MaxBackoff
+		}
+	}
+}
+
+// isBusy reports whether the error is SQLite reporting a locked database
+func isBusy(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, `database is locked`) ||
+		strings.Contains(msg, `database table is locked`) ||
+		strings.Contains(msg, `sqlite_busy`)
+}
+
+// effectivePragmas returns the pragmas of the store along with
+// a default busy timeout, unless the DSN or the store sets one
+func (p *SQLtPlainKV) effectivePragmas() []pragma {
+	if strings.Contains(strings.ToLower(p.DSN), `busy_timeout`) {
+		return p.pragmas
+	}
+	for _, pr := range p.pragmas {
+		if pr.name == `busy_timeout` {
+			return p.pragmas
+		}
+	}
+	return append([]pragma{{name: `busy_timeout`, value: defaultBusyTimeout}}, p.pragmas...)
+}
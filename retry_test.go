@@ -0,0 +1,56 @@
+package sqltplainkv
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetry(t *testing.T) {
+	pkv := newTestKV(t)
+	pkv.SetRetryPolicy(RetryPolicy{
+		MaxAttempts:    4,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     2 * time.Millisecond,
+	})
+
+	calls := 0
+	err := pkv.retry(func() error {
+		calls++
+		if calls < 3 {
+			return errors.New(`database is locked (5) (SQLITE_BUSY)`)
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Logf(`Expected success on the third attempt, got %d (%v)`, calls, err)
+		t.Fail()
+	}
+
+	calls = 0
+	err = pkv.retry(func() error {
+		calls++
+		return errors.New(`database is locked`)
+	})
+	if err == nil || calls != 4 {
+		t.Logf(`Expected failure after 4 attempts, got %d (%v)`, calls, err)
+		t.Fail()
+	}
+
+	calls = 0
+	pkv.retry(func() error {
+		calls++
+		return errors.New(`constraint failed`)
+	})
+	if calls != 1 {
+		t.Logf(`Expected other errors not to be retried, got %d attempts`, calls)
+		t.Fail()
+	}
+
+	var timeout string
+	pkv.db.QueryRow(`PRAGMA busy_timeout`).Scan(&timeout)
+	if timeout != defaultBusyTimeout {
+		t.Logf(`Expected the default busy timeout, got %s`, timeout)
+		t.Fail()
+	}
+}
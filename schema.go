@@ -0,0 +1,184 @@
+package sqltplainkv
+
+import (
+	"database/sql"
+	"errors"
+	"regexp"
+	"strings"
+)
+
+var (
+	ErrInvalidTableName error = errors.New(`invalid table name`)
+
+	tableNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// companionSuffixes name the tables kept alongside the key-value
+// table, such as the change log, the audit log and the search index
+var companionSuffixes = []string{`_changes`, `_audit`, `_queue`, `_search`, `_search_keys`, `_snapshots`, `_snapshot_rows`}
+
+// RenameTable renames the key-value table along with its companion
+// tables, such as the change log and the search index, and switches
+// the store to the new name. The triggers of the table are created
+// again under the new name and its indexes are renamed. SQLite renames tables in place, so the rename is
+// done in one transaction and does not copy any data. It cannot be
+// called inside a transaction
+func (p *SQLtPlainKV) RenameTable(newName string) error {
+	var err error
+	if !tableNameRe.MatchString(newName) {
+		return ErrInvalidTableName
+	}
+	if p.inTransaction {
+		return ErrInTransaction
+	}
+	if err = p.open(); err != nil {
+		return err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	if newName == p.defTableName {
+		return nil
+	}
+	old := p.defTableName
+	tx, err := p.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	tables := []string{old}
+	for _, sfx := range companionSuffixes {
+		var n int
+		err = tx.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?;`, old+sfx).Scan(&n)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			tables = append(tables, old+sfx)
+		}
+	}
+	// the triggers write to the companion tables by name, so they are
+	// dropped before the rename and created again after it
+	triggers, err := schemaObjects(tx, `trigger`, tables)
+	if err != nil {
+		return err
+	}
+	var stmts []string
+	logged, snapped := false, false
+	for name := range triggers {
+		stmts = append(stmts, `DROP TRIGGER `+name+`;`)
+		logged = logged || name == old+`_log_insert`
+		snapped = snapped || name == old+`_snap_update`
+	}
+	for _, t := range tables {
+		stmts = append(stmts, `ALTER TABLE `+t+` RENAME TO `+newName+strings.TrimPrefix(t, old)+`;`)
+	}
+	if logged {
+		stmts = append(stmts, p.changeLogSchema(newName)...)
+	}
+	if snapped {
+		stmts = append(stmts, p.snapshotSchema(newName)...)
+	}
+	for _, s := range stmts {
+		if _, err = tx.Exec(s); err != nil {
+			return err
+		}
+	}
+	// indexes follow their table but keep their name, so they are
+	// created again under the new name
+	renamed := make([]string, len(tables))
+	for i, t := range tables {
+		renamed[i] = newName + strings.TrimPrefix(t, old)
+	}
+	indexes, err := schemaObjects(tx, `index`, renamed)
+	if err != nil {
+		return err
+	}
+	for name, ddl := range indexes {
+		if !strings.HasPrefix(name, old) {
+			continue
+		}
+		if _, err = tx.Exec(`DROP INDEX ` + name + `;`); err != nil {
+			return err
+		}
+		if _, err = tx.Exec(strings.Replace(ddl, name, newName+strings.TrimPrefix(name, old), 1)); err != nil {
+			return err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	p.defTableName = newName
+	p.schemaReady = newName
+	return nil
+}
+
+// schemaObjects returns the statements of the triggers or indexes on
+// the tables by name. Automatic indexes have no statement and are left out
+func schemaObjects(tx *sql.Tx, typ string, tables []string) (map[string]string, error) {
+	args := []any{typ}
+	for _, t := range tables {
+		args = append(args, t)
+	}
+	rows, err := tx.Query(`
+	SELECT name, sql FROM sqlite_master
+	WHERE type = ?
+		AND sql IS NOT NULL
+		AND tbl_name IN (?`+strings.Repeat(`, ?`, len(tables)-1)+`);`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	objs := make(map[string]string)
+	for rows.Next() {
+		var name, ddl string
+		if err = rows.Scan(&name, &ddl); err != nil {
+			return nil, err
+		}
+		objs[name] = ddl
+	}
+	return objs, rows.Err()
+}
+
+// EnsureSchema creates the key-value table if it does not exist and
+// adds any missing columns. Open does this only the first time the
+// table is used, so call EnsureSchema when the table may have been
+// dropped or replaced since
+func (p *SQLtPlainKV) EnsureSchema() error {
+	p.schemaReady = ``
+	if err := p.open(); err != nil {
+		return err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	if p.schemaReady == p.defTableName {
+		return nil // created by Open
+	}
+	if err := p.ensureSchema(); err != nil {
+		return err
+	}
+	return p.prepareStatements()
+}
+
+func (p *SQLtPlainKV) ensureSchema() error {
+	if _, err := p.db.Exec(p.tableSchema(p.defTableName)); err != nil {
+		return err
+	}
+	if err := p.migrate(); err != nil {
+		return err
+	}
+	if p.changeLog {
+		if err := p.ensureChangeLog(); err != nil {
+			return err
+		}
+	}
+	if p.audit {
+		if err := p.ensureAudit(); err != nil {
+			return err
+		}
+	}
+	p.schemaReady = p.defTableName
+	p.debug(`schema ready`, `table`, p.defTableName)
+	return nil
+}
@@ -0,0 +1,176 @@
+package sqltplainkv
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestRenameTable(t *testing.T) {
+	pkv := newTestKV(t)
+
+	pkv.Set(`sample_key`, []byte(`Sample value`))
+
+	if err := pkv.RenameTable(`bad name; DROP`); !errors.Is(err, ErrInvalidTableName) {
+		t.Logf(`Expected ErrInvalidTableName, got %v`, err)
+		t.Fail()
+	}
+	if err := pkv.RenameTable(`RenamedTBL`); err != nil {
+		t.Fatalf(`%s`, err)
+	}
+
+	b, err := pkv.Get(`sample_key`)
+	if err != nil || string(b) != `Sample value` {
+		t.Logf(`Expected value in the renamed table, got %s (%v)`, b, err)
+		t.Fail()
+	}
+
+	var n int
+	pkv.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name='KeyValueTBL'`).Scan(&n)
+	if n != 0 {
+		t.Logf(`Expected the old table to be gone`)
+		t.Fail()
+	}
+}
+
+func TestRenameTableCompanions(t *testing.T) {
+	pkv := newTestKV(t)
+
+	if err := pkv.EnableChangeLog(); err != nil {
+		t.Fatalf(`%s`, err)
+	}
+	if err := pkv.EnableAudit(); err != nil {
+		t.Fatalf(`%s`, err)
+	}
+	if err := pkv.Set(`sample_key`, []byte(`Sample value`)); err != nil {
+		t.Fatalf(`%s`, err)
+	}
+	if _, err := pkv.QueuePush(`jobs`, []byte(`job`)); err != nil {
+		t.Fatalf(`%s`, err)
+	}
+	if err := pkv.RenameTable(`RenamedTBL`); err != nil {
+		t.Fatalf(`%s`, err)
+	}
+	if err := pkv.Set(`sample_key`, []byte(`New value`)); err != nil {
+		t.Fatalf(`%s`, err)
+	}
+
+	changes, err := pkv.ChangesSince(0)
+	if err != nil || len(changes) != 2 {
+		t.Logf(`Expected both changes under the new name, got %d (%v)`, len(changes), err)
+		t.Fail()
+	}
+	entries, err := pkv.AuditLog(AuditFilter{})
+	if err != nil || len(entries) != 2 {
+		t.Logf(`Expected both audit entries under the new name, got %d (%v)`, len(entries), err)
+		t.Fail()
+	}
+	if b, err := pkv.QueuePop(`jobs`); err != nil || string(b) != `job` {
+		t.Logf(`Expected the queued job under the new name, got %s (%v)`, b, err)
+		t.Fail()
+	}
+
+	var n int
+	pkv.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name LIKE 'KeyValueTBL%'`).Scan(&n)
+	if n != 0 {
+		t.Logf(`Expected no tables, triggers or indexes left under the old name, got %d`, n)
+		t.Fail()
+	}
+}
+
+func TestEnsureSchema(t *testing.T) {
+	pkv := newTestKV(t)
+
+	if err := pkv.Set(`sample_key`, []byte(`Sample value`)); err != nil {
+		t.Fatalf(`%s`, err)
+	}
+	if _, err := pkv.db.Exec(`DROP TABLE KeyValueTBL;`); err != nil {
+		t.Fatalf(`%s`, err)
+	}
+	pkv.Close()
+
+	// Reopening does not recreate the table
+	if err := pkv.Set(`sample_key`, []byte(`Sample value`)); err == nil {
+		t.Logf(`Expected the schema not to be recreated on reopen`)
+		t.Fail()
+	}
+
+	if err := pkv.EnsureSchema(); err != nil {
+		t.Fatalf(`%s`, err)
+	}
+	if err := pkv.Set(`sample_key`, []byte(`Sample value`)); err != nil {
+		t.Logf(`Expected EnsureSchema to recreate the table, got %v`, err)
+		t.Fail()
+	}
+}
+
+func TestWithoutMigration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "old.dat")
+	pkv := NewSQLtPlainKV(path, false)
+	if err := pkv.Set(`sample_key`, []byte(`Sample value`)); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if _, err := pkv.db.Exec(`ALTER TABLE KeyValueTBL DROP COLUMN ExpirySetAt;`); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	pkv.Close()
+
+	pkv, err := New(path, WithoutMigration())
+	if err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	defer pkv.Close()
+	if v, err := pkv.Get(`sample_key`); err != nil || string(v) != `Sample value` {
+		t.Logf(`Expected Sample value, got %q (%v)`, v, err)
+		t.Fail()
+	}
+	var n int
+	pkv.db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info('KeyValueTBL') WHERE name='ExpirySetAt';`).Scan(&n)
+	if n != 0 {
+		t.Logf(`Expected the table to be left as it is`)
+		t.Fail()
+	}
+}
+
+func TestMigrateMimes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "old.dat")
+	db, err := sql.Open(driverName, path)
+	if err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	// the layout before the Mime column, with mimes in their own bucket
+	for _, s := range []string{
+		`CREATE TABLE KeyValueTBL (Bucket VARCHAR(50), KeyID VARCHAR(255), Value BLOB, PRIMARY KEY (Bucket, KeyID));`,
+		`INSERT INTO KeyValueTBL VALUES ('default', 'page', '<p>hi</p>');`,
+		`INSERT INTO KeyValueTBL VALUES ('site', 'page', '<p>site</p>');`,
+		`INSERT INTO KeyValueTBL VALUES ('site', 'plain', 'no mime');`,
+		`INSERT INTO KeyValueTBL VALUES ('--mime--', 'page', 'text/html; charset=utf-8');`,
+		`INSERT INTO KeyValueTBL VALUES ('--mime--', 'orphan', 'text/plain');`,
+	} {
+		if _, err = db.Exec(s); err != nil {
+			t.Fatalf(`%v`, err)
+		}
+	}
+	db.Close()
+
+	pkv := NewSQLtPlainKV(path, false)
+	defer pkv.Close()
+	for _, bucket := range []string{`default`, `site`} {
+		pkv.SetBucket(bucket)
+		if m, err := pkv.GetMime(`page`); err != nil || m != `text/html; charset=utf-8` {
+			t.Logf(`Expected the mime of %s/page to be migrated, got %s (%v)`, bucket, m, err)
+			t.Fail()
+		}
+	}
+	if meta, _ := pkv.GetMeta(`plain`); meta.Mime != `` {
+		t.Logf(`Expected no mime for site/plain, got %s`, meta.Mime)
+		t.Fail()
+	}
+	var n int
+	pkv.db.QueryRow(`SELECT COUNT(*) FROM KeyValueTBL WHERE Bucket='--mime--'`).Scan(&n)
+	if n != 0 {
+		t.Logf(`Expected the mime bucket to be gone, %d rows left`, n)
+		t.Fail()
+	}
+}
@@ -0,0 +1,249 @@
+package sqltplainkv
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// searchHits is the most hits returned by Search
+const searchHits int = 100
+
+var (
+	ErrSearchOff         error = errors.New(`search not enabled for the bucket`)
+	ErrSearchUnsupported error = errors.New(`sqlite driver built without fts5`)
+	ErrSearchEncrypted   error = errors.New(`search cannot index encrypted values`)
+)
+
+// SearchHit is a key whose value matches a search
+type SearchHit struct {
+	Key     string
+	Snippet string  // the matching part of the value, matches in [ and ]
+	Rank    float64 // bm25 rank, lower is better
+}
+
+func (p *SQLtPlainKV) searchTable() string {
+	return p.defTableName + `_search`
+}
+
+// searchKeysTable maps the rows of the search index to their keys,
+// so a key is removed from the index without scanning it
+func (p *SQLtPlainKV) searchKeysTable() string {
+	return p.defTableName + `_search_keys`
+}
+
+// EnableSearch indexes the text values of a bucket with SQLite FTS5
+// for Search, starting with the keys already stored. The index is
+// kept up to date as keys are written and deleted through the store.
+// It is kept in the bucket options, so other instances that read them
+// before it was enabled do not index their writes until they reopen
+// the database. Values that are not valid UTF-8 are not indexed.
+// The index holds the values in plain text, so it returns
+// ErrSearchEncrypted while an encryption key is set, and a store given
+// an encryption key later neither indexes nor searches its values.
+// It returns ErrSearchUnsupported if the SQLite driver has no FTS5,
+// which mattn/go-sqlite3 only has when built with the sqlite_fts5 tag
+func (p *SQLtPlainKV) EnableSearch(bucket string) error {
+	var err error
+	if bucket == "" {
+		bucket = "default"
+	}
+	if p.inTransaction {
+		return ErrInTransaction
+	}
+	if p.encActive != nil {
+		return ErrSearchEncrypted
+	}
+	if err = p.open(); err != nil {
+		return err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	if err = p.ensureSearch(); err != nil {
+		return err
+	}
+	opts, _, err := p.GetBucketOptions(bucket)
+	if err != nil {
+		return err
+	}
+	if opts.Search {
+		return nil
+	}
+	opts.Search = true
+	if err = p.SetBucketOptions(bucket, opts); err != nil {
+		return err
+	}
+	keys, err := p.listKeys(bucket, "")
+	if err != nil {
+		return err
+	}
+	return p.withTx(func(tx *sql.Tx) error {
+		for _, k := range keys {
+			if !p.searched(bucket, k) {
+				continue
+			}
+			if err := p.indexSearch(tx, bucket, k, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DisableSearch stops indexing a bucket and drops its index
+func (p *SQLtPlainKV) DisableSearch(bucket string) error {
+	var err error
+	if bucket == "" {
+		bucket = "default"
+	}
+	if err = p.open(); err != nil {
+		return err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	opts, ok, err := p.GetBucketOptions(bucket)
+	if err != nil || !ok || !opts.Search {
+		return err
+	}
+	opts.Search = false
+	if err = p.SetBucketOptions(bucket, opts); err != nil {
+		return err
+	}
+	_, err = p.conn().Exec(p.rebind(`
+	DELETE FROM `+p.searchTable()+`
+	WHERE rowid IN (SELECT ID FROM `+p.searchKeysTable()+` WHERE Bucket=?);`), bucket)
+	if err != nil {
+		return err
+	}
+	_, err = p.conn().Exec(p.rebind(`DELETE FROM `+p.searchKeysTable()+` WHERE Bucket=?;`), bucket)
+	return err
+}
+
+// ensureSearch creates the search index and its key table
+func (p *SQLtPlainKV) ensureSearch() error {
+	_, err := p.db.Exec(`CREATE VIRTUAL TABLE IF NOT EXISTS ` + p.searchTable() + ` USING fts5(Body);`)
+	if err != nil {
+		if strings.Contains(err.Error(), `no such module`) {
+			return ErrSearchUnsupported
+		}
+		return err
+	}
+	// an explicit ID survives VACUUM, which may renumber rowids
+	_, err = p.db.Exec(`CREATE TABLE IF NOT EXISTS ` + p.searchKeysTable() + ` (
+			ID INTEGER PRIMARY KEY,
+			Bucket VARCHAR(` + strconv.Itoa(p.limits.MaxBucketLen) + `),
+			KeyID VARCHAR(` + strconv.Itoa(p.limits.MaxKeyLen) + `),
+			UNIQUE (Bucket, KeyID)
+		);`)
+	return err
+}
+
+// searched reports whether the values of the key are indexed.
+// Nothing is indexed while values are encrypted
+func (p *SQLtPlainKV) searched(bucket, key string) bool {
+	opts, ok := p.bucketOpts[bucket]
+	return ok && opts.Search && p.encActive == nil && !isInternalBucket(bucket) && !strings.HasPrefix(key, internalKeyPrefix)
+}
+
+// syncSearch updates the search index for a written or deleted key in
+// the transaction of the write, so the write fails along with it
+func (p *SQLtPlainKV) syncSearch(tx *sql.Tx, typ ChangeType, bucket, key string, value []byte) error {
+	if !p.searched(bucket, key) {
+		return nil
+	}
+	if typ == ChangeDelete {
+		return p.unindexSearch(tx, bucket, key)
+	}
+	return p.indexSearch(tx, bucket, key, value)
+}
+
+// indexSearch indexes the value of a key, reading it when it is nil
+func (p *SQLtPlainKV) indexSearch(tx *sql.Tx, bucket, key string, value []byte) error {
+	var err error
+	if value == nil {
+		if value, err = p.readValue(tx, bucket, key); err != nil {
+			return err
+		}
+	}
+	if err = p.unindexSearch(tx, bucket, key); err != nil || !utf8.Valid(value) || len(value) == 0 {
+		return err
+	}
+	res, err := p.on(tx).Exec(p.rebind(`INSERT INTO `+p.searchKeysTable()+` (Bucket, KeyID) VALUES (?, ?);`), bucket, key)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	_, err = p.on(tx).Exec(p.rebind(`INSERT INTO `+p.searchTable()+` (rowid, Body) VALUES (?, ?);`), id, string(value))
+	return err
+}
+
+// unindexSearch removes a key from the search index
+func (p *SQLtPlainKV) unindexSearch(tx *sql.Tx, bucket, key string) error {
+	var id int64
+	err := p.on(tx).QueryRow(p.rebind(`
+	SELECT ID FROM `+p.searchKeysTable()+`
+	WHERE Bucket=?
+		AND KeyID=?;`), bucket, key).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, err = p.on(tx).Exec(p.rebind(`DELETE FROM `+p.searchTable()+` WHERE rowid=?;`), id); err != nil {
+		return err
+	}
+	_, err = p.on(tx).Exec(p.rebind(`DELETE FROM `+p.searchKeysTable()+` WHERE ID=?;`), id)
+	return err
+}
+
+// Search returns the keys of a bucket whose values match an FTS5 query,
+// best first, at most 100 of them, with a snippet of the matching text.
+// Expired keys are left out. It returns ErrSearchOff unless
+// EnableSearch was called for the bucket
+func (p *SQLtPlainKV) Search(bucket, query string) ([]SearchHit, error) {
+	var err error
+	if bucket == "" {
+		bucket = "default"
+	}
+	hits := make([]SearchHit, 0)
+	if err = p.open(); err != nil {
+		return hits, err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	if !p.searched(bucket, "") {
+		return hits, ErrSearchOff
+	}
+	s := p.searchTable()
+	rows, err := p.conn().Query(p.rebind(`
+	SELECT k.KeyID, snippet(`+s+`, 0, '[', ']', '...', 16), `+s+`.rank FROM `+s+`
+	JOIN `+p.searchKeysTable()+` k ON k.ID = `+s+`.rowid
+	JOIN `+p.defTableName+` t ON t.Bucket = k.Bucket AND t.KeyID = k.KeyID
+	WHERE `+s+` MATCH ?
+		AND k.Bucket=?
+		AND (t.ExpiresAt IS NULL OR t.ExpiresAt > ?)
+	ORDER BY `+s+`.rank
+	LIMIT ?;`), query, bucket, time.Now().UnixNano(), searchHits)
+	if err != nil {
+		return hits, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var h SearchHit
+		if err = rows.Scan(&h.Key, &h.Snippet, &h.Rank); err != nil {
+			return hits, err
+		}
+		hits = append(hits, h)
+	}
+	return hits, rows.Err()
+}
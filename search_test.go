@@ -0,0 +1,115 @@
+package sqltplainkv
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSearch(t *testing.T) {
+	pkv := newTestKV(t)
+	pkv.SetBucket(`articles`)
+	pkv.Set(`go`, []byte(`Go is an open source programming language`))
+	if _, err := pkv.Search(`articles`, `language`); !errors.Is(err, ErrSearchOff) {
+		t.Logf(`Expected ErrSearchOff, got %v`, err)
+		t.Fail()
+	}
+	if err := pkv.EnableSearch(`articles`); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	pkv.SetBucketOptions(`articles`, BucketOptions{Compression: Gzip, Search: true})
+	pkv.Set(`sqlite`, []byte(`SQLite is a small and fast database engine written in C`))
+	pkv.Set(`rust`, []byte(`Rust is a programming language focused on safety`))
+	pkv.Set(`binary`, []byte{0xff, 0xfe, 0x00})
+	pkv.SetWithTTL(`stale`, []byte(`an expired programming note`), time.Millisecond)
+	pkv.SetBucket(`other`)
+	pkv.Set(`lang`, []byte(`a programming language elsewhere`))
+	pkv.SetBucket(`articles`)
+	time.Sleep(5 * time.Millisecond)
+
+	hits, err := pkv.Search(`articles`, `programming language`)
+	if err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	keys := make([]string, len(hits))
+	for i, h := range hits {
+		keys[i] = h.Key
+	}
+	if len(keys) != 2 || !strings.Contains(strings.Join(keys, ` `), `go`) || !strings.Contains(strings.Join(keys, ` `), `rust`) {
+		t.Logf(`Expected go and rust, got %v`, keys)
+		t.Fail()
+	}
+	if len(hits) > 0 && !strings.Contains(hits[0].Snippet, `[language]`) {
+		t.Logf(`Expected a snippet marking the match, got %q`, hits[0].Snippet)
+		t.Fail()
+	}
+
+	// rewrites and deletes keep the index in step
+	pkv.Set(`rust`, []byte(`Rust has a borrow checker`))
+	pkv.Del(`go`)
+	if hits, _ = pkv.Search(`articles`, `language`); len(hits) != 0 {
+		t.Logf(`Expected no hits, got %+v`, hits)
+		t.Fail()
+	}
+	if hits, _ = pkv.Search(`articles`, `borrow`); len(hits) != 1 || hits[0].Key != `rust` {
+		t.Logf(`Expected the rewritten value to be found, got %+v`, hits)
+		t.Fail()
+	}
+	if _, err = pkv.Compact(); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if hits, _ = pkv.Search(`articles`, `engine`); len(hits) != 1 || hits[0].Key != `sqlite` {
+		t.Logf(`Expected the index to survive compaction, got %+v`, hits)
+		t.Fail()
+	}
+
+	if err = pkv.DisableSearch(`articles`); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if _, err = pkv.Search(`articles`, `engine`); !errors.Is(err, ErrSearchOff) {
+		t.Logf(`Expected ErrSearchOff, got %v`, err)
+		t.Fail()
+	}
+}
+
+func TestSearchInTheWrite(t *testing.T) {
+	pkv := newTestKV(t)
+	pkv.SetLimits(Limits{ChunkSize: 16})
+	pkv.SetBucket(`articles`)
+	if err := pkv.EnableSearch(`articles`); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	// streamed values are read back in the write's transaction
+	if err := pkv.SetFrom(`streamed`, strings.NewReader(`a value streamed in several chunks`)); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if hits, err := pkv.Search(`articles`, `streamed`); err != nil || len(hits) != 1 {
+		t.Logf(`Expected the streamed value to be found, got %+v (%v)`, hits, err)
+		t.Fail()
+	}
+	pkv.SoftDel(`streamed`)
+	if hits, _ := pkv.Search(`articles`, `streamed`); len(hits) != 0 {
+		t.Logf(`Expected no hits once deleted, got %+v`, hits)
+		t.Fail()
+	}
+	if err := pkv.Undelete(`streamed`); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if hits, _ := pkv.Search(`articles`, `streamed`); len(hits) != 1 {
+		t.Logf(`Expected the restored value to be found, got %+v`, hits)
+		t.Fail()
+	}
+
+	// the index would hold encrypted values in plain text
+	pkv.SetEncryptionKey(bytes.Repeat([]byte{0x24}, 16))
+	if err := pkv.EnableSearch(`notes`); !errors.Is(err, ErrSearchEncrypted) {
+		t.Logf(`Expected ErrSearchEncrypted, got %v`, err)
+		t.Fail()
+	}
+	if _, err := pkv.Search(`articles`, `streamed`); !errors.Is(err, ErrSearchOff) {
+		t.Logf(`Expected ErrSearchOff while encrypting, got %v`, err)
+		t.Fail()
+	}
+}
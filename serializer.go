@@ -0,0 +1,90 @@
+package sqltplainkv
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+)
+
+// Serializer converts Go values to and from their stored form.
+// Mime returns the mime recorded for values it produces
+type Serializer interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+	Mime() string
+}
+
+var (
+	// JSONSerializer stores values as JSON
+	JSONSerializer Serializer = jsonSerializer{}
+	// GobSerializer stores values using encoding/gob
+	GobSerializer Serializer = gobSerializer{}
+)
+
+type jsonSerializer struct{}
+
+func (jsonSerializer) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonSerializer) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+func (jsonSerializer) Mime() string {
+	return mimeJSON
+}
+
+type gobSerializer struct{}
+
+func (gobSerializer) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobSerializer) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+func (gobSerializer) Mime() string {
+	return mimeGob
+}
+
+// setObject serializes v and stores it with the mime of the serializer
+func (p *SQLtPlainKV) setObject(bucket, key string, v any, s Serializer) error {
+	b, err := s.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return p.setTyped(bucket, key, b, s.Mime())
+}
+
+// getObject retrieves a record and deserializes it into out
+func (p *SQLtPlainKV) getObject(bucket, key string, out any, s Serializer) error {
+	b, err := p.get(bucket, key)
+	if err != nil {
+		return err
+	}
+	if len(b) == 0 {
+		return ErrKeyNotFound
+	}
+	return s.Unmarshal(b, out)
+}
+
+// SetObject serializes v with the serializer and stores it by the key.
+// The mime of the key is set to the mime of the serializer
+func (p *SQLtPlainKV) SetObject(key string, v any, s Serializer) error {
+	if p.currBuckt == "" {
+		p.currBuckt = "default"
+	}
+	return p.setObject(p.currBuckt, key, v, s)
+}
+
+// GetObject retrieves a record using a key and deserializes it into out.
+// It returns ErrKeyNotFound if the key does not exist
+func (p *SQLtPlainKV) GetObject(key string, out any, s Serializer) error {
+	return p.getObject(p.currBuckt, key, out, s)
+}
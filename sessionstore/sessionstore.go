@@ -0,0 +1,144 @@
+// Package sessionstore stores gorilla/sessions sessions in SQLtPlainKV
+package sessionstore
+
+import (
+	"encoding/base32"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+	sqltplainkv "github.com/narsilworks/sqlt-plainkv"
+)
+
+const (
+	// Mime is the MIME type recorded for stored sessions
+	Mime string = `application/x-gorilla-session`
+
+	keyPrefix string = `session_`
+)
+
+// Store is a sessions.Store keeping the values of sessions in the
+// current bucket of a key-value store, under the session ID. The
+// cookie only holds the signed ID. Sessions expire with their MaxAge.
+// The store switches no buckets, so give it a SQLtPlainKV of its own
+// set to the bucket sessions belong in
+type Store struct {
+	Codecs  []securecookie.Codec
+	Options *sessions.Options // default configuration
+
+	kv *sqltplainkv.SQLtPlainKV
+}
+
+var _ sessions.Store = (*Store)(nil)
+
+// New returns a session store over kv. The key pairs sign and
+// optionally encrypt the cookies and stored values, as for
+// sessions.NewCookieStore
+func New(kv *sqltplainkv.SQLtPlainKV, keyPairs ...[]byte) *Store {
+	s := &Store{
+		Codecs: securecookie.CodecsFromPairs(keyPairs...),
+		Options: &sessions.Options{
+			Path:   "/",
+			MaxAge: 86400 * 30,
+		},
+		kv: kv,
+	}
+	s.MaxAge(s.Options.MaxAge)
+	return s
+}
+
+// MaxAge sets the maximum age of the sessions of the store and of
+// their cookies, in seconds. Sessions stored before keep their expiry
+func (s *Store) MaxAge(age int) {
+	s.Options.MaxAge = age
+	for _, c := range s.Codecs {
+		if sc, ok := c.(*securecookie.SecureCookie); ok {
+			sc.MaxAge(age)
+		}
+	}
+}
+
+// Get returns a session for the given name after adding it to the
+// registry of the request. It returns a new session if the session
+// does not exist. See sessions.CookieStore.Get
+func (s *Store) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(s, name)
+}
+
+// New returns a session for the given name without adding it to the
+// registry. The session is loaded if the request carries a valid
+// cookie for it, otherwise a new session is returned
+func (s *Store) New(r *http.Request, name string) (*sessions.Session, error) {
+	session := sessions.NewSession(s, name)
+	opts := *s.Options
+	session.Options = &opts
+	session.IsNew = true
+	var err error
+	if c, errCookie := r.Cookie(name); errCookie == nil {
+		err = securecookie.DecodeMulti(name, c.Value, &session.ID, s.Codecs...)
+		if err == nil {
+			err = s.load(session)
+			if err == nil {
+				session.IsNew = false
+			}
+		}
+	}
+	return session, err
+}
+
+// Save stores the session and sets its cookie on the response.
+// A session with a negative MaxAge is deleted along with its cookie
+func (s *Store) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	if session.Options.MaxAge < 0 {
+		if session.ID != "" {
+			if err := s.kv.Del(keyPrefix + session.ID); err != nil {
+				return err
+			}
+		}
+		http.SetCookie(w, sessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+	if session.ID == "" {
+		session.ID = strings.TrimRight(
+			base32.StdEncoding.EncodeToString(
+				securecookie.GenerateRandomKey(32)), "=")
+	}
+	if err := s.save(session); err != nil {
+		return err
+	}
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, s.Codecs...)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, sessions.NewCookie(session.Name(), encoded, session.Options))
+	return nil
+}
+
+// save stores the encoded values of the session
+func (s *Store) save(session *sessions.Session) error {
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.Values, s.Codecs...)
+	if err != nil {
+		return err
+	}
+	key := keyPrefix + session.ID
+	ttl := time.Duration(session.Options.MaxAge) * time.Second
+	if err = s.kv.SetWithTTL(key, []byte(encoded), ttl); err != nil {
+		return err
+	}
+	return s.kv.SetMime(key, Mime)
+}
+
+// load reads the values of the session.
+// It returns sqltplainkv.ErrKeyNotFound if the session has expired
+func (s *Store) load(session *sessions.Session) error {
+	val, err := s.kv.Get(keyPrefix + session.ID)
+	if err != nil {
+		return err
+	}
+	if len(val) == 0 {
+		return sqltplainkv.ErrKeyNotFound
+	}
+	return securecookie.DecodeMulti(session.Name(), string(val), &session.Values, s.Codecs...)
+}
@@ -0,0 +1,66 @@
+package sessionstore
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/narsilworks/sqlt-plainkv/sqltplainkvtest"
+)
+
+func TestStore(t *testing.T) {
+	kv := sqltplainkvtest.NewTempKV(t)
+	kv.SetBucket(`sessions`)
+	store := New(kv, []byte(`0123456789abcdef0123456789abcdef`))
+
+	req := httptest.NewRequest(http.MethodGet, `/`, nil)
+	session, err := store.Get(req, `app`)
+	if err != nil || !session.IsNew {
+		t.Fatalf(`Expected a new session, got %v`, err)
+	}
+	session.Values[`user`] = `alice`
+	rec := httptest.NewRecorder()
+	if err = session.Save(req, rec); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].MaxAge != 86400*30 {
+		t.Fatalf(`Unexpected cookies %+v`, cookies)
+	}
+	keys, _ := kv.ListKeys(keyPrefix)
+	if len(keys) != 1 || keys[0] != keyPrefix+session.ID {
+		t.Fatalf(`Expected the session to be stored, got %v`, keys)
+	}
+	if mime, _ := kv.GetMime(keys[0]); mime != Mime {
+		t.Logf(`Expected the session mime, got %s`, mime)
+		t.Fail()
+	}
+
+	req = httptest.NewRequest(http.MethodGet, `/`, nil)
+	req.AddCookie(cookies[0])
+	loaded, err := store.New(req, `app`)
+	if err != nil || loaded.IsNew || loaded.Values[`user`] != `alice` {
+		t.Fatalf(`Expected the stored session, got %+v, %v`, loaded.Values, err)
+	}
+
+	loaded.Options.MaxAge = -1
+	rec = httptest.NewRecorder()
+	if err = store.Save(req, rec, loaded); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if keys, _ = kv.ListKeys(keyPrefix); len(keys) != 0 {
+		t.Logf(`Expected the session to be deleted, got %v`, keys)
+		t.Fail()
+	}
+	if loaded, err = store.New(req, `app`); err == nil || !loaded.IsNew {
+		t.Logf(`Expected a deleted session to come back new with an error`)
+		t.Fail()
+	}
+
+	req = httptest.NewRequest(http.MethodGet, `/`, nil)
+	req.AddCookie(&http.Cookie{Name: `app`, Value: `forged`})
+	if loaded, err = store.New(req, `app`); err == nil || !loaded.IsNew {
+		t.Logf(`Expected a forged cookie to be rejected`)
+		t.Fail()
+	}
+}
@@ -0,0 +1,74 @@
+package sqltplainkv
+
+import "time"
+
+// SlowOpInfo describes an operation that took longer than the
+// slow operation threshold
+type SlowOpInfo struct {
+	Op       string // get, set, del or list
+	Bucket   string
+	Key      string // the pattern for list
+	Duration time.Duration
+	Err      error
+}
+
+// slowOps holds the slow operation settings of a store
+type slowOps struct {
+	threshold time.Duration
+	fn        func(SlowOpInfo)
+}
+
+// SetSlowOpThreshold calls fn for every get, set, delete or list
+// that takes threshold or longer. Slow operations are also logged at
+// warn level when the store has a logger, fn may be nil to only log
+// them. A threshold of zero or less turns the hook off. fn runs on
+// the goroutine of the operation, after it completed
+func (p *SQLtPlainKV) SetSlowOpThreshold(threshold time.Duration, fn func(SlowOpInfo)) {
+	if threshold <= 0 {
+		p.slowOps = slowOps{}
+		return
+	}
+	p.slowOps = slowOps{
+		threshold: threshold,
+		fn:        fn,
+	}
+}
+
+// WithSlowOpThreshold calls fn for every operation taking threshold
+// or longer, see SetSlowOpThreshold
+func WithSlowOpThreshold(threshold time.Duration, fn func(SlowOpInfo)) Option {
+	return func(p *SQLtPlainKV) error {
+		p.SetSlowOpThreshold(threshold, fn)
+		return nil
+	}
+}
+
+// observing reports whether operations need to be timed
+func (p *SQLtPlainKV) observing() bool {
+	return p.metrics != nil || p.slowOps.threshold > 0
+}
+
+// observe records an operation on a key that started at start and
+// returned err. Operations on internal buckets are left out
+func (p *SQLtPlainKV) observe(op opKind, bucket, key string, start time.Time, err error) {
+	if isInternalBucket(bucket) {
+		return
+	}
+	d := time.Since(start)
+	if p.metrics != nil {
+		p.metrics.observe(op, d, err)
+	}
+	if p.slowOps.threshold <= 0 || d < p.slowOps.threshold {
+		return
+	}
+	p.warn(`slow operation`, `op`, op.String(), `bucket`, bucket, `key`, key, `duration`, d)
+	if p.slowOps.fn != nil {
+		p.slowOps.fn(SlowOpInfo{
+			Op:       op.String(),
+			Bucket:   bucket,
+			Key:      key,
+			Duration: d,
+			Err:      err,
+		})
+	}
+}
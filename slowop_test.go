@@ -0,0 +1,44 @@
+package sqltplainkv
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSlowOpThreshold(t *testing.T) {
+	pkv := newTestKV(t)
+	var got []SlowOpInfo
+	pkv.SetSlowOpThreshold(time.Nanosecond, func(info SlowOpInfo) {
+		got = append(got, info)
+	})
+	pkv.Set(`a`, []byte(`1`))
+	pkv.SetMime(`a`, `text/plain`)
+	pkv.Get(`a`)
+	pkv.ListKeys(`a`)
+	pkv.Del(`a`)
+
+	want := []string{`set`, `get`, `list`, `del`}
+	if len(got) != len(want) {
+		t.Fatalf(`Expected %d slow operations, got %+v`, len(want), got)
+	}
+	for i, op := range want {
+		if got[i].Op != op || got[i].Bucket != `default` || got[i].Key != `a` || got[i].Duration <= 0 {
+			t.Logf(`Unexpected slow operation %+v, expected %s`, got[i], op)
+			t.Fail()
+		}
+	}
+
+	got = nil
+	pkv.SetSlowOpThreshold(time.Hour, func(info SlowOpInfo) {
+		got = append(got, info)
+	})
+	pkv.Get(`a`)
+	pkv.SetSlowOpThreshold(0, func(info SlowOpInfo) {
+		got = append(got, info)
+	})
+	pkv.Get(`a`)
+	if len(got) != 0 {
+		t.Logf(`Expected no slow operations, got %+v`, got)
+		t.Fail()
+	}
+}
@@ -0,0 +1,274 @@
+package sqltplainkv
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrSnapshotNotFound error = errors.New(`snapshot not found`)
+)
+
+func (p *SQLtPlainKV) snapshotsTable() string {
+	return p.defTableName + `_snapshots`
+}
+
+func (p *SQLtPlainKV) snapshotRowsTable() string {
+	return p.defTableName + `_snapshot_rows`
+}
+
+// hasSnapshotRows reports whether the table of the records copied for
+// snapshots exists
+func (p *SQLtPlainKV) hasSnapshotRows() (bool, error) {
+	var n int
+	err := p.conn().QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?;`, p.snapshotRowsTable()).Scan(&n)
+	return n > 0, err
+}
+
+// ensureSnapshots creates the snapshot tables and the triggers copying
+// the records of a snapshotted bucket before they first change
+func (p *SQLtPlainKV) ensureSnapshots() error {
+	for _, s := range p.snapshotSchema(p.defTableName) {
+		if _, err := p.db.Exec(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// snapshotSchema returns the statements creating the snapshot tables
+// of a key-value table and its triggers
+func (p *SQLtPlainKV) snapshotSchema(table string) []string {
+	snaps, rows := table+`_snapshots`, table+`_snapshot_rows`
+	// a record written after the snapshot was taken is not part of it,
+	// and the first copy made for a snapshot is kept
+	cow := func(event, when string) string {
+		return `CREATE TRIGGER IF NOT EXISTS ` + table + `_snap_` + event + ` AFTER ` + strings.ToUpper(event) + ` ON ` + table + `
+		` + when + `
+		BEGIN
+			INSERT OR IGNORE INTO ` + rows + ` (Name, Bucket, KeyID, Value, ExpiresAt, UpdatedAt)
+			SELECT s.Name, OLD.Bucket, OLD.KeyID, OLD.Value, OLD.ExpiresAt, OLD.UpdatedAt
+			FROM ` + snaps + ` s
+			WHERE s.Bucket = OLD.Bucket
+				AND (OLD.UpdatedAt IS NULL OR OLD.UpdatedAt <= s.TakenAt);
+		END;`
+	}
+	return []string{
+		`CREATE TABLE IF NOT EXISTS ` + snaps + ` (
+			Bucket VARCHAR(` + strconv.Itoa(p.limits.MaxBucketLen) + `),
+			Name VARCHAR(255),
+			TakenAt BIGINT,
+			PRIMARY KEY (Bucket, Name)
+		);`,
+		`CREATE TABLE IF NOT EXISTS ` + rows + ` (
+			Bucket VARCHAR(` + strconv.Itoa(p.limits.MaxBucketLen) + `),
+			Name VARCHAR(255),
+			KeyID VARCHAR(` + strconv.Itoa(p.limits.MaxKeyLen) + `),
+			Value ` + p.dialect.BlobType(p.limits.MaxValueSize) + `,
+			ExpiresAt BIGINT,
+			UpdatedAt BIGINT,
+			PRIMARY KEY (Bucket, Name, KeyID)
+		);`,
+		// reads only move AccessedAt, so they copy nothing
+		cow(`update`, `WHEN OLD.Value IS NOT NEW.Value
+			OR OLD.ExpiresAt IS NOT NEW.ExpiresAt
+			OR OLD.UpdatedAt IS NOT NEW.UpdatedAt`),
+		cow(`delete`, ``),
+	}
+}
+
+// Snapshot captures the state of the current bucket under a name, to
+// compare the bucket against later with DiffSnapshot. Taking it copies
+// nothing: records are copied the first time they change or are
+// deleted afterwards. Values stored in chunks keep only their
+// manifest. Taking a snapshot again under the same name replaces it
+func (p *SQLtPlainKV) Snapshot(name string) error {
+	var err error
+	if p.currBuckt == "" {
+		p.currBuckt = "default"
+	}
+	if p.inTransaction {
+		return ErrInTransaction
+	}
+	if err = p.open(); err != nil {
+		return err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	if err = p.ensureSnapshots(); err != nil {
+		return err
+	}
+	bucket := p.currBuckt
+	return p.inLocalTx(func(tx *sql.Tx) error {
+		if err := p.delSnapshot(tx, bucket, name); err != nil {
+			return err
+		}
+		_, err := p.on(tx).Exec(p.rebind(`
+		INSERT INTO `+p.snapshotsTable()+` (Bucket, Name, TakenAt)
+		VALUES (?, ?, ?);`), bucket, name, time.Now().UnixNano())
+		return err
+	})
+}
+
+// DelSnapshot removes a snapshot of the current bucket and the records
+// copied for it. It returns ErrSnapshotNotFound if there is none
+func (p *SQLtPlainKV) DelSnapshot(name string) error {
+	if p.currBuckt == "" {
+		p.currBuckt = "default"
+	}
+	bucket := p.currBuckt
+	return p.inLocalTx(func(tx *sql.Tx) error {
+		if _, err := p.snapshotTakenAt(tx, bucket, name); err != nil {
+			return err
+		}
+		return p.delSnapshot(tx, bucket, name)
+	})
+}
+
+func (p *SQLtPlainKV) delSnapshot(tx *sql.Tx, bucket, name string) error {
+	for _, table := range []string{p.snapshotsTable(), p.snapshotRowsTable()} {
+		_, err := p.on(tx).Exec(p.rebind(`DELETE FROM `+table+` WHERE Bucket=? AND Name=?;`), bucket, name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// snapshotTakenAt returns the time a snapshot was taken, in UnixNano
+func (p *SQLtPlainKV) snapshotTakenAt(tx *sql.Tx, bucket, name string) (int64, error) {
+	var n int
+	err := p.on(tx).QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?;`, p.snapshotsTable()).Scan(&n)
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return 0, ErrSnapshotNotFound
+	}
+	var at int64
+	err = p.on(tx).QueryRow(p.rebind(`
+	SELECT TakenAt FROM `+p.snapshotsTable()+`
+	WHERE Bucket=?
+		AND Name=?;`), bucket, name).Scan(&at)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrSnapshotNotFound
+	}
+	return at, err
+}
+
+// snapshotRecord is the stored value of a key and when it was written
+type snapshotRecord struct {
+	value     []byte
+	updatedAt sql.NullInt64
+}
+
+// DiffSnapshot lists the keys of the current bucket added, removed or
+// modified since a snapshot was taken, by key. Added and modified keys
+// carry the time they were last written, removed keys a zero time.
+// Stored values are compared, except values stored in chunks, which
+// are modified when they were written again. It returns
+// ErrSnapshotNotFound if there is no such snapshot
+func (p *SQLtPlainKV) DiffSnapshot(name string) ([]Change, error) {
+	var err error
+	if p.currBuckt == "" {
+		p.currBuckt = "default"
+	}
+	if err = p.open(); err != nil {
+		return nil, err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	bucket := p.currBuckt
+	takenAt, err := p.snapshotTakenAt(nil, bucket, name)
+	if err != nil {
+		return nil, err
+	}
+	scan := func(rows *sql.Rows, into map[string]snapshotRecord) error {
+		defer rows.Close()
+		for rows.Next() {
+			var (
+				k   string
+				rec snapshotRecord
+			)
+			if err := rows.Scan(&k, &rec.value, &rec.updatedAt); err != nil {
+				return err
+			}
+			if !strings.HasPrefix(k, internalKeyPrefix) {
+				into[k] = rec
+			}
+		}
+		return rows.Err()
+	}
+
+	// records unchanged since the snapshot have no copy
+	then := make(map[string]snapshotRecord)
+	rows, err := p.conn().Query(p.rebind(`
+	SELECT KeyID, Value, UpdatedAt FROM `+p.snapshotRowsTable()+`
+	WHERE Bucket=?
+		AND Name=?
+		AND (ExpiresAt IS NULL OR ExpiresAt > ?)
+	UNION ALL
+	SELECT t.KeyID, t.Value, t.UpdatedAt FROM `+p.defTableName+` t
+	WHERE t.Bucket=?
+		AND (t.UpdatedAt IS NULL OR t.UpdatedAt <= ?)
+		AND (t.ExpiresAt IS NULL OR t.ExpiresAt > ?)
+		AND NOT EXISTS (SELECT 1 FROM `+p.snapshotRowsTable()+` s
+			WHERE s.Bucket=t.Bucket AND s.Name=? AND s.KeyID=t.KeyID);`),
+		bucket, name, takenAt, bucket, takenAt, takenAt, name)
+	if err != nil {
+		return nil, err
+	}
+	if err = scan(rows, then); err != nil {
+		return nil, err
+	}
+	now := make(map[string]snapshotRecord)
+	rows, err = p.conn().Query(p.rebind(`
+	SELECT KeyID, Value, UpdatedAt FROM `+p.defTableName+`
+	WHERE Bucket=?
+		AND (ExpiresAt IS NULL OR ExpiresAt > ?);`), bucket, time.Now().UnixNano())
+	if err != nil {
+		return nil, err
+	}
+	if err = scan(rows, now); err != nil {
+		return nil, err
+	}
+
+	changes := make([]Change, 0)
+	for k, cur := range now {
+		c := Change{
+			Bucket: bucket,
+			Key:    k,
+			At:     nanoTime(cur.updatedAt),
+		}
+		old, ok := then[k]
+		switch {
+		case !ok:
+			c.Type = ChangeCreate
+		case !bytes.Equal(old.value, cur.value):
+			c.Type = ChangeUpdate
+		default:
+			_, chunked := parseManifest(cur.value)
+			if !chunked || old.updatedAt == cur.updatedAt {
+				continue
+			}
+			c.Type = ChangeUpdate
+		}
+		changes = append(changes, c)
+	}
+	for k := range then {
+		if _, ok := now[k]; !ok {
+			changes = append(changes, Change{Type: ChangeDelete, Bucket: bucket, Key: k})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Key < changes[j].Key
+	})
+	return changes, nil
+}
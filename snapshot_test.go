@@ -0,0 +1,71 @@
+package sqltplainkv
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSnapshotDiff(t *testing.T) {
+	pkv := newTestKV(t)
+	pkv.SetLimits(Limits{ChunkSize: 16})
+	pkv.SetBucket(`config`)
+	big := strings.Repeat(`chunked value `, 10)
+	for k, v := range map[string]string{
+		`same`:    `same`,
+		`changed`: `before`,
+		`removed`: `removed`,
+		`rewrite`: `rewrite`,
+		`big`:     big,
+	} {
+		pkv.Set(k, []byte(v))
+	}
+	if err := pkv.Snapshot(`pre-deploy`); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+
+	pkv.Set(`changed`, []byte(`after`))
+	pkv.Set(`changed`, []byte(`after again`))
+	pkv.Del(`removed`)
+	pkv.Set(`rewrite`, []byte(`rewrite`))
+	pkv.Set(`added`, []byte(`added`))
+	pkv.Set(`big`, []byte(strings.ToUpper(big)))
+	pkv.SetBucket(`other`)
+	pkv.Set(`elsewhere`, []byte(`ignored`))
+	pkv.SetBucket(`config`)
+
+	changes, err := pkv.DiffSnapshot(`pre-deploy`)
+	if err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	got := make([]string, len(changes))
+	for i, c := range changes {
+		got[i] = c.Key + `:` + c.Type.String()
+	}
+	want := `added:create big:update changed:update removed:delete`
+	if strings.Join(got, ` `) != want {
+		t.Logf(`Expected %s, got %v`, want, got)
+		t.Fail()
+	}
+
+	if _, err = pkv.DiffSnapshot(`missing`); !errors.Is(err, ErrSnapshotNotFound) {
+		t.Logf(`Expected ErrSnapshotNotFound, got %v`, err)
+		t.Fail()
+	}
+
+	// taking it again starts over
+	if err = pkv.Snapshot(`pre-deploy`); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if changes, _ = pkv.DiffSnapshot(`pre-deploy`); len(changes) != 0 {
+		t.Logf(`Expected no changes, got %v`, changes)
+		t.Fail()
+	}
+	if err = pkv.DelSnapshot(`pre-deploy`); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if err = pkv.DelSnapshot(`pre-deploy`); !errors.Is(err, ErrSnapshotNotFound) {
+		t.Logf(`Expected ErrSnapshotNotFound, got %v`, err)
+		t.Fail()
+	}
+}
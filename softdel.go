@@ -0,0 +1,176 @@
+package sqltplainkv
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Soft deleted records are moved to an internal bucket, so they are
+// hidden from every read of their bucket until they are undeleted.
+// Their chunks move along with them
+const (
+	deletedBuckt string = `--deleted--`
+	deletedKey   string = `%d:%s:%s` // bucket length, bucket, key
+)
+
+func deletedKeyID(bucket, key string) string {
+	return fmt.Sprintf(deletedKey, len(bucket), bucket, key)
+}
+
+// SoftDel deletes a record with the provided key from the current
+// bucket but keeps it, so Undelete can restore it until PurgeDeleted
+// removes it. A key soft deleted again replaces the record kept for it.
+// It returns ErrKeyNotFound if the key does not exist
+func (p *SQLtPlainKV) SoftDel(key string) error {
+	if p.currBuckt == "" {
+		p.currBuckt = "default"
+	}
+	bucket := p.currBuckt
+	err := p.inLocalTx(func(tx *sql.Tx) error {
+		dk := deletedKeyID(bucket, key)
+		if err := p.dropKeys(tx, "", deletedBuckt, []string{dk}); err != nil {
+			return err
+		}
+		now := time.Now().UnixNano()
+		if err := p.moveRecord(tx, bucket, key, deletedBuckt, dk, sql.NullInt64{Int64: now, Valid: true}); err != nil {
+			return err
+		}
+		return p.recordChange(tx, "", ChangeDelete, bucket, key, nil)
+	})
+	if err != nil {
+		return err
+	}
+	p.warmDel(bucket, key)
+	p.afterDelete(bucket, key)
+	return nil
+}
+
+// Undelete restores a record of the current bucket removed by SoftDel.
+// It returns ErrKeyNotFound if no record of the key is kept and
+// ErrKeyExists if the key was stored again since it was deleted
+func (p *SQLtPlainKV) Undelete(key string) error {
+	if p.currBuckt == "" {
+		p.currBuckt = "default"
+	}
+	bucket := p.currBuckt
+	err := p.inLocalTx(func(tx *sql.Tx) error {
+		ok, err := p.exists(tx, bucket, key)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return ErrKeyExists
+		}
+		// an expired record would collide with the restored one
+		if err = p.dropKeys(tx, "", bucket, []string{key}); err != nil {
+			return err
+		}
+		if err = p.moveRecord(tx, deletedBuckt, deletedKeyID(bucket, key), bucket, key, sql.NullInt64{}); err != nil {
+			return err
+		}
+		return p.recordChange(tx, "", ChangeCreate, bucket, key, nil)
+	})
+	if err != nil {
+		return err
+	}
+	value, err := p.get(bucket, key)
+	if err != nil {
+		return err
+	}
+	p.afterSet(bucket, key, value, ChangeCreate)
+	return nil
+}
+
+// PurgeDeleted removes the records soft deleted at least olderThan ago
+// for good and returns their count. A zero olderThan removes them all
+func (p *SQLtPlainKV) PurgeDeleted(olderThan time.Duration) (int, error) {
+	var n int
+	err := p.inLocalTx(func(tx *sql.Tx) error {
+		rows, err := p.on(tx).Query(p.rebind(`
+		SELECT KeyID FROM `+p.defTableName+`
+		WHERE Bucket=?
+			AND DeletedAt <= ?;`), deletedBuckt, time.Now().Add(-olderThan).UnixNano())
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		keys := make([]string, 0)
+		for rows.Next() {
+			var k string
+			if err = rows.Scan(&k); err != nil {
+				return err
+			}
+			keys = append(keys, k)
+		}
+		if err = rows.Err(); err != nil {
+			return err
+		}
+		rows.Close()
+		if err = p.dropKeys(tx, "", deletedBuckt, keys); err != nil {
+			return err
+		}
+		n = len(keys)
+		return nil
+	})
+	return n, err
+}
+
+// inLocalTx opens the database and runs fn in the current transaction,
+// or in a transaction of its own if there is none, see withTx
+func (p *SQLtPlainKV) inLocalTx(fn func(tx *sql.Tx) error) error {
+	if err := p.open(); err != nil {
+		return err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	return p.withTx(fn)
+}
+
+// moveRecord moves a live record and its chunks to another bucket and
+// key, setting its deletion time. The destination must not exist.
+// It returns ErrKeyNotFound if the record does not exist
+func (p *SQLtPlainKV) moveRecord(tx *sql.Tx, bucket, key, toBucket, toKey string, deletedAt sql.NullInt64) error {
+	t := p.defTableName
+	cols := strings.Join(recordColumns[2:], `, `)
+	res, err := p.on(tx).Exec(p.rebind(`
+	INSERT INTO `+t+` (Bucket, KeyID, `+cols+`, DeletedAt)
+	SELECT ?, ?, `+cols+`, ? FROM `+t+`
+	WHERE Bucket=?
+		AND KeyID=?
+		AND (ExpiresAt IS NULL OR ExpiresAt > ?);`), toBucket, toKey, deletedAt, bucket, key, time.Now().UnixNano())
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrKeyNotFound
+	}
+	st, err := p.stmtOn(tx, stmtDel)
+	if err != nil {
+		return err
+	}
+	if _, err = st.Exec(bucket, key); err != nil {
+		return err
+	}
+	// chunks keep their index and take the prefix of the destination
+	first, last, length := chunkRange(bucket, key, 0)
+	_, err = p.on(tx).Exec(p.rebind(`
+	UPDATE `+t+` SET KeyID = ? || substr(KeyID, ?)
+	WHERE Bucket=?
+		AND KeyID BETWEEN ? AND ?
+		AND length(KeyID) = ?;`), chunkPrefix(toBucket, toKey), utf8.RuneCountInString(chunkPrefix(bucket, key))+1,
+		chunkBuckt, first, last, length)
+	return err
+}
+
+// chunkPrefix returns the start of the keys of the chunks of a key
+func chunkPrefix(bucket, key string) string {
+	return strings.TrimSuffix(chunkKeyID(bucket, key, 0), fmt.Sprintf(`%08d`, 0))
+}
@@ -0,0 +1,127 @@
+package sqltplainkv
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSoftDel(t *testing.T) {
+	pkv := newTestKV(t)
+	pkv.SetLimits(Limits{ChunkSize: 16})
+	pkv.SetBucket(`docs`)
+	big := strings.Repeat(`chunked value `, 10)
+	pkv.Set(`small`, []byte(`kept`))
+	pkv.Set(`big`, []byte(big))
+	pkv.SetMime(`small`, `text/plain`)
+
+	for _, k := range []string{`small`, `big`} {
+		if err := pkv.SoftDel(k); err != nil {
+			t.Fatalf(`%v`, err)
+		}
+	}
+	if v, _ := pkv.Get(`small`); len(v) != 0 {
+		t.Logf(`Expected a soft deleted key to be hidden, got %q`, v)
+		t.Fail()
+	}
+	if keys, _ := pkv.ListKeys(``); len(keys) != 0 {
+		t.Logf(`Expected no keys, got %v`, keys)
+		t.Fail()
+	}
+	if err := pkv.SoftDel(`missing`); !errors.Is(err, ErrKeyNotFound) {
+		t.Logf(`Expected ErrKeyNotFound, got %v`, err)
+		t.Fail()
+	}
+
+	for _, k := range []string{`small`, `big`} {
+		if err := pkv.Undelete(k); err != nil {
+			t.Fatalf(`%v`, err)
+		}
+	}
+	if v, _ := pkv.Get(`small`); string(v) != `kept` {
+		t.Logf(`Expected the restored value, got %q`, v)
+		t.Fail()
+	}
+	if m, ok, _ := pkv.GetMimeStrict(`small`); !ok || m != `text/plain` {
+		t.Logf(`Expected the restored mime, got %q`, m)
+		t.Fail()
+	}
+	if v, _ := pkv.Get(`big`); string(v) != big {
+		t.Logf(`Expected the restored chunked value, got %q`, v)
+		t.Fail()
+	}
+	if err := pkv.Undelete(`missing`); !errors.Is(err, ErrKeyNotFound) {
+		t.Logf(`Expected ErrKeyNotFound, got %v`, err)
+		t.Fail()
+	}
+
+	pkv.SoftDel(`small`)
+	pkv.Set(`small`, []byte(`new`))
+	if err := pkv.Undelete(`small`); !errors.Is(err, ErrKeyExists) {
+		t.Logf(`Expected ErrKeyExists, got %v`, err)
+		t.Fail()
+	}
+}
+
+func TestPurgeDeleted(t *testing.T) {
+	pkv := newTestKV(t)
+	pkv.SetLimits(Limits{ChunkSize: 16})
+	pkv.Set(`a`, []byte(strings.Repeat(`chunked value `, 10)))
+	pkv.Set(`b`, []byte(`b`))
+	pkv.SoftDel(`a`)
+	pkv.SoftDel(`b`)
+
+	if n, err := pkv.PurgeDeleted(time.Hour); err != nil || n != 0 {
+		t.Logf(`Expected nothing old enough to purge, got %d (%v)`, n, err)
+		t.Fail()
+	}
+	if n, err := pkv.PurgeDeleted(0); err != nil || n != 2 {
+		t.Logf(`Expected 2 records purged, got %d (%v)`, n, err)
+		t.Fail()
+	}
+	if err := pkv.Undelete(`a`); !errors.Is(err, ErrKeyNotFound) {
+		t.Logf(`Expected ErrKeyNotFound after purging, got %v`, err)
+		t.Fail()
+	}
+	var n int
+	pkv.db.QueryRow(`SELECT COUNT(*) FROM `+pkv.defTableName+` WHERE Bucket=?;`, chunkBuckt).Scan(&n)
+	if n != 0 {
+		t.Logf(`Expected the chunks to be purged, got %d`, n)
+		t.Fail()
+	}
+}
+
+func TestSoftDelConcurrent(t *testing.T) {
+	pkv := newTestKV(t)
+	for i := 0; i < 40; i++ {
+		if err := pkv.Set(fmt.Sprintf(`key-%d`, i), []byte(`value`)); err != nil {
+			t.Fatalf(`Set failed: %v`, err)
+		}
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, 40)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 5; j++ {
+				if err := pkv.SoftDel(fmt.Sprintf(`key-%d`, i*5+j)); err != nil {
+					errs <- err
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Logf(`Expected concurrent soft deletes to succeed, got %v`, err)
+		t.Fail()
+	}
+	if n, err := pkv.PurgeDeleted(0); err != nil || n != 40 {
+		t.Logf(`Expected 40 records purged, got %d (%v)`, n, err)
+		t.Fail()
+	}
+}
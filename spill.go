@@ -0,0 +1,243 @@
+package sqltplainkv
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultSpillThreshold is the value size from which values are
+// spilled when SetSpillover is given no threshold
+const DefaultSpillThreshold = 1 << 20
+
+// spillGrace is how long SweepSpillover leaves a file alone after it
+// was written or reused, so the write referring to it can commit
+const spillGrace = 10 * time.Minute
+
+var (
+	ErrBlobNotFound error = errors.New(`spilled value file not found`)
+)
+
+// spillover holds the spillover settings of a store
+type spillover struct {
+	dir       string
+	threshold int
+}
+
+// SetSpillover stores values of at least threshold bytes in files
+// under dir instead of the database. The table keeps a pointer to the
+// file, named after the hash of its content, so Get and Set work as
+// before. Files are written after the codec and the built-in
+// transforms are applied, so they are compressed and encrypted like
+// stored values. An empty dir turns spillover off, values already
+// spilled stay readable as long as dir is set
+func (p *SQLtPlainKV) SetSpillover(dir string, threshold int) error {
+	if threshold <= 0 {
+		threshold = DefaultSpillThreshold
+	}
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return err
+		}
+	}
+	p.spill = spillover{
+		dir:       dir,
+		threshold: threshold,
+	}
+	return nil
+}
+
+// WithSpillover stores values of at least threshold bytes in files under dir
+func WithSpillover(dir string, threshold int) Option {
+	return func(p *SQLtPlainKV) error {
+		return p.SetSpillover(dir, threshold)
+	}
+}
+
+// spills reports whether a value of the size is stored in a file
+func (p *SQLtPlainKV) spills(bucket string, size int) bool {
+	return p.spill.dir != "" && size >= p.spill.threshold && !isInternalBucket(bucket)
+}
+
+// spillPath returns the path of the file with the content hash
+func (p *SQLtPlainKV) spillPath(sum []byte) string {
+	name := hex.EncodeToString(sum)
+	return filepath.Join(p.spill.dir, name[:2], name)
+}
+
+// spillValue writes an encoded value to its file and returns the
+// pointer envelope stored in its place. Files with the same content
+// are written once
+func (p *SQLtPlainKV) spillValue(value []byte) ([]byte, error) {
+	sum := sha256.Sum256(value)
+	path := p.spillPath(sum[:])
+	if _, err := os.Stat(path); err == nil {
+		// a reused file is kept from sweeps like a new one
+		now := time.Now()
+		if err = os.Chtimes(path, now, now); err != nil {
+			return nil, err
+		}
+		return envelope(envExternal, sum[:]), nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), `.spill-*`)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	if _, err = f.Write(value); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err = f.Sync(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err = f.Close(); err != nil {
+		return nil, err
+	}
+	if err = os.Rename(f.Name(), path); err != nil {
+		return nil, err
+	}
+	return envelope(envExternal, sum[:]), nil
+}
+
+// readSpilled reads the file a pointer envelope refers to
+func (p *SQLtPlainKV) readSpilled(body []byte) ([]byte, error) {
+	if len(body) != sha256.Size {
+		return nil, ErrCorruptValue
+	}
+	if p.spill.dir == "" {
+		return nil, ErrBlobNotFound
+	}
+	value, err := os.ReadFile(p.spillPath(body))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrBlobNotFound
+		}
+		return nil, err
+	}
+	if sum := sha256.Sum256(value); !bytes.Equal(sum[:], body) {
+		return nil, ErrChecksumMismatch
+	}
+	return value, nil
+}
+
+// spillHead reads the first n bytes of the file a pointer envelope
+// refers to, fewer if the file is shorter
+func (p *SQLtPlainKV) spillHead(body []byte, n int) ([]byte, error) {
+	if len(body) != sha256.Size {
+		return nil, ErrCorruptValue
+	}
+	f, err := os.Open(p.spillPath(body))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrBlobNotFound
+		}
+		return nil, err
+	}
+	defer f.Close()
+	head := make([]byte, n)
+	n, err = io.ReadFull(f, head)
+	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		err = nil
+	}
+	return head[:n], err
+}
+
+// isSpilled reports whether a stored value points to a spillover file
+func isSpilled(value []byte) bool {
+	return len(value) > len(envMagic) &&
+		bytes.HasPrefix(value, []byte(envMagic)) &&
+		value[len(envMagic)] == envExternal
+}
+
+// SweepSpillover removes the files in the spillover directory that no
+// stored value refers to anymore and returns their count. Files are
+// shared by values with the same content, so they are not removed when
+// a value is deleted or replaced. Versions, deleted keys kept in the
+// trash and records copied for snapshots keep their files. Files
+// written or reused in the last ten minutes are kept, as the write
+// referring to them may not have committed yet. The throttle pause is
+// taken after every batch of files removed
+func (p *SQLtPlainKV) SweepSpillover() (int, error) {
+	var err error
+	if p.spill.dir == "" {
+		return 0, nil
+	}
+	if err = p.open(); err != nil {
+		return 0, err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	snapped, err := p.hasSnapshotRows()
+	if err != nil {
+		return 0, err
+	}
+	prefix := []byte(envMagic + string(envExternal))
+	args := []any{len(prefix), prefix}
+	snapRefs := ``
+	if snapped {
+		snapRefs = `
+	UNION
+	SELECT Value FROM ` + p.snapshotRowsTable() + `
+	WHERE substr(Value, 1, ?) = ?`
+		args = append(args, len(prefix), prefix)
+	}
+	rows, err := p.conn().Query(`
+	SELECT Value FROM `+p.defTableName+`
+	WHERE substr(Value, 1, ?) = ?`+snapRefs+`;`, args...)
+	if err != nil {
+		return 0, err
+	}
+	inUse := make(map[string]bool)
+	for rows.Next() {
+		var val []byte
+		if err = rows.Scan(&val); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		inUse[hex.EncodeToString(val[len(prefix):])] = true
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	err = filepath.WalkDir(p.spill.dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		name := d.Name()
+		if strings.HasPrefix(name, `.spill-`) || inUse[name] {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if time.Since(info.ModTime()) < spillGrace {
+			return nil
+		}
+		if err = os.Remove(path); err != nil {
+			return err
+		}
+		removed++
+		if removed%p.throttle.BatchSize == 0 {
+			p.pause()
+		}
+		return nil
+	})
+	return removed, err
+}
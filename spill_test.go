@@ -0,0 +1,116 @@
+package sqltplainkv
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSpillover(t *testing.T) {
+	pkv := newTestKV(t)
+	dir := filepath.Join(t.TempDir(), `blobs`)
+	if err := pkv.SetSpillover(dir, 64); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+
+	big := bytes.Repeat([]byte(`spilled `), 32)
+	pkv.Set(`a`, big)
+	pkv.Set(`b`, big)
+	pkv.Set(`small`, []byte(`kept in the table`))
+
+	if val, err := pkv.Get(`a`); err != nil || !bytes.Equal(val, big) {
+		t.Logf(`Expected the spilled value back, got %d bytes (%v)`, len(val), err)
+		t.Fail()
+	}
+	if info, _ := pkv.InspectStorage(`a`); !info.Spilled {
+		t.Logf(`Expected the value to be reported as spilled`)
+		t.Fail()
+	}
+	if info, _ := pkv.InspectStorage(`small`); info.Spilled {
+		t.Logf(`Expected the small value to stay in the table`)
+		t.Fail()
+	}
+
+	files := func() int {
+		n := 0
+		filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+			if err == nil && !d.IsDir() {
+				n++
+			}
+			return err
+		})
+		return n
+	}
+	if n := files(); n != 1 {
+		t.Logf(`Expected equal values to share a file, got %d files`, n)
+		t.Fail()
+	}
+
+	pkv.Del(`a`)
+	if n, _ := pkv.SweepSpillover(); n != 0 {
+		t.Logf(`Expected the file still referenced by b to be kept, removed %d`, n)
+		t.Fail()
+	}
+	pkv.Set(`b`, []byte(`small now`))
+	if n, _ := pkv.SweepSpillover(); n != 0 {
+		t.Logf(`Expected a file just written to be kept, removed %d`, n)
+		t.Fail()
+	}
+	ageSpilled(t, dir)
+	if n, _ := pkv.SweepSpillover(); n != 1 || files() != 0 {
+		t.Logf(`Expected the unreferenced file to be removed, removed %d`, n)
+		t.Fail()
+	}
+
+	pkv.Set(`c`, big)
+	os.RemoveAll(dir)
+	if _, err := pkv.Get(`c`); !errors.Is(err, ErrBlobNotFound) {
+		t.Logf(`Expected ErrBlobNotFound, got %v`, err)
+		t.Fail()
+	}
+}
+
+func TestSweepSpilloverKeepsSnapshots(t *testing.T) {
+	pkv := newTestKV(t)
+	dir := filepath.Join(t.TempDir(), `blobs`)
+	if err := pkv.SetSpillover(dir, 64); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+
+	pkv.Set(`a`, bytes.Repeat([]byte(`spilled `), 32))
+	if err := pkv.Snapshot(`before`); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	pkv.Del(`a`)
+	if n, err := pkv.SweepSpillover(); n != 0 || err != nil {
+		t.Logf(`Expected the file of the snapshot copy to be kept, removed %d (%v)`, n, err)
+		t.Fail()
+	}
+
+	if err := pkv.DelSnapshot(`before`); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	ageSpilled(t, dir)
+	if n, err := pkv.SweepSpillover(); n != 1 || err != nil {
+		t.Logf(`Expected the unreferenced file to be removed, removed %d (%v)`, n, err)
+		t.Fail()
+	}
+}
+
+// ageSpilled moves the files of a spillover directory past the grace
+// period of sweeps
+func ageSpilled(t *testing.T, dir string) {
+	old := time.Now().Add(-2 * spillGrace)
+	filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			err = os.Chtimes(path, old, old)
+		}
+		if err != nil {
+			t.Fatalf(`%v`, err)
+		}
+		return nil
+	})
+}
@@ -2,13 +2,14 @@
 package sqltplainkv
 
 import (
+	"bytes"
 	"database/sql"
 	"errors"
 	"fmt"
 	"strconv"
 	"time"
 
-	_ "github.com/glebarez/go-sqlite"
+	"golang.org/x/sync/singleflight"
 )
 
 // SQLtPlainKV is a key-value database that uses
@@ -21,113 +22,331 @@ type SQLtPlainKV struct {
 	defTableName  string
 	autoClose     bool
 	inTransaction bool
+	flight        singleflight.Group
+	codec         Codec
+	bucketCodecs  map[string]Codec
+	warm          *warmCache
+	compression   compressionPolicy
+	bucketOpts    map[string]BucketOptions
+	encActive     *encryptionKey
+	encKeys       map[string]*encryptionKey
+	dbKey         []byte
+	limits        Limits
+	pool          poolSettings
+	pragmas       []pragma
+	retryPolicy   RetryPolicy
+	checksums     bool
+	trackAccess   bool
+	maxDBSize     int64
+	metrics       *metrics
+	slowOps       slowOps
+	hooks         []Hooks
+	watch         watchers
+	changeLog     bool
+	audit         bool
+	log           logger
+	throttle      Throttle
+	spill         spillover
+	stmts         stmtCache
+	schemaReady   string // table whose schema has been ensured
+	noMigrate     bool   // use the table as it is, see WithoutMigration
+	queueReady    string // table whose queue table has been ensured
+	idle          idleCloser
+	dialect       Dialect
 }
 
 const (
-	mimeBuckt string = `--mime--`
+	mimeBuckt string = `--mime--` // where mimes were stored before the Mime column
 	tallyKey  string = `_______#tally-%s`
 )
 
 var (
-	ErrBucketIdTooLong error = errors.New(`bucket id too long`)
-	ErrKeyTooLong      error = errors.New(`key too long`)
-	ErrValueTooLong    error = errors.New(`value too large`)
+	ErrBucketIdTooLong   error = errors.New(`bucket id too long`)
+	ErrKeyTooLong        error = errors.New(`key too long`)
+	ErrValueTooLong      error = errors.New(`value too large`)
+	ErrKeyNotFound       error = errors.New(`key not found`)
+	ErrInTransaction     error = errors.New(`not allowed inside a transaction`)
+	ErrAutoCloseInMemory error = errors.New(`auto-close would discard an in-memory database`)
 )
 
 // NewSQLtPlainKV creates a new SQLtPlainKV object
-// This is the recommended method
+// This is the recommended method.
+// With autoClose, the database is closed once it has been idle
+// for the idle timeout, see SetIdleTimeout
 func NewSQLtPlainKV(dsn string, autoClose bool) *SQLtPlainKV {
 	return &SQLtPlainKV{
 		DSN:          dsn,
 		currBuckt:    `default`,
 		autoClose:    autoClose,
 		defTableName: `KeyValueTBL`,
+		limits:       DefaultLimits,
+		pool:         defaultPool,
+		retryPolicy:  DefaultRetryPolicy,
+		throttle:     DefaultThrottle,
+		idle:         idleCloser{timeout: DefaultIdleTimeout},
+		dialect:      SQLite,
 	}
 }
 
-func (p *SQLtPlainKV) get(bucket, key string) ([]byte, error) {
-
-	var (
-		err error
-		val []byte
-	)
-
+func (p *SQLtPlainKV) get(bucket, key string) (val []byte, err error) {
 	val = make([]byte, 0)
-	if err = p.Open(); err != nil {
+	if bucket == "" {
+		bucket = "default"
+	}
+	if p.observing() {
+		defer func(start time.Time) {
+			p.observe(opGet, bucket, key, start, err)
+		}(time.Now())
+	}
+	if wv, ok := p.warmGet(bucket, key); ok {
+		if _, chunked := parseManifest(wv); !chunked {
+			return p.decodeValue(bucket, wv)
+		}
+	}
+	if err = p.open(); err != nil {
 		return val, err
 	}
 	if p.autoClose {
-		defer p.Close()
-	}
-	if bucket == "" {
-		bucket = "default"
+		defer p.closeWhenIdle()
 	}
 
-	sqlstr := `
-	SELECT Value FROM ` + p.defTableName + `
-	WHERE Bucket=?
-		AND KeyID=?;`
-	if p.inTransaction {
-		err = p.tx.QueryRow(sqlstr, bucket, key).Scan(&val)
-	} else {
-		err = p.db.QueryRow(sqlstr, bucket, key).Scan(&val)
+	if val, err = p.readValue(nil, bucket, key); err != nil {
+		return make([]byte, 0), err
 	}
+	return val, nil
+}
+
+// readValue reads and decodes the value of a key in the transaction,
+// empty if the key does not exist
+func (p *SQLtPlainKV) readValue(tx *sql.Tx, bucket, key string) ([]byte, error) {
+	val, err := p.getRow(tx, bucket, key)
 	if err != nil {
 		if !errors.Is(err, sql.ErrNoRows) {
-			return val, err
+			return nil, err
 		}
+		return make([]byte, 0), nil
 	}
-	return val, nil
+	if m, ok := parseManifest(val); ok {
+		var buf bytes.Buffer
+		buf.Grow(int(m.size))
+		if err = p.readChunks(tx, bucket, key, m, &buf); err != nil {
+			return nil, err
+		}
+		return p.decodeChunked(bucket, m, buf.Bytes())
+	}
+	return p.decodeValue(bucket, val)
 }
 
 // Set creates or updates the record by the value
 func (p *SQLtPlainKV) set(bucket, key string, value []byte) error {
-	var err error
+	return p.setExpiring(bucket, key, value, time.Time{})
+}
+
+// setExpiring creates or updates the record by the value.
+// A zero expiry stores the record without expiration
+func (p *SQLtPlainKV) setExpiring(bucket, key string, value []byte, expiry time.Time) error {
+	return p.store(bucket, key, value, expiry, storeOptions{})
+}
+
+// setTyped creates or updates the record by the value and sets its
+// mime in the same transaction. An empty mime removes it
+func (p *SQLtPlainKV) setTyped(bucket, key string, value []byte, mime string) error {
+	return p.store(bucket, key, value, time.Time{}, storeOptions{mime: sql.NullString{String: mime, Valid: true}})
+}
 
-	if err = p.Open(); err != nil {
+// storeOptions are the settings of a single write made by store
+type storeOptions struct {
+	principal string                 // who makes the write, for the audit log
+	mime      sql.NullString         // set along with the value if valid, kept otherwise
+	skip      skipped                // built-in transforms left out
+	also      func(tx *sql.Tx) error // more writes made in the transaction of the value
+	keepTTL   bool                   // keep the expiry of the key, ignoring expiry and the bucket ttl
+}
+
+// store creates or updates the record by the value with the settings
+// of opts, in a single transaction
+func (p *SQLtPlainKV) store(bucket, key string, value []byte, expiry time.Time, opts storeOptions) (err error) {
+	var exp sql.NullInt64
+	if p.observing() {
+		defer func(start time.Time) {
+			p.observe(opSet, bucket, key, start, err)
+		}(time.Now())
+	}
+	defer func() {
+		if err != nil {
+			p.warn(`set failed`, `bucket`, bucket, `key`, key, `error`, err)
+		}
+	}()
+
+	if err = p.open(); err != nil {
 		return err
 	}
 	if p.autoClose {
-		defer p.Close()
+		defer p.closeWhenIdle()
 	}
-	if len(bucket) > 50 {
+	if len(bucket) > p.limits.MaxBucketLen {
 		return ErrBucketIdTooLong
 	}
-	if len(key) > 300 {
+	if len(key) > p.limits.MaxKeyLen {
 		return ErrKeyTooLong
 	}
-	if len(value) > 16777215 {
-		return ErrValueTooLong
+	if err = p.beforeSet(bucket, key, value); err != nil {
+		return err
 	}
-	sqlstr := `
-	INSERT INTO ` + p.defTableName + ` (Bucket, KeyID, Value) VALUES (?, ?, ?)
-	ON CONFLICT(Bucket,KeyID) DO UPDATE SET Value=excluded.Value;`
-	if p.inTransaction {
-		_, err = p.tx.Exec(sqlstr, bucket, key, value)
-	} else {
-		_, err = p.db.Exec(sqlstr, bucket, key, value)
+	if expiry = p.bucketExpiry(bucket, key, expiry); !expiry.IsZero() {
+		exp = sql.NullInt64{Int64: expiry.UnixNano(), Valid: true}
 	}
+	var (
+		typ     ChangeType
+		evicted []string
+	)
+	err = p.retry(func() error {
+		return p.withTx(func(tx *sql.Tx) error {
+			var err error
+			if typ, err = p.changeType(tx, bucket, key); err != nil {
+				return err
+			}
+			if err = p.saveVersion(tx, bucket, key); err != nil {
+				return err
+			}
+			if opts.keepTTL {
+				if exp, err = p.expiresAt(tx, bucket, key); err != nil {
+					return err
+				}
+			}
+			if evicted, err = p.writeValue(tx, bucket, key, value, exp, opts.skip); err != nil {
+				return err
+			}
+			if opts.mime.Valid {
+				if _, err = p.setMimeOn(tx, bucket, key, opts.mime.String); err != nil {
+					return err
+				}
+			}
+			if opts.also != nil {
+				if err = opts.also(tx); err != nil {
+					return err
+				}
+			}
+			return p.recordChange(tx, opts.principal, typ, bucket, key, value)
+		})
+	})
 	if err != nil {
 		return err
 	}
+	p.warmDel(bucket, key)
+	for _, k := range evicted {
+		p.afterDelete(bucket, k)
+	}
+	p.afterSet(bucket, key, value, typ)
+	return p.enforceMaxKeys(bucket)
+}
 
-	return nil
+// writeValue encodes a value and stores it under a key in the
+// transaction, in chunks if it is too large for a single row. It
+// returns the keys evicted to make room for it
+func (p *SQLtPlainKV) writeValue(tx *sql.Tx, bucket, key string, value []byte, exp sql.NullInt64, skip skipped) ([]string, error) {
+	var err error
+	spill := p.spills(bucket, len(value))
+	hash := p.contentHash(bucket, value)
+	if len(value) > p.limits.ChunkSize && !isInternalBucket(bucket) && !spill {
+		return p.setChunked(tx, bucket, key, value, exp, hash, skip)
+	}
+	if p.dedups(bucket) && !spill && len(value) > 0 && skip == (skipped{}) {
+		value, err = p.dedupValue(tx, bucket, value)
+	} else {
+		value, err = p.encodeValue(bucket, value, skip)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(value) > p.limits.MaxValueSize {
+		return nil, ErrValueTooLong
+	}
+	if spill {
+		if value, err = p.spillValue(value); err != nil {
+			return nil, err
+		}
+	}
+	evicted, err := p.checkQuota(tx, bucket, key, int64(len(value)))
+	if err != nil {
+		return nil, err
+	}
+	more, err := p.checkDatabaseSize(tx, bucket, key, int64(len(value)))
+	if err != nil {
+		return nil, err
+	}
+	st, err := p.stmtOn(tx, stmtSet)
+	if err != nil {
+		return nil, err
+	}
+	delChunks, err := p.stmtOn(tx, stmtDelChunks)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = delChunks.Exec(chunkRangeArgs(bucket, key)...); err != nil {
+		return nil, err
+	}
+	now := time.Now().UnixNano()
+	if _, err = st.Exec(bucket, key, value, exp, p.checksum(value), now, now, hash); err != nil {
+		return nil, err
+	}
+	return append(evicted, more...), nil
 }
 
 // Get retrieves a record using a key
 func (p *SQLtPlainKV) Get(key string) ([]byte, error) {
-	return p.get(p.currBuckt, key)
+	val, err := p.get(p.currBuckt, key)
+	if err == nil && len(val) > 0 {
+		err = p.recordAccess(p.currBuckt, key)
+	}
+	return val, err
 }
 
-// Get retrieves a record using a key
+// GetMime gets the mime of the value stored under the key in the
+// current bucket
 func (p *SQLtPlainKV) GetMime(key string) (string, error) {
+	if p.currBuckt == "" {
+		p.currBuckt = "default"
+	}
+	mime, err := p.getMime(p.currBuckt, key)
+	if err != nil || mime == "" {
+		return p.bucketDefaultMime(p.currBuckt), err
+	}
+	return mime, nil
+}
 
-	val, err := p.get(mimeBuckt, key)
-	if err != nil || len(val) == 0 {
-		return "text/html", err
+// getMime returns the mime of a key in the bucket, empty if the key
+// has none or does not exist
+func (p *SQLtPlainKV) getMime(bucket, key string) (string, error) {
+	mime, err := p.lookupMime(bucket, key)
+	if errors.Is(err, ErrKeyNotFound) {
+		return "", nil
 	}
+	return mime, err
+}
 
-	return string(val), nil
+// lookupMime returns the mime of a key in the bucket, empty if the key
+// has none. It returns ErrKeyNotFound if the key does not exist
+func (p *SQLtPlainKV) lookupMime(bucket, key string) (string, error) {
+	if we, ok := p.warmLookup(bucket, key); ok {
+		return we.mime, nil
+	}
+	if err := p.open(); err != nil {
+		return "", err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	st, err := p.stmt(stmtGetMime)
+	if err != nil {
+		return "", err
+	}
+	var mime sql.NullString
+	err = st.QueryRow(bucket, key, time.Now().UnixNano()).Scan(&mime)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrKeyNotFound
+	}
+	return mime.String, err
 }
 
 // Set creates or updates the record by the value
@@ -141,14 +360,71 @@ func (p *SQLtPlainKV) Set(key string, value []byte) error {
 	return nil
 }
 
-// SetMime sets the mime of the value stored
+// SetWithTTL creates or updates the record by the value.
+// The record expires after the ttl has elapsed.
+// A ttl of zero or less stores the record with the ttl of the bucket,
+// see SetBucketTTL, or without expiration
+func (p *SQLtPlainKV) SetWithTTL(key string, value []byte, ttl time.Duration) error {
+	if p.currBuckt == "" {
+		p.currBuckt = "default"
+	}
+	var expiry time.Time
+	if ttl > 0 {
+		expiry = time.Now().Add(ttl)
+	}
+	if err := p.setExpiring(p.currBuckt, key, value, expiry); err != nil {
+		return err
+	}
+	return nil
+}
+
+// SetMime sets the mime of the value stored under the key in the
+// current bucket. It returns ErrKeyNotFound if the key does not exist,
+// the mime is deleted along with the value. An empty mime removes it
 func (p *SQLtPlainKV) SetMime(key string, mime string) error {
-	if err := p.set(mimeBuckt, key, []byte(mime)); err != nil {
+	if p.currBuckt == "" {
+		p.currBuckt = "default"
+	}
+	return p.setMime(p.currBuckt, key, mime)
+}
+
+func (p *SQLtPlainKV) setMime(bucket, key, mime string) error {
+	if err := p.open(); err != nil {
+		return err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	var n int64
+	err := p.retry(func() error {
+		var err error
+		n, err = p.setMimeOn(nil, bucket, key, mime)
+		return err
+	})
+	if err != nil {
 		return err
 	}
+	if n == 0 {
+		return ErrKeyNotFound
+	}
+	p.warmDel(bucket, key)
 	return nil
 }
 
+// setMimeOn sets the mime of a record in the transaction and returns
+// the number of records updated
+func (p *SQLtPlainKV) setMimeOn(tx *sql.Tx, bucket, key, mime string) (int64, error) {
+	st, err := p.stmtOn(tx, stmtSetMime)
+	if err != nil {
+		return 0, err
+	}
+	res, err := st.Exec(sql.NullString{String: mime, Valid: mime != ""}, bucket, key, time.Now().UnixNano())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
 // SetBucket sets the current bucket.
 // If set, all succeeding values will be retrieved and stored by the bucket name
 func (p *SQLtPlainKV) SetBucket(bucket string) {
@@ -157,62 +433,81 @@ func (p *SQLtPlainKV) SetBucket(bucket string) {
 
 // Del deletes a record with the provided key
 func (p *SQLtPlainKV) Del(key string) error {
-	var err error
-	if err = p.Open(); err != nil {
-		return err
-	}
-	if p.autoClose {
-		defer p.Close()
-	}
 	if p.currBuckt == "" {
 		p.currBuckt = "default"
 	}
-	sqlstr := `DELETE FROM ` + p.defTableName + ` WHERE Bucket = ? AND KeyID = ?;`
+	return p.del(p.currBuckt, key)
+}
 
-	if p.inTransaction {
-		if _, err = p.tx.Exec(sqlstr, p.currBuckt, key); err != nil {
-			return err
-		}
-		if _, err = p.tx.Exec(sqlstr, mimeBuckt, key); err != nil {
-			return err
-		}
-		return nil
-	}
+func (p *SQLtPlainKV) del(bucket, key string) error {
+	return p.delAs("", bucket, key)
+}
 
-	if _, err = p.db.Exec(sqlstr, p.currBuckt, key); err != nil {
+// delAs deletes a record on behalf of the principal
+func (p *SQLtPlainKV) delAs(principal, bucket, key string) (err error) {
+	if p.observing() {
+		defer func(start time.Time) {
+			p.observe(opDel, bucket, key, start, err)
+		}(time.Now())
+	}
+	defer func() {
+		if err != nil {
+			p.warn(`delete failed`, `bucket`, bucket, `key`, key, `error`, err)
+		}
+	}()
+	if err = p.open(); err != nil {
 		return err
 	}
-	if _, err = p.db.Exec(sqlstr, mimeBuckt, key); err != nil {
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	p.warmDel(bucket, key)
+	err = p.retry(func() error {
+		return p.withTx(func(tx *sql.Tx) error {
+			if err := p.saveVersion(tx, bucket, key); err != nil {
+				return err
+			}
+			return p.dropKeys(tx, principal, bucket, []string{key})
+		})
+	})
+	if err != nil {
 		return err
 	}
+	p.afterDelete(bucket, key)
 	return nil
 }
 
 // ListKeys lists all keys containing the current pattern
 func (p *SQLtPlainKV) ListKeys(pattern string) ([]string, error) {
+	if p.currBuckt == "" {
+		p.currBuckt = "default"
+	}
+	return p.listKeys(p.currBuckt, pattern)
+}
+
+func (p *SQLtPlainKV) listKeys(bucket, pattern string) (val []string, err error) {
 	var (
-		err error
-		val []string
 		k   string
 		sqr *sql.Rows
 	)
 
 	val = make([]string, 0)
-	if err = p.Open(); err != nil {
+	if p.observing() {
+		defer func(start time.Time) {
+			p.observe(opList, bucket, pattern, start, err)
+		}(time.Now())
+	}
+	if err = p.open(); err != nil {
 		return val, err
 	}
 	if p.autoClose {
-		defer p.Close()
-	}
-	if p.currBuckt == "" {
-		p.currBuckt = "default"
+		defer p.closeWhenIdle()
 	}
-	sqlstr := `SELECT KeyID FROM ` + p.defTableName + ` WHERE Bucket=? AND KeyID LIKE ?;`
-	if p.inTransaction {
-		sqr, err = p.tx.Query(sqlstr, p.currBuckt, pattern+"%")
-	} else {
-		sqr, err = p.db.Query(sqlstr, p.currBuckt, pattern+"%")
+	st, err := p.stmt(stmtList)
+	if err != nil {
+		return val, err
 	}
+	sqr, err = st.Query(bucket, pattern+"%", time.Now().UnixNano())
 	if err != nil {
 		if !errors.Is(err, sql.ErrNoRows) {
 			return val, err
@@ -295,45 +590,183 @@ func (p *SQLtPlainKV) TallyReset(key string) error {
 	return nil
 }
 
-// Open a connection to a MySQL database database
+// Open a connection to a MySQL database database. An auto-closing
+// store closes it again once idle, as after any other operation
 func (p *SQLtPlainKV) Open() error {
+	if err := p.open(); err != nil {
+		return err
+	}
+	if p.autoClose {
+		p.closeWhenIdle()
+	}
+	return nil
+}
+
+// open opens the database if it is not open. An auto-closing store
+// counts the caller as a running operation until it calls closeWhenIdle
+func (p *SQLtPlainKV) open() (err error) {
+	if p.autoClose {
+		p.acquire()
+		defer func() {
+			if err != nil {
+				p.closeWhenIdle()
+			}
+		}()
+	}
 	if p.db != nil {
 		return nil
 	}
 	p.inTransaction = false
-	var err error
-	p.db, err = sql.Open("sqlite", p.DSN)
+	dsn, err := p.dataSource()
+	if err != nil {
+		return err
+	}
+	p.debug(`opening database`, `driver`, driverName, `table`, p.defTableName)
+	defer func() {
+		if err != nil {
+			p.warn(`opening database failed`, `error`, err)
+		}
+	}()
+	p.db, err = sql.Open(driverName, dsn)
 	if err != nil {
 		return err
 	}
 
 	// See "Important settings" section.
-	p.db.SetConnMaxLifetime(time.Minute * 3)
-	p.db.SetMaxOpenConns(10)
-	p.db.SetMaxIdleConns(10)
-
-	// Check if table exists and create it if not
-	sql :=
-		`CREATE TABLE IF NOT EXISTS ` + p.defTableName + ` (
-			Bucket VARCHAR(50),
-			KeyID VARCHAR(300),
-			Value MEDIUMBLOB,
+	p.db.SetConnMaxLifetime(p.pool.maxLifetime)
+	p.db.SetMaxOpenConns(p.pool.maxOpen)
+	p.db.SetMaxIdleConns(p.pool.maxIdle)
+
+	// Create the table once, not on every reopen
+	if p.schemaReady != p.defTableName && !p.noMigrate {
+		if err = p.ensureSchema(); err != nil {
+			return err
+		}
+	}
+	if err = p.loadBucketOptions(); err != nil {
+		return err
+	}
+	if err = p.prepareStatements(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// tableSchema returns the statement creating the key-value table
+func (p *SQLtPlainKV) tableSchema(tableName string) string {
+	return `CREATE TABLE IF NOT EXISTS ` + tableName + ` (
+			Bucket VARCHAR(` + strconv.Itoa(p.limits.MaxBucketLen) + `),
+			KeyID VARCHAR(` + strconv.Itoa(p.limits.MaxKeyLen) + `),
+			Value ` + p.dialect.BlobType(p.limits.MaxValueSize) + `,
+			ExpiresAt BIGINT,
+			Checksum BIGINT,
+			CreatedAt BIGINT,
+			UpdatedAt BIGINT,
+			AccessedAt BIGINT,
+			Hash VARCHAR(64),
+			Mime VARCHAR(255),
+			DeletedAt BIGINT,
+			ExpirySetAt BIGINT,
 			PRIMARY KEY (Bucket, KeyID)
 		);`
-	_, err = p.db.Exec(sql)
+}
+
+// migrate adds the columns introduced after the initial
+// schema to tables created by older versions
+func (p *SQLtPlainKV) migrate() error {
+	cols := [][2]string{
+		{`ExpiresAt`, `BIGINT`},
+		{`Checksum`, `BIGINT`},
+		{`CreatedAt`, `BIGINT`},
+		{`UpdatedAt`, `BIGINT`},
+		{`AccessedAt`, `BIGINT`},
+		{`Hash`, `VARCHAR(64)`},
+		{`Mime`, `VARCHAR(255)`},
+		{`DeletedAt`, `BIGINT`},
+		{`ExpirySetAt`, `BIGINT`},
+	}
+	have := make(map[string]bool)
+	rows, err := p.db.Query(`SELECT name FROM pragma_table_info(?);`, p.defTableName)
 	if err != nil {
 		return err
 	}
-	return nil
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return err
+		}
+		have[name] = true
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+	for _, col := range cols {
+		if have[col[0]] {
+			continue
+		}
+		p.debug(`adding column`, `table`, p.defTableName, `column`, col[0])
+		if _, err = p.db.Exec(`ALTER TABLE ` + p.defTableName + ` ADD COLUMN ` + col[0] + ` ` + col[1] + `;`); err != nil {
+			return err
+		}
+	}
+	return p.migrateMimes()
+}
+
+// migrateMimes moves the mimes kept in the mime bucket by versions
+// before the Mime column into the column. Those mimes were shared by
+// the key in every bucket, so every record of the key gets its mime
+func (p *SQLtPlainKV) migrateMimes() error {
+	t := p.defTableName
+	var n int
+	if err := p.db.QueryRow(`SELECT COUNT(*) FROM `+t+` WHERE Bucket=?;`, mimeBuckt).Scan(&n); err != nil {
+		return err
+	}
+	if n == 0 {
+		return nil
+	}
+	p.debug(`moving mimes to the Mime column`, `table`, t, `mimes`, n)
+	tx, err := p.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec(`
+	UPDATE `+t+` SET Mime = (SELECT CAST(m.Value AS TEXT) FROM `+t+` m
+		WHERE m.Bucket=? AND m.KeyID=`+t+`.KeyID)
+	WHERE Mime IS NULL
+		AND NOT (length(Bucket) > 4 AND Bucket LIKE '--%--')
+		AND KeyID IN (SELECT KeyID FROM `+t+` WHERE Bucket=?);`, mimeBuckt, mimeBuckt)
+	if err != nil {
+		return err
+	}
+	if _, err = tx.Exec(`DELETE FROM `+t+` WHERE Bucket=?;`, mimeBuckt); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 // Begin a transaction
+// With auto-close, the database stays open until the transaction ends
 func (p *SQLtPlainKV) Begin() error {
-	var err error
-	if p.tx, err = p.db.Begin(); err != nil {
+	if err := p.open(); err != nil {
+		return err
+	}
+	err := p.retry(func() error {
+		var err error
+		p.tx, err = p.db.Begin()
+		return err
+	})
+	if err != nil {
+		p.warn(`beginning transaction failed`, `error`, err)
+		if p.autoClose {
+			p.closeWhenIdle()
+		}
 		return err
 	}
 	p.inTransaction = true
+	p.debug(`transaction begun`)
 	return nil
 }
 
@@ -342,10 +775,14 @@ func (p *SQLtPlainKV) Commit() error {
 	if p.tx == nil {
 		return nil // silently commit
 	}
-	if err := p.tx.Commit(); err != nil {
+	// the transaction is over even if the commit failed
+	err := p.tx.Commit()
+	p.endTx()
+	if err != nil {
+		p.warn(`committing transaction failed`, `error`, err)
 		return err
 	}
-	p.inTransaction = false
+	p.debug(`transaction committed`)
 	return nil
 }
 
@@ -354,25 +791,48 @@ func (p *SQLtPlainKV) Rollback() error {
 	if p.tx == nil {
 		return nil // silently rollback
 	}
-	if err := p.tx.Rollback(); err != nil {
+	err := p.tx.Rollback()
+	p.endTx()
+	if err != nil {
+		p.warn(`rolling back transaction failed`, `error`, err)
 		return err
 	}
-	p.inTransaction = false
+	p.debug(`transaction rolled back`)
 	return nil
 }
 
+// endTx forgets a finished transaction and ends the operation Begin
+// started, so an auto-closing store can close once idle
+func (p *SQLtPlainKV) endTx() {
+	p.releaseTxStatements()
+	p.tx = nil
+	p.inTransaction = false
+	if p.autoClose {
+		p.closeWhenIdle()
+	}
+}
+
 // Close closes the database
 func (p *SQLtPlainKV) Close() error {
+	p.stopIdle()
+	p.idle.mu.Lock()
+	defer p.idle.mu.Unlock()
+	return p.closeDB()
+}
+
+func (p *SQLtPlainKV) closeDB() error {
 	if p.tx != nil {
 		p.tx = nil
 	}
 	if p.db == nil {
 		return nil
 	}
+	p.closeStatements()
 	if err := p.db.Close(); err != nil {
 		return err
 	}
 	p.db = nil
+	p.debug(`database closed`)
 	return nil
 }
 
@@ -380,3 +840,53 @@ func (p *SQLtPlainKV) Close() error {
 func (p *SQLtPlainKV) SetTableName(tableName string) {
 	p.defTableName = tableName
 }
+
+// querier is implemented by both *sql.DB and *sql.Tx
+type querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// conn returns the current transaction if one is active,
+// otherwise the database
+func (p *SQLtPlainKV) conn() querier {
+	if p.inTransaction {
+		return p.tx
+	}
+	return p.db
+}
+
+// on returns the transaction of a write, or the current connection
+// for a nil transaction, see withTx
+func (p *SQLtPlainKV) on(tx *sql.Tx) querier {
+	if tx != nil {
+		return tx
+	}
+	return p.conn()
+}
+
+// withTx runs fn in the current transaction, passing it a nil
+// transaction, or else in a transaction of its own begun on the
+// database. Writes made of several statements use it rather than
+// Begin, which would share the transaction with concurrent callers.
+// The database must be open
+func (p *SQLtPlainKV) withTx(fn func(tx *sql.Tx) error) error {
+	if p.inTransaction {
+		return fn(nil)
+	}
+	tx, err := p.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	// take the write lock before fn reads, so two writes that read
+	// first wait for each other instead of failing as a deadlock
+	if _, err = tx.Exec(`UPDATE ` + p.defTableName + ` SET Bucket = Bucket WHERE 0 = 1;`); err != nil {
+		return err
+	}
+	if err = fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
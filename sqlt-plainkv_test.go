@@ -1,13 +1,14 @@
 package sqltplainkv
 
 import (
+	"path/filepath"
 	"strconv"
 	"testing"
 )
 
 func TestOpen(t *testing.T) {
 
-	pkv := NewSQLtPlainKV("local.dat", false)
+	pkv := NewSQLtPlainKV(filepath.Join(t.TempDir(), "local.dat"), false)
 
 	if err := pkv.Open(); err != nil {
 		t.Logf(`%s`, err)
@@ -44,7 +45,7 @@ func TestOpen(t *testing.T) {
 
 func TestOpenMime(t *testing.T) {
 
-	pkv := NewSQLtPlainKV("local.dat?_pragma=journal_mode(WAL)", false)
+	pkv := NewSQLtPlainKV(filepath.Join(t.TempDir(), "local.dat")+"?_pragma=journal_mode(WAL)", false)
 	if err := pkv.Open(); err != nil {
 		t.Logf(`%s`, err)
 		t.Fail()
@@ -81,7 +82,7 @@ func TestOpenMime(t *testing.T) {
 
 func TestOpenListKeys(t *testing.T) {
 
-	pkv := NewSQLtPlainKV("local.dat?_pragma=journal_mode(WAL)", false)
+	pkv := NewSQLtPlainKV(filepath.Join(t.TempDir(), "local.dat")+"?_pragma=journal_mode(WAL)", false)
 	if err := pkv.Open(); err != nil {
 		t.Logf(`%s`, err)
 		t.Fail()
@@ -112,7 +113,7 @@ func TestOpenListKeys(t *testing.T) {
 }
 
 func TestIncrement(t *testing.T) {
-	pkv := NewSQLtPlainKV("local.dat", false)
+	pkv := NewSQLtPlainKV(filepath.Join(t.TempDir(), "local.dat"), false)
 	if err := pkv.Open(); err != nil {
 		t.Logf(`%s`, err)
 		t.Fail()
@@ -144,7 +145,7 @@ func TestIncrement(t *testing.T) {
 }
 
 func TestDecrement(t *testing.T) {
-	pkv := NewSQLtPlainKV("local.dat", false)
+	pkv := NewSQLtPlainKV(filepath.Join(t.TempDir(), "local.dat"), false)
 	if err := pkv.Open(); err != nil {
 		t.Logf(`%s`, err)
 		t.Fail()
@@ -176,7 +177,7 @@ func TestDecrement(t *testing.T) {
 
 func BenchmarkPerformance(b *testing.B) {
 
-	pkv := NewSQLtPlainKV("local.dat?_pragma=journal_mode(WAL)", false)
+	pkv := NewSQLtPlainKV(filepath.Join(b.TempDir(), "local.dat")+"?_pragma=journal_mode(WAL)", false)
 	if err := pkv.Open(); err != nil {
 		b.Logf(`%s`, err)
 		b.Fail()
@@ -195,3 +196,16 @@ func BenchmarkPerformance(b *testing.B) {
 	pkv.Commit()
 	pkv.Close()
 }
+
+// newTestKV opens a store in a temporary directory that
+// is removed when the test finishes
+func newTestKV(t *testing.T) *SQLtPlainKV {
+	pkv := NewSQLtPlainKV(filepath.Join(t.TempDir(), "test.dat"), false)
+	if err := pkv.Open(); err != nil {
+		t.Fatalf(`%s`, err)
+	}
+	t.Cleanup(func() {
+		pkv.Close()
+	})
+	return pkv
+}
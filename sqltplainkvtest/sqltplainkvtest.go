@@ -0,0 +1,29 @@
+// Package sqltplainkvtest provides helpers for tests
+// of code using sqltplainkv
+package sqltplainkvtest
+
+import (
+	"path/filepath"
+	"testing"
+
+	sqltplainkv "github.com/narsilworks/sqlt-plainkv"
+)
+
+// NewTempKV creates a store in a temporary directory of the test,
+// configured by the options and opened. It is closed and its files
+// are removed once the test and its subtests complete. The test
+// fails immediately if the store cannot be created
+func NewTempKV(t testing.TB, opts ...sqltplainkv.Option) *sqltplainkv.SQLtPlainKV {
+	t.Helper()
+	kv, err := sqltplainkv.New(filepath.Join(t.TempDir(), `test.db`), opts...)
+	if err != nil {
+		t.Fatalf(`sqltplainkvtest: %v`, err)
+	}
+	if err = kv.Open(); err != nil {
+		t.Fatalf(`sqltplainkvtest: %v`, err)
+	}
+	t.Cleanup(func() {
+		kv.Close()
+	})
+	return kv
+}
@@ -0,0 +1,28 @@
+package sqltplainkvtest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	sqltplainkv "github.com/narsilworks/sqlt-plainkv"
+)
+
+func TestNewTempKV(t *testing.T) {
+	var dir string
+	t.Run(`store`, func(t *testing.T) {
+		kv := NewTempKV(t, sqltplainkv.WithBucket(`fixtures`))
+		if err := kv.Set(`alpha`, []byte(`one`)); err != nil {
+			t.Fatalf(`%v`, err)
+		}
+		if val, _ := kv.Get(`alpha`); string(val) != `one` {
+			t.Logf(`Expected one, got %s`, val)
+			t.Fail()
+		}
+		dir = filepath.Dir(kv.DSN)
+	})
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Logf(`Expected the temporary files to be removed, got %v`, err)
+		t.Fail()
+	}
+}
@@ -0,0 +1,198 @@
+package sqltplainkv
+
+import (
+	"fmt"
+	"time"
+	"unicode/utf8"
+)
+
+// StoreStats are the aggregate figures of a store or a bucket
+type StoreStats struct {
+	Buckets      int     // buckets holding keys
+	Keys         int64   // live keys, expired keys left out
+	ValueBytes   int64   // bytes of the values as stored, chunks and shared blobs included
+	AvgValueSize float64 // ValueBytes per key
+	DedupSaved   int64   // bytes deduplicated values did not store again
+	FileSize     int64   // size of the whole database file
+}
+
+// internalBucketSQL matches the rows of the internal buckets
+const internalBucketSQL = `(length(Bucket) > 4 AND Bucket LIKE '--%--')`
+
+// Stats returns the figures of the whole store. Values spilled to
+// files count only the pointer kept in the database, deduplicated
+// values count their blobs once
+func (p *SQLtPlainKV) Stats() (StoreStats, error) {
+	var (
+		err    error
+		st     StoreStats
+		stored int64
+		chunks int64
+	)
+	if err = p.open(); err != nil {
+		return st, err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	now := time.Now().UnixNano()
+	err = p.conn().QueryRow(p.rebind(`
+	SELECT COUNT(DISTINCT Bucket), COUNT(*), COALESCE(SUM(length(Value)), 0) FROM `+p.defTableName+`
+	WHERE NOT `+internalBucketSQL+`
+		AND (ExpiresAt IS NULL OR ExpiresAt > ?);`), now).Scan(&st.Buckets, &st.Keys, &stored)
+	if err != nil {
+		return st, err
+	}
+	err = p.conn().QueryRow(p.rebind(`
+	SELECT COALESCE(SUM(length(Value)), 0) FROM `+p.defTableName+`
+	WHERE Bucket=?
+		AND (ExpiresAt IS NULL OR ExpiresAt > ?);`), chunkBuckt, now).Scan(&chunks)
+	if err != nil {
+		return st, err
+	}
+	blobs, saved, err := p.dedupStats(`NOT ` + internalBucketSQL)
+	if err != nil {
+		return st, err
+	}
+	st.ValueBytes = stored + chunks + blobs
+	st.DedupSaved = saved
+	return p.finishStats(st)
+}
+
+// BucketStats returns the figures of a bucket. FileSize is the
+// size of the whole database file
+func (p *SQLtPlainKV) BucketStats(bucket string) (StoreStats, error) {
+	var (
+		err    error
+		st     StoreStats
+		stored int64
+		chunks int64
+	)
+	if bucket == "" {
+		bucket = "default"
+	}
+	if err = p.open(); err != nil {
+		return st, err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	now := time.Now().UnixNano()
+	err = p.conn().QueryRow(p.rebind(`
+	SELECT COUNT(*), COALESCE(SUM(length(Value)), 0) FROM `+p.defTableName+`
+	WHERE Bucket=?
+		AND (ExpiresAt IS NULL OR ExpiresAt > ?);`), bucket, now).Scan(&st.Keys, &stored)
+	if err != nil {
+		return st, err
+	}
+	prefix := fmt.Sprintf(`%d:%s:`, len(bucket), bucket)
+	err = p.conn().QueryRow(p.rebind(`
+	SELECT COALESCE(SUM(length(Value)), 0) FROM `+p.defTableName+`
+	WHERE Bucket=?
+		AND substr(KeyID, 1, ?)=?
+		AND (ExpiresAt IS NULL OR ExpiresAt > ?);`), chunkBuckt, len(prefix), prefix, now).Scan(&chunks)
+	if err != nil {
+		return st, err
+	}
+	if st.Keys > 0 {
+		st.Buckets = 1
+	}
+	blobs, saved, err := p.dedupStats(`t.Bucket=?`, bucket)
+	if err != nil {
+		return st, err
+	}
+	st.ValueBytes = stored + chunks + blobs
+	st.DedupSaved = saved
+	return p.finishStats(st)
+}
+
+// Buckets lists the buckets holding live keys in order,
+// internal buckets left out
+func (p *SQLtPlainKV) Buckets() ([]string, error) {
+	var err error
+	buckets := make([]string, 0)
+	if err = p.open(); err != nil {
+		return buckets, err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	rows, err := p.conn().Query(p.rebind(`
+	SELECT DISTINCT Bucket FROM `+p.defTableName+`
+	WHERE NOT `+internalBucketSQL+`
+		AND (ExpiresAt IS NULL OR ExpiresAt > ?)
+	ORDER BY Bucket;`), time.Now().UnixNano())
+	if err != nil {
+		return buckets, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var b string
+		if err = rows.Scan(&b); err != nil {
+			return buckets, err
+		}
+		buckets = append(buckets, b)
+	}
+	return buckets, rows.Err()
+}
+
+// finishStats fills in the average value size and the file size
+func (p *SQLtPlainKV) finishStats(st StoreStats) (StoreStats, error) {
+	var err error
+	if st.Keys > 0 {
+		st.AvgValueSize = float64(st.ValueBytes) / float64(st.Keys)
+	}
+	st.FileSize, err = p.databaseSize()
+	return st, err
+}
+
+// KeySize is the stored size of a key, chunks included
+type KeySize struct {
+	Key  string
+	Size int64
+}
+
+// TopKeysBySize returns the n keys of a bucket taking the most space,
+// largest first. Sizes are those of the values as stored, after
+// compression, with the chunks of large values added up
+func (p *SQLtPlainKV) TopKeysBySize(bucket string, n int) ([]KeySize, error) {
+	var err error
+	if bucket == "" {
+		bucket = "default"
+	}
+	if n <= 0 {
+		return []KeySize{}, nil
+	}
+	if err = p.open(); err != nil {
+		return nil, err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	prefix := fmt.Sprintf(`%d:%s:`, len(bucket), bucket)
+	manifest := envMagic + string(envChunked)
+	rows, err := p.conn().Query(p.rebind(`
+	SELECT t.KeyID, length(t.Value) + CASE WHEN substr(t.Value, 1, ?) = ? THEN
+		(SELECT COALESCE(SUM(length(c.Value)), 0) FROM `+p.defTableName+` c
+		WHERE c.Bucket=? AND c.KeyID BETWEEN ? || t.KeyID || '#00000000' AND ? || t.KeyID || '#99999999'
+			AND length(c.KeyID) = ? + length(t.KeyID) + 9)
+		ELSE 0 END AS Size
+	FROM `+p.defTableName+` t
+	WHERE t.Bucket=?
+		AND (t.ExpiresAt IS NULL OR t.ExpiresAt > ?)
+	ORDER BY Size DESC, t.KeyID
+	LIMIT ?;`), len(manifest), []byte(manifest), chunkBuckt, prefix, prefix, utf8.RuneCountInString(prefix), bucket, time.Now().UnixNano(), n)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	keys := make([]KeySize, 0, n)
+	for rows.Next() {
+		var ks KeySize
+		if err = rows.Scan(&ks.Key, &ks.Size); err != nil {
+			return nil, err
+		}
+		keys = append(keys, ks)
+	}
+	return keys, rows.Err()
+}
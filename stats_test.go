@@ -0,0 +1,67 @@
+package sqltplainkv
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	pkv := newTestKV(t)
+	pkv.Set(`a`, bytes.Repeat([]byte(`a`), 100))
+	pkv.Set(`b`, bytes.Repeat([]byte(`b`), 300))
+	pkv.SetMime(`a`, `text/plain`)
+	pkv.SetWithTTL(`gone`, []byte(`x`), time.Nanosecond)
+	pkv.SetBucket(`big`)
+	pkv.SetLimits(Limits{ChunkSize: 64})
+	pkv.Set(`chunked`, bytes.Repeat([]byte(`c`), 200))
+	time.Sleep(time.Millisecond)
+
+	st, err := pkv.Stats()
+	if err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if st.Buckets != 2 || st.Keys != 3 || st.ValueBytes < 600 || st.FileSize == 0 {
+		t.Logf(`Unexpected store stats %+v`, st)
+		t.Fail()
+	}
+
+	st, err = pkv.BucketStats(`default`)
+	if err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if st.Keys != 2 || st.ValueBytes != 400 || st.AvgValueSize != 200 {
+		t.Logf(`Unexpected bucket stats %+v`, st)
+		t.Fail()
+	}
+	st, _ = pkv.BucketStats(`big`)
+	if st.Keys != 1 || st.ValueBytes < 200 {
+		t.Logf(`Expected the chunks to be counted, got %+v`, st)
+		t.Fail()
+	}
+	if st, _ = pkv.BucketStats(`empty`); st.Buckets != 0 || st.Keys != 0 || st.AvgValueSize != 0 {
+		t.Logf(`Unexpected stats for an empty bucket %+v`, st)
+		t.Fail()
+	}
+	if buckets, err := pkv.Buckets(); err != nil || len(buckets) != 2 || buckets[0] != `big` || buckets[1] != `default` {
+		t.Logf(`Unexpected buckets %v %v`, buckets, err)
+		t.Fail()
+	}
+}
+
+func TestTopKeysBySize(t *testing.T) {
+	pkv := newTestKV(t)
+	pkv.Set(`small`, bytes.Repeat([]byte(`s`), 10))
+	pkv.Set(`medium`, bytes.Repeat([]byte(`m`), 100))
+	pkv.SetLimits(Limits{ChunkSize: 64})
+	pkv.Set(`large`, bytes.Repeat([]byte(`l`), 1000))
+
+	top, err := pkv.TopKeysBySize(`default`, 2)
+	if err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if len(top) != 2 || top[0].Key != `large` || top[0].Size < 1000 || top[1].Key != `medium` {
+		t.Logf(`Expected large then medium, got %+v`, top)
+		t.Fail()
+	}
+}
@@ -0,0 +1,162 @@
+package sqltplainkv
+
+import (
+	"database/sql"
+	"sync"
+)
+
+type stmtKind int
+
+const (
+	stmtGet stmtKind = iota
+	stmtSet
+	stmtDel
+	stmtList
+	stmtDelChunks
+	stmtGetMime
+	stmtSetMime
+	stmtKinds
+)
+
+// stmtCache holds the statements of the hot paths, prepared once per
+// database and once per transaction
+type stmtCache struct {
+	mu    sync.Mutex
+	table string
+	db    [stmtKinds]*sql.Stmt
+	tx    [stmtKinds]*sql.Stmt
+	owner *sql.Tx
+}
+
+// statementSQL returns the statement text of a kind for a table
+// in the dialect
+func statementSQL(d Dialect, kind stmtKind, table string) string {
+	if kind == stmtSet {
+		return upsertSQL(d, table)
+	}
+	return rebind(d, statementText(kind, table))
+}
+
+func statementText(kind stmtKind, table string) string {
+	switch kind {
+	case stmtGet:
+		return `
+	SELECT Value, Checksum FROM ` + table + `
+	WHERE Bucket=?
+		AND KeyID=?
+		AND (ExpiresAt IS NULL OR ExpiresAt > ?);`
+	case stmtDel:
+		return `DELETE FROM ` + table + ` WHERE Bucket = ? AND KeyID = ?;`
+	case stmtDelChunks:
+		return `DELETE FROM ` + table + ` WHERE Bucket = ? AND KeyID BETWEEN ? AND ? AND length(KeyID) = ?;`
+	case stmtGetMime:
+		return `
+	SELECT Mime FROM ` + table + `
+	WHERE Bucket=?
+		AND KeyID=?
+		AND (ExpiresAt IS NULL OR ExpiresAt > ?);`
+	case stmtSetMime:
+		return `
+	UPDATE ` + table + ` SET Mime=?
+	WHERE Bucket=?
+		AND KeyID=?
+		AND (ExpiresAt IS NULL OR ExpiresAt > ?);`
+	case stmtList:
+		return `
+	SELECT KeyID FROM ` + table + `
+	WHERE Bucket=?
+		AND KeyID LIKE ?
+		AND (ExpiresAt IS NULL OR ExpiresAt > ?);`
+	}
+	return ``
+}
+
+// prepareStatements prepares the statements of the hot paths
+// against the database, replacing any prepared before
+func (p *SQLtPlainKV) prepareStatements() error {
+	p.stmts.mu.Lock()
+	defer p.stmts.mu.Unlock()
+	return p.prepareLocked()
+}
+
+func (p *SQLtPlainKV) prepareLocked() error {
+	p.closeLocked()
+	for kind := stmtKind(0); kind < stmtKinds; kind++ {
+		st, err := p.db.Prepare(statementSQL(p.dialect, kind, p.defTableName))
+		if err != nil {
+			p.closeLocked()
+			return err
+		}
+		p.stmts.db[kind] = st
+	}
+	p.stmts.table = p.defTableName
+	return nil
+}
+
+// stmt returns the prepared statement of a kind, bound to the
+// current transaction if one is active. Statements are prepared
+// again when the table name has changed since they were prepared
+func (p *SQLtPlainKV) stmt(kind stmtKind) (*sql.Stmt, error) {
+	p.stmts.mu.Lock()
+	defer p.stmts.mu.Unlock()
+	if p.stmts.db[kind] == nil || p.stmts.table != p.defTableName {
+		if err := p.prepareLocked(); err != nil {
+			return nil, err
+		}
+	}
+	if !p.inTransaction {
+		return p.stmts.db[kind], nil
+	}
+	if p.stmts.owner != p.tx {
+		p.stmts.tx = [stmtKinds]*sql.Stmt{}
+		p.stmts.owner = p.tx
+	}
+	if p.stmts.tx[kind] == nil {
+		p.stmts.tx[kind] = p.tx.Stmt(p.stmts.db[kind])
+	}
+	return p.stmts.tx[kind], nil
+}
+
+// stmtOn returns the prepared statement of a kind bound to the
+// transaction of a write, or as stmt does for a nil transaction
+func (p *SQLtPlainKV) stmtOn(tx *sql.Tx, kind stmtKind) (*sql.Stmt, error) {
+	if tx == nil {
+		return p.stmt(kind)
+	}
+	p.stmts.mu.Lock()
+	defer p.stmts.mu.Unlock()
+	if p.stmts.db[kind] == nil || p.stmts.table != p.defTableName {
+		if err := p.prepareLocked(); err != nil {
+			return nil, err
+		}
+	}
+	return tx.Stmt(p.stmts.db[kind]), nil
+}
+
+// releaseTxStatements forgets the statements bound to a finished
+// transaction. They are closed along with the transaction
+func (p *SQLtPlainKV) releaseTxStatements() {
+	p.stmts.mu.Lock()
+	defer p.stmts.mu.Unlock()
+	p.stmts.tx = [stmtKinds]*sql.Stmt{}
+	p.stmts.owner = nil
+}
+
+// closeStatements closes all prepared statements
+func (p *SQLtPlainKV) closeStatements() {
+	p.stmts.mu.Lock()
+	defer p.stmts.mu.Unlock()
+	p.closeLocked()
+}
+
+func (p *SQLtPlainKV) closeLocked() {
+	for kind, st := range p.stmts.db {
+		if st != nil {
+			st.Close()
+		}
+		p.stmts.db[kind] = nil
+	}
+	p.stmts.tx = [stmtKinds]*sql.Stmt{}
+	p.stmts.owner = nil
+	p.stmts.table = ``
+}
@@ -0,0 +1,45 @@
+package sqltplainkv
+
+import "testing"
+
+func TestPreparedStatements(t *testing.T) {
+	pkv := newTestKV(t)
+	if err := pkv.Set(`a`, []byte(`1`)); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	st, err := pkv.stmt(stmtGet)
+	if err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if again, _ := pkv.stmt(stmtGet); again != st {
+		t.Logf(`Expected the statement to be reused`)
+		t.Fail()
+	}
+
+	if err = pkv.Begin(); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if err = pkv.Set(`b`, []byte(`2`)); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	txst, _ := pkv.stmt(stmtGet)
+	if txst == st {
+		t.Logf(`Expected a statement bound to the transaction`)
+		t.Fail()
+	}
+	if err = pkv.Rollback(); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if val, _ := pkv.Get(`b`); len(val) != 0 {
+		t.Logf(`Expected the rolled back value to be gone, got %s`, val)
+		t.Fail()
+	}
+
+	if err = pkv.RenameTable(`RenamedTBL`); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if val, err := pkv.Get(`a`); err != nil || string(val) != `1` {
+		t.Logf(`Expected statements to follow the renamed table, got %s (%v)`, val, err)
+		t.Fail()
+	}
+}
@@ -0,0 +1,253 @@
+package sqltplainkv
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/hex"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Values transformed by the store are wrapped in an envelope: a magic
+// prefix followed by a byte naming the transform. Values written before
+// the envelope existed carry no magic and are read as they are. Stored
+// values that would start with the magic by chance are wrapped in a
+// plain envelope so they are never mistaken for a transformed value.
+const (
+	envMagic string = "\x00SKV"
+
+	envPlain byte = 'p'
+	envGzip  byte = 'g'
+	envZstd  byte = 'z'
+
+	envEncrypted byte = 'e'
+
+	envChunked  byte = 'c' // manifest of a value stored in chunks
+	envExternal byte = 'x' // pointer to a value spilled to a file
+	envDedup    byte = 'd' // pointer to a value shared in the blob bucket
+)
+
+// Compression is a value compression algorithm
+type Compression byte
+
+const (
+	NoCompression Compression = iota
+	Gzip
+	Zstd
+)
+
+var (
+	ErrCorruptValue error = errors.New(`corrupt stored value`)
+)
+
+// compressionPolicy holds the compression settings of a store
+type compressionPolicy struct {
+	algo      Compression
+	threshold int
+}
+
+// SetOptions are the settings of a single write made with SetWith
+type SetOptions struct {
+	TTL           time.Duration // expiry of the record, see SetWithTTL
+	NoCompression bool          // store the value uncompressed
+	NoEncryption  bool          // store the value unencrypted
+}
+
+// skipped names the built-in transforms left out of a single write
+type skipped struct {
+	compression bool
+	encryption  bool
+}
+
+// SetWith creates or updates the record by the value with the settings
+// of opts, which apply to this write only. Values already compressed,
+// or holding data that must be readable without the encryption key,
+// can be stored as they are. Codecs still apply, and values stored
+// without a transform are not deduplicated. Rotating the encryption
+// key leaves unencrypted values as they are
+func (p *SQLtPlainKV) SetWith(key string, value []byte, opts SetOptions) error {
+	if p.currBuckt == "" {
+		p.currBuckt = "default"
+	}
+	var expiry time.Time
+	if opts.TTL > 0 {
+		expiry = time.Now().Add(opts.TTL)
+	}
+	return p.store(p.currBuckt, key, value, expiry, storeOptions{
+		skip: skipped{
+			compression: opts.NoCompression,
+			encryption:  opts.NoEncryption,
+		},
+	})
+}
+
+// SetCompression compresses values of at least threshold bytes with
+// the algorithm. Values already stored keep their format and remain
+// readable whatever the current setting is
+func (p *SQLtPlainKV) SetCompression(algo Compression, threshold int) {
+	p.compression = compressionPolicy{
+		algo:      algo,
+		threshold: threshold,
+	}
+}
+
+// wrapValue applies the built-in transforms of the store to a value.
+// Values are compressed before they are encrypted
+func (p *SQLtPlainKV) wrapValue(bucket string, value []byte, skip skipped) ([]byte, error) {
+	var err error
+	if value, err = p.compressOrEscape(bucket, value, skip.compression); err != nil {
+		return nil, err
+	}
+	if p.encActive != nil && !skip.encryption {
+		return p.encActive.encrypt(value)
+	}
+	return value, nil
+}
+
+func (p *SQLtPlainKV) compressOrEscape(bucket string, value []byte, noCompression bool) ([]byte, error) {
+	cp := p.compression
+	if opts, ok := p.bucketOpts[bucket]; ok {
+		cp = compressionPolicy{
+			algo:      opts.Compression,
+			threshold: opts.CompressionThreshold,
+		}
+	}
+	if cp.algo != NoCompression && !noCompression && len(value) >= cp.threshold {
+		cv, err := compressValue(cp.algo, value)
+		if err != nil {
+			return nil, err
+		}
+		if len(cv) < len(value) {
+			return cv, nil
+		}
+	}
+	if bytes.HasPrefix(value, []byte(envMagic)) {
+		return envelope(envPlain, value), nil
+	}
+	return value, nil
+}
+
+// unwrapValue removes the envelopes from a stored value
+func (p *SQLtPlainKV) unwrapValue(value []byte) ([]byte, error) {
+	var err error
+	for bytes.HasPrefix(value, []byte(envMagic)) {
+		if len(value) <= len(envMagic) {
+			return nil, ErrCorruptValue
+		}
+		kind, body := value[len(envMagic)], value[len(envMagic)+1:]
+		if kind == envPlain {
+			return body, nil
+		}
+		if value, err = p.peel(kind, body); err != nil {
+			return nil, err
+		}
+	}
+	return value, nil
+}
+
+// peel removes a single envelope of the kind from its body
+func (p *SQLtPlainKV) peel(kind byte, body []byte) ([]byte, error) {
+	switch kind {
+	case envGzip, envZstd:
+		return decompressValue(kind, body)
+	case envEncrypted:
+		return p.decrypt(body)
+	case envExternal:
+		return p.readSpilled(body)
+	case envDedup:
+		return p.readBlob(body)
+	}
+	return nil, ErrCorruptValue
+}
+
+// envelopeInfo describes one envelope wrapping a stored value
+type envelopeInfo struct {
+	kind  byte
+	keyID string // encryption key id of encrypted envelopes
+}
+
+// envelopes lists the envelopes wrapping a stored value, outermost first.
+// It stops at the first envelope it cannot remove
+func (p *SQLtPlainKV) envelopes(value []byte) []envelopeInfo {
+	var envs []envelopeInfo
+	for bytes.HasPrefix(value, []byte(envMagic)) && len(value) > len(envMagic) {
+		env := envelopeInfo{kind: value[len(envMagic)]}
+		body := value[len(envMagic)+1:]
+		if env.kind == envEncrypted && len(body) >= encKeyIDLen {
+			env.keyID = hex.EncodeToString(body[:encKeyIDLen])
+		}
+		envs = append(envs, env)
+		if env.kind == envPlain {
+			break
+		}
+		var err error
+		if value, err = p.peel(env.kind, body); err != nil {
+			break
+		}
+	}
+	return envs
+}
+
+func envelope(kind byte, body []byte) []byte {
+	out := make([]byte, 0, len(envMagic)+1+len(body))
+	out = append(out, envMagic...)
+	out = append(out, kind)
+	return append(out, body...)
+}
+
+func compressValue(algo Compression, value []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(envMagic)
+	switch algo {
+	case Gzip:
+		buf.WriteByte(envGzip)
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(value); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+	case Zstd:
+		buf.WriteByte(envZstd)
+		zw, err := zstd.NewWriter(&buf)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = zw.Write(value); err != nil {
+			return nil, err
+		}
+		if err = zw.Close(); err != nil {
+			return nil, err
+		}
+	default:
+		return value, nil
+	}
+	return buf.Bytes(), nil
+}
+
+func decompressValue(kind byte, body []byte) ([]byte, error) {
+	var r io.Reader
+	switch kind {
+	case envGzip:
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	case envZstd:
+		zr, err := zstd.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	default:
+		return nil, ErrCorruptValue
+	}
+	return io.ReadAll(r)
+}
@@ -0,0 +1,58 @@
+package sqltplainkv
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCompression(t *testing.T) {
+	pkv := newTestKV(t)
+
+	page := []byte(strings.Repeat(`<p>Sample paragraph</p>`, 200))
+	pkv.Set(`uncompressed`, page)
+
+	for _, algo := range []Compression{Gzip, Zstd} {
+		pkv.SetCompression(algo, 1024)
+		if err := pkv.Set(`page`, page); err != nil {
+			t.Fatalf(`%s`, err)
+		}
+		pkv.Set(`small`, []byte(`tiny`))
+
+		info, err := pkv.InspectStorage(`page`)
+		if err != nil {
+			t.Fatalf(`%s`, err)
+		}
+		if info.Compressed != algo || info.StoredSize >= int64(len(page)) {
+			t.Logf(`Expected value compressed with %d, got %+v`, algo, info)
+			t.Fail()
+		}
+		if info, _ = pkv.InspectStorage(`small`); info.Compressed != NoCompression {
+			t.Logf(`Expected small value to be stored as is, got %+v`, info)
+			t.Fail()
+		}
+
+		for _, k := range []string{`page`, `uncompressed`} {
+			b, err := pkv.Get(k)
+			if err != nil || !bytes.Equal(b, page) {
+				t.Logf(`Expected the original value of %s (%v)`, k, err)
+				t.Fail()
+			}
+		}
+	}
+
+	// compressed values stay readable when compression is turned off
+	pkv.SetCompression(NoCompression, 0)
+	if b, _ := pkv.Get(`page`); !bytes.Equal(b, page) {
+		t.Logf(`Expected the original value after disabling compression`)
+		t.Fail()
+	}
+
+	// values that happen to start with the envelope magic round trip
+	tricky := []byte(envMagic + "g not really gzip")
+	pkv.Set(`tricky`, tricky)
+	if b, err := pkv.Get(`tricky`); err != nil || !bytes.Equal(b, tricky) {
+		t.Logf(`Expected %q, got %q (%v)`, tricky, b, err)
+		t.Fail()
+	}
+}
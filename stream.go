@@ -0,0 +1,342 @@
+package sqltplainkv
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+	"unicode/utf8"
+)
+
+// Streamed values and values larger than the chunk size are split
+// into chunks stored in an internal bucket.
+// The key itself holds a manifest envelope recording the number of
+// chunks and the total size, so chunks are only read when needed.
+const (
+	chunkBuckt string = `--chunk--`
+	chunkKey   string = `%d:%s:%s#%08d` // bucket length, bucket, key, index
+	chunkMax   int    = 99999999
+
+	streamChunkSize int = 1 << 20
+
+	manifestLen int = 13 // flags, chunk count, total size
+
+	// manifestCodec marks values encoded by the bucket codec as a
+	// whole before they were split. Streamed values are never encoded
+	manifestCodec byte = 1
+)
+
+// chunkManifest describes a value stored in chunks
+type chunkManifest struct {
+	flags  byte
+	chunks int
+	size   int64
+}
+
+func (m chunkManifest) encode() []byte {
+	body := make([]byte, manifestLen)
+	body[0] = m.flags
+	binary.BigEndian.PutUint32(body[1:5], uint32(m.chunks))
+	binary.BigEndian.PutUint64(body[5:], uint64(m.size))
+	return envelope(envChunked, body)
+}
+
+// parseManifest returns the manifest of a stored value
+// and whether the value is a manifest at all
+func parseManifest(value []byte) (chunkManifest, bool) {
+	prefix := len(envMagic) + 1
+	if len(value) != prefix+manifestLen ||
+		!bytes.HasPrefix(value, []byte(envMagic)) ||
+		value[len(envMagic)] != envChunked {
+		return chunkManifest{}, false
+	}
+	body := value[prefix:]
+	return chunkManifest{
+		flags:  body[0],
+		chunks: int(binary.BigEndian.Uint32(body[1:5])),
+		size:   int64(binary.BigEndian.Uint64(body[5:])),
+	}, true
+}
+
+func chunkKeyID(bucket, key string, index int) string {
+	return fmt.Sprintf(chunkKey, len(bucket), bucket, key, index)
+}
+
+// chunkRange returns the first and last keys of the chunks of a key
+// from index from on, and the length of those keys. The chunks of
+// other keys starting with the key and # fall in the range too, those
+// of a#1 in the range of a, but their keys are longer, so ranges are
+// matched with KeyID BETWEEN first AND last AND length(KeyID) = length
+func chunkRange(bucket, key string, from int) (first, last string, length int) {
+	first = chunkKeyID(bucket, key, from)
+	return first, chunkKeyID(bucket, key, chunkMax), utf8.RuneCountInString(first)
+}
+
+// chunkRangeArgs returns the arguments of stmtDelChunks
+// deleting all the chunks of a key
+func chunkRangeArgs(bucket, key string) []any {
+	first, last, n := chunkRange(bucket, key, 0)
+	return []any{chunkBuckt, first, last, n}
+}
+
+// SetFrom stores the contents of the reader under a key in the
+// current bucket. The value is read and written in chunks, so it
+// is never held in memory as a whole. Codecs are not applied to
+// streamed values, compression and encryption are applied per chunk
+func (p *SQLtPlainKV) SetFrom(key string, r io.Reader) error {
+	var err error
+	if p.currBuckt == "" {
+		p.currBuckt = "default"
+	}
+	bucket := p.currBuckt
+	if len(bucket) > p.limits.MaxBucketLen {
+		return ErrBucketIdTooLong
+	}
+	if len(key) > p.limits.MaxKeyLen {
+		return ErrKeyTooLong
+	}
+	if err = p.beforeSet(bucket, key, nil); err != nil {
+		return err
+	}
+	if err = p.open(); err != nil {
+		return err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	size := streamChunkSize
+	if size > p.limits.ChunkSize {
+		size = p.limits.ChunkSize
+	}
+	buf := make([]byte, size)
+	total := 0
+	h := sha256.New()
+	hash := func() sql.NullString {
+		return sql.NullString{String: hex.EncodeToString(h.Sum(nil)[:hashLen]), Valid: true}
+	}
+	var exp sql.NullInt64
+	if expiry := p.bucketExpiry(bucket, key, time.Time{}); !expiry.IsZero() {
+		exp = sql.NullInt64{Int64: expiry.UnixNano(), Valid: true}
+	}
+	next := func() ([]byte, int, error) {
+		n, err := io.ReadFull(r, buf)
+		if n == 0 {
+			if err == nil || err == io.ErrUnexpectedEOF {
+				err = io.EOF
+			}
+			return nil, 0, err
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return nil, 0, err
+		}
+		if total += n; total > p.limits.MaxValueSize {
+			return nil, 0, ErrValueTooLong
+		}
+		h.Write(buf[:n])
+		chunk, err := p.wrapValue(bucket, buf[:n], skipped{})
+		return chunk, n, err
+	}
+	var (
+		typ     ChangeType
+		evicted []string
+	)
+	// the reader cannot be read again, so the write is not retried
+	err = p.withTx(func(tx *sql.Tx) error {
+		var err error
+		if typ, err = p.changeType(tx, bucket, key); err != nil {
+			return err
+		}
+		if err = p.saveVersion(tx, bucket, key); err != nil {
+			return err
+		}
+		if evicted, err = p.storeChunks(tx, bucket, key, 0, exp, hash, next); err != nil {
+			return err
+		}
+		return p.recordChange(tx, "", typ, bucket, key, nil)
+	})
+	if err != nil {
+		return err
+	}
+	p.warmDel(bucket, key)
+	for _, k := range evicted {
+		p.afterDelete(bucket, k)
+	}
+	p.afterSet(bucket, key, nil, typ)
+	return p.enforceMaxKeys(bucket)
+}
+
+// setChunked stores a value too large for a single row in chunks of
+// the configured chunk size. The codec of the bucket is applied to
+// the value as a whole, the built-in transforms to every chunk.
+// It returns the keys evicted to make room for it
+func (p *SQLtPlainKV) setChunked(tx *sql.Tx, bucket, key string, value []byte, exp sql.NullInt64, hash sql.NullString, skip skipped) ([]string, error) {
+	var err error
+	if c := p.codecFor(bucket); c != nil {
+		if value, err = c.Encode(value); err != nil {
+			return nil, err
+		}
+	}
+	if len(value) > p.limits.MaxValueSize {
+		return nil, ErrValueTooLong
+	}
+	rest := value
+	sum := func() sql.NullString { return hash }
+	return p.storeChunks(tx, bucket, key, manifestCodec, exp, sum, func() ([]byte, int, error) {
+		if len(rest) == 0 {
+			return nil, 0, io.EOF
+		}
+		n := len(rest)
+		if n > p.limits.ChunkSize {
+			n = p.limits.ChunkSize
+		}
+		chunk, err := p.wrapValue(bucket, rest[:n], skip)
+		rest = rest[n:]
+		return chunk, n, err
+	})
+}
+
+// decodeChunked applies the codec of the bucket to a reassembled value
+// if it was encoded before it was split
+func (p *SQLtPlainKV) decodeChunked(bucket string, m chunkManifest, value []byte) ([]byte, error) {
+	if m.flags&manifestCodec == 0 {
+		return value, nil
+	}
+	if c := p.codecFor(bucket); c != nil {
+		return c.Decode(value)
+	}
+	return value, nil
+}
+
+// storeChunks writes the chunks returned by next followed by the
+// manifest of the value, in the transaction of the write. next returns
+// the chunk to store along with the number of value bytes it holds,
+// and io.EOF once there are no more chunks. hash returns the content
+// hash of the value once all chunks have been returned. It returns
+// the keys evicted to make room for the value
+func (p *SQLtPlainKV) storeChunks(tx *sql.Tx, bucket, key string, flags byte, exp sql.NullInt64, hash func() sql.NullString, next func() ([]byte, int, error)) ([]string, error) {
+	set, err := p.stmtOn(tx, stmtSet)
+	if err != nil {
+		return nil, err
+	}
+	delChunks, err := p.stmtOn(tx, stmtDelChunks)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UnixNano()
+	m := chunkManifest{flags: flags}
+	var stored int64
+	for {
+		chunk, n, err := next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if m.chunks >= chunkMax {
+			return nil, ErrValueTooLong
+		}
+		if _, err = set.Exec(chunkBuckt, chunkKeyID(bucket, key, m.chunks), chunk, exp, p.checksum(chunk), now, now, sql.NullString{}); err != nil {
+			return nil, err
+		}
+		m.chunks++
+		m.size += int64(n)
+		stored += int64(len(chunk))
+	}
+	// drop the chunks left over from a longer previous value
+	first, last, n := chunkRange(bucket, key, m.chunks)
+	if _, err = delChunks.Exec(chunkBuckt, first, last, n); err != nil {
+		return nil, err
+	}
+	manifest := m.encode()
+	evicted, err := p.checkQuota(tx, bucket, key, stored+int64(len(manifest)))
+	if err != nil {
+		return nil, err
+	}
+	more, err := p.checkDatabaseSize(tx, bucket, key, int64(len(manifest)))
+	if err != nil {
+		return nil, err
+	}
+	if _, err = set.Exec(bucket, key, manifest, exp, p.checksum(manifest), now, now, hash()); err != nil {
+		return nil, err
+	}
+	return append(evicted, more...), nil
+}
+
+// GetTo writes the value of a key in the current bucket to the
+// writer. Values stored by SetFrom are written chunk by chunk.
+// It returns ErrKeyNotFound if the key does not exist
+func (p *SQLtPlainKV) GetTo(key string, w io.Writer) error {
+	var (
+		err error
+		val []byte
+	)
+	if p.currBuckt == "" {
+		p.currBuckt = "default"
+	}
+	bucket := p.currBuckt
+	if err = p.open(); err != nil {
+		return err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	if val, err = p.getRow(nil, bucket, key); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrKeyNotFound
+		}
+		return err
+	}
+	if m, ok := parseManifest(val); ok {
+		if m.flags&manifestCodec == 0 || p.codecFor(bucket) == nil {
+			return p.readChunks(nil, bucket, key, m, w)
+		}
+		// the value was encoded as a whole, so it is decoded as a whole
+		var buf bytes.Buffer
+		buf.Grow(int(m.size))
+		if err = p.readChunks(nil, bucket, key, m, &buf); err != nil {
+			return err
+		}
+		if val, err = p.decodeChunked(bucket, m, buf.Bytes()); err != nil {
+			return err
+		}
+		_, err = w.Write(val)
+		return err
+	}
+	if val, err = p.decodeValue(bucket, val); err != nil {
+		return err
+	}
+	_, err = w.Write(val)
+	return err
+}
+
+// readChunks writes the chunks of a value to the writer in order
+func (p *SQLtPlainKV) readChunks(tx *sql.Tx, bucket, key string, m chunkManifest, w io.Writer) error {
+	var written int64
+	for i := 0; i < m.chunks; i++ {
+		chunk, err := p.getRow(tx, chunkBuckt, chunkKeyID(bucket, key, i))
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrCorruptValue
+			}
+			return err
+		}
+		if chunk, err = p.unwrapValue(chunk); err != nil {
+			return err
+		}
+		if _, err = w.Write(chunk); err != nil {
+			return err
+		}
+		written += int64(len(chunk))
+	}
+	if written != m.size {
+		return ErrCorruptValue
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package sqltplainkv
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+func TestStream(t *testing.T) {
+	pkv := newTestKV(t)
+	pkv.SetCompression(Zstd, 0)
+
+	big := make([]byte, 2*streamChunkSize+100)
+	rand.New(rand.NewSource(1)).Read(big)
+	if err := pkv.SetFrom(`big`, bytes.NewReader(big)); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+
+	var out bytes.Buffer
+	if err := pkv.GetTo(`big`, &out); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if !bytes.Equal(out.Bytes(), big) {
+		t.Logf(`Expected the streamed value back, got %d bytes`, out.Len())
+		t.Fail()
+	}
+	if val, err := pkv.Get(`big`); err != nil || !bytes.Equal(val, big) {
+		t.Logf(`Expected Get to reassemble the chunks, got %d bytes (%v)`, len(val), err)
+		t.Fail()
+	}
+	if info, _ := pkv.InspectStorage(`big`); info.Chunks != 3 {
+		t.Logf(`Expected 3 chunks, got %d`, info.Chunks)
+		t.Fail()
+	}
+
+	// a shorter value drops the extra chunks
+	if err := pkv.SetFrom(`big`, bytes.NewReader([]byte(`small`))); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	var n int
+	pkv.db.QueryRow(`SELECT COUNT(*) FROM KeyValueTBL WHERE Bucket = ?`, chunkBuckt).Scan(&n)
+	if n != 1 {
+		t.Logf(`Expected 1 chunk row, got %d`, n)
+		t.Fail()
+	}
+
+	pkv.Set(`plain`, []byte(`value`))
+	out.Reset()
+	if err := pkv.GetTo(`plain`, &out); err != nil || out.String() != `value` {
+		t.Logf(`Expected GetTo to write a plain value, got %s (%v)`, out.String(), err)
+		t.Fail()
+	}
+	if err := pkv.GetTo(`missing`, &out); !errors.Is(err, ErrKeyNotFound) {
+		t.Logf(`Expected ErrKeyNotFound, got %v`, err)
+		t.Fail()
+	}
+
+	if err := pkv.Del(`big`); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	pkv.db.QueryRow(`SELECT COUNT(*) FROM KeyValueTBL WHERE Bucket = ?`, chunkBuckt).Scan(&n)
+	if n != 0 {
+		t.Logf(`Expected Del to remove the chunks, got %d`, n)
+		t.Fail()
+	}
+}
+
+func TestChunkedSet(t *testing.T) {
+	pkv := newTestKV(t)
+	pkv.SetLimits(Limits{MaxValueSize: 1 << 20, ChunkSize: 1000})
+	pkv.SetCodec(xorCodec(0x5a))
+	if err := pkv.SetEncryptionKey(bytes.Repeat([]byte{7}, 32)); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+
+	big := make([]byte, 4500)
+	rand.New(rand.NewSource(2)).Read(big)
+	if err := pkv.Set(`big`, big); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if val, err := pkv.Get(`big`); err != nil || !bytes.Equal(val, big) {
+		t.Logf(`Expected the chunked value back, got %d bytes (%v)`, len(val), err)
+		t.Fail()
+	}
+	var out bytes.Buffer
+	if err := pkv.GetTo(`big`, &out); err != nil || !bytes.Equal(out.Bytes(), big) {
+		t.Logf(`Expected GetTo to write the chunked value, got %d bytes (%v)`, out.Len(), err)
+		t.Fail()
+	}
+	if info, _ := pkv.InspectStorage(`big`); info.Chunks != 5 {
+		t.Logf(`Expected 5 chunks, got %d`, info.Chunks)
+		t.Fail()
+	}
+	if err := pkv.RotateEncryptionKey(bytes.Repeat([]byte{7}, 32), bytes.Repeat([]byte{8}, 32)); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if val, err := pkv.Get(`big`); err != nil || !bytes.Equal(val, big) {
+		t.Logf(`Expected the chunks to be re-encrypted, got %d bytes (%v)`, len(val), err)
+		t.Fail()
+	}
+
+	// a value fitting a row replaces the chunks
+	pkv.Set(`big`, []byte(`small`))
+	var n int
+	pkv.db.QueryRow(`SELECT COUNT(*) FROM KeyValueTBL WHERE Bucket = ?`, chunkBuckt).Scan(&n)
+	if n != 0 {
+		t.Logf(`Expected the chunks to be removed, got %d`, n)
+		t.Fail()
+	}
+
+	if err := pkv.Set(`huge`, make([]byte, 2<<20)); !errors.Is(err, ErrValueTooLong) {
+		t.Logf(`Expected ErrValueTooLong above the maximum value size, got %v`, err)
+		t.Fail()
+	}
+}
+
+func TestChunkRangeOfPrefixedKey(t *testing.T) {
+	pkv := newTestKV(t)
+	pkv.SetLimits(Limits{ChunkSize: 16})
+	big := bytes.Repeat([]byte(`chunked value `), 10)
+	if err := pkv.SetFrom(`a#1`, bytes.NewReader(big)); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	// the chunks of a#1 sort between those of a
+	pkv.Set(`a`, []byte(`small`))
+	if val, err := pkv.Get(`a#1`); err != nil || !bytes.Equal(val, big) {
+		t.Logf(`Expected the chunks of a#1 to be kept, got %d bytes (%v)`, len(val), err)
+		t.Fail()
+	}
+	pkv.Set(`a`, big)
+	if err := pkv.SoftDel(`a`); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if val, err := pkv.Get(`a#1`); err != nil || !bytes.Equal(val, big) {
+		t.Logf(`Expected the chunks of a#1 to stay put, got %d bytes (%v)`, len(val), err)
+		t.Fail()
+	}
+	if err := pkv.Undelete(`a`); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if val, err := pkv.Get(`a`); err != nil || !bytes.Equal(val, big) {
+		t.Logf(`Expected a to be restored, got %d bytes (%v)`, len(val), err)
+		t.Fail()
+	}
+}
+
+func TestSetFromConcurrent(t *testing.T) {
+	pkv := newTestKV(t)
+	pkv.SetLimits(Limits{ChunkSize: 100})
+	big := bytes.Repeat([]byte(`chunked value `), 50)
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				if err := pkv.SetFrom(fmt.Sprintf(`key-%d`, i), bytes.NewReader(big)); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Logf(`Expected concurrent writes to succeed, got %v`, err)
+			t.Fail()
+		}
+	}
+	for i := 0; i < 16; i++ {
+		if val, err := pkv.Get(fmt.Sprintf(`key-%d`, i)); err != nil || !bytes.Equal(val, big) {
+			t.Logf(`Expected key-%d back, got %d bytes (%v)`, i, len(val), err)
+			t.Fail()
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package sqltplainkv
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultSweepInterval is how often StartSweeper sweeps
+// when it is given no interval
+const DefaultSweepInterval = time.Minute
+
+// SweepExpired deletes the expired records of every bucket, along
+// with their chunks, and returns their count. Records are deleted in
+// batches of the throttle batch size, each in a short transaction,
+// with the throttle pause between batches, so writers are never
+// blocked for long. Deleted keys are passed to the AfterDelete hooks
+// and the watchers. It cannot be called inside a transaction
+func (p *SQLtPlainKV) SweepExpired() (int, error) {
+	if p.inTransaction {
+		return 0, ErrInTransaction
+	}
+	return p.sweepExpired()
+}
+
+// StartSweeper runs SweepExpired every interval in the background
+// until the context is done, and returns a channel closed once it has
+// stopped, to wait for before closing the store. Failed sweeps are
+// logged as warnings and the records removed are counted in the
+// metrics. A zero interval sweeps every DefaultSweepInterval
+func (p *SQLtPlainKV) StartSweeper(ctx context.Context, interval time.Duration) <-chan struct{} {
+	if interval <= 0 {
+		interval = DefaultSweepInterval
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+			n, err := p.sweepExpired()
+			if err != nil {
+				p.warn(`sweeping expired records failed`, `error`, err)
+				continue
+			}
+			if n > 0 {
+				p.debug(`expired records swept`, `count`, n)
+			}
+		}
+	}()
+	return done
+}
+
+func (p *SQLtPlainKV) sweepExpired() (int, error) {
+	var err error
+	if err = p.open(); err != nil {
+		return 0, err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	t := p.defTableName
+	if _, err = p.db.Exec(`CREATE INDEX IF NOT EXISTS ` + t + `_expires ON ` + t + ` (ExpiresAt);`); err != nil {
+		return 0, err
+	}
+	total := 0
+	for {
+		n, err := p.sweepBatch()
+		total += n
+		if p.metrics != nil {
+			atomic.AddInt64(&p.metrics.expired, int64(n))
+		}
+		if err != nil || n < p.throttle.BatchSize {
+			return total, err
+		}
+		p.pause()
+	}
+}
+
+// sweepBatch deletes at most a batch of expired records
+func (p *SQLtPlainKV) sweepBatch() (int, error) {
+	t := p.defTableName
+	now := time.Now().UnixNano()
+	// chunks expire with their record and are deleted along with it
+	rows, err := p.db.Query(p.rebind(`
+	SELECT Bucket, KeyID FROM `+t+`
+	WHERE ExpiresAt IS NOT NULL
+		AND ExpiresAt <= ?
+		AND Bucket <> ?
+	LIMIT ?;`), now, chunkBuckt, p.throttle.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	type record struct{ bucket, key string }
+	expired := make([]record, 0)
+	for rows.Next() {
+		var r record
+		if err = rows.Scan(&r.bucket, &r.key); err != nil {
+			return 0, err
+		}
+		expired = append(expired, r)
+	}
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+	rows.Close()
+	if len(expired) == 0 {
+		return 0, nil
+	}
+
+	tx, err := p.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	// a record written again since it was found is no longer expired
+	del, err := tx.Prepare(p.rebind(`DELETE FROM ` + t + ` WHERE Bucket=? AND KeyID=? AND ExpiresAt <= ?;`))
+	if err != nil {
+		return 0, err
+	}
+	defer del.Close()
+	delChunks, err := tx.Prepare(statementSQL(p.dialect, stmtDelChunks, t))
+	if err != nil {
+		return 0, err
+	}
+	defer delChunks.Close()
+	deleted := expired[:0]
+	for _, r := range expired {
+		res, err := del.Exec(r.bucket, r.key, now)
+		if err != nil {
+			return 0, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		if n == 0 {
+			continue
+		}
+		if _, err = delChunks.Exec(chunkRangeArgs(r.bucket, r.key)...); err != nil {
+			return 0, err
+		}
+		if err = p.recordChange(tx, "", ChangeDelete, r.bucket, r.key, nil); err != nil {
+			return 0, err
+		}
+		deleted = append(deleted, r)
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	for _, r := range deleted {
+		p.warmDel(r.bucket, r.key)
+		p.afterDelete(r.bucket, r.key)
+	}
+	return len(deleted), nil
+}
@@ -0,0 +1,119 @@
+package sqltplainkv
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSweepExpired(t *testing.T) {
+	pkv := newTestKV(t)
+	pkv.EnableMetrics()
+	pkv.SetLimits(Limits{ChunkSize: 16})
+	pkv.SetThrottle(Throttle{BatchSize: 2})
+	var deleted int32
+	pkv.AddHooks(Hooks{AfterDelete: func(bucket, key string) {
+		atomic.AddInt32(&deleted, 1)
+	}})
+	for _, k := range []string{`a`, `b`, `c`} {
+		pkv.SetWithTTL(k, []byte(k), time.Millisecond)
+	}
+	pkv.SetWithTTL(`big`, []byte(strings.Repeat(`chunked value `, 10)), time.Millisecond)
+	pkv.SetWithTTL(`live`, []byte(`live`), time.Hour)
+	pkv.Set(`forever`, []byte(`forever`))
+	time.Sleep(5 * time.Millisecond)
+
+	if n, err := pkv.SweepExpired(); err != nil || n != 4 {
+		t.Logf(`Expected 4 records swept, got %d (%v)`, n, err)
+		t.Fail()
+	}
+	if keys, _ := pkv.listKeys(`default`, ``); len(keys) != 2 {
+		t.Logf(`Expected the live keys to be kept, got %v`, keys)
+		t.Fail()
+	}
+	var chunks int
+	pkv.db.QueryRow(`SELECT COUNT(*) FROM `+pkv.defTableName+` WHERE Bucket=?;`, chunkBuckt).Scan(&chunks)
+	if chunks != 0 {
+		t.Logf(`Expected the chunks to be swept, got %d`, chunks)
+		t.Fail()
+	}
+	if m, _ := pkv.Metrics(); m.Expired != 4 || atomic.LoadInt32(&deleted) != 4 {
+		t.Logf(`Expected 4 expired records reported, got %d and %d`, m.Expired, deleted)
+		t.Fail()
+	}
+
+	pkv.Begin()
+	if _, err := pkv.SweepExpired(); err != ErrInTransaction {
+		t.Logf(`Expected ErrInTransaction, got %v`, err)
+		t.Fail()
+	}
+	pkv.Rollback()
+}
+
+func TestStartSweeper(t *testing.T) {
+	pkv := newTestKV(t)
+	pkv.SetWithTTL(`k`, []byte(`v`), time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := pkv.StartSweeper(ctx, 5*time.Millisecond)
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		var n int
+		pkv.db.QueryRow(`SELECT COUNT(*) FROM ` + pkv.defTableName + `;`).Scan(&n)
+		if n == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf(`Expected the sweeper to delete the expired record`)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestSweepRecordsInItsTransaction(t *testing.T) {
+	pkv := newTestKV(t)
+	if err := pkv.EnableAudit(); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	for _, k := range []string{`a`, `b`, `c`} {
+		pkv.SetWithTTL(k, []byte(k), time.Millisecond)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	// the sweep runs alongside a transaction of the store
+	done := make(chan error)
+	go func() {
+		_, err := pkv.sweepExpired()
+		done <- err
+	}()
+	if err := pkv.Begin(); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	pkv.Set(`live`, []byte(`live`))
+	if err := pkv.Commit(); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	entries, err := pkv.AuditLog(AuditFilter{})
+	if err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	swept := 0
+	for _, e := range entries {
+		if e.Type == ChangeDelete {
+			swept++
+		}
+	}
+	if swept != 3 {
+		t.Logf(`Expected the 3 swept keys in the audit log, got %+v`, entries)
+		t.Fail()
+	}
+}
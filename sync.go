@@ -0,0 +1,87 @@
+package sqltplainkv
+
+import "io"
+
+// defaultMime is returned by GetMime for keys without a mime in
+// buckets without a default mime, so it is not copied between stores
+const defaultMime string = `text/html`
+
+// SyncFrom copies the keys of the buckets of another store, such as
+// the MySQL-backed plainkv, into this one, overwriting keys present
+// in both. The default bucket is copied when no bucket is given.
+// The current bucket of the other store is changed while copying
+func (p *SQLtPlainKV) SyncFrom(src PlainKVer, buckets ...string) error {
+	if src == PlainKVer(p) {
+		return ErrMergeSelf
+	}
+	if len(buckets) == 0 {
+		buckets = []string{`default`}
+	}
+	for _, bucket := range buckets {
+		src.SetBucket(bucket)
+		keys, err := src.ListKeys(``)
+		if err != nil {
+			return err
+		}
+		i := 0
+		_, err = p.ImportRecords(ImportOptions{
+			Bucket:    bucket,
+			Conflict:  ConflictOverwrite,
+			BatchSize: p.throttle.BatchSize,
+		}, func() (Record, error) {
+			if i == len(keys) {
+				return Record{}, io.EOF
+			}
+			rec := Record{Key: keys[i]}
+			i++
+			var err error
+			if rec.Value, err = src.Get(rec.Key); err != nil {
+				return rec, err
+			}
+			if rec.Mime, err = src.GetMime(rec.Key); err != nil {
+				return rec, err
+			}
+			if rec.Mime == defaultMime {
+				rec.Mime = ``
+			}
+			return rec, nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SyncTo copies the keys of the buckets of this store into another
+// store, such as the MySQL-backed plainkv, overwriting keys present
+// in both. Every bucket is copied when no bucket is given. Expiry is
+// not carried over, as PlainKVer has no notion of it. The current
+// bucket of the other store is left at the last bucket copied
+func (p *SQLtPlainKV) SyncTo(dst PlainKVer, buckets ...string) error {
+	var err error
+	if dst == PlainKVer(p) {
+		return ErrMergeSelf
+	}
+	if len(buckets) == 0 {
+		if buckets, err = p.buckets(); err != nil {
+			return err
+		}
+	}
+	for _, bucket := range buckets {
+		dst.SetBucket(bucket)
+		err = p.exportRecords(bucket, func(rec Record) error {
+			if err := dst.Set(rec.Key, rec.Value); err != nil {
+				return err
+			}
+			if rec.Mime == "" {
+				return nil
+			}
+			return dst.SetMime(rec.Key, rec.Mime)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
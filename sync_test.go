@@ -0,0 +1,48 @@
+package sqltplainkv
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSyncFromAndTo(t *testing.T) {
+	remote := newTestKV(t)
+	remote.Set(`greeting`, []byte(`hello`))
+	remote.SetMime(`greeting`, `text/plain`)
+	remote.SetBucket(`users`)
+	remote.Set(`alice`, []byte(`admin`))
+
+	local := newTestKV(t)
+	local.Set(`greeting`, []byte(`stale`))
+	if err := local.SyncFrom(remote, `default`, `users`); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if val, _ := local.Get(`greeting`); string(val) != `hello` {
+		t.Logf(`Expected the remote value to overwrite, got %s`, val)
+		t.Fail()
+	}
+	if mime, _ := local.GetMime(`greeting`); mime != `text/plain` {
+		t.Logf(`Expected the mime to be copied, got %s`, mime)
+		t.Fail()
+	}
+	local.SetBucket(`users`)
+	if val, _ := local.Get(`alice`); string(val) != `admin` {
+		t.Logf(`Expected the users bucket to be copied, got %s`, val)
+		t.Fail()
+	}
+
+	local.Set(`bob`, []byte(`editor`))
+	if err := local.SyncTo(remote); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	remote.SetBucket(`users`)
+	if val, _ := remote.Get(`bob`); string(val) != `editor` {
+		t.Logf(`Expected the local key to be copied back, got %s`, val)
+		t.Fail()
+	}
+
+	if err := local.SyncTo(local); !errors.Is(err, ErrMergeSelf) {
+		t.Logf(`Expected ErrMergeSelf, got %v`, err)
+		t.Fail()
+	}
+}
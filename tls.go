@@ -0,0 +1,113 @@
+package sqltplainkv
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNoClientCA error = errors.New(`no certificates found in client CA file`)
+)
+
+// TLSOptions configures TLS for the network servers of the package.
+// Setting ClientCAFile enables mutual TLS: clients must present a
+// certificate signed by one of the CAs in the file.
+// The files are checked for changes at most once per ReloadInterval
+// (a second by default) and reloaded, so certificates can be rotated
+// without restarting the server.
+type TLSOptions struct {
+	CertFile       string
+	KeyFile        string
+	ClientCAFile   string
+	ReloadInterval time.Duration
+}
+
+// NewTLSConfig creates a server TLS configuration from the options
+func NewTLSConfig(opts TLSOptions) (*tls.Config, error) {
+	if opts.ReloadInterval <= 0 {
+		opts.ReloadInterval = time.Second
+	}
+	r := &tlsReloader{opts: opts}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return r.config(), nil
+		},
+	}, nil
+}
+
+// tlsReloader keeps the TLS configuration in sync with the files
+type tlsReloader struct {
+	opts    TLSOptions
+	mu      sync.Mutex
+	cfg     *tls.Config
+	modTime time.Time
+	checked time.Time
+}
+
+func (r *tlsReloader) config() *tls.Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if time.Since(r.checked) >= r.opts.ReloadInterval {
+		r.checked = time.Now()
+		if r.latestModTime().After(r.modTime) {
+			// keep serving the previous files if the new ones are broken
+			r.load()
+		}
+	}
+	return r.cfg
+}
+
+func (r *tlsReloader) reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checked = time.Now()
+	return r.load()
+}
+
+func (r *tlsReloader) load() error {
+	mt := r.latestModTime()
+	cert, err := tls.LoadX509KeyPair(r.opts.CertFile, r.opts.KeyFile)
+	if err != nil {
+		return err
+	}
+	cfg := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}
+	if r.opts.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.opts.ClientCAFile)
+		if err != nil {
+			return err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return ErrNoClientCA
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	r.cfg = cfg
+	r.modTime = mt
+	return nil
+}
+
+func (r *tlsReloader) latestModTime() time.Time {
+	var latest time.Time
+	for _, f := range []string{r.opts.CertFile, r.opts.KeyFile, r.opts.ClientCAFile} {
+		if f == "" {
+			continue
+		}
+		if fi, err := os.Stat(f); err == nil && fi.ModTime().After(latest) {
+			latest = fi.ModTime()
+		}
+	}
+	return latest
+}
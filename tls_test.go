@@ -0,0 +1,113 @@
+package sqltplainkv
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestCert writes a self-signed certificate and its key
+func writeTestCert(t *testing.T, dir, name string) (string, string, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf(`%s`, err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{`localhost`},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf(`%s`, err)
+	}
+	kb, _ := x509.MarshalECPrivateKey(key)
+	certFile := filepath.Join(dir, name+`.crt`)
+	keyFile := filepath.Join(dir, name+`.key`)
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: `CERTIFICATE`, Bytes: der}), 0600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: `EC PRIVATE KEY`, Bytes: kb}), 0600)
+	cert, _ := x509.ParseCertificate(der)
+	return certFile, keyFile, cert
+}
+
+func TestTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, serverCert := writeTestCert(t, dir, `server`)
+	caFile, caKey, _ := writeTestCert(t, dir, `client`)
+
+	cfg, err := NewTLSConfig(TLSOptions{
+		CertFile:       certFile,
+		KeyFile:        keyFile,
+		ClientCAFile:   caFile,
+		ReloadInterval: time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf(`%s`, err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(serverCert)
+	clientCert, _ := tls.LoadX509KeyPair(caFile, caKey)
+
+	handshake := func(withCert bool) (*x509.Certificate, error) {
+		cc, sc := net.Pipe()
+		defer cc.Close()
+		defer sc.Close()
+		go tls.Server(sc, cfg).Handshake()
+		ccfg := &tls.Config{RootCAs: roots, ServerName: `localhost`}
+		if withCert {
+			ccfg.Certificates = []tls.Certificate{clientCert}
+		}
+		conn := tls.Client(cc, ccfg)
+		if err := conn.Handshake(); err != nil {
+			return nil, err
+		}
+		// the server verifies the client certificate after the client finishes
+		conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		if _, err := conn.Read(make([]byte, 1)); err != nil {
+			if ne, ok := err.(net.Error); !ok || !ne.Timeout() {
+				return nil, err
+			}
+		}
+		return conn.ConnectionState().PeerCertificates[0], nil
+	}
+
+	if _, err = handshake(true); err != nil {
+		t.Logf(`Expected mutual TLS handshake to succeed, got %v`, err)
+		t.Fail()
+	}
+	if _, err = handshake(false); err == nil {
+		t.Logf(`Expected handshake without client certificate to fail`)
+		t.Fail()
+	}
+
+	// rotate the server certificate
+	time.Sleep(10 * time.Millisecond)
+	_, _, rotated := writeTestCert(t, dir, `server`)
+	roots.AddCert(rotated)
+	time.Sleep(10 * time.Millisecond)
+	peer, err := handshake(true)
+	if err != nil {
+		t.Fatalf(`%s`, err)
+	}
+	if !peer.Equal(rotated) {
+		t.Logf(`Expected the rotated certificate to be served`)
+		t.Fail()
+	}
+}
@@ -0,0 +1,93 @@
+package sqltplainkv
+
+import (
+	"database/sql"
+	"time"
+	"unicode/utf8"
+)
+
+// Touch marks a key in the current bucket as updated and accessed now
+// without rewriting its value. A key that expires has its expiry
+// moved forward by the time since it was last updated or its expiry
+// last set, so it keeps the time to live it was set with. Keys of a
+// versioned bucket keep the time their value was written, which
+// GetAsOf relies on, and are only marked as accessed. It returns
+// ErrKeyNotFound if the key does not exist
+func (p *SQLtPlainKV) Touch(key string) error {
+	var err error
+	if p.currBuckt == "" {
+		p.currBuckt = "default"
+	}
+	bucket := p.currBuckt
+	if err = p.open(); err != nil {
+		return err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	var touched bool
+	err = p.retry(func() error {
+		return p.withTx(func(tx *sql.Tx) error {
+			var err error
+			touched, err = p.touch(tx, bucket, key, nil)
+			return err
+		})
+	})
+	if err != nil {
+		return err
+	}
+	if !touched {
+		return ErrKeyNotFound
+	}
+	p.warmDel(bucket, key)
+	return nil
+}
+
+// touch marks a key as updated and accessed now in the transaction
+// and reports whether it exists. A nil expiry keeps the time to live
+// of the key, see Touch, a zero one removes its expiry
+func (p *SQLtPlainKV) touch(tx *sql.Tx, bucket, key string, expiry *time.Time) (bool, error) {
+	now := time.Now().UnixNano()
+	// the time to live runs from the last write or the last time the
+	// expiry was set without one, whichever is later
+	set := `CASE WHEN ExpiresAt IS NULL OR UpdatedAt IS NULL THEN ExpiresAt
+			ELSE ? + ExpiresAt - CASE WHEN ExpirySetAt > UpdatedAt THEN ExpirySetAt ELSE UpdatedAt END END`
+	var exp any = now
+	if expiry != nil {
+		set = `?`
+		exp = sql.NullInt64{}
+		if !expiry.IsZero() {
+			exp = expiry.UnixNano()
+		}
+	}
+	// a versioned key keeps the time its value was written
+	updated := `UpdatedAt`
+	args := []any{exp, now}
+	if !p.versioned(bucket, key) {
+		updated = `?`
+		args = append(args, now)
+	}
+	args = append(args, now)
+	sqlstr := p.rebind(`
+	UPDATE ` + p.defTableName + `
+	SET ExpiresAt = ` + set + `,
+		ExpirySetAt = ?,
+		UpdatedAt = ` + updated + `,
+		AccessedAt = ?
+	WHERE Bucket=?
+		AND KeyID BETWEEN ? AND ?
+		AND length(KeyID) = ?
+		AND (ExpiresAt IS NULL OR ExpiresAt > ?);`)
+	res, err := p.on(tx).Exec(sqlstr, append(args, bucket, key, key, utf8.RuneCountInString(key), now)...)
+	if err != nil {
+		return false, err
+	}
+	touched, err := res.RowsAffected()
+	if err != nil || touched == 0 {
+		return false, err
+	}
+	// chunks expire along with their manifest
+	first, last, n := chunkRange(bucket, key, 0)
+	_, err = p.on(tx).Exec(sqlstr, append(args, chunkBuckt, first, last, n, now)...)
+	return true, err
+}
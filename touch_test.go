@@ -0,0 +1,56 @@
+package sqltplainkv
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTouch(t *testing.T) {
+	pkv := newTestKV(t)
+	pkv.Set(`plain`, []byte(`value`))
+	before, _ := pkv.GetMeta(`plain`)
+	time.Sleep(2 * time.Millisecond)
+	if err := pkv.Touch(`plain`); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	after, _ := pkv.GetMeta(`plain`)
+	if !after.UpdatedAt.After(before.UpdatedAt) || !after.CreatedAt.Equal(before.CreatedAt) || !after.ExpiresAt.IsZero() {
+		t.Logf(`Expected only UpdatedAt to move, got %+v then %+v`, before, after)
+		t.Fail()
+	}
+
+	pkv.SetWithTTL(`session`, []byte(`abc`), 150*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	if err := pkv.Touch(`session`); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if val, _ := pkv.Get(`session`); string(val) != `abc` {
+		t.Logf(`Expected the touch to extend the ttl, got %q`, val)
+		t.Fail()
+	}
+	meta, _ := pkv.GetMeta(`session`)
+	if ttl := meta.ExpiresAt.Sub(meta.UpdatedAt); ttl < 140*time.Millisecond || ttl > 150*time.Millisecond {
+		t.Logf(`Expected the ttl to be kept, got %v`, ttl)
+		t.Fail()
+	}
+
+	if err := pkv.Touch(`missing`); !errors.Is(err, ErrKeyNotFound) {
+		t.Logf(`Expected ErrKeyNotFound, got %v`, err)
+		t.Fail()
+	}
+}
+
+func TestTouchChunked(t *testing.T) {
+	pkv := newTestKV(t)
+	pkv.SetLimits(Limits{ChunkSize: 4})
+	pkv.SetWithTTL(`big`, []byte(`0123456789`), 150*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	pkv.Touch(`big`)
+	time.Sleep(100 * time.Millisecond)
+	if val, err := pkv.Get(`big`); string(val) != `0123456789` {
+		t.Logf(`Expected the chunks to be extended, got %q (%v)`, val, err)
+		t.Fail()
+	}
+}
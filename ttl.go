@@ -0,0 +1,180 @@
+package sqltplainkv
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// NoTTL is the time to live TTL returns for keys that do not expire
+const NoTTL time.Duration = -1
+
+// TTL returns the time left before a key in the current bucket
+// expires, or NoTTL if it does not expire. It returns ErrKeyNotFound
+// if the key does not exist
+func (p *SQLtPlainKV) TTL(key string) (time.Duration, error) {
+	var err error
+	if p.currBuckt == "" {
+		p.currBuckt = "default"
+	}
+	if err = p.open(); err != nil {
+		return 0, err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	meta, err := p.getMeta(p.currBuckt, key)
+	if err != nil {
+		return 0, err
+	}
+	if meta.ExpiresAt.IsZero() {
+		return NoTTL, nil
+	}
+	ttl := time.Until(meta.ExpiresAt)
+	if ttl < 0 {
+		ttl = 0 // expired since it was read
+	}
+	return ttl, nil
+}
+
+// expiresAt returns the expiry of a key in the transaction,
+// NULL if it does not expire or does not exist
+func (p *SQLtPlainKV) expiresAt(tx *sql.Tx, bucket, key string) (sql.NullInt64, error) {
+	var exp sql.NullInt64
+	err := p.on(tx).QueryRow(p.rebind(`
+	SELECT ExpiresAt FROM `+p.defTableName+`
+	WHERE Bucket=? AND KeyID=?;`), bucket, key).Scan(&exp)
+	if errors.Is(err, sql.ErrNoRows) {
+		return sql.NullInt64{}, nil
+	}
+	return exp, err
+}
+
+// Persist removes the expiration of a key in the current bucket, so
+// it is kept until it is deleted. Keys that do not expire are left
+// as they are. It returns ErrKeyNotFound if the key does not exist
+func (p *SQLtPlainKV) Persist(key string) error {
+	var err error
+	if p.currBuckt == "" {
+		p.currBuckt = "default"
+	}
+	bucket := p.currBuckt
+	if err = p.open(); err != nil {
+		return err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	sqlstr := p.rebind(`
+	UPDATE ` + p.defTableName + `
+	SET ExpiresAt = NULL,
+		ExpirySetAt = ?
+	WHERE Bucket=?
+		AND KeyID BETWEEN ? AND ?
+		AND length(KeyID) = ?
+		AND (ExpiresAt IS NULL OR ExpiresAt > ?);`)
+	var persisted int64
+	err = p.retry(func() error {
+		now := time.Now().UnixNano()
+		res, err := p.conn().Exec(sqlstr, now, bucket, key, key, utf8.RuneCountInString(key), now)
+		if err != nil {
+			return err
+		}
+		if persisted, err = res.RowsAffected(); err != nil || persisted == 0 {
+			return err
+		}
+		// chunks expire along with their manifest
+		first, last, n := chunkRange(bucket, key, 0)
+		_, err = p.conn().Exec(sqlstr, now, chunkBuckt, first, last, n, now)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if persisted == 0 {
+		return ErrKeyNotFound
+	}
+	p.warmDel(bucket, key)
+	return nil
+}
+
+// SetBucketTTL sets the time to live of the keys written to the bucket
+// without one, so the bucket cleans itself up like a cache. Writes with
+// a ttl of their own keep it, and Persist removes the expiration of a
+// key. Keys already stored are left as they are, and hidden keys, such
+// as versions and tallies, do not expire. It is kept in the bucket
+// options. A ttl of zero or less stores keys without expiration again
+func (p *SQLtPlainKV) SetBucketTTL(bucket string, ttl time.Duration) error {
+	if bucket == "" {
+		bucket = "default"
+	}
+	if ttl < 0 {
+		ttl = 0
+	}
+	opts, _, err := p.GetBucketOptions(bucket)
+	if err != nil {
+		return err
+	}
+	opts.TTL = ttl
+	return p.SetBucketOptions(bucket, opts)
+}
+
+// bucketExpiry returns the expiry of a key written with the expiry,
+// which is the time to live of the bucket from now if it has none
+func (p *SQLtPlainKV) bucketExpiry(bucket, key string, expiry time.Time) time.Time {
+	if !expiry.IsZero() || strings.HasPrefix(key, internalKeyPrefix) {
+		return expiry
+	}
+	if opts, ok := p.bucketOpts[bucket]; ok && opts.TTL > 0 && !isInternalBucket(bucket) {
+		return time.Now().Add(opts.TTL)
+	}
+	return expiry
+}
+
+// ExpireBucket sets the expiry of every key stored in a bucket, and of
+// their chunks, in a single statement, to invalidate a generation of
+// cached keys at once. A time in the past expires them right away.
+// Locale variants expire along with the keys, other hidden keys, such
+// as versions and tallies, are left as they are, and keys written
+// afterwards are not affected
+func (p *SQLtPlainKV) ExpireBucket(bucket string, at time.Time) error {
+	var err error
+	if bucket == "" {
+		bucket = "default"
+	}
+	if err = p.open(); err != nil {
+		return err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	prefix := fmt.Sprintf(`%d:%s:`, len(bucket), bucket)
+	hidden := prefix + internalKeyPrefix
+	locales := prefix + localePrefix
+	sqlstr := p.rebind(`
+	UPDATE ` + p.defTableName + `
+	SET ExpiresAt = ?,
+		ExpirySetAt = ?
+	WHERE ((Bucket=? AND (substr(KeyID, 1, ?)<>? OR substr(KeyID, 1, ?)=?))
+		OR (Bucket=? AND substr(KeyID, 1, ?)=?
+			AND (substr(KeyID, 1, ?)<>? OR substr(KeyID, 1, ?)=?)))
+		AND (ExpiresAt IS NULL OR ExpiresAt > ?);`)
+	err = p.retry(func() error {
+		now := time.Now().UnixNano()
+		_, err := p.conn().Exec(sqlstr, at.UnixNano(), now,
+			bucket, utf8.RuneCountInString(internalKeyPrefix), internalKeyPrefix,
+			utf8.RuneCountInString(localePrefix), localePrefix,
+			chunkBuckt, utf8.RuneCountInString(prefix), prefix,
+			utf8.RuneCountInString(hidden), hidden, utf8.RuneCountInString(locales), locales,
+			now)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	p.warmDelBucket(bucket)
+	return nil
+}
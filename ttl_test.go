@@ -0,0 +1,177 @@
+package sqltplainkv
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTTL(t *testing.T) {
+	pkv := newTestKV(t)
+	pkv.SetWithTTL(`session`, []byte(`s`), time.Hour)
+	pkv.Set(`config`, []byte(`c`))
+
+	if ttl, err := pkv.TTL(`session`); err != nil || ttl <= 59*time.Minute || ttl > time.Hour {
+		t.Logf(`Expected about an hour, got %v (%v)`, ttl, err)
+		t.Fail()
+	}
+	if ttl, err := pkv.TTL(`config`); err != nil || ttl != NoTTL {
+		t.Logf(`Expected NoTTL, got %v (%v)`, ttl, err)
+		t.Fail()
+	}
+	if _, err := pkv.TTL(`missing`); !errors.Is(err, ErrKeyNotFound) {
+		t.Logf(`Expected ErrKeyNotFound, got %v`, err)
+		t.Fail()
+	}
+}
+
+func TestPersist(t *testing.T) {
+	pkv := newTestKV(t)
+	pkv.SetLimits(Limits{ChunkSize: 16})
+	big := strings.Repeat(`chunked value `, 10)
+	pkv.SetWithTTL(`big`, []byte(big), 20*time.Millisecond)
+	pkv.Set(`config`, []byte(`c`))
+
+	if err := pkv.Persist(`big`); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if err := pkv.Persist(`config`); err != nil {
+		t.Logf(`Expected keys without expiry to be left alone, got %v`, err)
+		t.Fail()
+	}
+	if err := pkv.Persist(`missing`); !errors.Is(err, ErrKeyNotFound) {
+		t.Logf(`Expected ErrKeyNotFound, got %v`, err)
+		t.Fail()
+	}
+	time.Sleep(30 * time.Millisecond)
+	if ttl, _ := pkv.TTL(`big`); ttl != NoTTL {
+		t.Logf(`Expected NoTTL, got %v`, ttl)
+		t.Fail()
+	}
+	if v, err := pkv.Get(`big`); err != nil || string(v) != big {
+		t.Logf(`Expected the chunked value to outlive its expiry, got %q (%v)`, v, err)
+		t.Fail()
+	}
+}
+
+func TestBucketTTL(t *testing.T) {
+	pkv := newTestKV(t)
+	if err := pkv.SetBucketTTL(`cache`, time.Hour); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	pkv.SetBucket(`cache`)
+	pkv.Set(`page`, []byte(`p`))
+	pkv.SetWithTTL(`short`, []byte(`s`), time.Minute)
+	pkv.SetFrom(`streamed`, strings.NewReader(`streamed`))
+	pkv.SetNX(`once`, []byte(`o`), 0)
+	pkv.Tally(`hits`, 0)
+
+	for _, k := range []string{`page`, `streamed`, `once`} {
+		if ttl, err := pkv.TTL(k); err != nil || ttl <= 59*time.Minute || ttl > time.Hour {
+			t.Logf(`Expected %s to inherit the bucket ttl, got %v (%v)`, k, ttl, err)
+			t.Fail()
+		}
+	}
+	if ttl, _ := pkv.TTL(`short`); ttl > time.Minute {
+		t.Logf(`Expected the ttl of the write to be kept, got %v`, ttl)
+		t.Fail()
+	}
+	if ttl, _ := pkv.TTL(fmt.Sprintf(tallyKey, `hits`)); ttl != NoTTL {
+		t.Logf(`Expected hidden keys not to expire, got %v`, ttl)
+		t.Fail()
+	}
+
+	pkv.SetBucket(`default`)
+	pkv.Set(`config`, []byte(`c`))
+	if ttl, _ := pkv.TTL(`config`); ttl != NoTTL {
+		t.Logf(`Expected other buckets not to expire, got %v`, ttl)
+		t.Fail()
+	}
+	pkv.SetBucketTTL(`cache`, 0)
+	pkv.SetBucket(`cache`)
+	pkv.Set(`page`, []byte(`p`))
+	if ttl, _ := pkv.TTL(`page`); ttl != NoTTL {
+		t.Logf(`Expected no expiry once the bucket ttl is removed, got %v`, ttl)
+		t.Fail()
+	}
+}
+
+func TestExpireBucket(t *testing.T) {
+	pkv := newTestKV(t)
+	pkv.SetLimits(Limits{ChunkSize: 16})
+	pkv.SetBucket(`cache`)
+	pkv.Set(`page`, []byte(`p`))
+	pkv.Set(`big`, []byte(strings.Repeat(`chunked value `, 10)))
+	pkv.SetLocale(`page`, `de`, []byte(strings.Repeat(`Seite `, 10)))
+	pkv.Tally(`hits`, 0)
+	pkv.SetBucket(`default`)
+	pkv.Set(`config`, []byte(`c`))
+
+	if err := pkv.ExpireBucket(`cache`, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	pkv.SetBucket(`cache`)
+	if ttl, err := pkv.TTL(fmt.Sprintf(localeKey, `de`, `page`)); err != nil || ttl <= 59*time.Minute || ttl > time.Hour {
+		t.Logf(`Expected the locale variant to expire in an hour, got %v (%v)`, ttl, err)
+		t.Fail()
+	}
+	for _, k := range []string{`page`, `big`} {
+		if ttl, err := pkv.TTL(k); err != nil || ttl <= 59*time.Minute || ttl > time.Hour {
+			t.Logf(`Expected %s to expire in an hour, got %v (%v)`, k, ttl, err)
+			t.Fail()
+		}
+	}
+	if ttl, _ := pkv.TTL(fmt.Sprintf(tallyKey, `hits`)); ttl != NoTTL {
+		t.Logf(`Expected hidden keys to be left alone, got %v`, ttl)
+		t.Fail()
+	}
+
+	if err := pkv.ExpireBucket(`cache`, time.Now().Add(-time.Second)); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if v, _ := pkv.Get(`page`); len(v) != 0 {
+		t.Logf(`Expected the key to have expired, got %q`, v)
+		t.Fail()
+	}
+	if v, loc, _ := pkv.GetLocale(`page`, `de`); len(v) != 0 {
+		t.Logf(`Expected the locale variant to have expired, got %q (%s)`, v, loc)
+		t.Fail()
+	}
+	if n, err := pkv.SweepExpired(); err != nil || n != 3 {
+		t.Logf(`Expected 3 records swept, got %d (%v)`, n, err)
+		t.Fail()
+	}
+	var chunks int
+	pkv.db.QueryRow(`SELECT COUNT(*) FROM `+pkv.defTableName+` WHERE Bucket=?;`, chunkBuckt).Scan(&chunks)
+	if chunks != 0 {
+		t.Logf(`Expected the chunks to be swept, got %d`, chunks)
+		t.Fail()
+	}
+	pkv.SetBucket(`default`)
+	if ttl, _ := pkv.TTL(`config`); ttl != NoTTL {
+		t.Logf(`Expected other buckets to be left alone, got %v`, ttl)
+		t.Fail()
+	}
+}
+
+func TestTouchAfterExpireBucket(t *testing.T) {
+	pkv := newTestKV(t)
+	pkv.SetBucket(`cache`)
+	pkv.Set(`page`, []byte(`p`))
+	// written a day ago
+	if err := pkv.setUpdatedAt(`cache`, `page`, time.Now().Add(-24*time.Hour)); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if err := pkv.ExpireBucket(`cache`, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if err := pkv.Touch(`page`); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if ttl, err := pkv.TTL(`page`); err != nil || ttl <= 59*time.Minute || ttl > time.Hour {
+		t.Logf(`Expected Touch to keep the hour set by ExpireBucket, got %v (%v)`, ttl, err)
+		t.Fail()
+	}
+}
@@ -0,0 +1,94 @@
+package sqltplainkv
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// SetString stores a string by the key
+func (p *SQLtPlainKV) SetString(key, value string) error {
+	return p.Set(key, []byte(value))
+}
+
+// GetString retrieves a string using a key.
+// It returns ErrKeyNotFound if the key does not exist
+func (p *SQLtPlainKV) GetString(key string) (string, error) {
+	b, err := p.getTyped(key)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// SetInt64 stores an integer by the key
+func (p *SQLtPlainKV) SetInt64(key string, value int64) error {
+	return p.Set(key, []byte(strconv.FormatInt(value, 10)))
+}
+
+// GetInt64 retrieves an integer using a key.
+// It returns ErrKeyNotFound if the key does not exist
+func (p *SQLtPlainKV) GetInt64(key string) (int64, error) {
+	b, err := p.getTyped(key)
+	if err != nil {
+		return 0, err
+	}
+	v, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf(`value of %s is not an int64: %w`, key, err)
+	}
+	return v, nil
+}
+
+// SetBool stores a boolean by the key
+func (p *SQLtPlainKV) SetBool(key string, value bool) error {
+	return p.Set(key, []byte(strconv.FormatBool(value)))
+}
+
+// GetBool retrieves a boolean using a key.
+// It returns ErrKeyNotFound if the key does not exist
+func (p *SQLtPlainKV) GetBool(key string) (bool, error) {
+	b, err := p.getTyped(key)
+	if err != nil {
+		return false, err
+	}
+	v, err := strconv.ParseBool(string(b))
+	if err != nil {
+		return false, fmt.Errorf(`value of %s is not a bool: %w`, key, err)
+	}
+	return v, nil
+}
+
+// SetTime stores a time by the key in RFC 3339 format
+func (p *SQLtPlainKV) SetTime(key string, value time.Time) error {
+	return p.Set(key, []byte(value.Format(time.RFC3339Nano)))
+}
+
+// GetTime retrieves a time using a key.
+// It returns ErrKeyNotFound if the key does not exist
+func (p *SQLtPlainKV) GetTime(key string) (time.Time, error) {
+	b, err := p.getTyped(key)
+	if err != nil {
+		return time.Time{}, err
+	}
+	v, err := time.Parse(time.RFC3339Nano, string(b))
+	if err != nil {
+		return time.Time{}, fmt.Errorf(`value of %s is not a time: %w`, key, err)
+	}
+	return v, nil
+}
+
+func (p *SQLtPlainKV) getTyped(key string) ([]byte, error) {
+	b, err := p.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	// Get returns no value for a missing key, an empty value needs a
+	// lookup to tell the two apart
+	if len(b) == 0 {
+		if _, err = p.lookupMime(p.currBuckt, key); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
@@ -0,0 +1,53 @@
+package sqltplainkv
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTypedAccessors(t *testing.T) {
+	pkv := newTestKV(t)
+
+	now := time.Now()
+	pkv.SetString(`str`, `hello`)
+	pkv.SetInt64(`int`, -42)
+	pkv.SetBool(`bool`, true)
+	pkv.SetTime(`time`, now)
+
+	if s, err := pkv.GetString(`str`); err != nil || s != `hello` {
+		t.Logf(`Expected hello, got %s (%v)`, s, err)
+		t.Fail()
+	}
+	if i, err := pkv.GetInt64(`int`); err != nil || i != -42 {
+		t.Logf(`Expected -42, got %d (%v)`, i, err)
+		t.Fail()
+	}
+	if b, err := pkv.GetBool(`bool`); err != nil || !b {
+		t.Logf(`Expected true, got %v (%v)`, b, err)
+		t.Fail()
+	}
+	if tm, err := pkv.GetTime(`time`); err != nil || !tm.Equal(now) {
+		t.Logf(`Expected %s, got %s (%v)`, now, tm, err)
+		t.Fail()
+	}
+
+	if _, err := pkv.GetInt64(`str`); err == nil {
+		t.Logf(`Expected a parse error`)
+		t.Fail()
+	}
+	if _, err := pkv.GetBool(`missing`); !errors.Is(err, ErrKeyNotFound) {
+		t.Logf(`Expected ErrKeyNotFound, got %v`, err)
+		t.Fail()
+	}
+
+	pkv.SetString(`empty`, ``)
+	if s, err := pkv.GetString(`empty`); err != nil || s != `` {
+		t.Logf(`Expected an empty string, got %q (%v)`, s, err)
+		t.Fail()
+	}
+	if _, err := pkv.GetString(`missing`); !errors.Is(err, ErrKeyNotFound) {
+		t.Logf(`Expected ErrKeyNotFound, got %v`, err)
+		t.Fail()
+	}
+}
@@ -0,0 +1,57 @@
+package sqltplainkv
+
+// TypedBucket stores values of a single Go type in a bucket.
+// Values are converted by the serializer given on creation,
+// so every value in the bucket shares the same type and format.
+type TypedBucket[T any] struct {
+	p      *SQLtPlainKV
+	bucket string
+	ser    Serializer
+}
+
+// NewTypedBucket creates a TypedBucket over the named bucket.
+// If the serializer is nil, values are stored as JSON
+func NewTypedBucket[T any](p *SQLtPlainKV, bucket string, s Serializer) *TypedBucket[T] {
+	if bucket == "" {
+		bucket = "default"
+	}
+	if s == nil {
+		s = JSONSerializer
+	}
+	return &TypedBucket[T]{
+		p:      p,
+		bucket: bucket,
+		ser:    s,
+	}
+}
+
+// Name returns the name of the bucket
+func (b *TypedBucket[T]) Name() string {
+	return b.bucket
+}
+
+// Get retrieves a value using a key.
+// It returns ErrKeyNotFound if the key does not exist
+func (b *TypedBucket[T]) Get(key string) (T, error) {
+	var v T
+	if err := b.p.getObject(b.bucket, key, &v, b.ser); err != nil {
+		var zero T
+		return zero, err
+	}
+	return v, nil
+}
+
+// Set creates or updates the record by the value
+func (b *TypedBucket[T]) Set(key string, value T) error {
+	return b.p.setObject(b.bucket, key, value, b.ser)
+}
+
+// Del deletes a record with the provided key
+func (b *TypedBucket[T]) Del(key string) error {
+	return b.p.del(b.bucket, key)
+}
+
+// ListKeys lists all keys containing the current pattern
+func (b *TypedBucket[T]) ListKeys(pattern string) ([]string, error) {
+	return b.p.listKeys(b.bucket, pattern)
+}
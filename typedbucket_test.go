@@ -0,0 +1,51 @@
+package sqltplainkv
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTypedBucket(t *testing.T) {
+	pkv := newTestKV(t)
+
+	type user struct {
+		Name  string
+		Admin bool
+	}
+
+	for _, s := range []Serializer{JSONSerializer, GobSerializer} {
+		users := NewTypedBucket[user](pkv, `users`, s)
+
+		if err := users.Set(`alice`, user{Name: `Alice`, Admin: true}); err != nil {
+			t.Fatalf(`%s`, err)
+		}
+
+		u, err := users.Get(`alice`)
+		if err != nil {
+			t.Fatalf(`%s`, err)
+		}
+		if u.Name != `Alice` || !u.Admin {
+			t.Logf(`Unexpected value: %+v`, u)
+			t.Fail()
+		}
+
+		if mime, _ := pkv.getMime(`users`, `alice`); mime != s.Mime() {
+			t.Logf(`Expected %s, got %s`, s.Mime(), mime)
+			t.Fail()
+		}
+
+		if err = users.Del(`alice`); err != nil {
+			t.Fatalf(`%s`, err)
+		}
+		if _, err = users.Get(`alice`); !errors.Is(err, ErrKeyNotFound) {
+			t.Logf(`Expected ErrKeyNotFound, got %v`, err)
+			t.Fail()
+		}
+	}
+
+	// the current bucket is left untouched
+	if b, _ := pkv.Get(`alice`); len(b) != 0 {
+		t.Logf(`Expected no value in the default bucket, got %s`, b)
+		t.Fail()
+	}
+}
@@ -0,0 +1,209 @@
+package sqltplainkv
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	versionKey string = `_______#version-%d:%s:`
+)
+
+// Version describes a prior value of a key
+type Version struct {
+	N         int       // version number, starting at 1 for the oldest
+	Size      int64     // size of the value as returned by GetVersion
+	WrittenAt time.Time // time the value was written, zero if unknown
+	SavedAt   time.Time // time the value was replaced or deleted
+}
+
+// SetBucketVersioning turns versioning of a bucket on or off. Every
+// write to a key of a versioned bucket first saves the value it
+// replaces as a new version of the key, read back with GetVersion, and
+// deleting a key saves its value too. Versions are stored as hidden
+// keys of the bucket, encoded like other values, and are kept until
+// PruneVersions removes them, even when the key is deleted
+func (p *SQLtPlainKV) SetBucketVersioning(bucket string, on bool) error {
+	if bucket == "" {
+		bucket = "default"
+	}
+	opts, _, err := p.GetBucketOptions(bucket)
+	if err != nil {
+		return err
+	}
+	opts.Versioned = on
+	return p.SetBucketOptions(bucket, opts)
+}
+
+// versioned reports whether writes to the key save versions
+func (p *SQLtPlainKV) versioned(bucket, key string) bool {
+	opts, ok := p.bucketOpts[bucket]
+	return ok && opts.Versioned && !isInternalBucket(bucket) && !strings.HasPrefix(key, internalKeyPrefix)
+}
+
+// versionPrefix returns the start of the keys of the versions of a key
+func versionPrefix(key string) string {
+	return fmt.Sprintf(versionKey, len(key), key)
+}
+
+// versionKeyID returns the key of version n of a key. Version numbers
+// are padded so the versions of a key sort in order
+func versionKeyID(key string, n int) string {
+	return versionPrefix(key) + fmt.Sprintf(`%020d`, n)
+}
+
+// saveVersion saves the current value of a key of a versioned bucket
+// as its next version in the transaction of the write replacing it, if
+// the key exists. The stored value and its chunks are copied as they
+// are, keeping the time the value was written as the creation time
+func (p *SQLtPlainKV) saveVersion(tx *sql.Tx, bucket, key string) error {
+	if !p.versioned(bucket, key) {
+		return nil
+	}
+	var newest sql.NullString
+	prefix := versionPrefix(key)
+	err := p.on(tx).QueryRow(p.rebind(`
+	SELECT MAX(KeyID) FROM `+p.defTableName+`
+	WHERE Bucket=?
+		AND substr(KeyID, 1, ?)=?;`), bucket, len(prefix), prefix).Scan(&newest)
+	if err != nil {
+		return err
+	}
+	n := 1
+	if newest.Valid {
+		if n, err = strconv.Atoi(newest.String[len(prefix):]); err != nil {
+			return err
+		}
+		n++
+	}
+	t := p.defTableName
+	vk := versionKeyID(key, n)
+	now := time.Now().UnixNano()
+	res, err := p.on(tx).Exec(p.rebind(`
+	INSERT INTO `+t+` (Bucket, KeyID, Value, Checksum, Hash, Mime, CreatedAt, UpdatedAt)
+	SELECT Bucket, ?, Value, Checksum, Hash, Mime, UpdatedAt, ? FROM `+t+`
+	WHERE Bucket=?
+		AND KeyID=?
+		AND (ExpiresAt IS NULL OR ExpiresAt > ?);`), vk, now, bucket, key, now)
+	if err != nil {
+		return err
+	}
+	if copied, err := res.RowsAffected(); err != nil || copied == 0 {
+		return err
+	}
+	// chunks keep their index and take the prefix of the version
+	first, last, length := chunkRange(bucket, key, 0)
+	cols := strings.Join(recordColumns[2:], `, `)
+	_, err = p.on(tx).Exec(p.rebind(`
+	INSERT INTO `+t+` (Bucket, KeyID, `+cols+`)
+	SELECT Bucket, ? || substr(KeyID, ?), `+cols+` FROM `+t+`
+	WHERE Bucket=?
+		AND KeyID BETWEEN ? AND ?
+		AND length(KeyID) = ?;`), chunkPrefix(bucket, vk), utf8.RuneCountInString(chunkPrefix(bucket, key))+1,
+		chunkBuckt, first, last, length)
+	return err
+}
+
+// versions lists the versions of a key, oldest first
+func (p *SQLtPlainKV) versions(bucket, key string) ([]Version, error) {
+	prefix := versionPrefix(key)
+	rows, err := p.conn().Query(p.rebind(`
+	SELECT KeyID, Value, CreatedAt, UpdatedAt FROM `+p.defTableName+`
+	WHERE Bucket=?
+		AND substr(KeyID, 1, ?)=?
+	ORDER BY KeyID;`), bucket, len(prefix), prefix)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	versions := make([]Version, 0)
+	for rows.Next() {
+		var (
+			v   Version
+			vk  string
+			val []byte
+			crt sql.NullInt64
+			upd sql.NullInt64
+		)
+		if err = rows.Scan(&vk, &val, &crt, &upd); err != nil {
+			return versions, err
+		}
+		if v.N, err = strconv.Atoi(vk[len(prefix):]); err != nil {
+			continue
+		}
+		if v.Size, err = p.valueSize(bucket, vk, val); err != nil {
+			return versions, err
+		}
+		v.WrittenAt = nanoTime(crt)
+		v.SavedAt = nanoTime(upd)
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+// ListVersions lists the versions of a key in the current bucket,
+// oldest first
+func (p *SQLtPlainKV) ListVersions(key string) ([]Version, error) {
+	if p.currBuckt == "" {
+		p.currBuckt = "default"
+	}
+	if err := p.open(); err != nil {
+		return make([]Version, 0), err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	return p.versions(p.currBuckt, key)
+}
+
+// GetVersion returns version n of a key in the current bucket.
+// It returns ErrKeyNotFound if there is no such version
+func (p *SQLtPlainKV) GetVersion(key string, n int) ([]byte, error) {
+	if p.currBuckt == "" {
+		p.currBuckt = "default"
+	}
+	val, err := p.get(p.currBuckt, versionKeyID(key, n))
+	if err != nil {
+		return val, err
+	}
+	if len(val) == 0 {
+		return val, ErrKeyNotFound
+	}
+	return val, nil
+}
+
+// PruneVersions removes the versions of a key in the current bucket
+// but the keep most recent ones and returns the number removed. A keep
+// of zero removes all of them
+func (p *SQLtPlainKV) PruneVersions(key string, keep int) (int, error) {
+	var err error
+	if p.currBuckt == "" {
+		p.currBuckt = "default"
+	}
+	if keep < 0 {
+		keep = 0
+	}
+	if err = p.open(); err != nil {
+		return 0, err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	versions, err := p.versions(p.currBuckt, key)
+	if err != nil || len(versions) <= keep {
+		return 0, err
+	}
+	old := versions[:len(versions)-keep]
+	keys := make([]string, len(old))
+	for i, v := range old {
+		keys[i] = versionKeyID(key, v.N)
+	}
+	if err = p.delKeys(p.currBuckt, keys); err != nil {
+		return 0, err
+	}
+	return len(keys), nil
+}
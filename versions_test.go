@@ -0,0 +1,102 @@
+package sqltplainkv
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestVersions(t *testing.T) {
+	pkv := newTestKV(t)
+	if err := pkv.SetBucketVersioning(`config`, true); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	pkv.SetBucket(`config`)
+	for _, v := range []string{`v1`, `v2`, `v3`} {
+		if err := pkv.Set(`app`, []byte(v)); err != nil {
+			t.Fatalf(`%v`, err)
+		}
+	}
+	pkv.Set(`app:extra`, []byte(`other key`))
+
+	versions, err := pkv.ListVersions(`app`)
+	if err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if len(versions) != 2 || versions[0].N != 1 || versions[1].N != 2 || versions[0].Size != 2 || versions[0].SavedAt.IsZero() {
+		t.Logf(`Expected versions 1 and 2, got %+v`, versions)
+		t.Fail()
+	}
+	for n, want := range map[int]string{1: `v1`, 2: `v2`} {
+		if v, err := pkv.GetVersion(`app`, n); err != nil || string(v) != want {
+			t.Logf(`Expected version %d to be %s, got %q (%v)`, n, want, v, err)
+			t.Fail()
+		}
+	}
+	if _, err = pkv.GetVersion(`app`, 3); !errors.Is(err, ErrKeyNotFound) {
+		t.Logf(`Expected ErrKeyNotFound, got %v`, err)
+		t.Fail()
+	}
+	if v, _ := pkv.Get(`app`); string(v) != `v3` {
+		t.Logf(`Expected the current value, got %q`, v)
+		t.Fail()
+	}
+
+	// deleting saves the value, and versions outlive the key until pruned
+	pkv.Del(`app`)
+	pkv.Set(`app`, []byte(`v4`))
+	if n, err := pkv.PruneVersions(`app`, 1); n != 2 || err != nil {
+		t.Logf(`Expected 2 versions pruned, got %d (%v)`, n, err)
+		t.Fail()
+	}
+	if versions, _ = pkv.ListVersions(`app`); len(versions) != 1 || versions[0].N != 3 {
+		t.Logf(`Expected version 3 to be kept, got %+v`, versions)
+		t.Fail()
+	}
+	if v, _ := pkv.GetVersion(`app`, 3); string(v) != `v3` {
+		t.Logf(`Expected the deleted value, got %q`, v)
+		t.Fail()
+	}
+	pkv.Set(`app`, []byte(`v5`))
+	if v, _ := pkv.GetVersion(`app`, 4); string(v) != `v4` {
+		t.Logf(`Expected version numbers to go on, got %q`, v)
+		t.Fail()
+	}
+
+	pkv.SetBucket(`default`)
+	pkv.Set(`app`, []byte(`a`))
+	pkv.Set(`app`, []byte(`b`))
+	if versions, _ = pkv.ListVersions(`app`); len(versions) != 0 {
+		t.Logf(`Expected no versions in other buckets, got %+v`, versions)
+		t.Fail()
+	}
+}
+
+func TestVersionsOfChunkedAndFilteredKeys(t *testing.T) {
+	pkv := newTestKV(t)
+	pkv.SetLimits(Limits{ChunkSize: 16})
+	if err := pkv.SetBucketVersioning(`config`, true); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	pkv.SetBucket(`config`)
+	large := strings.Repeat(`0123456789`, 10)
+	if err := pkv.Set(`app`, []byte(large)); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if err := pkv.Set(`app`, []byte(`small`)); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	if v, err := pkv.GetVersion(`app`, 1); err != nil || string(v) != large {
+		t.Logf(`Expected the chunked value as version 1, got %q (%v)`, v, err)
+		t.Fail()
+	}
+
+	// deleting by filter saves the values too
+	if n, err := pkv.DelWhere(`key == "app"`); n != 1 || err != nil {
+		t.Fatalf(`Expected 1 key deleted, got %d (%v)`, n, err)
+	}
+	if v, err := pkv.GetVersion(`app`, 2); err != nil || string(v) != `small` {
+		t.Logf(`Expected the deleted value as version 2, got %q (%v)`, v, err)
+		t.Fail()
+	}
+}
@@ -0,0 +1,210 @@
+package sqltplainkv
+
+import (
+	"database/sql"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// warmCache holds records loaded from a warm cache file
+type warmCache struct {
+	mu      sync.RWMutex
+	entries map[string]warmEntry
+}
+
+type warmEntry struct {
+	value   []byte
+	mime    string
+	expires int64 // Unix nanoseconds, zero if it does not expire
+}
+
+// WarmCache writes the records of the current bucket whose keys start
+// with any of the prefixes, along with their mime, to a separate SQLite
+// file at path. Load it with LoadWarmCache on startup to serve these
+// records from memory without touching the main database.
+// Without prefixes the whole bucket is written. Files written before
+// mimes were stored with the records must be written again
+func (p *SQLtPlainKV) WarmCache(path string, prefixes ...string) error {
+	var err error
+	if err = p.open(); err != nil {
+		return err
+	}
+	if p.autoClose {
+		defer p.closeWhenIdle()
+	}
+	if p.currBuckt == "" {
+		p.currBuckt = "default"
+	}
+	if len(prefixes) == 0 {
+		prefixes = []string{""}
+	}
+
+	tmp := path + ".tmp"
+	os.Remove(tmp)
+	cdb, err := sql.Open(driverName, tmp)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		cdb.Close()
+		os.Remove(tmp)
+	}()
+	if _, err = cdb.Exec(p.tableSchema(p.defTableName)); err != nil {
+		return err
+	}
+	wtx, err := cdb.Begin()
+	if err != nil {
+		return err
+	}
+	defer wtx.Rollback()
+	ins, err := wtx.Prepare(`
+	INSERT OR REPLACE INTO ` + p.defTableName + ` (Bucket, KeyID, Value, ExpiresAt, Mime)
+	VALUES (?, ?, ?, ?, ?);`)
+	if err != nil {
+		return err
+	}
+	defer ins.Close()
+
+	sqlstr := p.rebind(`
+	SELECT Bucket, KeyID, Value, ExpiresAt, Mime FROM ` + p.defTableName + `
+	WHERE Bucket=?
+		AND KeyID LIKE ?
+		AND (ExpiresAt IS NULL OR ExpiresAt > ?);`)
+	now := time.Now().UnixNano()
+	for _, prefix := range prefixes {
+		rows, err := p.conn().Query(sqlstr, p.currBuckt, prefix+"%", now)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var (
+				bucket, key string
+				value       []byte
+				exp         sql.NullInt64
+				mime        sql.NullString
+			)
+			if err = rows.Scan(&bucket, &key, &value, &exp, &mime); err != nil {
+				rows.Close()
+				return err
+			}
+			if _, err = ins.Exec(bucket, key, value, exp, mime); err != nil {
+				rows.Close()
+				return err
+			}
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return err
+		}
+	}
+	if err = wtx.Commit(); err != nil {
+		return err
+	}
+	if err = cdb.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// LoadWarmCache loads a file written by WarmCache into memory.
+// Reads of the records it contains are served from memory until they
+// are written or deleted through this instance. Changes made by other
+// processes are not seen, so it suits read-mostly data.
+func (p *SQLtPlainKV) LoadWarmCache(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+	cdb, err := sql.Open(driverName, path)
+	if err != nil {
+		return err
+	}
+	defer cdb.Close()
+	rows, err := cdb.Query(`SELECT Bucket, KeyID, Value, ExpiresAt, Mime FROM ` + p.defTableName + `;`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	wc := &warmCache{
+		entries: make(map[string]warmEntry),
+	}
+	for rows.Next() {
+		var (
+			bucket, key string
+			we          warmEntry
+			exp         sql.NullInt64
+			mime        sql.NullString
+		)
+		if err = rows.Scan(&bucket, &key, &we.value, &exp, &mime); err != nil {
+			return err
+		}
+		we.expires = exp.Int64
+		we.mime = mime.String
+		wc.entries[bucket+"\x00"+key] = we
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	p.warm = wc
+	return nil
+}
+
+// DropWarmCache releases the records loaded by LoadWarmCache
+func (p *SQLtPlainKV) DropWarmCache() {
+	p.warm = nil
+}
+
+func (p *SQLtPlainKV) warmGet(bucket, key string) ([]byte, bool) {
+	we, ok := p.warmLookup(bucket, key)
+	if !ok {
+		return nil, false
+	}
+	return append([]byte(nil), we.value...), true
+}
+
+// warmLookup returns the entry of a key loaded from a warm cache
+// file, if there is one that has not expired
+func (p *SQLtPlainKV) warmLookup(bucket, key string) (warmEntry, bool) {
+	wc := p.warm
+	if wc == nil {
+		return warmEntry{}, false
+	}
+	wc.mu.RLock()
+	we, ok := wc.entries[bucket+"\x00"+key]
+	wc.mu.RUnlock()
+	if !ok {
+		return warmEntry{}, false
+	}
+	if we.expires != 0 && we.expires <= time.Now().UnixNano() {
+		p.warmDel(bucket, key)
+		return warmEntry{}, false
+	}
+	return we, true
+}
+
+func (p *SQLtPlainKV) warmDel(bucket, key string) {
+	wc := p.warm
+	if wc == nil {
+		return
+	}
+	wc.mu.Lock()
+	delete(wc.entries, bucket+"\x00"+key)
+	wc.mu.Unlock()
+}
+
+// warmDelBucket drops the entries of every key of a bucket
+func (p *SQLtPlainKV) warmDelBucket(bucket string) {
+	wc := p.warm
+	if wc == nil {
+		return
+	}
+	wc.mu.Lock()
+	for k := range wc.entries {
+		if strings.HasPrefix(k, bucket+"\x00") {
+			delete(wc.entries, k)
+		}
+	}
+	wc.mu.Unlock()
+}
@@ -0,0 +1,45 @@
+package sqltplainkv
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestWarmCache(t *testing.T) {
+	pkv := newTestKV(t)
+	path := filepath.Join(t.TempDir(), "warm.dat")
+
+	pkv.Set(`page/index`, []byte(`<h1>Home</h1>`))
+	pkv.SetMime(`page/index`, `text/html`)
+	pkv.Set(`other/key`, []byte(`not cached`))
+
+	if err := pkv.WarmCache(path, `page/`); err != nil {
+		t.Fatalf(`%s`, err)
+	}
+
+	// a fresh instance serves the cached records without the main database
+	cold := NewSQLtPlainKV(filepath.Join(t.TempDir(), "empty.dat"), false)
+	defer cold.Close()
+	if err := cold.LoadWarmCache(path); err != nil {
+		t.Fatalf(`%s`, err)
+	}
+	if b, _ := cold.Get(`page/index`); string(b) != `<h1>Home</h1>` {
+		t.Logf(`Expected cached page, got %s`, b)
+		t.Fail()
+	}
+	if mime, _ := cold.GetMime(`page/index`); mime != `text/html` {
+		t.Logf(`Expected cached mime, got %s`, mime)
+		t.Fail()
+	}
+	if b, _ := cold.Get(`other/key`); len(b) != 0 {
+		t.Logf(`Expected uncached key to be missing, got %s`, b)
+		t.Fail()
+	}
+
+	// writes through the instance replace the cached record
+	cold.Set(`page/index`, []byte(`<h1>New</h1>`))
+	if b, _ := cold.Get(`page/index`); string(b) != `<h1>New</h1>` {
+		t.Logf(`Expected updated page, got %s`, b)
+		t.Fail()
+	}
+}
@@ -0,0 +1,192 @@
+package sqltplainkv
+
+import (
+	"database/sql"
+	"strings"
+	"sync"
+)
+
+// ChangeType is the kind of change reported to watchers
+type ChangeType int
+
+const (
+	ChangeCreate ChangeType = iota // a new key was stored
+	ChangeUpdate                   // an existing key was replaced
+	ChangeDelete                   // a key was deleted
+)
+
+func (c ChangeType) String() string {
+	switch c {
+	case ChangeCreate:
+		return `create`
+	case ChangeUpdate:
+		return `update`
+	case ChangeDelete:
+		return `delete`
+	}
+	return ``
+}
+
+// parseChangeType returns the change type of its String form,
+// ChangeUpdate if it is not known
+func parseChangeType(s string) ChangeType {
+	switch s {
+	case `create`:
+		return ChangeCreate
+	case `delete`:
+		return ChangeDelete
+	}
+	return ChangeUpdate
+}
+
+// ChangeEvent describes a change of a key
+type ChangeEvent struct {
+	Seq    int64 // the change log sequence, zero for Watch
+	Type   ChangeType
+	Bucket string
+	Key    string
+	Value  []byte // the stored value, nil for deletions and streamed values
+}
+
+// CancelFunc stops a watch and closes its channel
+type CancelFunc func()
+
+// watchBuffer is the number of events a watcher holds before
+// further events are dropped
+const watchBuffer int = 64
+
+// watcher receives the changes of the keys matching its pattern
+type watcher struct {
+	bucket  string
+	pattern string
+	ch      chan ChangeEvent
+}
+
+// watchers holds the watchers of a store
+type watchers struct {
+	mu   sync.Mutex
+	next int
+	list map[int]*watcher
+}
+
+// Watch reports the changes of the keys of the current bucket
+// starting with pattern, which may hold LIKE wildcards as in
+// ListKeys. Only changes made through this store are reported.
+// Changes made inside a transaction are reported as they are made,
+// whether or not the transaction commits. Events are dropped when
+// the receiver falls behind by more than 64 events, use WatchFrom to
+// miss none and resume after a disconnect. Call the returned function
+// to stop watching
+func (p *SQLtPlainKV) Watch(pattern string) (<-chan ChangeEvent, CancelFunc) {
+	if p.currBuckt == "" {
+		p.currBuckt = "default"
+	}
+	w := &watcher{
+		bucket:  p.currBuckt,
+		pattern: pattern + "%",
+		ch:      make(chan ChangeEvent, watchBuffer),
+	}
+	p.watch.mu.Lock()
+	defer p.watch.mu.Unlock()
+	if p.watch.list == nil {
+		p.watch.list = make(map[int]*watcher)
+	}
+	id := p.watch.next
+	p.watch.next++
+	p.watch.list[id] = w
+	return w.ch, func() {
+		p.watch.mu.Lock()
+		defer p.watch.mu.Unlock()
+		if _, ok := p.watch.list[id]; ok {
+			delete(p.watch.list, id)
+			close(w.ch)
+		}
+	}
+}
+
+// watched reports whether any watcher matches the key
+func (p *SQLtPlainKV) watched(bucket, key string) bool {
+	if isInternalBucket(bucket) {
+		return false
+	}
+	p.watch.mu.Lock()
+	defer p.watch.mu.Unlock()
+	for _, w := range p.watch.list {
+		if w.bucket == bucket && like(key, w.pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// changeType returns whether storing the key in the transaction of the
+// write creates or updates it. The key is only looked up when it is
+// watched or audited
+func (p *SQLtPlainKV) changeType(tx *sql.Tx, bucket, key string) (ChangeType, error) {
+	if !p.watched(bucket, key) && !p.audited(bucket, key) {
+		return ChangeUpdate, nil
+	}
+	found, err := p.exists(tx, bucket, key)
+	if err != nil || found {
+		return ChangeUpdate, err
+	}
+	return ChangeCreate, nil
+}
+
+// notify sends a change to the watchers matching the key
+func (p *SQLtPlainKV) notify(typ ChangeType, bucket, key string, value []byte) {
+	if isInternalBucket(bucket) {
+		return
+	}
+	p.watch.mu.Lock()
+	defer p.watch.mu.Unlock()
+	if len(p.watch.list) == 0 {
+		return
+	}
+	if value != nil {
+		value = append([]byte(nil), value...)
+	}
+	ev := ChangeEvent{
+		Type:   typ,
+		Bucket: bucket,
+		Key:    key,
+		Value:  value,
+	}
+	for _, w := range p.watch.list {
+		if w.bucket != bucket || !like(key, w.pattern) {
+			continue
+		}
+		select {
+		case w.ch <- ev:
+		default:
+		}
+	}
+}
+
+// like reports whether s matches the SQL LIKE pattern,
+// ignoring case as SQLite does
+func like(s, pattern string) bool {
+	s, pattern = strings.ToLower(s), strings.ToLower(pattern)
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '%':
+			for i := 0; i <= len(s); i++ {
+				if like(s[i:], pattern[1:]) {
+					return true
+				}
+			}
+			return false
+		case '_':
+			if len(s) == 0 {
+				return false
+			}
+			s, pattern = s[1:], pattern[1:]
+		default:
+			if len(s) == 0 || s[0] != pattern[0] {
+				return false
+			}
+			s, pattern = s[1:], pattern[1:]
+		}
+	}
+	return len(s) == 0
+}
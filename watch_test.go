@@ -0,0 +1,63 @@
+package sqltplainkv
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestWatch(t *testing.T) {
+	pkv := newTestKV(t)
+	pkv.Set(`config:a`, []byte(`old`))
+	ch, cancel := pkv.Watch(`config:`)
+
+	pkv.Set(`config:a`, []byte(`1`))
+	pkv.Set(`config:b`, []byte(`2`))
+	pkv.Set(`other`, []byte(`3`))
+	pkv.SetMime(`config:a`, `text/plain`)
+	pkv.Del(`config:b`)
+	pkv.SetBucket(`elsewhere`)
+	pkv.Set(`config:a`, []byte(`4`))
+
+	want := []string{`update config:a=1`, `create config:b=2`, `delete config:b=`}
+	for _, w := range want {
+		select {
+		case ev := <-ch:
+			if got := fmt.Sprintf(`%s %s=%s`, ev.Type, ev.Key, ev.Value); got != w || ev.Bucket != `default` {
+				t.Logf(`Expected %q, got %q in %s`, w, got, ev.Bucket)
+				t.Fail()
+			}
+		default:
+			t.Fatalf(`Expected %q, got nothing`, w)
+		}
+	}
+	select {
+	case ev := <-ch:
+		t.Logf(`Unexpected event %+v`, ev)
+		t.Fail()
+	default:
+	}
+
+	cancel()
+	cancel()
+	if _, ok := <-ch; ok {
+		t.Logf(`Expected the channel to be closed`)
+		t.Fail()
+	}
+	pkv.SetBucket(`default`)
+	pkv.Set(`config:a`, []byte(`5`))
+}
+
+func TestWatchDropsWhenFull(t *testing.T) {
+	pkv := newTestKV(t)
+	ch, cancel := pkv.Watch(`k`)
+	defer cancel()
+	pkv.Begin()
+	for i := 0; i < watchBuffer+10; i++ {
+		pkv.Set(fmt.Sprintf(`k%d`, i), []byte(`x`))
+	}
+	pkv.Commit()
+	if len(ch) != watchBuffer {
+		t.Logf(`Expected %d buffered events, got %d`, watchBuffer, len(ch))
+		t.Fail()
+	}
+}
@@ -0,0 +1,244 @@
+// Package webhook posts the changes of a SQLtPlainKV store to a URL
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	sqltplainkv "github.com/narsilworks/sqlt-plainkv"
+)
+
+var (
+	ErrNoURL  error = errors.New(`webhook url not set`)
+	ErrClosed error = errors.New(`notifier closed`)
+)
+
+// Event is a change of a key as posted to the webhook
+type Event struct {
+	Type   string    `json:"type"` // set or delete
+	Bucket string    `json:"bucket"`
+	Key    string    `json:"key"`
+	At     time.Time `json:"at"`
+}
+
+// payload is the body of a request to the webhook
+type payload struct {
+	Events []Event `json:"events"`
+}
+
+// Options controls where and how changes are posted
+type Options struct {
+	// URL receives the batches of events as a JSON POST
+	URL string
+	// Buckets are the buckets whose changes are posted, all buckets
+	// when empty
+	Buckets []string
+	// Header is added to every request, for example to authenticate
+	Header http.Header
+	// Client sends the requests. Defaults to a client with a
+	// 10 second timeout
+	Client *http.Client
+	// BatchSize is the most events posted in one request. Defaults to 100
+	BatchSize int
+	// FlushInterval is how long events wait for a batch to fill.
+	// Defaults to one second
+	FlushInterval time.Duration
+	// MaxAttempts is the number of tries of a request, including the
+	// first one. Defaults to 5
+	MaxAttempts int
+	// Backoff is the wait before the first retry, doubled after every
+	// attempt. Defaults to 500 milliseconds
+	Backoff time.Duration
+	// QueueSize is the number of events held while requests are
+	// being sent. Events are dropped when the queue is full.
+	// Defaults to 10000
+	QueueSize int
+}
+
+// Notifier posts the changes of a store to a webhook
+type Notifier struct {
+	opts    Options
+	buckets map[string]bool
+	queue   chan Event
+	done    chan struct{}
+	wg      sync.WaitGroup
+	mu      sync.RWMutex
+	closed  bool
+	dropped int64
+}
+
+// New starts posting the changes of the store to the URL of the
+// options. Changes are posted once they are made, in batches, by a
+// background goroutine. Requests failing with a network error or a
+// 429 or 5xx status are retried with backoff, a batch is dropped once
+// its attempts run out. Close the notifier to post the pending events
+// and stop
+func New(kv *sqltplainkv.SQLtPlainKV, opts Options) (*Notifier, error) {
+	if opts.URL == "" {
+		return nil, ErrNoURL
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = time.Second
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 5
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = 500 * time.Millisecond
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 10000
+	}
+	n := &Notifier{
+		opts:  opts,
+		queue: make(chan Event, opts.QueueSize),
+		done:  make(chan struct{}),
+	}
+	if len(opts.Buckets) > 0 {
+		n.buckets = make(map[string]bool)
+		for _, b := range opts.Buckets {
+			n.buckets[b] = true
+		}
+	}
+	kv.AddHooks(sqltplainkv.Hooks{
+		AfterSet: func(bucket, key string, value []byte) {
+			n.add(`set`, bucket, key)
+		},
+		AfterDelete: func(bucket, key string) {
+			n.add(`delete`, bucket, key)
+		},
+	})
+	n.wg.Add(1)
+	go n.run()
+	return n, nil
+}
+
+// Dropped returns the number of events dropped because the queue
+// was full or their batch could not be posted
+func (n *Notifier) Dropped() int64 {
+	return atomic.LoadInt64(&n.dropped)
+}
+
+// Close posts the pending events, retrying as usual, and stops the
+// notifier. Changes made after Close are not posted
+func (n *Notifier) Close() error {
+	n.mu.Lock()
+	if n.closed {
+		n.mu.Unlock()
+		return ErrClosed
+	}
+	n.closed = true
+	close(n.done)
+	n.mu.Unlock()
+	n.wg.Wait()
+	return nil
+}
+
+// add queues the change of a key if its bucket is selected
+func (n *Notifier) add(typ, bucket, key string) {
+	if n.buckets != nil && !n.buckets[bucket] {
+		return
+	}
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	if n.closed {
+		return
+	}
+	select {
+	case n.queue <- Event{Type: typ, Bucket: bucket, Key: key, At: time.Now().UTC()}:
+	default:
+		atomic.AddInt64(&n.dropped, 1)
+	}
+}
+
+// run collects the queued events into batches and posts them
+func (n *Notifier) run() {
+	defer n.wg.Done()
+	ticker := time.NewTicker(n.opts.FlushInterval)
+	defer ticker.Stop()
+	batch := make([]Event, 0, n.opts.BatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := n.post(batch); err != nil {
+			atomic.AddInt64(&n.dropped, int64(len(batch)))
+		}
+		batch = batch[:0]
+	}
+	for {
+		select {
+		case ev := <-n.queue:
+			if batch = append(batch, ev); len(batch) >= n.opts.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-n.done:
+			for {
+				select {
+				case ev := <-n.queue:
+					if batch = append(batch, ev); len(batch) >= n.opts.BatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// post sends a batch, retrying failures that may be temporary
+func (n *Notifier) post(batch []Event) error {
+	body, err := json.Marshal(payload{Events: batch})
+	if err != nil {
+		return err
+	}
+	backoff := n.opts.Backoff
+	for attempt := 1; ; attempt++ {
+		retry, err := n.send(body)
+		if err == nil || !retry || attempt >= n.opts.MaxAttempts {
+			return err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// send makes one request and reports whether a failure may be retried
+func (n *Notifier) send(body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, n.opts.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	for k, v := range n.opts.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set(`Content-Type`, `application/json`)
+	resp, err := n.opts.Client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode < 300 {
+		return false, nil
+	}
+	err = fmt.Errorf(`webhook: %s`, resp.Status)
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500, err
+}
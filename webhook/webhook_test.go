@@ -0,0 +1,121 @@
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/narsilworks/sqlt-plainkv/sqltplainkvtest"
+)
+
+// recorder is a webhook failing the first requests it receives
+type recorder struct {
+	mu       sync.Mutex
+	failures int
+	requests int
+	events   []string
+}
+
+func (r *recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests++
+	if r.failures > 0 {
+		r.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	if req.Header.Get(`Authorization`) != `Bearer token` {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var p payload
+	if err := json.NewDecoder(req.Body).Decode(&p); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	for _, ev := range p.Events {
+		r.events = append(r.events, ev.Type+` `+ev.Bucket+`/`+ev.Key)
+	}
+}
+
+func TestNotifier(t *testing.T) {
+	rec := &recorder{failures: 2}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	kv := sqltplainkvtest.NewTempKV(t)
+	n, err := New(kv, Options{
+		URL:           srv.URL,
+		Buckets:       []string{`default`},
+		Header:        http.Header{`Authorization`: {`Bearer token`}},
+		BatchSize:     2,
+		FlushInterval: time.Hour,
+		Backoff:       time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	kv.Set(`a`, []byte(`1`))
+	kv.Set(`b`, []byte(`2`))
+	kv.Del(`a`)
+	kv.SetBucket(`other`)
+	kv.Set(`c`, []byte(`3`))
+	if err = n.Close(); err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	kv.SetBucket(`default`)
+	kv.Set(`after`, []byte(`4`))
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	want := `set default/a,set default/b,delete default/a`
+	if got := strings.Join(rec.events, `,`); got != want {
+		t.Logf(`Expected %s, got %s`, want, got)
+		t.Fail()
+	}
+	if rec.requests != 4 || n.Dropped() != 0 {
+		t.Logf(`Expected 2 failed and 2 posted requests, got %d requests and %d dropped`, rec.requests, n.Dropped())
+		t.Fail()
+	}
+	if err = n.Close(); err != ErrClosed {
+		t.Logf(`Expected ErrClosed, got %v`, err)
+		t.Fail()
+	}
+}
+
+func TestNotifierGivesUp(t *testing.T) {
+	rec := &recorder{failures: 100}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	kv := sqltplainkvtest.NewTempKV(t)
+	n, err := New(kv, Options{
+		URL:           srv.URL,
+		FlushInterval: time.Millisecond,
+		MaxAttempts:   3,
+		Backoff:       time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf(`%v`, err)
+	}
+	kv.Set(`a`, []byte(`1`))
+	for i := 0; i < 100 && n.Dropped() == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	n.Close()
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if n.Dropped() != 1 || rec.requests != 3 {
+		t.Logf(`Expected the event dropped after 3 attempts, got %d dropped and %d requests`, n.Dropped(), rec.requests)
+		t.Fail()
+	}
+	if _, err = New(kv, Options{}); err != ErrNoURL {
+		t.Logf(`Expected ErrNoURL, got %v`, err)
+		t.Fail()
+	}
+}